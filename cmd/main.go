@@ -7,16 +7,21 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/elyarsadig/studybud-go/configs"
 	"github.com/elyarsadig/studybud-go/internal/application"
+	"github.com/elyarsadig/studybud-go/internal/domain"
 	"github.com/elyarsadig/studybud-go/migrations"
 	confighandler "github.com/elyarsadig/studybud-go/pkg/configHandler"
 	"github.com/elyarsadig/studybud-go/pkg/encryption"
 	"github.com/elyarsadig/studybud-go/pkg/errorHandler"
 	"github.com/elyarsadig/studybud-go/pkg/logger"
+	"github.com/elyarsadig/studybud-go/pkg/mailer"
+	"github.com/elyarsadig/studybud-go/pkg/oidc"
 	redispkg "github.com/elyarsadig/studybud-go/pkg/redis"
+	"github.com/elyarsadig/studybud-go/pkg/storage"
 	"github.com/elyarsadig/studybud-go/pkg/unmarshaller"
 	"github.com/elyarsadig/studybud-go/transport"
 	"github.com/joho/godotenv"
@@ -29,6 +34,7 @@ func main() {
 	configFile := flag.String("c", "", "Path to config file")
 	migrate := flag.Bool("migrate", false, "Run DB migrations")
 	seed := flag.Bool("seed", false, "seed DB")
+	superuser := flag.String("superuser", "", "Grant staff and superuser status to the account with this email")
 	flag.Parse()
 
 	if *configFile == "" {
@@ -84,9 +90,48 @@ func main() {
 		log.Println("Successfully seeded the database")
 	}
 
+	if *superuser != "" {
+		err := migrations.PromoteSuperuser(db, *superuser)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("%s is now a superuser", *superuser)
+	}
+
 	router := transport.NewHTTPServer(cfg.HttpAddress, logger)
 
-	aes, err := encryption.NewAES[string]([]byte(os.Getenv("SESSION_PRIVATE_KEY")))
+	privateKey := []byte(os.Getenv("SESSION_PRIVATE_KEY"))
+	aes, err := encryption.NewAES[string](privateKey)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Email tokens and invite links get keys of their own, so neither can be
+	// passed off as the other or as a session cookie.
+	emailKey, err := encryption.DeriveKey(privateKey, "email tokens")
+	if err != nil {
+		log.Fatal(err)
+	}
+	emailTokens, err := encryption.NewAES[domain.EmailVerificationClaims](emailKey)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	inviteKey, err := encryption.DeriveKey(privateKey, "room invites")
+	if err != nil {
+		log.Fatal(err)
+	}
+	roomInvites, err := encryption.NewAES[domain.RoomInviteClaims](inviteKey)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	mail, err := initMailer(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	files, err := initStorage(ctx, cfg)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -101,6 +146,11 @@ func main() {
 		logger,
 		serviceInfo,
 		aes,
+		emailTokens,
+		roomInvites,
+		mail,
+		files,
+		initOIDCProviders(cfg),
 		time.Minute*time.Duration(cfg.ExtraData.SessionExpireDuration),
 	)
 
@@ -168,6 +218,58 @@ func initRedis(cfg *confighandler.Config[configs.ExtraData]) *redispkg.Redis {
 	return &redistClient
 }
 
+func initMailer(cfg *confighandler.Config[configs.ExtraData]) (mailer.Mailer, error) {
+	mail := cfg.ExtraData.Mail
+	switch mail.Driver {
+	case "smtp":
+		return mailer.NewSMTP(mailer.SMTPConfig{
+			Host:     mail.Host,
+			Port:     mail.Port,
+			Username: mail.Username,
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     mail.From,
+		}), nil
+	case "file":
+		return mailer.NewFile(mail.From, mail.Path)
+	default:
+		return mailer.NewWriter(mail.From, os.Stdout), nil
+	}
+}
+
+func initStorage(ctx context.Context, cfg *confighandler.Config[configs.ExtraData]) (storage.Storage, error) {
+	store := cfg.ExtraData.Attachments.Storage
+	switch store.Driver {
+	case "s3":
+		return storage.NewS3(ctx, storage.S3Config{
+			Endpoint:        store.Endpoint,
+			Region:          store.Region,
+			Bucket:          store.Bucket,
+			AccessKeyID:     store.AccessKeyID,
+			SecretAccessKey: os.Getenv("S3_SECRET_ACCESS_KEY"),
+			UseSSL:          store.UseSSL,
+		})
+	default:
+		return storage.NewLocal(store.Path)
+	}
+}
+
+func initOIDCProviders(cfg *confighandler.Config[configs.ExtraData]) []*oidc.Provider {
+	publicURL := strings.TrimSuffix(cfg.ExtraData.PublicURL, "/")
+	providers := make([]*oidc.Provider, 0, len(cfg.ExtraData.OIDCProviders))
+	for _, provider := range cfg.ExtraData.OIDCProviders {
+		providers = append(providers, oidc.New(oidc.Config{
+			Name:         provider.Name,
+			DisplayName:  provider.DisplayName,
+			Issuer:       provider.Issuer,
+			ClientID:     provider.ClientID,
+			ClientSecret: os.Getenv("OIDC_" + strings.ToUpper(provider.Name) + "_CLIENT_SECRET"),
+			RedirectURL:  publicURL + "/login/oidc/" + provider.Name + "/callback",
+			Scopes:       provider.Scopes,
+		}))
+	}
+	return providers
+}
+
 func initLogging(cfg *confighandler.Config[configs.ExtraData]) (logger.Logger, error) {
 	logger, err := logger.New(logger.JSON, logger.DebugLevel)
 	if err != nil {
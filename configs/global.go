@@ -6,7 +6,10 @@ import (
 
 type CtxKey string
 
-const UserCtxKey CtxKey = "user"
+const (
+	UserCtxKey   CtxKey = "user"
+	ClientCtxKey CtxKey = "client"
+)
 
 //go:embed service_info.yaml
 var ServiceInfoYAML []byte
@@ -24,15 +27,73 @@ const (
 	AUTH_PERMISSIONS_DB_NAME       = "auth_permissions"
 	AUTH_GROUPS_DB_NAME            = "auth_groups"
 	AUTH_GROUP_PERMISSIONS_DB_NAME = "auth_group_permissions"
+	AUDIT_LOGS_DB_NAME             = "audit_logs"
+	PERSONAL_ACCESS_TOKENS_DB_NAME = "personal_access_tokens"
+	NOTIFICATIONS_DB_NAME          = "notifications"
+	SEARCH_DB_NAME                 = "search"
 )
 
 type ExtraData struct {
-	HealthCheck           bool  `json:"health_check" yaml:"health_check"`
-	SessionExpireDuration int   `yaml:"session_expire_duration" json:"session_expire_duration"`
-	MaxAttemptLoginTime   uint8 `yaml:"max_attempt_login_time" json:"max_attempt_login_time"`
+	HealthCheck           bool                 `json:"health_check" yaml:"health_check"`
+	SessionExpireDuration int                  `yaml:"session_expire_duration" json:"session_expire_duration"`
+	MaxAttemptLoginTime   uint8                `yaml:"max_attempt_login_time" json:"max_attempt_login_time"` // failed logins before an account is locked
+	PublicURL             string               `yaml:"public_url" json:"public_url"`
+	RequireStaff2FA       bool                 `yaml:"require_staff_2fa" json:"require_staff_2fa"`
+	MessageEditWindow     int                  `yaml:"message_edit_window" json:"message_edit_window"` // minutes authors may edit a message for, 0 turns editing off
+	Mail                  MailConfig           `yaml:"mail" json:"mail"`
+	Attachments           AttachmentsConfig    `yaml:"attachments" json:"attachments"`
+	OIDCProviders         []OIDCProviderConfig `yaml:"oidc_providers" json:"oidc_providers"`
 	ServicePermissions    ServiceInfo
 }
 
+// MailConfig picks the mailer: "smtp" delivers through Host and Port, "file"
+// appends messages to Path and anything else prints them to stdout. The SMTP
+// password is read from the SMTP_PASSWORD environment variable.
+type MailConfig struct {
+	Driver   string `yaml:"driver" json:"driver"`
+	From     string `yaml:"from" json:"from"`
+	Host     string `yaml:"host" json:"host"`
+	Port     string `yaml:"port" json:"port"`
+	Username string `yaml:"username" json:"username"`
+	Path     string `yaml:"path" json:"path"`
+}
+
+// AttachmentsConfig limits the files members may attach to a message.
+// MaxSize is in megabytes and applies to each file, AllowedTypes lists the
+// media types accepted once the content has been sniffed.
+type AttachmentsConfig struct {
+	MaxSize      int64         `yaml:"max_size" json:"max_size"`
+	MaxFiles     int           `yaml:"max_files" json:"max_files"`
+	AllowedTypes []string      `yaml:"allowed_types" json:"allowed_types"`
+	Storage      StorageConfig `yaml:"storage" json:"storage"`
+}
+
+// StorageConfig picks where attachments are kept: "s3" stores them in Bucket
+// of any S3-compatible service at Endpoint and anything else writes them under
+// Path on the local disk. The S3 secret key is read from the
+// S3_SECRET_ACCESS_KEY environment variable.
+type StorageConfig struct {
+	Driver      string `yaml:"driver" json:"driver"`
+	Path        string `yaml:"path" json:"path"`
+	Endpoint    string `yaml:"endpoint" json:"endpoint"`
+	Region      string `yaml:"region" json:"region"`
+	Bucket      string `yaml:"bucket" json:"bucket"`
+	AccessKeyID string `yaml:"access_key_id" json:"access_key_id"`
+	UseSSL      bool   `yaml:"use_ssl" json:"use_ssl"`
+}
+
+// OIDCProviderConfig configures a single sign-on provider. Name is used in
+// the callback URL "<public_url>/login/oidc/<name>/callback" that must be
+// registered with the provider. The client secret is read from the
+// OIDC_<NAME>_CLIENT_SECRET environment variable.
+type OIDCProviderConfig struct {
+	Name        string   `yaml:"name" json:"name"`
+	DisplayName string   `yaml:"display_name" json:"display_name"`
+	Issuer      string   `yaml:"issuer" json:"issuer"`
+	ClientID    string   `yaml:"client_id" json:"client_id"`
+	Scopes      []string `yaml:"scopes" json:"scopes"`
+}
+
 type ServiceInfo struct {
 	ServiceName    string `yaml:"service_name" json:"service_name"`
 	ServiceCode    string `yaml:"service_code" json:"service_code"`
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
@@ -11,13 +12,17 @@ import (
 
 	"github.com/elyarsadig/studybud-go/configs"
 	"github.com/elyarsadig/studybud-go/internal/delivery"
+	"github.com/elyarsadig/studybud-go/internal/domain"
 	"github.com/elyarsadig/studybud-go/internal/repository"
 	"github.com/elyarsadig/studybud-go/internal/usecase"
 	confighandler "github.com/elyarsadig/studybud-go/pkg/configHandler"
 	"github.com/elyarsadig/studybud-go/pkg/encryption"
 	"github.com/elyarsadig/studybud-go/pkg/errorHandler"
 	"github.com/elyarsadig/studybud-go/pkg/logger"
+	"github.com/elyarsadig/studybud-go/pkg/mailer"
+	"github.com/elyarsadig/studybud-go/pkg/oidc"
 	redispkg "github.com/elyarsadig/studybud-go/pkg/redis"
+	"github.com/elyarsadig/studybud-go/pkg/storage"
 	"github.com/elyarsadig/studybud-go/transport"
 	"github.com/hellofresh/health-go/v5"
 	"gorm.io/gorm"
@@ -30,6 +35,14 @@ type Bootstrapper interface {
 
 const ApiVersion = "/apis/v1"
 
+// unverifiedUsersSweepInterval is how often unverified accounts past their
+// deadline are removed.
+const unverifiedUsersSweepInterval = time.Hour
+
+// attachmentsSweepInterval is how often the files of deleted messages are
+// removed.
+const attachmentsSweepInterval = 10 * time.Minute
+
 type Application struct {
 	httpServer        transport.HTTPTransporter
 	db                *gorm.DB
@@ -41,6 +54,11 @@ type Application struct {
 	serviceInfo       *configs.ServiceInfo
 	sessionExpiration time.Duration
 	aes               *encryption.AES[string]
+	emailTokens       *encryption.AES[domain.EmailVerificationClaims]
+	roomInvites       *encryption.AES[domain.RoomInviteClaims]
+	mailer            mailer.Mailer
+	storage           storage.Storage
+	oidcProviders     []*oidc.Provider
 }
 
 func New(
@@ -53,6 +71,11 @@ func New(
 	logger logger.Logger,
 	serviceInfo *configs.ServiceInfo,
 	aes *encryption.AES[string],
+	emailTokens *encryption.AES[domain.EmailVerificationClaims],
+	roomInvites *encryption.AES[domain.RoomInviteClaims],
+	mailer mailer.Mailer,
+	storage storage.Storage,
+	oidcProviders []*oidc.Provider,
 	sessionExpiration time.Duration,
 ) (Bootstrapper, error) {
 	app := new(Application)
@@ -62,6 +85,11 @@ func New(
 	}
 
 	app.aes = aes
+	app.emailTokens = emailTokens
+	app.roomInvites = roomInvites
+	app.mailer = mailer
+	app.storage = storage
+	app.oidcProviders = oidcProviders
 	app.db = db
 	app.redis = redis
 	app.logger = logger
@@ -119,20 +147,85 @@ func (a *Application) registerServiceLayers(ctx context.Context) error {
 	topicRepo := repository.NewTopic(a.db, a.error, a.logger)
 	roomRepo := repository.NewRoom(a.db, a.error, a.logger)
 	messageRepo := repository.NewMessage(a.db, a.error, a.logger)
+	permissionRepo := repository.NewPermission(a.db, a.error, a.logger)
+	auditRepo := repository.NewAudit(a.db, a.error, a.logger)
+	tokenRepo := repository.NewToken(a.db, a.error, a.logger)
+	notificationRepo := repository.NewNotification(a.db, a.error, a.logger)
+	searchRepo := repository.NewSearch(a.db, a.error, a.logger)
 
-	userUseCase := usecase.NewUser(a.error, a.sessionExpiration, a.redis, a.logger, userRepo)
+	userUseCase := usecase.NewUser(a.error, a.sessionExpiration, a.redis, a.mailer, a.emailTokens, a.serviceConfig.ExtraData.PublicURL, int(a.serviceConfig.ExtraData.MaxAttemptLoginTime), a.serviceConfig.ExtraData.RequireStaff2FA, a.oidcProviders, a.logger, userRepo, permissionRepo)
 	topicUseCase := usecase.NewTopic(a.error, a.logger, topicRepo)
-	roomUseCase := usecase.NewRoom(a.error, a.logger, roomRepo, topicRepo)
-	messageUseCase := usecase.NewMessage(a.error, a.logger, messageRepo)
-	apiHandler, err := delivery.NewApiHandler(ctx, int(a.sessionExpiration.Seconds()), a.aes, a.redis, a.error, a.logger, userUseCase, topicUseCase, roomUseCase, messageUseCase)
+	roomUseCase := usecase.NewRoom(a.error, a.roomInvites, a.serviceConfig.ExtraData.PublicURL, a.logger, roomRepo, topicRepo, permissionRepo)
+	attachments := a.serviceConfig.ExtraData.Attachments
+	attachmentLimits := domain.AttachmentLimits{
+		MaxSize:      attachments.MaxSize << 20,
+		MaxFiles:     attachments.MaxFiles,
+		AllowedTypes: attachments.AllowedTypes,
+	}
+	messageUseCase := usecase.NewMessage(a.error, time.Minute*time.Duration(a.serviceConfig.ExtraData.MessageEditWindow), attachmentLimits, a.storage, a.logger, messageRepo, roomRepo, permissionRepo, userRepo, notificationRepo)
+	permissionUseCase := usecase.NewPermission(a.error, a.logger, permissionRepo)
+	adminUseCase := usecase.NewAdmin(a.error, a.redis, a.logger, userRepo, roomRepo, messageRepo, topicRepo, auditRepo, tokenRepo)
+	tokenUseCase := usecase.NewToken(a.error, a.logger, tokenRepo)
+	notificationUseCase := usecase.NewNotification(a.error, a.logger, notificationRepo)
+	searchUseCase := usecase.NewSearch(a.error, a.logger, searchRepo)
+	apiHandler, err := delivery.NewApiHandler(ctx, int(a.sessionExpiration.Seconds()), a.aes, a.redis, a.error, a.logger, userUseCase, topicUseCase, roomUseCase, messageUseCase, permissionUseCase, adminUseCase, tokenUseCase, notificationUseCase, searchUseCase)
 	if err != nil {
 		return err
 	}
+	// Bodies may carry every attachment of a message, plus a megabyte for
+	// the rest of the form.
+	maxBody := attachmentLimits.MaxSize*int64(attachmentLimits.MaxFiles) + 1<<20
+	a.httpServer.Use(transport.LimitBody(maxBody), transport.CSRF(ApiVersion, delivery.SessionCookieName, apiHandler.CSRFFailure), apiHandler.TokenAuth)
 	a.registerAPIHandler(apiHandler)
+	a.registerRESTHandler(apiHandler)
+	a.registerAdminHandler(apiHandler)
+
+	go a.expireUnverifiedUsers(ctx, userUseCase)
+	go a.sweepAttachments(ctx, messageUseCase)
 
 	return nil
 }
 
+// expireUnverifiedUsers periodically removes accounts whose email address was
+// never verified. It runs until ctx is done.
+func (a *Application) expireUnverifiedUsers(ctx context.Context, userUseCase domain.UserUseCase) {
+	ticker := time.NewTicker(unverifiedUsersSweepInterval)
+	defer ticker.Stop()
+	for {
+		deleted, err := userUseCase.ExpireUnverifiedUsers(ctx)
+		if err != nil {
+			a.logger.ErrorContext(ctx, "failed to expire unverified users", "error", err)
+		} else if deleted > 0 {
+			a.logger.InfoContext(ctx, "expired unverified users", "count", deleted)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sweepAttachments periodically removes the files attached to deleted
+// messages. It runs until ctx is done.
+func (a *Application) sweepAttachments(ctx context.Context, messageUseCase domain.MessageUseCase) {
+	ticker := time.NewTicker(attachmentsSweepInterval)
+	defer ticker.Stop()
+	for {
+		deleted, err := messageUseCase.SweepAttachments(ctx)
+		if err != nil {
+			a.logger.ErrorContext(ctx, "failed to sweep attachments", "error", err)
+		} else if deleted > 0 {
+			a.logger.InfoContext(ctx, "swept attachments", "count", deleted)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
 func (a *Application) registerAPIHandler(apiHandler *delivery.ApiHandler) {
 	if a.serviceConfig.ExtraData.HealthCheck {
 		a.httpServer.AddHandler("get", "/health", a.healthCheck.HandlerFunc)
@@ -144,25 +237,160 @@ func (a *Application) registerAPIHandler(apiHandler *delivery.ApiHandler) {
 	a.httpServer.AddHandler("get", "/topics", apiHandler.Topics)
 	a.httpServer.AddHandler("get", "/home", apiHandler.HomePage)
 	a.httpServer.AddHandler("get", "/room/{id}", apiHandler.RoomPage)
-	a.httpServer.AddHandler("post", "/room/{id}", apiHandler.ProtectedHandler(apiHandler.CreateMessage))
+	a.httpServer.AddHandler("get", "/attachments/{id}", apiHandler.Attachment)
+	a.httpServer.AddHandler("get", "/attachments/{id}/thumbnail", apiHandler.AttachmentThumbnail)
+	a.httpServer.AddHandler("post", "/room/{id}", apiHandler.ProtectedHandler(apiHandler.RequirePermission(domain.PermAddMessage, apiHandler.CreateMessage)))
+	a.httpServer.AddHandler("get", "/room/{id}/ws", apiHandler.ProtectedHandler(apiHandler.RoomSocket))
+	a.httpServer.AddHandler("post", "/room/{id}/join", apiHandler.ProtectedHandler(apiHandler.JoinRoom))
+	a.httpServer.AddHandler("post", "/room/{id}/leave", apiHandler.ProtectedHandler(apiHandler.LeaveRoom))
+	a.httpServer.AddHandler("get", "/room/{id}/members", apiHandler.ProtectedHandler(apiHandler.RoomMembersPage))
+	a.httpServer.AddHandler("post", "/room/{id}/members/{userID}/role", apiHandler.ProtectedHandler(apiHandler.SetParticipantRole))
+	a.httpServer.AddHandler("post", "/room/{id}/members/{userID}/mute", apiHandler.ProtectedHandler(apiHandler.MuteParticipant))
+	a.httpServer.AddHandler("post", "/room/{id}/members/{userID}/unmute", apiHandler.ProtectedHandler(apiHandler.UnmuteParticipant))
+	a.httpServer.AddHandler("post", "/room/{id}/members/{userID}/kick", apiHandler.ProtectedHandler(apiHandler.KickParticipant))
+	a.httpServer.AddHandler("post", "/room/{id}/members/{userID}/transfer", apiHandler.ProtectedHandler(apiHandler.TransferRoomOwnership))
+	a.httpServer.AddHandler("get", "/my-rooms", apiHandler.ProtectedHandler(apiHandler.MyRoomsPage))
+	a.httpServer.AddHandler("get", "/room/{id}/invites", apiHandler.ProtectedHandler(apiHandler.RoomInvitesPage))
+	a.httpServer.AddHandler("post", "/room/{id}/invites", apiHandler.ProtectedHandler(apiHandler.CreateRoomInvite))
+	a.httpServer.AddHandler("post", "/room/{id}/invites/reset", apiHandler.ProtectedHandler(apiHandler.ResetRoomInvites))
+	a.httpServer.AddHandler("post", "/room/{id}/join-requests", apiHandler.ProtectedHandler(apiHandler.RequestToJoinRoom))
+	a.httpServer.AddHandler("post", "/room/{id}/join-requests/{requestID}/approve", apiHandler.ProtectedHandler(apiHandler.ApproveJoinRequest))
+	a.httpServer.AddHandler("post", "/room/{id}/join-requests/{requestID}/deny", apiHandler.ProtectedHandler(apiHandler.DenyJoinRequest))
+	a.httpServer.AddHandler("get", "/invite", apiHandler.ProtectedHandler(apiHandler.InvitePage))
+	a.httpServer.AddHandler("post", "/invite", apiHandler.ProtectedHandler(apiHandler.AcceptInvite))
 	a.httpServer.AddHandler("get", "/activity", apiHandler.ActivitiesPage)
+	a.httpServer.AddHandler("get", "/search", apiHandler.SearchPage)
 	a.httpServer.AddHandler("get", "/profile/{id}", apiHandler.UserProfilePage)
 	a.httpServer.AddHandler("get", "/login", apiHandler.RedirectIfAuthenticated(apiHandler.LoginPage))
 	a.httpServer.AddHandler("post", "/login", apiHandler.RedirectIfAuthenticated(apiHandler.LoginUser))
+	a.httpServer.AddHandler("get", "/login/2fa", apiHandler.RedirectIfAuthenticated(apiHandler.TwoFactorLoginPage))
+	a.httpServer.AddHandler("post", "/login/2fa", apiHandler.RedirectIfAuthenticated(apiHandler.TwoFactorLogin))
+	a.httpServer.AddHandler("get", "/login/oidc/{provider}", apiHandler.RedirectIfAuthenticated(apiHandler.OIDCLogin))
+	a.httpServer.AddHandler("get", "/login/oidc/{provider}/callback", apiHandler.RedirectIfAuthenticated(apiHandler.OIDCCallback))
 	a.httpServer.AddHandler("get", "/register", apiHandler.RedirectIfAuthenticated(apiHandler.RegisterPage))
 	a.httpServer.AddHandler("post", "/register", apiHandler.RedirectIfAuthenticated(apiHandler.RegisterUser))
-	a.httpServer.AddHandler("get", "/create-room", apiHandler.ProtectedHandler(apiHandler.CreateRoomPage))
-	a.httpServer.AddHandler("post", "/create-room", apiHandler.ProtectedHandler(apiHandler.CreateRoom))
+	a.httpServer.AddHandler("get", "/forgot-password", apiHandler.RedirectIfAuthenticated(apiHandler.ForgotPasswordPage))
+	a.httpServer.AddHandler("post", "/forgot-password", apiHandler.RedirectIfAuthenticated(apiHandler.ForgotPassword))
+	a.httpServer.AddHandler("get", "/reset-password", apiHandler.ResetPasswordPage)
+	a.httpServer.AddHandler("post", "/reset-password", apiHandler.ResetPassword)
+	a.httpServer.AddHandler("get", "/verify-email", apiHandler.ProtectedHandler(apiHandler.VerifyEmailPage))
+	a.httpServer.AddHandler("post", "/verify-email/resend", apiHandler.ProtectedHandler(apiHandler.ResendVerification))
+	a.httpServer.AddHandler("get", "/verify-email/confirm", apiHandler.ConfirmEmail)
+	a.httpServer.AddHandler("get", "/sessions", apiHandler.ProtectedHandler(apiHandler.SessionsPage))
+	a.httpServer.AddHandler("post", "/sessions/revoke-all", apiHandler.ProtectedHandler(apiHandler.RevokeAllSessions))
+	a.httpServer.AddHandler("post", "/sessions/{id}/revoke", apiHandler.ProtectedHandler(apiHandler.RevokeSession))
+	a.httpServer.AddHandler("get", "/notifications", apiHandler.ProtectedHandler(apiHandler.NotificationsPage))
+	a.httpServer.AddHandler("post", "/notifications/read-all", apiHandler.ProtectedHandler(apiHandler.MarkAllNotificationsRead))
+	a.httpServer.AddHandler("post", "/notifications/{id}/open", apiHandler.ProtectedHandler(apiHandler.OpenNotification))
+	a.httpServer.AddHandler("get", "/tokens", apiHandler.ProtectedHandler(apiHandler.TokensPage))
+	a.httpServer.AddHandler("post", "/tokens", apiHandler.ProtectedHandler(apiHandler.CreateToken))
+	a.httpServer.AddHandler("post", "/tokens/{id}/revoke", apiHandler.ProtectedHandler(apiHandler.RevokeToken))
+	a.httpServer.AddHandler("get", "/2fa", apiHandler.ProtectedHandler(apiHandler.TwoFactorPage))
+	a.httpServer.AddHandler("get", "/2fa/setup", apiHandler.ProtectedHandler(apiHandler.TwoFactorSetupPage))
+	a.httpServer.AddHandler("post", "/2fa/setup", apiHandler.ProtectedHandler(apiHandler.TwoFactorSetup))
+	a.httpServer.AddHandler("post", "/2fa/disable", apiHandler.ProtectedHandler(apiHandler.DisableTwoFactor))
+	a.httpServer.AddHandler("post", "/2fa/recovery-codes", apiHandler.ProtectedHandler(apiHandler.RegenerateRecoveryCodes))
+	a.httpServer.AddHandler("get", "/create-room", apiHandler.ProtectedHandler(apiHandler.RequirePermission(domain.PermAddRoom, apiHandler.CreateRoomPage)))
+	a.httpServer.AddHandler("post", "/create-room", apiHandler.ProtectedHandler(apiHandler.RequirePermission(domain.PermAddRoom, apiHandler.CreateRoom)))
 	a.httpServer.AddHandler("get", "/update-room/{id}", apiHandler.ProtectedHandler(apiHandler.UpdateRoomPage))
 	a.httpServer.AddHandler("post", "/update-room/{id}", apiHandler.ProtectedHandler(apiHandler.UpdateRoom))
 	a.httpServer.AddHandler("get", "/user-update", apiHandler.ProtectedHandler(apiHandler.UpdateProfilePage))
 	a.httpServer.AddHandler("post", "/user-update", apiHandler.ProtectedHandler(apiHandler.UpdateProfile))
 	a.httpServer.AddHandler("get", "/delete-message/{id}", apiHandler.ProtectedHandler(apiHandler.DeleteMessagePage))
 	a.httpServer.AddHandler("post", "/delete-message/{id}", apiHandler.ProtectedHandler(apiHandler.DeleteMessage))
+	a.httpServer.AddHandler("get", "/edit-message/{id}", apiHandler.ProtectedHandler(apiHandler.EditMessagePage))
+	a.httpServer.AddHandler("post", "/edit-message/{id}", apiHandler.ProtectedHandler(apiHandler.RequirePermission(domain.PermAddMessage, apiHandler.EditMessage)))
+	a.httpServer.AddHandler("get", "/message/{id}/history", apiHandler.ProtectedHandler(apiHandler.MessageHistoryPage))
+	a.httpServer.AddHandler("post", "/react-message/{id}", apiHandler.ProtectedHandler(apiHandler.RequirePermission(domain.PermAddMessage, apiHandler.ReactToMessage)))
+	a.httpServer.AddHandler("post", "/pin-message/{id}", apiHandler.ProtectedHandler(apiHandler.PinMessage))
+	a.httpServer.AddHandler("post", "/unpin-message/{id}", apiHandler.ProtectedHandler(apiHandler.UnpinMessage))
 	a.httpServer.AddHandler("get", "/delete-room/{id}", apiHandler.ProtectedHandler(apiHandler.DeleteRoomPage))
 	a.httpServer.AddHandler("post", "/delete-room/{id}", apiHandler.ProtectedHandler(apiHandler.DeleteRoom))
 }
 
+func (a *Application) registerRESTHandler(apiHandler *delivery.ApiHandler) {
+	a.httpServer.AddHandler("post", ApiVersion+"/auth/login", apiHandler.APILogin)
+	a.httpServer.AddHandler("post", ApiVersion+"/auth/login/2fa", apiHandler.APILoginTwoFactor)
+	a.httpServer.AddHandler("post", ApiVersion+"/auth/register", apiHandler.APIRegister)
+	a.httpServer.AddHandler("post", ApiVersion+"/auth/logout", apiHandler.APIProtectedHandler(apiHandler.APILogout))
+	a.httpServer.AddHandler("post", ApiVersion+"/auth/password/forgot", apiHandler.APIForgotPassword)
+	a.httpServer.AddHandler("post", ApiVersion+"/auth/password/reset", apiHandler.APIResetPassword)
+	a.httpServer.AddHandler("post", ApiVersion+"/auth/verify-email", apiHandler.APIVerifyEmail)
+	a.httpServer.AddHandler("post", ApiVersion+"/auth/verify-email/resend", apiHandler.APIProtectedHandler(apiHandler.APIResendVerification))
+	a.httpServer.AddHandler("get", ApiVersion+"/users/me", apiHandler.APIProtectedHandler(apiHandler.APICurrentUser))
+	a.httpServer.AddHandler("put", ApiVersion+"/users/me", apiHandler.APIProtectedHandler(apiHandler.APIUpdateCurrentUser))
+	a.httpServer.AddHandler("get", ApiVersion+"/users/me/sessions", apiHandler.APIProtectedHandler(apiHandler.APIListSessions))
+	a.httpServer.AddHandler("delete", ApiVersion+"/users/me/sessions", apiHandler.APIProtectedHandler(apiHandler.APIRevokeAllSessions))
+	a.httpServer.AddHandler("delete", ApiVersion+"/users/me/sessions/{id}", apiHandler.APIProtectedHandler(apiHandler.APIRevokeSession))
+	a.httpServer.AddHandler("get", ApiVersion+"/users/me/tokens", apiHandler.APIProtectedHandler(apiHandler.APIListTokens))
+	a.httpServer.AddHandler("post", ApiVersion+"/users/me/tokens", apiHandler.APIProtectedHandler(apiHandler.APICreateToken))
+	a.httpServer.AddHandler("delete", ApiVersion+"/users/me/tokens/{id}", apiHandler.APIProtectedHandler(apiHandler.APIRevokeToken))
+	a.httpServer.AddHandler("get", ApiVersion+"/users/me/notifications", apiHandler.APIProtectedHandler(apiHandler.APIListNotifications))
+	a.httpServer.AddHandler("post", ApiVersion+"/users/me/notifications/read", apiHandler.APIProtectedHandler(apiHandler.APIMarkAllNotificationsRead))
+	a.httpServer.AddHandler("post", ApiVersion+"/users/me/notifications/{id}/read", apiHandler.APIProtectedHandler(apiHandler.APIReadNotification))
+	a.httpServer.AddHandler("get", ApiVersion+"/users/me/rooms", apiHandler.APIProtectedHandler(apiHandler.APIListJoinedRooms))
+	a.httpServer.AddHandler("get", ApiVersion+"/users/{id}", apiHandler.APIGetUser)
+	a.httpServer.AddHandler("get", ApiVersion+"/users/{id}/rooms", apiHandler.APIListUserRooms)
+	a.httpServer.AddHandler("get", ApiVersion+"/users/{id}/messages", apiHandler.APIListUserMessages)
+	a.httpServer.AddHandler("get", ApiVersion+"/topics", apiHandler.APIListTopics)
+	a.httpServer.AddHandler("get", ApiVersion+"/search", apiHandler.APISearch)
+	a.httpServer.AddHandler("get", ApiVersion+"/rooms", apiHandler.APIListRooms)
+	a.httpServer.AddHandler("post", ApiVersion+"/rooms", apiHandler.APIProtectedHandler(apiHandler.APIRequirePermission(domain.PermAddRoom, apiHandler.APICreateRoom)))
+	a.httpServer.AddHandler("get", ApiVersion+"/rooms/{id}", apiHandler.APIGetRoom)
+	a.httpServer.AddHandler("put", ApiVersion+"/rooms/{id}", apiHandler.APIProtectedHandler(apiHandler.APIUpdateRoom))
+	a.httpServer.AddHandler("delete", ApiVersion+"/rooms/{id}", apiHandler.APIProtectedHandler(apiHandler.APIDeleteRoom))
+	a.httpServer.AddHandler("get", ApiVersion+"/rooms/{id}/participants", apiHandler.APIListRoomParticipants)
+	a.httpServer.AddHandler("put", ApiVersion+"/rooms/{id}/participants/{userID}", apiHandler.APIProtectedHandler(apiHandler.APISetParticipantRole))
+	a.httpServer.AddHandler("delete", ApiVersion+"/rooms/{id}/participants/{userID}", apiHandler.APIProtectedHandler(apiHandler.APIKickParticipant))
+	a.httpServer.AddHandler("put", ApiVersion+"/rooms/{id}/participants/{userID}/mute", apiHandler.APIProtectedHandler(apiHandler.APIMuteParticipant))
+	a.httpServer.AddHandler("delete", ApiVersion+"/rooms/{id}/participants/{userID}/mute", apiHandler.APIProtectedHandler(apiHandler.APIUnmuteParticipant))
+	a.httpServer.AddHandler("post", ApiVersion+"/rooms/{id}/transfer", apiHandler.APIProtectedHandler(apiHandler.APITransferRoom))
+	a.httpServer.AddHandler("post", ApiVersion+"/rooms/{id}/join", apiHandler.APIProtectedHandler(apiHandler.APIJoinRoom))
+	a.httpServer.AddHandler("post", ApiVersion+"/rooms/{id}/leave", apiHandler.APIProtectedHandler(apiHandler.APILeaveRoom))
+	a.httpServer.AddHandler("get", ApiVersion+"/rooms/{id}/messages", apiHandler.APIListRoomMessages)
+	a.httpServer.AddHandler("post", ApiVersion+"/rooms/{id}/messages", apiHandler.APIProtectedHandler(apiHandler.APIRequirePermission(domain.PermAddMessage, apiHandler.APICreateMessage)))
+	a.httpServer.AddHandler("get", ApiVersion+"/rooms/{id}/messages/pinned", apiHandler.APIListPinnedMessages)
+	a.httpServer.AddHandler("get", ApiVersion+"/messages/{id}/thread", apiHandler.APIGetThread)
+	a.httpServer.AddHandler("put", ApiVersion+"/messages/{id}", apiHandler.APIProtectedHandler(apiHandler.APIRequirePermission(domain.PermAddMessage, apiHandler.APIEditMessage)))
+	a.httpServer.AddHandler("get", ApiVersion+"/messages/{id}/revisions", apiHandler.APIProtectedHandler(apiHandler.APIListMessageRevisions))
+	a.httpServer.AddHandler("post", ApiVersion+"/messages/{id}/reactions", apiHandler.APIProtectedHandler(apiHandler.APIRequirePermission(domain.PermAddMessage, apiHandler.APIToggleReaction)))
+	a.httpServer.AddHandler("delete", ApiVersion+"/messages/{id}", apiHandler.APIProtectedHandler(apiHandler.APIDeleteMessage))
+	a.httpServer.AddHandler("put", ApiVersion+"/messages/{id}/pin", apiHandler.APIProtectedHandler(apiHandler.APIPinMessage))
+	a.httpServer.AddHandler("delete", ApiVersion+"/messages/{id}/pin", apiHandler.APIProtectedHandler(apiHandler.APIUnpinMessage))
+}
+
+func (a *Application) registerAdminHandler(apiHandler *delivery.ApiHandler) {
+	staff := func(next http.HandlerFunc) http.HandlerFunc {
+		return apiHandler.ProtectedHandler(apiHandler.StaffHandler(next))
+	}
+	a.httpServer.AddHandler("get", "/admin", staff(apiHandler.AdminIndex))
+	a.httpServer.AddHandler("get", "/admin/audit", staff(apiHandler.AdminAuditLogs))
+	a.httpServer.AddHandler("get", "/admin/users", staff(apiHandler.AdminUsers))
+	a.httpServer.AddHandler("post", "/admin/users/actions", staff(apiHandler.AdminUserActions))
+	a.httpServer.AddHandler("get", "/admin/users/{id}", staff(apiHandler.AdminUserPage))
+	a.httpServer.AddHandler("post", "/admin/users/{id}", staff(apiHandler.AdminUpdateUser))
+	a.httpServer.AddHandler("get", "/admin/users/{id}/delete", staff(apiHandler.AdminDeleteUserPage))
+	a.httpServer.AddHandler("post", "/admin/users/{id}/delete", staff(apiHandler.AdminDeleteUser))
+	a.httpServer.AddHandler("get", "/admin/rooms", staff(apiHandler.AdminRooms))
+	a.httpServer.AddHandler("post", "/admin/rooms/actions", staff(apiHandler.AdminRoomActions))
+	a.httpServer.AddHandler("get", "/admin/rooms/{id}", staff(apiHandler.AdminRoomPage))
+	a.httpServer.AddHandler("post", "/admin/rooms/{id}", staff(apiHandler.AdminUpdateRoom))
+	a.httpServer.AddHandler("get", "/admin/rooms/{id}/delete", staff(apiHandler.AdminDeleteRoomPage))
+	a.httpServer.AddHandler("post", "/admin/rooms/{id}/delete", staff(apiHandler.AdminDeleteRoom))
+	a.httpServer.AddHandler("get", "/admin/messages", staff(apiHandler.AdminMessages))
+	a.httpServer.AddHandler("post", "/admin/messages/actions", staff(apiHandler.AdminMessageActions))
+	a.httpServer.AddHandler("get", "/admin/messages/{id}", staff(apiHandler.AdminMessagePage))
+	a.httpServer.AddHandler("post", "/admin/messages/{id}", staff(apiHandler.AdminUpdateMessage))
+	a.httpServer.AddHandler("get", "/admin/messages/{id}/delete", staff(apiHandler.AdminDeleteMessagePage))
+	a.httpServer.AddHandler("post", "/admin/messages/{id}/delete", staff(apiHandler.AdminDeleteMessage))
+	a.httpServer.AddHandler("get", "/admin/topics", staff(apiHandler.AdminTopics))
+	a.httpServer.AddHandler("post", "/admin/topics/actions", staff(apiHandler.AdminTopicActions))
+	a.httpServer.AddHandler("get", "/admin/topics/{id}", staff(apiHandler.AdminTopicPage))
+	a.httpServer.AddHandler("post", "/admin/topics/{id}", staff(apiHandler.AdminUpdateTopic))
+	a.httpServer.AddHandler("get", "/admin/topics/{id}/delete", staff(apiHandler.AdminDeleteTopicPage))
+	a.httpServer.AddHandler("post", "/admin/topics/{id}/delete", staff(apiHandler.AdminDeleteTopic))
+}
+
 func healthChecker(name, version, code string) *health.Health {
 	h, _ := health.New(health.WithComponent(health.Component{
 		Name:    fmt.Sprintf("%s - service code: %s", name, code),
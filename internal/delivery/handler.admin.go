@@ -0,0 +1,658 @@
+package delivery
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/elyarsadig/studybud-go/configs"
+	"github.com/elyarsadig/studybud-go/internal/domain"
+	"github.com/elyarsadig/studybud-go/pkg/utils"
+	"github.com/go-chi/chi/v5"
+)
+
+const adminTimeFormat = "2006-01-02 15:04"
+
+// StaffHandler must be wrapped by ProtectedHandler. The staff flags are read
+// from the database on every request so revoking them takes effect at once.
+func (h *ApiHandler) StaffHandler(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		sessionValue := ctx.Value(configs.UserCtxKey).(domain.SessionValue)
+		useCase := domain.Bridge[domain.UserUseCase](configs.USERS_DB_NAME, h.useCases)
+		user, err := useCase.GetUserById(ctx, strconv.Itoa(sessionValue.ID))
+		if err == nil && !(user.IsActive && (user.IsStaff || user.IsSuperuser)) {
+			err = h.errHandler.New(http.StatusForbidden, "the admin console is restricted to staff members")
+		}
+		if err != nil {
+			h.handleError(w, r, err, "forbidden.html", adminBaseData(ctx))
+			return
+		}
+		next(w, r)
+	}
+}
+
+func (h *ApiHandler) AdminIndex(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	data := AdminIndexTemplateData{
+		BaseTemplateData: adminBaseData(ctx),
+	}
+	useCase := domain.Bridge[domain.AdminUseCase](configs.AUDIT_LOGS_DB_NAME, h.useCases)
+	countPage := domain.Page{Limit: 1}
+	users, err := useCase.ListUsers(ctx, "", countPage)
+	if err != nil {
+		h.handleFormError(w, r, err, "admin_index.html", &data.BaseTemplateData, &data)
+		return
+	}
+	rooms, err := useCase.ListRooms(ctx, "", countPage)
+	if err != nil {
+		h.handleFormError(w, r, err, "admin_index.html", &data.BaseTemplateData, &data)
+		return
+	}
+	messages, err := useCase.ListMessages(ctx, "", countPage)
+	if err != nil {
+		h.handleFormError(w, r, err, "admin_index.html", &data.BaseTemplateData, &data)
+		return
+	}
+	topics, err := useCase.ListTopics(ctx, "", countPage)
+	if err != nil {
+		h.handleFormError(w, r, err, "admin_index.html", &data.BaseTemplateData, &data)
+		return
+	}
+	logs, err := useCase.ListAuditLogs(ctx, domain.Page{Limit: 10})
+	if err != nil {
+		h.handleFormError(w, r, err, "admin_index.html", &data.BaseTemplateData, &data)
+		return
+	}
+	data.Sections = []AdminSection{
+		{Title: "Users", URL: "/admin/users", Count: users.Count},
+		{Title: "Rooms", URL: "/admin/rooms", Count: rooms.Count},
+		{Title: "Messages", URL: "/admin/messages", Count: messages.Count},
+		{Title: "Topics", URL: "/admin/topics", Count: topics.Count},
+	}
+	data.AuditLog = newAuditLogListData(logs)
+	h.renderTemplate(w, r, "admin_index.html", data)
+}
+
+func (h *ApiHandler) AdminAuditLogs(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	useCase := domain.Bridge[domain.AdminUseCase](configs.AUDIT_LOGS_DB_NAME, h.useCases)
+	logs, err := useCase.ListAuditLogs(ctx, pageFromRequest(r))
+	data := newAuditLogListData(logs)
+	data.BaseTemplateData = adminBaseData(ctx)
+	if err != nil {
+		h.handleFormError(w, r, err, "admin_list.html", &data.BaseTemplateData, &data)
+		return
+	}
+	data.NextPageURL = nextPageURL(r, logs.NextCursor)
+	h.renderTemplate(w, r, "admin_list.html", data)
+}
+
+func (h *ApiHandler) AdminUsers(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	searchQuery := r.URL.Query().Get("q")
+	useCase := domain.Bridge[domain.AdminUseCase](configs.AUDIT_LOGS_DB_NAME, h.useCases)
+	users, err := useCase.ListUsers(ctx, searchQuery, pageFromRequest(r))
+	data := AdminListTemplateData{
+		BaseTemplateData: adminBaseData(ctx),
+		Title:            "Users",
+		Query:            searchQuery,
+		Searchable:       true,
+		ActionURL:        "/admin/users/actions",
+		Actions: []AdminAction{
+			{Value: "deactivate", Label: "Deactivate selected users"},
+			{Value: "activate", Label: "Activate selected users"},
+		},
+		Columns: []string{"Username", "Email", "Name", "Active", "Verified", "Staff", "Superuser", "Joined"},
+		Count:   users.Count,
+	}
+	if err != nil {
+		h.handleFormError(w, r, err, "admin_list.html", &data.BaseTemplateData, &data)
+		return
+	}
+	for _, user := range users.List {
+		data.Rows = append(data.Rows, AdminRow{
+			ID:      user.ID,
+			EditURL: "/admin/users/" + strconv.Itoa(int(user.ID)),
+			Cells: []string{
+				user.Username,
+				user.Email,
+				user.Name,
+				yesNo(user.IsActive),
+				yesNo(!user.PendingEmailVerification),
+				yesNo(user.IsStaff),
+				yesNo(user.IsSuperuser),
+				user.DateJoined.Format(adminTimeFormat),
+			},
+		})
+	}
+	data.NextPageURL = nextPageURL(r, users.NextCursor)
+	h.renderTemplate(w, r, "admin_list.html", data)
+}
+
+func (h *ApiHandler) AdminUserPage(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := chi.URLParam(r, "id")
+	useCase := domain.Bridge[domain.AdminUseCase](configs.AUDIT_LOGS_DB_NAME, h.useCases)
+	user, err := useCase.GetUser(ctx, id)
+	if err != nil {
+		h.handleError(w, r, err, "not_found.html", adminBaseData(ctx))
+		return
+	}
+	h.renderTemplate(w, r, "admin_form.html", newAdminUserFormData(ctx, id, domain.AdminUserForm{
+		Name:        user.Name,
+		Username:    user.Username,
+		Email:       user.Email,
+		Bio:         user.Bio,
+		IsActive:    user.IsActive,
+		IsStaff:     user.IsStaff,
+		IsSuperuser: user.IsSuperuser,
+		RequireTOTP: user.RequireTOTP,
+	}))
+}
+
+func (h *ApiHandler) AdminUpdateUser(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := chi.URLParam(r, "id")
+	form := domain.AdminUserForm{
+		Name:        r.FormValue("name"),
+		Username:    r.FormValue("username"),
+		Email:       r.FormValue("email"),
+		Bio:         r.FormValue("bio"),
+		IsActive:    r.FormValue("is_active") == "on",
+		IsStaff:     r.FormValue("is_staff") == "on",
+		IsSuperuser: r.FormValue("is_superuser") == "on",
+		RequireTOTP: r.FormValue("require_totp") == "on",
+	}
+	useCase := domain.Bridge[domain.AdminUseCase](configs.AUDIT_LOGS_DB_NAME, h.useCases)
+	err := useCase.UpdateUser(ctx, id, form)
+	if err != nil {
+		data := newAdminUserFormData(ctx, id, form)
+		h.handleFormError(w, r, err, "admin_form.html", &data.BaseTemplateData, &data)
+		return
+	}
+	http.Redirect(w, r, "/admin/users", http.StatusFound)
+}
+
+func (h *ApiHandler) AdminDeleteUserPage(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	useCase := domain.Bridge[domain.AdminUseCase](configs.AUDIT_LOGS_DB_NAME, h.useCases)
+	user, err := useCase.GetUser(ctx, chi.URLParam(r, "id"))
+	if err != nil {
+		h.handleError(w, r, err, "not_found.html", adminBaseData(ctx))
+		return
+	}
+	h.renderTemplate(w, r, "delete.html", DeleteForm{BaseTemplateData: adminBaseData(ctx), Obj: user.Username})
+}
+
+func (h *ApiHandler) AdminDeleteUser(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	useCase := domain.Bridge[domain.AdminUseCase](configs.AUDIT_LOGS_DB_NAME, h.useCases)
+	err := useCase.DeleteUser(ctx, chi.URLParam(r, "id"))
+	if err != nil {
+		h.handleError(w, r, err, "admin_error.html", adminBaseData(ctx))
+		return
+	}
+	http.Redirect(w, r, "/admin/users", http.StatusFound)
+}
+
+func (h *ApiHandler) AdminUserActions(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	err := r.ParseForm()
+	if err != nil {
+		http.Error(w, "invalid form", http.StatusBadRequest)
+		return
+	}
+	useCase := domain.Bridge[domain.AdminUseCase](configs.AUDIT_LOGS_DB_NAME, h.useCases)
+	switch r.PostForm.Get("action") {
+	case "deactivate":
+		err = useCase.SetUsersActive(ctx, r.PostForm["ids"], false)
+	case "activate":
+		err = useCase.SetUsersActive(ctx, r.PostForm["ids"], true)
+	default:
+		err = h.errHandler.New(http.StatusBadRequest, "unknown action")
+	}
+	if err != nil {
+		h.handleError(w, r, err, "admin_error.html", adminBaseData(ctx))
+		return
+	}
+	http.Redirect(w, r, "/admin/users", http.StatusFound)
+}
+
+func (h *ApiHandler) AdminRooms(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	searchQuery := r.URL.Query().Get("q")
+	useCase := domain.Bridge[domain.AdminUseCase](configs.AUDIT_LOGS_DB_NAME, h.useCases)
+	rooms, err := useCase.ListRooms(ctx, searchQuery, pageFromRequest(r))
+	data := AdminListTemplateData{
+		BaseTemplateData: adminBaseData(ctx),
+		Title:            "Rooms",
+		Query:            searchQuery,
+		Searchable:       true,
+		ActionURL:        "/admin/rooms/actions",
+		Actions:          []AdminAction{{Value: "delete", Label: "Delete selected rooms"}},
+		Columns:          []string{"Name", "Topic", "Host", "Participants", "Created"},
+		Count:            rooms.Count,
+	}
+	if err != nil {
+		h.handleFormError(w, r, err, "admin_list.html", &data.BaseTemplateData, &data)
+		return
+	}
+	for _, room := range rooms.List {
+		data.Rows = append(data.Rows, AdminRow{
+			ID:      room.ID,
+			EditURL: "/admin/rooms/" + strconv.Itoa(int(room.ID)),
+			Cells: []string{
+				room.Name,
+				room.Topic.Name,
+				roomHostCell(room.Room),
+				strconv.FormatInt(room.ParticipantsCount, 10),
+				room.Created.Format(adminTimeFormat),
+			},
+		})
+	}
+	data.NextPageURL = nextPageURL(r, rooms.NextCursor)
+	h.renderTemplate(w, r, "admin_list.html", data)
+}
+
+func (h *ApiHandler) AdminRoomPage(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := chi.URLParam(r, "id")
+	useCase := domain.Bridge[domain.AdminUseCase](configs.AUDIT_LOGS_DB_NAME, h.useCases)
+	room, err := useCase.GetRoom(ctx, id)
+	if err != nil {
+		h.handleError(w, r, err, "not_found.html", adminBaseData(ctx))
+		return
+	}
+	h.renderTemplate(w, r, "admin_form.html", newAdminRoomFormData(ctx, id, domain.RoomForm{
+		TopicName:   room.Topic.Name,
+		Name:        room.Name,
+		Description: room.Description,
+	}))
+}
+
+func (h *ApiHandler) AdminUpdateRoom(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := chi.URLParam(r, "id")
+	form := domain.RoomForm{
+		TopicName:   r.FormValue("topic"),
+		Name:        r.FormValue("name"),
+		Description: r.FormValue("description"),
+	}
+	useCase := domain.Bridge[domain.AdminUseCase](configs.AUDIT_LOGS_DB_NAME, h.useCases)
+	err := useCase.UpdateRoom(ctx, id, form)
+	if err != nil {
+		data := newAdminRoomFormData(ctx, id, form)
+		h.handleFormError(w, r, err, "admin_form.html", &data.BaseTemplateData, &data)
+		return
+	}
+	http.Redirect(w, r, "/admin/rooms", http.StatusFound)
+}
+
+func (h *ApiHandler) AdminDeleteRoomPage(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	useCase := domain.Bridge[domain.AdminUseCase](configs.AUDIT_LOGS_DB_NAME, h.useCases)
+	room, err := useCase.GetRoom(ctx, chi.URLParam(r, "id"))
+	if err != nil {
+		h.handleError(w, r, err, "not_found.html", adminBaseData(ctx))
+		return
+	}
+	h.renderTemplate(w, r, "delete.html", DeleteForm{BaseTemplateData: adminBaseData(ctx), Obj: room.Name})
+}
+
+func (h *ApiHandler) AdminDeleteRoom(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	useCase := domain.Bridge[domain.AdminUseCase](configs.AUDIT_LOGS_DB_NAME, h.useCases)
+	err := useCase.DeleteRooms(ctx, []string{chi.URLParam(r, "id")})
+	if err != nil {
+		h.handleError(w, r, err, "admin_error.html", adminBaseData(ctx))
+		return
+	}
+	http.Redirect(w, r, "/admin/rooms", http.StatusFound)
+}
+
+func (h *ApiHandler) AdminRoomActions(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	err := r.ParseForm()
+	if err != nil {
+		http.Error(w, "invalid form", http.StatusBadRequest)
+		return
+	}
+	useCase := domain.Bridge[domain.AdminUseCase](configs.AUDIT_LOGS_DB_NAME, h.useCases)
+	switch r.PostForm.Get("action") {
+	case "delete":
+		err = useCase.DeleteRooms(ctx, r.PostForm["ids"])
+	default:
+		err = h.errHandler.New(http.StatusBadRequest, "unknown action")
+	}
+	if err != nil {
+		h.handleError(w, r, err, "admin_error.html", adminBaseData(ctx))
+		return
+	}
+	http.Redirect(w, r, "/admin/rooms", http.StatusFound)
+}
+
+func (h *ApiHandler) AdminMessages(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	searchQuery := r.URL.Query().Get("q")
+	useCase := domain.Bridge[domain.AdminUseCase](configs.AUDIT_LOGS_DB_NAME, h.useCases)
+	messages, err := useCase.ListMessages(ctx, searchQuery, pageFromRequest(r))
+	data := AdminListTemplateData{
+		BaseTemplateData: adminBaseData(ctx),
+		Title:            "Messages",
+		Query:            searchQuery,
+		Searchable:       true,
+		ActionURL:        "/admin/messages/actions",
+		Actions:          []AdminAction{{Value: "delete", Label: "Delete selected messages"}},
+		Columns:          []string{"Body", "Author", "Room", "Created"},
+		Count:            messages.Count,
+	}
+	if err != nil {
+		h.handleFormError(w, r, err, "admin_list.html", &data.BaseTemplateData, &data)
+		return
+	}
+	for _, message := range messages.MessageList {
+		data.Rows = append(data.Rows, AdminRow{
+			ID:      message.ID,
+			EditURL: "/admin/messages/" + strconv.Itoa(int(message.ID)),
+			Cells: []string{
+				utils.Truncate(message.Body, 80),
+				message.User.Username,
+				message.Room.Name,
+				message.Created.Format(adminTimeFormat),
+			},
+		})
+	}
+	data.NextPageURL = nextPageURL(r, messages.NextCursor)
+	h.renderTemplate(w, r, "admin_list.html", data)
+}
+
+func (h *ApiHandler) AdminMessagePage(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := chi.URLParam(r, "id")
+	useCase := domain.Bridge[domain.AdminUseCase](configs.AUDIT_LOGS_DB_NAME, h.useCases)
+	message, err := useCase.GetMessage(ctx, id)
+	if err != nil {
+		h.handleError(w, r, err, "not_found.html", adminBaseData(ctx))
+		return
+	}
+	h.renderTemplate(w, r, "admin_form.html", newAdminMessageFormData(ctx, id, domain.AdminMessageForm{Body: message.Body}))
+}
+
+func (h *ApiHandler) AdminUpdateMessage(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := chi.URLParam(r, "id")
+	form := domain.AdminMessageForm{Body: r.FormValue("body")}
+	useCase := domain.Bridge[domain.AdminUseCase](configs.AUDIT_LOGS_DB_NAME, h.useCases)
+	err := useCase.UpdateMessage(ctx, id, form)
+	if err != nil {
+		data := newAdminMessageFormData(ctx, id, form)
+		h.handleFormError(w, r, err, "admin_form.html", &data.BaseTemplateData, &data)
+		return
+	}
+	http.Redirect(w, r, "/admin/messages", http.StatusFound)
+}
+
+func (h *ApiHandler) AdminDeleteMessagePage(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	useCase := domain.Bridge[domain.AdminUseCase](configs.AUDIT_LOGS_DB_NAME, h.useCases)
+	message, err := useCase.GetMessage(ctx, chi.URLParam(r, "id"))
+	if err != nil {
+		h.handleError(w, r, err, "not_found.html", adminBaseData(ctx))
+		return
+	}
+	h.renderTemplate(w, r, "delete.html", DeleteForm{BaseTemplateData: adminBaseData(ctx), Obj: utils.Truncate(message.Body, 80)})
+}
+
+func (h *ApiHandler) AdminDeleteMessage(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	err := h.deleteMessagesAsStaff(ctx, []string{chi.URLParam(r, "id")})
+	if err != nil {
+		h.handleError(w, r, err, "admin_error.html", adminBaseData(ctx))
+		return
+	}
+	http.Redirect(w, r, "/admin/messages", http.StatusFound)
+}
+
+func (h *ApiHandler) AdminMessageActions(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	err := r.ParseForm()
+	if err != nil {
+		http.Error(w, "invalid form", http.StatusBadRequest)
+		return
+	}
+	switch r.PostForm.Get("action") {
+	case "delete":
+		err = h.deleteMessagesAsStaff(ctx, r.PostForm["ids"])
+	default:
+		err = h.errHandler.New(http.StatusBadRequest, "unknown action")
+	}
+	if err != nil {
+		h.handleError(w, r, err, "admin_error.html", adminBaseData(ctx))
+		return
+	}
+	http.Redirect(w, r, "/admin/messages", http.StatusFound)
+}
+
+// deleteMessagesAsStaff also tells the open rooms to drop the messages, the
+// same way a regular delete does.
+func (h *ApiHandler) deleteMessagesAsStaff(ctx context.Context, ids []string) error {
+	useCase := domain.Bridge[domain.AdminUseCase](configs.AUDIT_LOGS_DB_NAME, h.useCases)
+	messages, err := useCase.DeleteMessages(ctx, ids)
+	if err != nil {
+		return err
+	}
+	sv := ctx.Value(configs.UserCtxKey).(domain.SessionValue)
+	for _, message := range messages {
+		h.publishRoomEvent(ctx, newMessageEvent(domain.MessageDeletedEvent, domain.Message{ID: message.ID, RoomID: message.RoomID}, sv))
+	}
+	return nil
+}
+
+func (h *ApiHandler) AdminTopics(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	searchQuery := r.URL.Query().Get("q")
+	useCase := domain.Bridge[domain.AdminUseCase](configs.AUDIT_LOGS_DB_NAME, h.useCases)
+	topics, err := useCase.ListTopics(ctx, searchQuery, pageFromRequest(r))
+	data := AdminListTemplateData{
+		BaseTemplateData: adminBaseData(ctx),
+		Title:            "Topics",
+		Query:            searchQuery,
+		Searchable:       true,
+		ActionURL:        "/admin/topics/actions",
+		Actions: []AdminAction{
+			{Value: "merge", Label: "Merge selected topics into"},
+			{Value: "delete", Label: "Delete selected topics and their rooms"},
+		},
+		TargetLabel: "Topic to merge into",
+		Columns:     []string{"Name", "Rooms"},
+		Count:       topics.Count,
+	}
+	if err != nil {
+		h.handleFormError(w, r, err, "admin_list.html", &data.BaseTemplateData, &data)
+		return
+	}
+	for _, topic := range topics.List {
+		data.Rows = append(data.Rows, AdminRow{
+			ID:      topic.ID,
+			EditURL: "/admin/topics/" + strconv.Itoa(int(topic.ID)),
+			Cells:   []string{topic.Name, strconv.FormatInt(topic.RoomCount, 10)},
+		})
+	}
+	data.NextPageURL = nextPageURL(r, topics.NextCursor)
+	h.renderTemplate(w, r, "admin_list.html", data)
+}
+
+func (h *ApiHandler) AdminTopicPage(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := chi.URLParam(r, "id")
+	useCase := domain.Bridge[domain.AdminUseCase](configs.AUDIT_LOGS_DB_NAME, h.useCases)
+	topic, err := useCase.GetTopic(ctx, id)
+	if err != nil {
+		h.handleError(w, r, err, "not_found.html", adminBaseData(ctx))
+		return
+	}
+	h.renderTemplate(w, r, "admin_form.html", newAdminTopicFormData(ctx, id, domain.AdminTopicForm{Name: topic.Name}))
+}
+
+func (h *ApiHandler) AdminUpdateTopic(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := chi.URLParam(r, "id")
+	form := domain.AdminTopicForm{Name: r.FormValue("name")}
+	useCase := domain.Bridge[domain.AdminUseCase](configs.AUDIT_LOGS_DB_NAME, h.useCases)
+	err := useCase.UpdateTopic(ctx, id, form)
+	if err != nil {
+		data := newAdminTopicFormData(ctx, id, form)
+		h.handleFormError(w, r, err, "admin_form.html", &data.BaseTemplateData, &data)
+		return
+	}
+	http.Redirect(w, r, "/admin/topics", http.StatusFound)
+}
+
+func (h *ApiHandler) AdminDeleteTopicPage(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	useCase := domain.Bridge[domain.AdminUseCase](configs.AUDIT_LOGS_DB_NAME, h.useCases)
+	topic, err := useCase.GetTopic(ctx, chi.URLParam(r, "id"))
+	if err != nil {
+		h.handleError(w, r, err, "not_found.html", adminBaseData(ctx))
+		return
+	}
+	h.renderTemplate(w, r, "delete.html", DeleteForm{BaseTemplateData: adminBaseData(ctx), Obj: topic.Name + " and all of its rooms"})
+}
+
+func (h *ApiHandler) AdminDeleteTopic(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	useCase := domain.Bridge[domain.AdminUseCase](configs.AUDIT_LOGS_DB_NAME, h.useCases)
+	err := useCase.DeleteTopics(ctx, []string{chi.URLParam(r, "id")})
+	if err != nil {
+		h.handleError(w, r, err, "admin_error.html", adminBaseData(ctx))
+		return
+	}
+	http.Redirect(w, r, "/admin/topics", http.StatusFound)
+}
+
+func (h *ApiHandler) AdminTopicActions(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	err := r.ParseForm()
+	if err != nil {
+		http.Error(w, "invalid form", http.StatusBadRequest)
+		return
+	}
+	useCase := domain.Bridge[domain.AdminUseCase](configs.AUDIT_LOGS_DB_NAME, h.useCases)
+	switch r.PostForm.Get("action") {
+	case "merge":
+		err = useCase.MergeTopics(ctx, r.PostForm["ids"], r.PostForm.Get("target"))
+	case "delete":
+		err = useCase.DeleteTopics(ctx, r.PostForm["ids"])
+	default:
+		err = h.errHandler.New(http.StatusBadRequest, "unknown action")
+	}
+	if err != nil {
+		h.handleError(w, r, err, "admin_error.html", adminBaseData(ctx))
+		return
+	}
+	http.Redirect(w, r, "/admin/topics", http.StatusFound)
+}
+
+func adminBaseData(ctx context.Context) BaseTemplateData {
+	sessionValue := ctx.Value(configs.UserCtxKey).(domain.SessionValue)
+	return BaseTemplateData{
+		IsAuthenticated: true,
+		Username:        sessionValue.Username,
+		AvatarURL:       sessionValue.Avatar,
+	}
+}
+
+func newAuditLogListData(logs domain.AuditLogs) AdminListTemplateData {
+	data := AdminListTemplateData{
+		Title:   "Audit log",
+		Columns: []string{"When", "Staff", "Action", "Object", "Detail"},
+		Count:   logs.Count,
+	}
+	for _, entry := range logs.List {
+		data.Rows = append(data.Rows, AdminRow{
+			ID: entry.ID,
+			Cells: []string{
+				entry.Created.Format(adminTimeFormat),
+				entry.Actor.Username,
+				entry.Action,
+				entry.ObjectType + " #" + entry.ObjectID,
+				entry.Detail,
+			},
+		})
+	}
+	return data
+}
+
+func newAdminUserFormData(ctx context.Context, id string, form domain.AdminUserForm) AdminFormTemplateData {
+	return AdminFormTemplateData{
+		BaseTemplateData: adminBaseData(ctx),
+		Title:            "Edit user",
+		BackURL:          "/admin/users",
+		DeleteURL:        "/admin/users/" + id + "/delete",
+		Fields: []AdminField{
+			{Name: "username", Label: "Username", Type: "text", Value: form.Username},
+			{Name: "email", Label: "Email", Type: "email", Value: form.Email},
+			{Name: "name", Label: "Name", Type: "text", Value: form.Name},
+			{Name: "bio", Label: "Bio", Type: "textarea", Value: form.Bio},
+			{Name: "is_active", Label: "Active", Type: "checkbox", Checked: form.IsActive},
+			{Name: "is_staff", Label: "Staff", Type: "checkbox", Checked: form.IsStaff},
+			{Name: "is_superuser", Label: "Superuser", Type: "checkbox", Checked: form.IsSuperuser},
+			{Name: "require_totp", Label: "Require two-factor authentication", Type: "checkbox", Checked: form.RequireTOTP},
+		},
+	}
+}
+
+func newAdminRoomFormData(ctx context.Context, id string, form domain.RoomForm) AdminFormTemplateData {
+	return AdminFormTemplateData{
+		BaseTemplateData: adminBaseData(ctx),
+		Title:            "Edit room",
+		BackURL:          "/admin/rooms",
+		DeleteURL:        "/admin/rooms/" + id + "/delete",
+		Fields: []AdminField{
+			{Name: "name", Label: "Name", Type: "text", Value: form.Name},
+			{Name: "topic", Label: "Topic", Type: "text", Value: form.TopicName},
+			{Name: "description", Label: "Description", Type: "textarea", Value: form.Description},
+		},
+	}
+}
+
+func newAdminMessageFormData(ctx context.Context, id string, form domain.AdminMessageForm) AdminFormTemplateData {
+	return AdminFormTemplateData{
+		BaseTemplateData: adminBaseData(ctx),
+		Title:            "Edit message",
+		BackURL:          "/admin/messages",
+		DeleteURL:        "/admin/messages/" + id + "/delete",
+		Fields: []AdminField{
+			{Name: "body", Label: "Body", Type: "textarea", Value: form.Body},
+		},
+	}
+}
+
+func newAdminTopicFormData(ctx context.Context, id string, form domain.AdminTopicForm) AdminFormTemplateData {
+	return AdminFormTemplateData{
+		BaseTemplateData: adminBaseData(ctx),
+		Title:            "Edit topic",
+		BackURL:          "/admin/topics",
+		DeleteURL:        "/admin/topics/" + id + "/delete",
+		Fields: []AdminField{
+			{Name: "name", Label: "Name", Type: "text", Value: form.Name},
+		},
+	}
+}
+
+func yesNo(value bool) string {
+	if value {
+		return "yes"
+	}
+	return "no"
+}
+
+// roomHostCell names the host of room, or tells that it was archived after
+// losing its host.
+func roomHostCell(room domain.Room) string {
+	if room.Archived {
+		return "(archived)"
+	}
+	return room.Host.Username
+}
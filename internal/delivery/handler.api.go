@@ -0,0 +1,799 @@
+package delivery
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/elyarsadig/studybud-go/configs"
+	"github.com/elyarsadig/studybud-go/internal/domain"
+	"github.com/elyarsadig/studybud-go/pkg/errorHandler"
+	"github.com/go-chi/chi/v5"
+)
+
+func (h *ApiHandler) APIProtectedHandler(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sessionValue, ok := h.extractSession(r)
+		if !ok {
+			h.writeJSONError(w, h.errHandler.New(http.StatusUnauthorized, "authentication required"))
+			return
+		}
+		pending, err := h.twoFactorSetupPending(r, sessionValue)
+		if err == nil && pending {
+			err = h.errHandler.New(http.StatusForbidden, "set up two-factor authentication on the website first")
+		}
+		if err != nil {
+			h.writeJSONError(w, err)
+			return
+		}
+		ctx := context.WithValue(r.Context(), configs.UserCtxKey, sessionValue)
+		next(w, r.WithContext(ctx))
+	}
+}
+
+func (h *ApiHandler) APIRequirePermission(perm string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		sessionValue := ctx.Value(configs.UserCtxKey).(domain.SessionValue)
+		err := h.checkPermission(ctx, sessionValue, perm)
+		if err != nil {
+			h.writeJSONError(w, err)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// extractSession resolves the caller from a personal access token already
+// checked by TokenAuth, an "Authorization: Bearer" session token or the
+// session cookie used by the web pages, in that order.
+func (h *ApiHandler) extractSession(r *http.Request) (domain.SessionValue, bool) {
+	if sessionValue, ok := r.Context().Value(configs.UserCtxKey).(domain.SessionValue); ok {
+		return sessionValue, true
+	}
+	if token, ok := bearerToken(r); ok {
+		return h.resolveSessionToken(r.Context(), token)
+	}
+	return h.extractSessionFromCookie(r)
+}
+
+// optionalSession returns the request context with the caller's session when
+// they are authenticated. Public endpoints use it so the caller also sees the
+// private rooms they belong to.
+func (h *ApiHandler) optionalSession(r *http.Request) context.Context {
+	if sessionValue, ok := h.extractSession(r); ok {
+		return context.WithValue(r.Context(), configs.UserCtxKey, sessionValue)
+	}
+	return r.Context()
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	header := r.Header.Get("Authorization")
+	scheme, token, found := strings.Cut(header, " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") || len(token) == 0 {
+		return "", false
+	}
+	return strings.TrimSpace(token), true
+}
+
+func (h *ApiHandler) writeJSON(w http.ResponseWriter, status int, data any) {
+	if data == nil {
+		w.WriteHeader(status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	err := json.NewEncoder(w).Encode(data)
+	if err != nil {
+		h.logger.Error(err.Error())
+	}
+}
+
+func (h *ApiHandler) writeJSONError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	message := "something went wrong!"
+	errWithDetails, ok := err.(*errorHandler.Error)
+	if ok {
+		status = errWithDetails.HTTPStatus()
+		message = errWithDetails.Error()
+	} else {
+		h.logger.Error(err.Error())
+	}
+	h.writeJSON(w, status, ErrorResponse{Error: ErrorBody{Status: status, Message: message}})
+}
+
+func (h *ApiHandler) decodeJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	err := json.NewDecoder(r.Body).Decode(v)
+	if err != nil {
+		h.writeJSONError(w, h.errHandler.New(http.StatusBadRequest, "invalid request body"))
+		return false
+	}
+	return true
+}
+
+func (h *ApiHandler) APILogin(w http.ResponseWriter, r *http.Request) {
+	var req LoginRequest
+	if !h.decodeJSON(w, r, &req) {
+		return
+	}
+	useCase := domain.Bridge[domain.UserUseCase](configs.USERS_DB_NAME, h.useCases)
+	result, err := useCase.Login(withClientInfo(r), &domain.UserLoginForm{Email: req.Email, Password: req.Password, IP: clientIP(r)})
+	if err != nil {
+		h.writeJSONError(w, err)
+		return
+	}
+	if result.TwoFactorToken != "" {
+		h.writeJSON(w, http.StatusOK, TwoFactorChallengeResponse{
+			TwoFactorRequired: true,
+			TwoFactorToken:    result.TwoFactorToken,
+		})
+		return
+	}
+	h.writeJSON(w, http.StatusOK, TokenResponse{
+		Token:                  h.encodeSessionToken(result.SessionKey),
+		TwoFactorSetupRequired: result.SetupTwoFactor,
+	})
+}
+
+// APILoginTwoFactor finishes a login that answered with a two-factor token.
+func (h *ApiHandler) APILoginTwoFactor(w http.ResponseWriter, r *http.Request) {
+	var req TwoFactorLoginRequest
+	if !h.decodeJSON(w, r, &req) {
+		return
+	}
+	useCase := domain.Bridge[domain.UserUseCase](configs.USERS_DB_NAME, h.useCases)
+	sessionKey, err := useCase.VerifyTwoFactorLogin(withClientInfo(r), req.Token, &domain.TwoFactorForm{Code: req.Code})
+	if err != nil {
+		h.writeJSONError(w, err)
+		return
+	}
+	h.writeJSON(w, http.StatusOK, TokenResponse{Token: h.encodeSessionToken(sessionKey)})
+}
+
+func (h *ApiHandler) APIRegister(w http.ResponseWriter, r *http.Request) {
+	var req RegisterRequest
+	if !h.decodeJSON(w, r, &req) {
+		return
+	}
+	form := &domain.UserRegisterForm{
+		Name:      req.Name,
+		Username:  req.Username,
+		Email:     req.Email,
+		Password1: req.Password1,
+		Password2: req.Password2,
+	}
+	useCase := domain.Bridge[domain.UserUseCase](configs.USERS_DB_NAME, h.useCases)
+	sessionKey, err := useCase.RegisterUser(withClientInfo(r), form)
+	if err != nil {
+		h.writeJSONError(w, err)
+		return
+	}
+	h.writeJSON(w, http.StatusCreated, TokenResponse{Token: h.encodeSessionToken(sessionKey)})
+}
+
+func (h *ApiHandler) APILogout(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	useCase := domain.Bridge[domain.UserUseCase](configs.USERS_DB_NAME, h.useCases)
+	err := useCase.Logout(ctx)
+	if err != nil {
+		h.writeJSONError(w, err)
+		return
+	}
+	h.writeJSON(w, http.StatusNoContent, nil)
+}
+
+// APIForgotPassword always answers 202 for a well formed request, whether or
+// not the email belongs to an account.
+func (h *ApiHandler) APIForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var req ForgotPasswordRequest
+	if !h.decodeJSON(w, r, &req) {
+		return
+	}
+	useCase := domain.Bridge[domain.UserUseCase](configs.USERS_DB_NAME, h.useCases)
+	err := useCase.RequestPasswordReset(r.Context(), req.Email)
+	if err != nil {
+		h.writeJSONError(w, err)
+		return
+	}
+	h.writeJSON(w, http.StatusAccepted, nil)
+}
+
+func (h *ApiHandler) APIResetPassword(w http.ResponseWriter, r *http.Request) {
+	var req ResetPasswordRequest
+	if !h.decodeJSON(w, r, &req) {
+		return
+	}
+	form := &domain.PasswordResetForm{
+		Token:     req.Token,
+		Password1: req.Password1,
+		Password2: req.Password2,
+	}
+	useCase := domain.Bridge[domain.UserUseCase](configs.USERS_DB_NAME, h.useCases)
+	err := useCase.ResetPassword(r.Context(), form)
+	if err != nil {
+		h.writeJSONError(w, err)
+		return
+	}
+	h.writeJSON(w, http.StatusNoContent, nil)
+}
+
+// APIVerifyEmail confirms the address from an emailed token. Authentication is
+// optional, when present the caller's session is rotated and the new token is
+// returned.
+func (h *ApiHandler) APIVerifyEmail(w http.ResponseWriter, r *http.Request) {
+	var req VerifyEmailRequest
+	if !h.decodeJSON(w, r, &req) {
+		return
+	}
+	ctx := r.Context()
+	if sessionValue, ok := h.extractSession(r); ok {
+		ctx = context.WithValue(ctx, configs.UserCtxKey, sessionValue)
+	}
+	useCase := domain.Bridge[domain.UserUseCase](configs.USERS_DB_NAME, h.useCases)
+	_, sessionKey, err := useCase.VerifyEmail(ctx, req.Token)
+	if err != nil {
+		h.writeJSONError(w, err)
+		return
+	}
+	if sessionKey != "" {
+		h.writeJSON(w, http.StatusOK, TokenResponse{Token: h.encodeSessionToken(sessionKey)})
+		return
+	}
+	h.writeJSON(w, http.StatusNoContent, nil)
+}
+
+func (h *ApiHandler) APIResendVerification(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	sv := ctx.Value(configs.UserCtxKey).(domain.SessionValue)
+	useCase := domain.Bridge[domain.UserUseCase](configs.USERS_DB_NAME, h.useCases)
+	err := useCase.SendEmailVerification(ctx, strconv.Itoa(sv.ID))
+	if err != nil {
+		h.writeJSONError(w, err)
+		return
+	}
+	h.writeJSON(w, http.StatusAccepted, nil)
+}
+
+func (h *ApiHandler) APICurrentUser(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	sv := ctx.Value(configs.UserCtxKey).(domain.SessionValue)
+	useCase := domain.Bridge[domain.UserUseCase](configs.USERS_DB_NAME, h.useCases)
+	user, err := useCase.GetUserByEmail(ctx, sv.Email)
+	if err != nil {
+		h.writeJSONError(w, err)
+		return
+	}
+	response := newUserResponse(user)
+	response.Email = user.Email
+	verified := !user.PendingEmailVerification
+	response.Verified = &verified
+	h.writeJSON(w, http.StatusOK, response)
+}
+
+func (h *ApiHandler) APIListSessions(w http.ResponseWriter, r *http.Request) {
+	useCase := domain.Bridge[domain.UserUseCase](configs.USERS_DB_NAME, h.useCases)
+	sessions, err := useCase.ListSessions(r.Context())
+	if err != nil {
+		h.writeJSONError(w, err)
+		return
+	}
+	items := make([]SessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		items = append(items, newSessionResponse(session))
+	}
+	h.writeJSON(w, http.StatusOK, items)
+}
+
+func (h *ApiHandler) APIRevokeSession(w http.ResponseWriter, r *http.Request) {
+	useCase := domain.Bridge[domain.UserUseCase](configs.USERS_DB_NAME, h.useCases)
+	err := useCase.RevokeSession(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		h.writeJSONError(w, err)
+		return
+	}
+	h.writeJSON(w, http.StatusNoContent, nil)
+}
+
+// APIRevokeAllSessions logs the caller out everywhere, including the token
+// used for this request.
+func (h *ApiHandler) APIRevokeAllSessions(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	sv := ctx.Value(configs.UserCtxKey).(domain.SessionValue)
+	useCase := domain.Bridge[domain.UserUseCase](configs.USERS_DB_NAME, h.useCases)
+	err := useCase.RevokeAllSessions(ctx, strconv.Itoa(sv.ID))
+	if err != nil {
+		h.writeJSONError(w, err)
+		return
+	}
+	h.writeJSON(w, http.StatusNoContent, nil)
+}
+
+func (h *ApiHandler) APIListTokens(w http.ResponseWriter, r *http.Request) {
+	useCase := domain.Bridge[domain.TokenUseCase](configs.PERSONAL_ACCESS_TOKENS_DB_NAME, h.useCases)
+	tokens, err := useCase.ListTokens(r.Context())
+	if err != nil {
+		h.writeJSONError(w, err)
+		return
+	}
+	items := make([]PersonalTokenResponse, 0, len(tokens))
+	for _, token := range tokens {
+		items = append(items, newPersonalTokenResponse(token))
+	}
+	h.writeJSON(w, http.StatusOK, items)
+}
+
+func (h *ApiHandler) APICreateToken(w http.ResponseWriter, r *http.Request) {
+	var req CreateTokenRequest
+	if !h.decodeJSON(w, r, &req) {
+		return
+	}
+	useCase := domain.Bridge[domain.TokenUseCase](configs.PERSONAL_ACCESS_TOKENS_DB_NAME, h.useCases)
+	token, value, err := useCase.CreateToken(r.Context(), &domain.TokenForm{
+		Name:   req.Name,
+		Scopes: req.Scopes,
+	})
+	if err != nil {
+		h.writeJSONError(w, err)
+		return
+	}
+	response := newPersonalTokenResponse(token)
+	response.Token = value
+	h.writeJSON(w, http.StatusCreated, response)
+}
+
+func (h *ApiHandler) APIRevokeToken(w http.ResponseWriter, r *http.Request) {
+	useCase := domain.Bridge[domain.TokenUseCase](configs.PERSONAL_ACCESS_TOKENS_DB_NAME, h.useCases)
+	err := useCase.RevokeToken(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		h.writeJSONError(w, err)
+		return
+	}
+	h.writeJSON(w, http.StatusNoContent, nil)
+}
+
+func (h *ApiHandler) APIListNotifications(w http.ResponseWriter, r *http.Request) {
+	useCase := domain.Bridge[domain.NotificationUseCase](configs.NOTIFICATIONS_DB_NAME, h.useCases)
+	notifications, err := useCase.ListNotifications(r.Context(), pageFromRequest(r))
+	if err != nil {
+		h.writeJSONError(w, err)
+		return
+	}
+	h.writeJSON(w, http.StatusOK, newNotificationListResponse(notifications))
+}
+
+func (h *ApiHandler) APIReadNotification(w http.ResponseWriter, r *http.Request) {
+	useCase := domain.Bridge[domain.NotificationUseCase](configs.NOTIFICATIONS_DB_NAME, h.useCases)
+	notification, err := useCase.OpenNotification(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		h.writeJSONError(w, err)
+		return
+	}
+	h.writeJSON(w, http.StatusOK, newNotificationResponse(notification))
+}
+
+func (h *ApiHandler) APIMarkAllNotificationsRead(w http.ResponseWriter, r *http.Request) {
+	useCase := domain.Bridge[domain.NotificationUseCase](configs.NOTIFICATIONS_DB_NAME, h.useCases)
+	err := useCase.MarkAllRead(r.Context())
+	if err != nil {
+		h.writeJSONError(w, err)
+		return
+	}
+	h.writeJSON(w, http.StatusNoContent, nil)
+}
+
+func (h *ApiHandler) APIUpdateCurrentUser(w http.ResponseWriter, r *http.Request) {
+	var req UpdateUserRequest
+	if !h.decodeJSON(w, r, &req) {
+		return
+	}
+	updateUser := &domain.UpdateUser{
+		Name:     req.Name,
+		Username: req.Username,
+		Bio:      req.Bio,
+		Avatar:   req.Avatar,
+	}
+	useCase := domain.Bridge[domain.UserUseCase](configs.USERS_DB_NAME, h.useCases)
+	sessionKey, err := useCase.UpdateInfo(r.Context(), updateUser)
+	if err != nil {
+		h.writeJSONError(w, err)
+		return
+	}
+	if sessionKey == "" {
+		h.writeJSON(w, http.StatusNoContent, nil)
+		return
+	}
+	h.writeJSON(w, http.StatusOK, TokenResponse{Token: h.encodeSessionToken(sessionKey)})
+}
+
+func (h *ApiHandler) APIGetUser(w http.ResponseWriter, r *http.Request) {
+	useCase := domain.Bridge[domain.UserUseCase](configs.USERS_DB_NAME, h.useCases)
+	user, err := useCase.GetUserById(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		h.writeJSONError(w, err)
+		return
+	}
+	h.writeJSON(w, http.StatusOK, newUserResponse(user))
+}
+
+func (h *ApiHandler) APIListUserRooms(w http.ResponseWriter, r *http.Request) {
+	useCase := domain.Bridge[domain.RoomUseCase](configs.ROOMS_DB_NAME, h.useCases)
+	rooms, err := useCase.ListUserRooms(h.optionalSession(r), chi.URLParam(r, "id"), pageFromRequest(r))
+	if err != nil {
+		h.writeJSONError(w, err)
+		return
+	}
+	h.writeJSON(w, http.StatusOK, newRoomListResponse(rooms))
+}
+
+func (h *ApiHandler) APIListUserMessages(w http.ResponseWriter, r *http.Request) {
+	useCase := domain.Bridge[domain.MessageUseCase](configs.MESSAGES_DB_NAME, h.useCases)
+	messages, err := useCase.ListUserMessages(h.optionalSession(r), chi.URLParam(r, "id"), pageFromRequest(r))
+	if err != nil {
+		h.writeJSONError(w, err)
+		return
+	}
+	h.writeJSON(w, http.StatusOK, newMessageListResponse(messages))
+}
+
+func (h *ApiHandler) APIListTopics(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	useCase := domain.Bridge[domain.TopicUseCase](configs.TOPICS_DB_NAME, h.useCases)
+	name := r.URL.Query().Get("q")
+	var topics domain.Topics
+	var err error
+	if len(name) == 0 {
+		topics, err = useCase.ListAllTopics(ctx, pageFromRequest(r))
+	} else {
+		topics, err = useCase.SearchTopicByName(ctx, name, pageFromRequest(r))
+	}
+	if err != nil {
+		h.writeJSONError(w, err)
+		return
+	}
+	h.writeJSON(w, http.StatusOK, newTopicListResponse(topics))
+}
+
+func (h *ApiHandler) APIListRooms(w http.ResponseWriter, r *http.Request) {
+	useCase := domain.Bridge[domain.RoomUseCase](configs.ROOMS_DB_NAME, h.useCases)
+	rooms, err := useCase.ListRooms(h.optionalSession(r), r.URL.Query().Get("q"), pageFromRequest(r))
+	if err != nil {
+		h.writeJSONError(w, err)
+		return
+	}
+	h.writeJSON(w, http.StatusOK, newRoomListResponse(rooms))
+}
+
+func (h *ApiHandler) APICreateRoom(w http.ResponseWriter, r *http.Request) {
+	var req RoomRequest
+	if !h.decodeJSON(w, r, &req) {
+		return
+	}
+	useCase := domain.Bridge[domain.RoomUseCase](configs.ROOMS_DB_NAME, h.useCases)
+	err := useCase.CreateRoom(r.Context(), domain.RoomForm{
+		TopicName:   req.Topic,
+		Name:        req.Name,
+		Description: req.Description,
+		Visibility:  req.Visibility,
+	})
+	if err != nil {
+		h.writeJSONError(w, err)
+		return
+	}
+	h.writeJSON(w, http.StatusCreated, nil)
+}
+
+func (h *ApiHandler) APIGetRoom(w http.ResponseWriter, r *http.Request) {
+	ctx := h.optionalSession(r)
+	roomID := chi.URLParam(r, "id")
+	useCase := domain.Bridge[domain.RoomUseCase](configs.ROOMS_DB_NAME, h.useCases)
+	room, err := useCase.GetRoomById(ctx, roomID)
+	if err != nil {
+		h.writeJSONError(w, err)
+		return
+	}
+	participants, err := useCase.ListRoomParticipants(ctx, roomID)
+	if err != nil {
+		h.writeJSONError(w, err)
+		return
+	}
+	h.writeJSON(w, http.StatusOK, newRoomResponse(room, int64(len(participants))))
+}
+
+func (h *ApiHandler) APIUpdateRoom(w http.ResponseWriter, r *http.Request) {
+	var req RoomRequest
+	if !h.decodeJSON(w, r, &req) {
+		return
+	}
+	ctx := r.Context()
+	roomID := chi.URLParam(r, "id")
+	useCase := domain.Bridge[domain.RoomUseCase](configs.ROOMS_DB_NAME, h.useCases)
+	_, err := useCase.GetUserRoom(ctx, roomID)
+	if err != nil {
+		h.writeJSONError(w, err)
+		return
+	}
+	err = useCase.UpdateRoom(ctx, roomID, domain.RoomForm{
+		TopicName:   req.Topic,
+		Name:        req.Name,
+		Description: req.Description,
+		Visibility:  req.Visibility,
+	})
+	if err != nil {
+		h.writeJSONError(w, err)
+		return
+	}
+	h.publishAccessChanged(ctx, roomID, 0)
+	h.writeJSON(w, http.StatusNoContent, nil)
+}
+
+func (h *ApiHandler) APIDeleteRoom(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	roomID := chi.URLParam(r, "id")
+	useCase := domain.Bridge[domain.RoomUseCase](configs.ROOMS_DB_NAME, h.useCases)
+	_, err := useCase.GetUserRoom(ctx, roomID)
+	if err != nil {
+		h.writeJSONError(w, err)
+		return
+	}
+	err = useCase.DeleteUserRoom(ctx, roomID)
+	if err != nil {
+		h.writeJSONError(w, err)
+		return
+	}
+	h.writeJSON(w, http.StatusNoContent, nil)
+}
+
+func (h *ApiHandler) APIListRoomParticipants(w http.ResponseWriter, r *http.Request) {
+	useCase := domain.Bridge[domain.RoomUseCase](configs.ROOMS_DB_NAME, h.useCases)
+	participants, err := useCase.ListRoomParticipants(h.optionalSession(r), chi.URLParam(r, "id"))
+	if err != nil {
+		h.writeJSONError(w, err)
+		return
+	}
+	items := make([]ParticipantResponse, 0, len(participants))
+	for _, participant := range participants {
+		items = append(items, newParticipantResponse(participant))
+	}
+	h.writeJSON(w, http.StatusOK, ListResponse[ParticipantResponse]{Items: items, Count: int64(len(items))})
+}
+
+func (h *ApiHandler) APISetParticipantRole(w http.ResponseWriter, r *http.Request) {
+	var req ParticipantRoleRequest
+	if !h.decodeJSON(w, r, &req) {
+		return
+	}
+	useCase := domain.Bridge[domain.RoomUseCase](configs.ROOMS_DB_NAME, h.useCases)
+	err := useCase.SetParticipantRole(r.Context(), chi.URLParam(r, "id"), chi.URLParam(r, "userID"), req.Role)
+	if err != nil {
+		h.writeJSONError(w, err)
+		return
+	}
+	h.writeJSON(w, http.StatusNoContent, nil)
+}
+
+func (h *ApiHandler) APIMuteParticipant(w http.ResponseWriter, r *http.Request) {
+	h.apiSetParticipantMuted(w, r, true)
+}
+
+func (h *ApiHandler) APIUnmuteParticipant(w http.ResponseWriter, r *http.Request) {
+	h.apiSetParticipantMuted(w, r, false)
+}
+
+func (h *ApiHandler) apiSetParticipantMuted(w http.ResponseWriter, r *http.Request, muted bool) {
+	useCase := domain.Bridge[domain.RoomUseCase](configs.ROOMS_DB_NAME, h.useCases)
+	err := useCase.MuteParticipant(r.Context(), chi.URLParam(r, "id"), chi.URLParam(r, "userID"), muted)
+	if err != nil {
+		h.writeJSONError(w, err)
+		return
+	}
+	h.writeJSON(w, http.StatusNoContent, nil)
+}
+
+func (h *ApiHandler) APIKickParticipant(w http.ResponseWriter, r *http.Request) {
+	useCase := domain.Bridge[domain.RoomUseCase](configs.ROOMS_DB_NAME, h.useCases)
+	err := useCase.KickParticipant(r.Context(), chi.URLParam(r, "id"), chi.URLParam(r, "userID"))
+	if err != nil {
+		h.writeJSONError(w, err)
+		return
+	}
+	h.publishKick(r)
+	h.writeJSON(w, http.StatusNoContent, nil)
+}
+
+func (h *ApiHandler) APITransferRoom(w http.ResponseWriter, r *http.Request) {
+	var req TransferRoomRequest
+	if !h.decodeJSON(w, r, &req) {
+		return
+	}
+	useCase := domain.Bridge[domain.RoomUseCase](configs.ROOMS_DB_NAME, h.useCases)
+	err := useCase.TransferOwnership(r.Context(), chi.URLParam(r, "id"), strconv.Itoa(int(req.UserID)))
+	if err != nil {
+		h.writeJSONError(w, err)
+		return
+	}
+	h.writeJSON(w, http.StatusNoContent, nil)
+}
+
+func (h *ApiHandler) APIJoinRoom(w http.ResponseWriter, r *http.Request) {
+	useCase := domain.Bridge[domain.RoomUseCase](configs.ROOMS_DB_NAME, h.useCases)
+	err := useCase.JoinRoom(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		h.writeJSONError(w, err)
+		return
+	}
+	h.writeJSON(w, http.StatusNoContent, nil)
+}
+
+func (h *ApiHandler) APILeaveRoom(w http.ResponseWriter, r *http.Request) {
+	useCase := domain.Bridge[domain.RoomUseCase](configs.ROOMS_DB_NAME, h.useCases)
+	err := useCase.LeaveRoom(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		h.writeJSONError(w, err)
+		return
+	}
+	sv := r.Context().Value(configs.UserCtxKey).(domain.SessionValue)
+	h.publishAccessChanged(r.Context(), chi.URLParam(r, "id"), uint(sv.ID))
+	h.writeJSON(w, http.StatusNoContent, nil)
+}
+
+func (h *ApiHandler) APIListJoinedRooms(w http.ResponseWriter, r *http.Request) {
+	useCase := domain.Bridge[domain.RoomUseCase](configs.ROOMS_DB_NAME, h.useCases)
+	rooms, err := useCase.ListJoinedRooms(r.Context(), pageFromRequest(r))
+	if err != nil {
+		h.writeJSONError(w, err)
+		return
+	}
+	h.writeJSON(w, http.StatusOK, newRoomListResponse(rooms))
+}
+
+func (h *ApiHandler) APIListRoomMessages(w http.ResponseWriter, r *http.Request) {
+	useCase := domain.Bridge[domain.MessageUseCase](configs.MESSAGES_DB_NAME, h.useCases)
+	messages, err := useCase.ListRoomMessages(h.optionalSession(r), chi.URLParam(r, "id"), pageFromRequest(r))
+	if err != nil {
+		h.writeJSONError(w, err)
+		return
+	}
+	h.writeJSON(w, http.StatusOK, newMessageListResponse(messages))
+}
+
+func (h *ApiHandler) APIListPinnedMessages(w http.ResponseWriter, r *http.Request) {
+	useCase := domain.Bridge[domain.MessageUseCase](configs.MESSAGES_DB_NAME, h.useCases)
+	messages, err := useCase.ListPinnedMessages(h.optionalSession(r), chi.URLParam(r, "id"))
+	if err != nil {
+		h.writeJSONError(w, err)
+		return
+	}
+	h.writeJSON(w, http.StatusOK, newMessageListResponse(domain.Messages{MessageList: messages, Count: int64(len(messages))}))
+}
+
+func (h *ApiHandler) APIGetThread(w http.ResponseWriter, r *http.Request) {
+	useCase := domain.Bridge[domain.MessageUseCase](configs.MESSAGES_DB_NAME, h.useCases)
+	thread, err := useCase.GetThread(h.optionalSession(r), chi.URLParam(r, "id"))
+	if err != nil {
+		h.writeJSONError(w, err)
+		return
+	}
+	h.writeJSON(w, http.StatusOK, newMessageResponse(thread))
+}
+
+// APICreateMessage posts a message from a JSON body, or from a multipart form
+// with the same fields when files are attached to it.
+func (h *ApiHandler) APICreateMessage(w http.ResponseWriter, r *http.Request) {
+	uploads, closeUploads, err := h.uploadsFromForm(r)
+	if err != nil {
+		h.writeJSONError(w, err)
+		return
+	}
+	defer closeUploads()
+	var req MessageRequest
+	if r.MultipartForm != nil {
+		req = MessageRequest{Body: r.FormValue("body"), ParentID: parentIDFromForm(r)}
+	} else if !h.decodeJSON(w, r, &req) {
+		return
+	}
+	ctx := r.Context()
+	sv := ctx.Value(configs.UserCtxKey).(domain.SessionValue)
+	roomID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		h.writeJSONError(w, h.errHandler.New(http.StatusNotFound, "room not found"))
+		return
+	}
+	roomUseCase := domain.Bridge[domain.RoomUseCase](configs.ROOMS_DB_NAME, h.useCases)
+	_, err = roomUseCase.GetRoomById(ctx, strconv.Itoa(roomID))
+	if err != nil {
+		h.writeJSONError(w, err)
+		return
+	}
+	useCase := domain.Bridge[domain.MessageUseCase](configs.MESSAGES_DB_NAME, h.useCases)
+	message := &domain.Message{RoomID: uint(roomID), Body: req.Body, ParentID: req.ParentID}
+	err = useCase.CreateMessage(ctx, message, uploads...)
+	if err != nil {
+		h.writeJSONError(w, err)
+		return
+	}
+	h.publishRoomEvent(ctx, newMessageEvent(domain.MessageCreatedEvent, *message, sv))
+	message.User = domain.User{ID: uint(sv.ID), Username: sv.Username, Name: sv.Name, Avatar: sv.Avatar}
+	h.writeJSON(w, http.StatusCreated, newMessageResponse(*message))
+}
+
+func (h *ApiHandler) APIEditMessage(w http.ResponseWriter, r *http.Request) {
+	var req MessageRequest
+	if !h.decodeJSON(w, r, &req) {
+		return
+	}
+	ctx := r.Context()
+	sv := ctx.Value(configs.UserCtxKey).(domain.SessionValue)
+	useCase := domain.Bridge[domain.MessageUseCase](configs.MESSAGES_DB_NAME, h.useCases)
+	message, err := useCase.EditMessage(ctx, chi.URLParam(r, "id"), req.Body)
+	if err != nil {
+		h.writeJSONError(w, err)
+		return
+	}
+	h.publishRoomEvent(ctx, newMessageEvent(domain.MessageEditedEvent, message, sv))
+	h.writeJSON(w, http.StatusOK, newMessageResponse(message))
+}
+
+func (h *ApiHandler) APIListMessageRevisions(w http.ResponseWriter, r *http.Request) {
+	useCase := domain.Bridge[domain.MessageUseCase](configs.MESSAGES_DB_NAME, h.useCases)
+	_, revisions, err := useCase.ListRevisions(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		h.writeJSONError(w, err)
+		return
+	}
+	h.writeJSON(w, http.StatusOK, newMessageRevisionListResponse(revisions))
+}
+
+// APIToggleReaction adds the current user's reaction to a message or takes it
+// back, and returns the message with its reactions.
+func (h *ApiHandler) APIToggleReaction(w http.ResponseWriter, r *http.Request) {
+	var req ReactionRequest
+	if !h.decodeJSON(w, r, &req) {
+		return
+	}
+	useCase := domain.Bridge[domain.MessageUseCase](configs.MESSAGES_DB_NAME, h.useCases)
+	message, err := useCase.ToggleReaction(r.Context(), chi.URLParam(r, "id"), req.Emoji)
+	if err != nil {
+		h.writeJSONError(w, err)
+		return
+	}
+	h.writeJSON(w, http.StatusOK, newMessageResponse(message))
+}
+
+func (h *ApiHandler) APIDeleteMessage(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	sv := ctx.Value(configs.UserCtxKey).(domain.SessionValue)
+	id := chi.URLParam(r, "id")
+	useCase := domain.Bridge[domain.MessageUseCase](configs.MESSAGES_DB_NAME, h.useCases)
+	message, err := useCase.GetUserMessage(ctx, id)
+	if err != nil {
+		h.writeJSONError(w, err)
+		return
+	}
+	err = useCase.Delete(ctx, id)
+	if err != nil {
+		h.writeJSONError(w, err)
+		return
+	}
+	h.publishRoomEvent(ctx, newMessageEvent(domain.MessageDeletedEvent, domain.Message{ID: message.ID, RoomID: message.RoomID}, sv))
+	h.writeJSON(w, http.StatusNoContent, nil)
+}
+
+func (h *ApiHandler) APIPinMessage(w http.ResponseWriter, r *http.Request) {
+	h.apiSetMessagePinned(w, r, true)
+}
+
+func (h *ApiHandler) APIUnpinMessage(w http.ResponseWriter, r *http.Request) {
+	h.apiSetMessagePinned(w, r, false)
+}
+
+func (h *ApiHandler) apiSetMessagePinned(w http.ResponseWriter, r *http.Request, pinned bool) {
+	useCase := domain.Bridge[domain.MessageUseCase](configs.MESSAGES_DB_NAME, h.useCases)
+	_, err := useCase.PinMessage(r.Context(), chi.URLParam(r, "id"), pinned)
+	if err != nil {
+		h.writeJSONError(w, err)
+		return
+	}
+	h.writeJSON(w, http.StatusNoContent, nil)
+}
@@ -0,0 +1,424 @@
+package delivery
+
+import (
+	"time"
+
+	"github.com/elyarsadig/studybud-go/internal/domain"
+)
+
+type ErrorResponse struct {
+	Error ErrorBody `json:"error"`
+}
+
+type ErrorBody struct {
+	Status  int    `json:"status"`
+	Message string `json:"message"`
+}
+
+// TokenResponse carries a session token. TwoFactorSetupRequired tells the
+// account must set up two-factor authentication on the website before the
+// token is accepted anywhere.
+type TokenResponse struct {
+	Token                  string `json:"token"`
+	TwoFactorSetupRequired bool   `json:"two_factor_setup_required,omitempty"`
+}
+
+// TwoFactorChallengeResponse answers a correct password for an account with
+// two-factor authentication. The token is sent to auth/login/2fa with a code.
+type TwoFactorChallengeResponse struct {
+	TwoFactorRequired bool   `json:"two_factor_required"`
+	TwoFactorToken    string `json:"two_factor_token"`
+}
+
+type ListResponse[T any] struct {
+	Items      []T    `json:"items"`
+	Count      int64  `json:"count"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+type UserResponse struct {
+	ID         uint      `json:"id"`
+	Username   string    `json:"username"`
+	Name       string    `json:"name"`
+	Email      string    `json:"email,omitempty"`
+	Verified   *bool     `json:"email_verified,omitempty"`
+	Bio        string    `json:"bio"`
+	Avatar     string    `json:"avatar"`
+	DateJoined time.Time `json:"date_joined"`
+}
+
+// ParticipantResponse is a room participant, the user with their role in the
+// room.
+type ParticipantResponse struct {
+	UserResponse
+	Role  string `json:"role"`
+	Muted bool   `json:"muted"`
+}
+
+type SessionResponse struct {
+	ID        string    `json:"id"`
+	UserAgent string    `json:"user_agent"`
+	IP        string    `json:"ip"`
+	Created   time.Time `json:"created"`
+	LastSeen  time.Time `json:"last_seen"`
+	Current   bool      `json:"current"`
+}
+
+// PersonalTokenResponse describes a personal access token. Token is only
+// filled in the response to its creation.
+type PersonalTokenResponse struct {
+	ID       uint       `json:"id"`
+	Name     string     `json:"name"`
+	Prefix   string     `json:"prefix"`
+	Scopes   []string   `json:"scopes"`
+	LastUsed *time.Time `json:"last_used"`
+	Created  time.Time  `json:"created"`
+	Token    string     `json:"token,omitempty"`
+}
+
+type TopicResponse struct {
+	Name      string `json:"name"`
+	RoomCount int64  `json:"room_count"`
+}
+
+type RoomResponse struct {
+	ID                uint         `json:"id"`
+	Name              string       `json:"name"`
+	Description       string       `json:"description"`
+	DescriptionHTML   string       `json:"description_html"`
+	Topic             string       `json:"topic"`
+	Visibility        string       `json:"visibility"`
+	Archived          bool         `json:"archived"`
+	Host              UserResponse `json:"host"`
+	ParticipantsCount int64        `json:"participants_count"`
+	Created           time.Time    `json:"created"`
+	Updated           time.Time    `json:"updated"`
+}
+
+type MessageResponse struct {
+	ID       uint         `json:"id"`
+	RoomID   uint         `json:"room_id"`
+	Body     string       `json:"body"`
+	BodyHTML string       `json:"body_html"`
+	User     UserResponse `json:"user"`
+	Pinned   bool         `json:"pinned"`
+	Created  time.Time    `json:"created"`
+	Updated  time.Time    `json:"updated"`
+
+	// ParentID is set on replies. Messages that start a thread count their
+	// replies, and list them when they were loaded along.
+	ParentID   *uint              `json:"parent_id,omitempty"`
+	ReplyCount int64              `json:"reply_count"`
+	Replies    []MessageResponse  `json:"replies,omitempty"`
+	Edited     *time.Time         `json:"edited,omitempty"`
+	Reactions  []ReactionResponse `json:"reactions,omitempty"`
+
+	Attachments []AttachmentResponse `json:"attachments,omitempty"`
+}
+
+// AttachmentResponse describes a file attached to a message, ThumbnailURL is
+// only set on images that have a thumbnail.
+type AttachmentResponse struct {
+	ID           uint   `json:"id"`
+	Name         string `json:"name"`
+	ContentType  string `json:"content_type"`
+	Size         int64  `json:"size"`
+	URL          string `json:"url"`
+	ThumbnailURL string `json:"thumbnail_url,omitempty"`
+}
+
+// ReactionResponse counts the reactions with one emoji, Reacted tells the
+// current user is one of them.
+type ReactionResponse struct {
+	Emoji   string `json:"emoji"`
+	Count   int64  `json:"count"`
+	Reacted bool   `json:"reacted"`
+}
+
+type ReactionRequest struct {
+	Emoji string `json:"emoji"`
+}
+
+// MessageRevisionResponse is a body a message had until it was edited at
+// Replaced.
+type MessageRevisionResponse struct {
+	ID       uint      `json:"id"`
+	Body     string    `json:"body"`
+	Replaced time.Time `json:"replaced"`
+}
+
+// NotificationResponse tells the current user that Actor did something that
+// concerns them, Message is set for mentions.
+type NotificationResponse struct {
+	ID      uint             `json:"id"`
+	Kind    string           `json:"kind"`
+	Read    bool             `json:"read"`
+	Actor   UserResponse     `json:"actor"`
+	RoomID  uint             `json:"room_id"`
+	Message *MessageResponse `json:"message,omitempty"`
+	Created time.Time        `json:"created"`
+}
+
+type NotificationListResponse struct {
+	ListResponse[NotificationResponse]
+	Unread int64 `json:"unread"`
+}
+
+// SearchHitResponse is a search result. SnippetHTML is an escaped excerpt of
+// its text with the matches wrapped in <mark>.
+type SearchHitResponse[T any] struct {
+	Item        T       `json:"item"`
+	Rank        float32 `json:"rank"`
+	SnippetHTML string  `json:"snippet_html"`
+}
+
+// SearchResponse only holds the kinds of results that were searched.
+type SearchResponse struct {
+	Rooms    *ListResponse[SearchHitResponse[RoomResponse]]    `json:"rooms,omitempty"`
+	Messages *ListResponse[SearchHitResponse[MessageResponse]] `json:"messages,omitempty"`
+	Users    *ListResponse[SearchHitResponse[UserResponse]]    `json:"users,omitempty"`
+}
+
+type LoginRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+type TwoFactorLoginRequest struct {
+	Token string `json:"token"`
+	Code  string `json:"code"`
+}
+
+type RegisterRequest struct {
+	Name      string `json:"name"`
+	Username  string `json:"username"`
+	Email     string `json:"email"`
+	Password1 string `json:"password1"`
+	Password2 string `json:"password2"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+type ResetPasswordRequest struct {
+	Token     string `json:"token"`
+	Password1 string `json:"password1"`
+	Password2 string `json:"password2"`
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token"`
+}
+
+type CreateTokenRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+}
+
+type UpdateUserRequest struct {
+	Name     string `json:"name"`
+	Username string `json:"username"`
+	Bio      string `json:"bio"`
+	Avatar   string `json:"avatar"`
+}
+
+type RoomRequest struct {
+	Topic       string `json:"topic"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// Visibility is one of public, unlisted or private. Rooms are created
+	// public and keep their visibility on update when it is left empty.
+	Visibility string `json:"visibility"`
+}
+
+// MessageRequest posts a message, or a reply in a thread when ParentID is
+// set.
+type MessageRequest struct {
+	Body     string `json:"body"`
+	ParentID *uint  `json:"parent_id,omitempty"`
+}
+
+type TransferRoomRequest struct {
+	UserID uint `json:"user_id"`
+}
+
+// ParticipantRoleRequest appoints a participant, role is one of cohost,
+// moderator or member.
+type ParticipantRoleRequest struct {
+	Role string `json:"role"`
+}
+
+func newUserResponse(user domain.User) UserResponse {
+	return UserResponse{
+		ID:         user.ID,
+		Username:   user.Username,
+		Name:       user.Name,
+		Bio:        user.Bio,
+		Avatar:     user.Avatar,
+		DateJoined: user.DateJoined,
+	}
+}
+
+func newParticipantResponse(participant domain.RoomParticipant) ParticipantResponse {
+	return ParticipantResponse{
+		UserResponse: newUserResponse(participant.User),
+		Role:         participant.Role,
+		Muted:        participant.Muted,
+	}
+}
+
+func newSessionResponse(session domain.Session) SessionResponse {
+	return SessionResponse{
+		ID:        session.ID,
+		UserAgent: session.UserAgent,
+		IP:        session.IP,
+		Created:   session.Created,
+		LastSeen:  session.LastSeen,
+		Current:   session.Current,
+	}
+}
+
+func newPersonalTokenResponse(token domain.PersonalAccessToken) PersonalTokenResponse {
+	return PersonalTokenResponse{
+		ID:       token.ID,
+		Name:     token.Name,
+		Prefix:   token.Prefix,
+		Scopes:   token.ScopeList(),
+		LastUsed: token.LastUsed,
+		Created:  token.Created,
+	}
+}
+
+func newRoomResponse(room domain.Room, participantsCount int64) RoomResponse {
+	return RoomResponse{
+		ID:                room.ID,
+		Name:              room.Name,
+		Description:       room.Description,
+		DescriptionHTML:   string(room.DescriptionHTML),
+		Topic:             room.Topic.Name,
+		Visibility:        room.Visibility,
+		Archived:          room.Archived,
+		Host:              newUserResponse(room.Host),
+		ParticipantsCount: participantsCount,
+		Created:           room.Created,
+		Updated:           room.Updated,
+	}
+}
+
+func newRoomListResponse(rooms domain.Rooms) ListResponse[RoomResponse] {
+	items := make([]RoomResponse, 0, len(rooms.List))
+	for _, room := range rooms.List {
+		items = append(items, newRoomResponse(room.Room, room.ParticipantsCount))
+	}
+	return ListResponse[RoomResponse]{Items: items, Count: rooms.Count, NextCursor: rooms.NextCursor}
+}
+
+func newMessageResponse(message domain.Message) MessageResponse {
+	response := MessageResponse{
+		ID:       message.ID,
+		RoomID:   message.RoomID,
+		Body:     message.Body,
+		BodyHTML: string(message.BodyHTML),
+		User:     newUserResponse(message.User),
+		Pinned:   message.Pinned,
+		Created:  message.Created,
+		Updated:  message.Updated,
+
+		ParentID:   message.ParentID,
+		ReplyCount: message.ReplyCount,
+		Edited:     message.Edited,
+	}
+	for _, reaction := range message.Reactions {
+		response.Reactions = append(response.Reactions, ReactionResponse{Emoji: reaction.Emoji, Count: reaction.Count, Reacted: reaction.Reacted})
+	}
+	for _, attachment := range message.Attachments {
+		response.Attachments = append(response.Attachments, AttachmentResponse{
+			ID:           attachment.ID,
+			Name:         attachment.Name,
+			ContentType:  attachment.ContentType,
+			Size:         attachment.Size,
+			URL:          attachmentURL(attachment),
+			ThumbnailURL: thumbnailURL(attachment),
+		})
+	}
+	for _, reply := range message.Replies {
+		response.Replies = append(response.Replies, newMessageResponse(reply))
+	}
+	return response
+}
+
+func newMessageListResponse(messages domain.Messages) ListResponse[MessageResponse] {
+	items := make([]MessageResponse, 0, len(messages.MessageList))
+	for _, message := range messages.MessageList {
+		items = append(items, newMessageResponse(message))
+	}
+	return ListResponse[MessageResponse]{Items: items, Count: messages.Count, NextCursor: messages.NextCursor}
+}
+
+func newMessageRevisionListResponse(revisions []domain.MessageRevision) ListResponse[MessageRevisionResponse] {
+	items := make([]MessageRevisionResponse, 0, len(revisions))
+	for _, revision := range revisions {
+		items = append(items, MessageRevisionResponse{ID: revision.ID, Body: revision.Body, Replaced: revision.Created})
+	}
+	return ListResponse[MessageRevisionResponse]{Items: items, Count: int64(len(revisions))}
+}
+
+func newNotificationResponse(notification domain.Notification) NotificationResponse {
+	response := NotificationResponse{
+		ID:      notification.ID,
+		Kind:    notification.Kind,
+		Read:    notification.Read,
+		Actor:   newUserResponse(notification.Actor),
+		RoomID:  notification.RoomID,
+		Created: notification.Created,
+	}
+	if notification.Message != nil {
+		message := newMessageResponse(*notification.Message)
+		response.Message = &message
+	}
+	return response
+}
+
+func newNotificationListResponse(notifications domain.Notifications) NotificationListResponse {
+	items := make([]NotificationResponse, 0, len(notifications.List))
+	for _, notification := range notifications.List {
+		items = append(items, newNotificationResponse(notification))
+	}
+	return NotificationListResponse{
+		ListResponse: ListResponse[NotificationResponse]{Items: items, Count: notifications.Count, NextCursor: notifications.NextCursor},
+		Unread:       notifications.Unread,
+	}
+}
+
+func newTopicListResponse(topics domain.Topics) ListResponse[TopicResponse] {
+	items := make([]TopicResponse, 0, len(topics.List))
+	for _, topic := range topics.List {
+		items = append(items, TopicResponse{Name: topic.Name, RoomCount: topic.RoomCount})
+	}
+	return ListResponse[TopicResponse]{Items: items, Count: topics.Count, NextCursor: topics.NextCursor}
+}
+
+func newSearchResponse(filter domain.SearchFilter, results domain.SearchResults) SearchResponse {
+	var response SearchResponse
+	if filter.Kind == "" || filter.Kind == domain.SearchRooms {
+		response.Rooms = newSearchHitsResponse(results.Rooms, func(room domain.RoomWithDetails) RoomResponse {
+			return newRoomResponse(room.Room, room.ParticipantsCount)
+		})
+	}
+	if filter.Kind == "" || filter.Kind == domain.SearchMessages {
+		response.Messages = newSearchHitsResponse(results.Messages, newMessageResponse)
+	}
+	if (filter.Kind == "" || filter.Kind == domain.SearchUsers) && !filter.InRooms() {
+		response.Users = newSearchHitsResponse(results.Users, newUserResponse)
+	}
+	return response
+}
+
+func newSearchHitsResponse[T, R any](hits domain.SearchHits[T], item func(T) R) *ListResponse[SearchHitResponse[R]] {
+	items := make([]SearchHitResponse[R], 0, len(hits.List))
+	for _, hit := range hits.List {
+		items = append(items, SearchHitResponse[R]{Item: item(hit.Item), Rank: hit.Rank, SnippetHTML: string(hit.Snippet)})
+	}
+	return &ListResponse[SearchHitResponse[R]]{Items: items, Count: hits.Count, NextCursor: hits.NextCursor}
+}
@@ -0,0 +1,125 @@
+package delivery
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/elyarsadig/studybud-go/configs"
+	"github.com/elyarsadig/studybud-go/internal/domain"
+	"github.com/go-chi/chi/v5"
+)
+
+// attachmentsField is the multipart field files are attached to messages
+// with.
+const attachmentsField = "attachments"
+
+func (h *ApiHandler) Attachment(w http.ResponseWriter, r *http.Request) {
+	h.serveAttachment(w, r, false)
+}
+
+func (h *ApiHandler) AttachmentThumbnail(w http.ResponseWriter, r *http.Request) {
+	h.serveAttachment(w, r, true)
+}
+
+// serveAttachment sends an attachment, or its thumbnail, to anybody who may
+// read the room it was posted in. The browser is told not to second guess
+// the content type and to run nothing found in the file, so a file that slips
+// through as text or image cannot act as a page of this site.
+func (h *ApiHandler) serveAttachment(w http.ResponseWriter, r *http.Request, thumb bool) {
+	useCase := domain.Bridge[domain.MessageUseCase](configs.MESSAGES_DB_NAME, h.useCases)
+	attachment, content, err := useCase.OpenAttachment(h.optionalSession(r), chi.URLParam(r, "id"), thumb)
+	if err != nil {
+		sessionValue, ok := h.extractSessionFromCookie(r)
+		h.handleError(w, r, err, "not_found.html", BaseTemplateData{
+			Username:        sessionValue.Username,
+			IsAuthenticated: ok,
+			AvatarURL:       sessionValue.Avatar,
+		})
+		return
+	}
+	defer content.Close()
+	var body io.Reader = content
+	contentType := attachment.ContentType
+	if thumb {
+		buffered := bufio.NewReader(content)
+		head, _ := buffered.Peek(512)
+		contentType = http.DetectContentType(head)
+		body = buffered
+	} else {
+		w.Header().Set("Content-Length", strconv.FormatInt(attachment.Size, 10))
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	disposition := "attachment"
+	if attachment.IsImage() || mediaType == "application/pdf" || mediaType == "text/plain" {
+		disposition = "inline"
+	}
+	// Browsers refuse to show PDFs in a sandbox, they are rendered by the
+	// browser's own viewer which does not run scripts of the page.
+	if mediaType != "application/pdf" {
+		w.Header().Set("Content-Security-Policy", "sandbox")
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": attachment.Name}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "private, max-age=86400")
+	_, err = io.Copy(w, body)
+	if err != nil {
+		h.logger.Error(err.Error())
+	}
+}
+
+func attachmentURL(attachment domain.Attachment) string {
+	return "/attachments/" + strconv.Itoa(int(attachment.ID))
+}
+
+// thumbnailURL is empty for attachments without a thumbnail.
+func thumbnailURL(attachment domain.Attachment) string {
+	if !attachment.HasThumbnail() {
+		return ""
+	}
+	return attachmentURL(attachment) + "/thumbnail"
+}
+
+// uploadsFromForm opens the files attached to a multipart request. The
+// returned function closes them again, it must be called once the uploads
+// have been read.
+func (h *ApiHandler) uploadsFromForm(r *http.Request) ([]domain.Upload, func(), error) {
+	if !strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		return nil, func() {}, nil
+	}
+	if r.MultipartForm == nil {
+		err := r.ParseMultipartForm(32 << 20)
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return nil, func() {}, h.errHandler.New(http.StatusRequestEntityTooLarge, "the attached files are too large")
+		}
+		if err != nil {
+			return nil, func() {}, h.errHandler.New(http.StatusBadRequest, "invalid request body")
+		}
+	}
+	headers := r.MultipartForm.File[attachmentsField]
+	files := make([]multipart.File, 0, len(headers))
+	closeAll := func() {
+		for _, file := range files {
+			file.Close()
+		}
+	}
+	uploads := make([]domain.Upload, 0, len(headers))
+	for _, header := range headers {
+		file, err := header.Open()
+		if err != nil {
+			closeAll()
+			h.logger.Error(err.Error())
+			return nil, func() {}, h.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+		}
+		files = append(files, file)
+		uploads = append(uploads, domain.Upload{Name: header.Filename, Content: file})
+	}
+	return uploads, closeAll, nil
+}
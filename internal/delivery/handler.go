@@ -8,9 +8,14 @@ import (
 	"html/template"
 	"io"
 	"mime/multipart"
+	"net"
 	"net/http"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
 
 	studybudgo "github.com/elyarsadig/studybud-go"
 	"github.com/elyarsadig/studybud-go/configs"
@@ -21,8 +26,16 @@ import (
 	redispkg "github.com/elyarsadig/studybud-go/pkg/redis"
 	"github.com/elyarsadig/studybud-go/transport"
 	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
 )
 
+// SessionCookieName is the cookie the browser session is kept in.
+const SessionCookieName = "session_token"
+
+// sessionTouchInterval is how stale the last seen time of a session may get
+// before a request updates it.
+const sessionTouchInterval = time.Minute
+
 type ApiHandler struct {
 	transport.HttpServer
 	cookieExpiration int
@@ -31,6 +44,8 @@ type ApiHandler struct {
 	errHandler       errorHandler.Handler
 	aes              *encryption.AES[string]
 	redis            *redispkg.Redis
+	hub              *RoomHub
+	upgrader         websocket.Upgrader
 }
 
 func NewApiHandler(ctx context.Context, cookieExpiration int, aes *encryption.AES[string], redis *redispkg.Redis, errHandler errorHandler.Handler, logger logger.Logger, useCases ...domain.Bridger) (*ApiHandler, error) {
@@ -41,6 +56,10 @@ func NewApiHandler(ctx context.Context, cookieExpiration int, aes *encryption.AE
 		logger:           logger,
 		redis:            redis,
 		cookieExpiration: cookieExpiration,
+		upgrader: websocket.Upgrader{
+			ReadBufferSize:  1024,
+			WriteBufferSize: 1024,
+		},
 	}
 
 	for _, useCase := range useCases {
@@ -53,12 +72,24 @@ func NewApiHandler(ctx context.Context, cookieExpiration int, aes *encryption.AE
 			handler.useCases[configs.ROOMS_DB_NAME] = useCase
 		case domain.MessageUseCase:
 			handler.useCases[configs.MESSAGES_DB_NAME] = useCase
+		case domain.PermissionUseCase:
+			handler.useCases[configs.AUTH_PERMISSIONS_DB_NAME] = useCase
+		case domain.AdminUseCase:
+			handler.useCases[configs.AUDIT_LOGS_DB_NAME] = useCase
+		case domain.TokenUseCase:
+			handler.useCases[configs.PERSONAL_ACCESS_TOKENS_DB_NAME] = useCase
+		case domain.NotificationUseCase:
+			handler.useCases[configs.NOTIFICATIONS_DB_NAME] = useCase
+		case domain.SearchUseCase:
+			handler.useCases[configs.SEARCH_DB_NAME] = useCase
 		}
 	}
+	handler.hub = NewRoomHub(redis, logger, handler.canReadRoom)
+	go handler.hub.Run(ctx)
 	return handler, nil
 }
 
-func (h *ApiHandler) renderTemplate(w http.ResponseWriter, tmpl string, data any) {
+func (h *ApiHandler) renderTemplate(w http.ResponseWriter, r *http.Request, tmpl string, data any) {
 	tmplFiles := []string{
 		"web/main.html",
 		"web/navbar.html",
@@ -74,26 +105,153 @@ func (h *ApiHandler) renderTemplate(w http.ResponseWriter, tmpl string, data any
 		return
 	}
 
-	err = tmplParsed.ExecuteTemplate(w, "base", data)
+	err = tmplParsed.ExecuteTemplate(w, "base", h.withBaseData(r, data))
 	if err != nil {
 		h.logger.Error(err.Error())
 		return
 	}
 }
 
+// withBaseData fills in the CSRF token and the unread notification count of
+// the BaseTemplateData embedded in data. Handlers build their page data
+// without them, this is the one place they are added for every template.
+func (h *ApiHandler) withBaseData(r *http.Request, data any) any {
+	if data == nil {
+		return nil
+	}
+	v := reflect.ValueOf(data)
+	if v.Kind() != reflect.Pointer {
+		ptr := reflect.New(v.Type())
+		ptr.Elem().Set(v)
+		v = ptr
+	}
+	if page, ok := v.Interface().(interface{ baseData() *BaseTemplateData }); ok {
+		base := page.baseData()
+		base.CSRFToken = transport.CSRFToken(r)
+		if base.IsAuthenticated {
+			base.UnreadNotifications = h.unreadNotifications(r)
+		}
+	}
+	return v.Interface()
+}
+
+// unreadNotifications counts the unread notifications of the user behind r
+// for the bell in the navbar, pages that work without logging in have the
+// session in the cookie only.
+func (h *ApiHandler) unreadNotifications(r *http.Request) int64 {
+	ctx := r.Context()
+	sessionValue, ok := ctx.Value(configs.UserCtxKey).(domain.SessionValue)
+	if !ok {
+		sessionValue, ok = h.extractSessionFromCookie(r)
+		if !ok {
+			return 0
+		}
+		ctx = context.WithValue(ctx, configs.UserCtxKey, sessionValue)
+	}
+	useCase := domain.Bridge[domain.NotificationUseCase](configs.NOTIFICATIONS_DB_NAME, h.useCases)
+	count, err := useCase.CountUnread(ctx)
+	if err != nil {
+		return 0
+	}
+	return count
+}
+
+// CSRFFailure answers requests rejected by the CSRF middleware.
+func (h *ApiHandler) CSRFFailure(w http.ResponseWriter, r *http.Request) {
+	err := h.errHandler.New(http.StatusForbidden, "this form has expired, go back, reload the page and try again")
+	if strings.HasPrefix(r.URL.Path, "/apis/") {
+		h.writeJSONError(w, err)
+		return
+	}
+	data := BaseTemplateData{}
+	sessionValue, ok := h.extractSessionFromCookie(r)
+	if ok {
+		data = BaseTemplateData{
+			IsAuthenticated: true,
+			Username:        sessionValue.Username,
+			AvatarURL:       sessionValue.Avatar,
+		}
+	}
+	h.handleError(w, r, err, "forbidden.html", data)
+}
+
+// ProtectedHandler lets logged in users through, users who still have to set
+// up a required second factor are sent to do that first.
 func (h *ApiHandler) ProtectedHandler(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
-		sessionValue, ok := h.extractSessionFromCookie(r)
+		// TokenAuth already placed the owner of a personal access token
+		// in the context.
+		sessionValue, ok := ctx.Value(configs.UserCtxKey).(domain.SessionValue)
 		if !ok {
-			http.Redirect(w, r, "/login", http.StatusFound)
+			sessionValue, ok = h.extractSessionFromCookie(r)
+			if !ok {
+				http.Redirect(w, r, "/login", http.StatusFound)
+				return
+			}
+			ctx = context.WithValue(ctx, configs.UserCtxKey, sessionValue)
+		}
+		pending, err := h.twoFactorSetupPending(r, sessionValue)
+		if err != nil {
+			h.handleError(w, r, err, "forbidden.html", BaseTemplateData{
+				IsAuthenticated: true,
+				Username:        sessionValue.Username,
+				AvatarURL:       sessionValue.Avatar,
+			})
+			return
+		}
+		if pending {
+			http.Redirect(w, r, "/2fa", http.StatusFound)
 			return
 		}
-		ctx = context.WithValue(ctx, configs.UserCtxKey, sessionValue)
 		next(w, r.WithContext(ctx))
 	}
 }
 
+// twoFactorSetupPending tells whether the user must enroll two-factor
+// authentication before going anywhere but the two-factor pages.
+func (h *ApiHandler) twoFactorSetupPending(r *http.Request, sessionValue domain.SessionValue) (bool, error) {
+	if r.URL.Path == "/2fa" || strings.HasPrefix(r.URL.Path, "/2fa/") {
+		return false, nil
+	}
+	useCase := domain.Bridge[domain.UserUseCase](configs.USERS_DB_NAME, h.useCases)
+	return useCase.TwoFactorSetupPending(r.Context(), strconv.Itoa(sessionValue.ID))
+}
+
+// RequirePermission must be wrapped by ProtectedHandler, it relies on the
+// session value being present in the request context.
+func (h *ApiHandler) RequirePermission(perm string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		sessionValue := ctx.Value(configs.UserCtxKey).(domain.SessionValue)
+		err := h.checkPermission(ctx, sessionValue, perm)
+		if err != nil {
+			h.handleError(w, r, err, "forbidden.html", BaseTemplateData{
+				IsAuthenticated: true,
+				Username:        sessionValue.Username,
+				AvatarURL:       sessionValue.Avatar,
+			})
+			return
+		}
+		next(w, r)
+	}
+}
+
+func (h *ApiHandler) checkPermission(ctx context.Context, sessionValue domain.SessionValue, perm string) error {
+	useCase := domain.Bridge[domain.PermissionUseCase](configs.AUTH_PERMISSIONS_DB_NAME, h.useCases)
+	allowed, err := useCase.HasPerm(ctx, strconv.Itoa(sessionValue.ID), perm)
+	if err != nil {
+		return err
+	}
+	if !allowed && sessionValue.PendingVerification {
+		return h.errHandler.New(http.StatusForbidden, "verify your email address to perform this action, check your inbox for the link")
+	}
+	if !allowed {
+		return h.errHandler.New(http.StatusForbidden, "you do not have permission to perform this action")
+	}
+	return nil
+}
+
 func (h *ApiHandler) RedirectIfAuthenticated(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		_, ok := h.extractSessionFromCookie(r)
@@ -106,24 +264,45 @@ func (h *ApiHandler) RedirectIfAuthenticated(next http.HandlerFunc) http.Handler
 }
 
 func (h *ApiHandler) setCookie(w http.ResponseWriter, key string) {
-	result, _ := h.aes.Encrypt(key)
-	token := base64.URLEncoding.EncodeToString(result)
 	cookie := &http.Cookie{
-		Name:   "session_token",
-		Value:  token,
-		MaxAge: h.cookieExpiration,
-		Secure: true,
+		Name:     SessionCookieName,
+		Value:    h.encodeSessionToken(key),
+		Path:     "/",
+		MaxAge:   h.cookieExpiration,
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
 	}
 	http.SetCookie(w, cookie)
 }
 
+func (h *ApiHandler) clearCookie(w http.ResponseWriter) {
+	cookie := &http.Cookie{
+		Name:     SessionCookieName,
+		Path:     "/",
+		MaxAge:   -1,
+		Expires:  time.Unix(0, 0),
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+	http.SetCookie(w, cookie)
+}
+
+func (h *ApiHandler) encodeSessionToken(key string) string {
+	result, _ := h.aes.Encrypt(key)
+	return base64.URLEncoding.EncodeToString(result)
+}
+
 func (h *ApiHandler) extractSessionFromCookie(r *http.Request) (domain.SessionValue, bool) {
-	ctx := r.Context()
-	cookie, err := r.Cookie("session_token")
+	cookie, err := r.Cookie(SessionCookieName)
 	if err != nil {
 		return domain.SessionValue{}, false
 	}
-	token := cookie.Value
+	return h.resolveSessionToken(r.Context(), cookie.Value)
+}
+
+func (h *ApiHandler) resolveSessionToken(ctx context.Context, token string) (domain.SessionValue, bool) {
 	encryptedToken, err := base64.URLEncoding.DecodeString(token)
 	if err != nil {
 		h.logger.Error(err.Error())
@@ -141,9 +320,27 @@ func (h *ApiHandler) extractSessionFromCookie(r *http.Request) (domain.SessionVa
 		h.logger.Error(err.Error())
 		return domain.SessionValue{}, false
 	}
+	if time.Since(sessionValue.LastSeen) > sessionTouchInterval {
+		h.touchSession(ctx, sessionValue)
+	}
 	return sessionValue, true
 }
 
+// touchSession records that the session is still in use. It is throttled by
+// sessionTouchInterval to keep a write off most requests.
+func (h *ApiHandler) touchSession(ctx context.Context, sessionValue domain.SessionValue) {
+	sessionValue.LastSeen = time.Now()
+	v, err := json.Marshal(sessionValue)
+	if err != nil {
+		h.logger.Error(err.Error())
+		return
+	}
+	err = h.redis.Replace(ctx, "session", sessionValue.SessionKey, v)
+	if err != nil {
+		h.logger.Error(err.Error())
+	}
+}
+
 func (h *ApiHandler) extractUserProfileUpdateForm(r *http.Request) (domain.UpdateUser, error) {
 	var err error
 	err = r.ParseMultipartForm(10 << 20) // 10 MB max memory
@@ -197,12 +394,70 @@ func (h *ApiHandler) saveFileToServer(file multipart.File, handler *multipart.Fi
 	return fmt.Sprintf("/uploads/%s", filename), nil
 }
 
-func (h *ApiHandler) handleError(w http.ResponseWriter, err error, tmpl string, data BaseTemplateData) {
+// sidebarPage limits the short lists (topics, recent activities) rendered
+// next to the main content of a page.
+var sidebarPage = domain.Page{Limit: 5}
+
+// withClientInfo adds the device of the request to its context, it is stored
+// with sessions started by the request.
+func withClientInfo(r *http.Request) context.Context {
+	return context.WithValue(r.Context(), configs.ClientCtxKey, domain.ClientInfo{
+		IP:        clientIP(r),
+		UserAgent: r.UserAgent(),
+	})
+}
+
+// clientIP returns the address of the peer. Forwarding headers are ignored
+// because they can be set by the client.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func pageFromRequest(r *http.Request) domain.Page {
+	queryParams := r.URL.Query()
+	limit, _ := strconv.Atoi(queryParams.Get("limit"))
+	return domain.Page{
+		Cursor: queryParams.Get("cursor"),
+		Limit:  limit,
+	}
+}
+
+// nextPageURL keeps the current query (search terms, limit) and points it at
+// the next cursor. It returns an empty string on the last page.
+func nextPageURL(r *http.Request, cursor string) string {
+	if len(cursor) == 0 {
+		return ""
+	}
+	queryParams := r.URL.Query()
+	queryParams.Set("cursor", cursor)
+	return r.URL.Path + "?" + queryParams.Encode()
+}
+
+func (h *ApiHandler) handleError(w http.ResponseWriter, r *http.Request, err error, tmpl string, data BaseTemplateData) {
 	errWithDetails, ok := err.(*errorHandler.Error)
 	if !ok || errWithDetails.HTTPStatus() == http.StatusInternalServerError {
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 	data.Message = err.Error()
-	h.renderTemplate(w, tmpl, data)
+	w.WriteHeader(errWithDetails.HTTPStatus())
+	h.renderTemplate(w, r, tmpl, data)
+}
+
+// handleFormError works like handleError for pages that need more than the
+// base data, it renders tmpl again with the error on top and keeps the rest of
+// data (search query, submitted form values) on the page.
+func (h *ApiHandler) handleFormError(w http.ResponseWriter, r *http.Request, err error, tmpl string, base *BaseTemplateData, data any) {
+	errWithDetails, ok := err.(*errorHandler.Error)
+	if !ok || errWithDetails.HTTPStatus() == http.StatusInternalServerError {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	base.Message = err.Error()
+	w.WriteHeader(errWithDetails.HTTPStatus())
+	h.renderTemplate(w, r, tmpl, data)
 }
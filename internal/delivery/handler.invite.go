@@ -0,0 +1,190 @@
+package delivery
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/elyarsadig/studybud-go/configs"
+	"github.com/elyarsadig/studybud-go/internal/domain"
+	"github.com/elyarsadig/studybud-go/pkg/utils"
+	"github.com/go-chi/chi/v5"
+)
+
+func (h *ApiHandler) RoomInvitesPage(w http.ResponseWriter, r *http.Request) {
+	h.renderRoomInvites(w, r, h.newRoomInvitesData(r), nil)
+}
+
+func (h *ApiHandler) CreateRoomInvite(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	data := h.newRoomInvitesData(r)
+	hours, err := strconv.Atoi(r.FormValue("expires_in"))
+	if err != nil {
+		h.renderRoomInvites(w, r, data, h.errHandler.New(http.StatusBadRequest, "invalid invite expiration"))
+		return
+	}
+	expiresIn := time.Duration(hours) * time.Hour
+	useCase := domain.Bridge[domain.RoomUseCase](configs.ROOMS_DB_NAME, h.useCases)
+	link, err := useCase.CreateInvite(ctx, chi.URLParam(r, "id"), expiresIn)
+	if err != nil {
+		h.renderRoomInvites(w, r, data, err)
+		return
+	}
+	data.InviteLink = link
+	data.Message = "invite link created, it expires in " + utils.FormatDuration(expiresIn)
+	h.renderRoomInvites(w, r, data, nil)
+}
+
+func (h *ApiHandler) ResetRoomInvites(w http.ResponseWriter, r *http.Request) {
+	data := h.newRoomInvitesData(r)
+	useCase := domain.Bridge[domain.RoomUseCase](configs.ROOMS_DB_NAME, h.useCases)
+	err := useCase.ResetInvites(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		h.renderRoomInvites(w, r, data, err)
+		return
+	}
+	data.Message = "all invite links of this room stopped working"
+	h.renderRoomInvites(w, r, data, nil)
+}
+
+func (h *ApiHandler) ApproveJoinRequest(w http.ResponseWriter, r *http.Request) {
+	roomID := chi.URLParam(r, "id")
+	useCase := domain.Bridge[domain.RoomUseCase](configs.ROOMS_DB_NAME, h.useCases)
+	err := useCase.ApproveJoinRequest(r.Context(), roomID, chi.URLParam(r, "requestID"))
+	if err != nil {
+		h.renderRoomInvites(w, r, h.newRoomInvitesData(r), err)
+		return
+	}
+	http.Redirect(w, r, "/room/"+roomID+"/invites", http.StatusFound)
+}
+
+func (h *ApiHandler) DenyJoinRequest(w http.ResponseWriter, r *http.Request) {
+	roomID := chi.URLParam(r, "id")
+	useCase := domain.Bridge[domain.RoomUseCase](configs.ROOMS_DB_NAME, h.useCases)
+	err := useCase.DenyJoinRequest(r.Context(), roomID, chi.URLParam(r, "requestID"))
+	if err != nil {
+		h.renderRoomInvites(w, r, h.newRoomInvitesData(r), err)
+		return
+	}
+	http.Redirect(w, r, "/room/"+roomID+"/invites", http.StatusFound)
+}
+
+func (h *ApiHandler) RequestToJoinRoom(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	roomID := chi.URLParam(r, "id")
+	data := h.newRoomJoinData(r)
+	data.RoomID = roomID
+	useCase := domain.Bridge[domain.RoomUseCase](configs.ROOMS_DB_NAME, h.useCases)
+	err := useCase.RequestToJoin(ctx, roomID)
+	if err != nil {
+		h.handleFormError(w, r, err, "room_join.html", &data.BaseTemplateData, &data)
+		return
+	}
+	data.PendingRequest = true
+	data.Message = "your request was sent to the host"
+	h.renderTemplate(w, r, "room_join.html", data)
+}
+
+// InvitePage shows the room an invite link points to and asks the user to
+// join it. Joining is a separate POST so a link opened by accident, or
+// embedded in another page, does not add anyone to a room.
+func (h *ApiHandler) InvitePage(w http.ResponseWriter, r *http.Request) {
+	data := h.newRoomJoinData(r)
+	data.Token = r.URL.Query().Get("token")
+	useCase := domain.Bridge[domain.RoomUseCase](configs.ROOMS_DB_NAME, h.useCases)
+	room, err := useCase.GetInvitedRoom(r.Context(), data.Token)
+	if err != nil {
+		h.handleFormError(w, r, err, "room_join.html", &data.BaseTemplateData, &data)
+		return
+	}
+	data.Room = room
+	data.RoomID = strconv.Itoa(int(room.ID))
+	h.renderTemplate(w, r, "room_join.html", data)
+}
+
+func (h *ApiHandler) AcceptInvite(w http.ResponseWriter, r *http.Request) {
+	data := h.newRoomJoinData(r)
+	data.Token = r.FormValue("token")
+	useCase := domain.Bridge[domain.RoomUseCase](configs.ROOMS_DB_NAME, h.useCases)
+	room, err := useCase.AcceptInvite(r.Context(), data.Token)
+	if err != nil {
+		h.handleFormError(w, r, err, "room_join.html", &data.BaseTemplateData, &data)
+		return
+	}
+	http.Redirect(w, r, "/room/"+strconv.Itoa(int(room.ID)), http.StatusFound)
+}
+
+// privateRoomPage is rendered by RoomPage for a room the user may not read.
+// It reveals nothing about the room but offers to ask the host to join.
+func (h *ApiHandler) privateRoomPage(w http.ResponseWriter, r *http.Request, baseData BaseTemplateData, roomID string) {
+	data := RoomJoinTemplateData{
+		BaseTemplateData: baseData,
+		RoomID:           roomID,
+	}
+	if baseData.IsAuthenticated {
+		useCase := domain.Bridge[domain.RoomUseCase](configs.ROOMS_DB_NAME, h.useCases)
+		pending, err := useCase.HasPendingJoinRequest(r.Context(), roomID)
+		if err != nil {
+			h.handleFormError(w, r, err, "room_join.html", &data.BaseTemplateData, &data)
+			return
+		}
+		data.PendingRequest = pending
+	}
+	w.WriteHeader(http.StatusForbidden)
+	h.renderTemplate(w, r, "room_join.html", data)
+}
+
+func (h *ApiHandler) newRoomJoinData(r *http.Request) RoomJoinTemplateData {
+	sessionValue := r.Context().Value(configs.UserCtxKey).(domain.SessionValue)
+	return RoomJoinTemplateData{
+		BaseTemplateData: BaseTemplateData{
+			IsAuthenticated: true,
+			Username:        sessionValue.Username,
+			AvatarURL:       sessionValue.Avatar,
+		},
+		RoomID: chi.URLParam(r, "id"),
+	}
+}
+
+func (h *ApiHandler) newRoomInvitesData(r *http.Request) RoomInvitesTemplateData {
+	sessionValue := r.Context().Value(configs.UserCtxKey).(domain.SessionValue)
+	expirations := make([]InviteExpiration, 0, len(domain.RoomInviteExpirations))
+	for _, expiration := range domain.RoomInviteExpirations {
+		expirations = append(expirations, InviteExpiration{
+			Hours: int(expiration.Hours()),
+			Label: utils.FormatDuration(expiration),
+		})
+	}
+	return RoomInvitesTemplateData{
+		BaseTemplateData: BaseTemplateData{
+			IsAuthenticated: true,
+			Username:        sessionValue.Username,
+			AvatarURL:       sessionValue.Avatar,
+		},
+		Expirations: expirations,
+	}
+}
+
+// renderRoomInvites fills in the room and its pending join requests, with err
+// on top when it is set.
+func (h *ApiHandler) renderRoomInvites(w http.ResponseWriter, r *http.Request, data RoomInvitesTemplateData, err error) {
+	ctx := r.Context()
+	roomID := chi.URLParam(r, "id")
+	useCase := domain.Bridge[domain.RoomUseCase](configs.ROOMS_DB_NAME, h.useCases)
+	room, roomErr := useCase.GetUserRoom(ctx, roomID)
+	if roomErr != nil {
+		h.handleFormError(w, r, roomErr, "room_invites.html", &data.BaseTemplateData, &data)
+		return
+	}
+	data.Room = room
+	requests, listErr := useCase.ListJoinRequests(ctx, roomID)
+	if err == nil {
+		err = listErr
+	}
+	data.JoinRequests = requests
+	if err != nil {
+		h.handleFormError(w, r, err, "room_invites.html", &data.BaseTemplateData, &data)
+		return
+	}
+	h.renderTemplate(w, r, "room_invites.html", data)
+}
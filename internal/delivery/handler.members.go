@@ -0,0 +1,106 @@
+package delivery
+
+import (
+	"net/http"
+
+	"github.com/elyarsadig/studybud-go/configs"
+	"github.com/elyarsadig/studybud-go/internal/domain"
+	"github.com/go-chi/chi/v5"
+)
+
+func (h *ApiHandler) RoomMembersPage(w http.ResponseWriter, r *http.Request) {
+	h.renderRoomMembers(w, r, nil)
+}
+
+func (h *ApiHandler) SetParticipantRole(w http.ResponseWriter, r *http.Request) {
+	useCase := domain.Bridge[domain.RoomUseCase](configs.ROOMS_DB_NAME, h.useCases)
+	err := useCase.SetParticipantRole(r.Context(), chi.URLParam(r, "id"), chi.URLParam(r, "userID"), r.FormValue("role"))
+	h.afterMembersAction(w, r, err)
+}
+
+func (h *ApiHandler) MuteParticipant(w http.ResponseWriter, r *http.Request) {
+	useCase := domain.Bridge[domain.RoomUseCase](configs.ROOMS_DB_NAME, h.useCases)
+	err := useCase.MuteParticipant(r.Context(), chi.URLParam(r, "id"), chi.URLParam(r, "userID"), true)
+	h.afterMembersAction(w, r, err)
+}
+
+func (h *ApiHandler) UnmuteParticipant(w http.ResponseWriter, r *http.Request) {
+	useCase := domain.Bridge[domain.RoomUseCase](configs.ROOMS_DB_NAME, h.useCases)
+	err := useCase.MuteParticipant(r.Context(), chi.URLParam(r, "id"), chi.URLParam(r, "userID"), false)
+	h.afterMembersAction(w, r, err)
+}
+
+func (h *ApiHandler) KickParticipant(w http.ResponseWriter, r *http.Request) {
+	useCase := domain.Bridge[domain.RoomUseCase](configs.ROOMS_DB_NAME, h.useCases)
+	err := useCase.KickParticipant(r.Context(), chi.URLParam(r, "id"), chi.URLParam(r, "userID"))
+	if err == nil {
+		h.publishKick(r)
+	}
+	h.afterMembersAction(w, r, err)
+}
+
+func (h *ApiHandler) TransferRoomOwnership(w http.ResponseWriter, r *http.Request) {
+	roomID := chi.URLParam(r, "id")
+	useCase := domain.Bridge[domain.RoomUseCase](configs.ROOMS_DB_NAME, h.useCases)
+	err := useCase.TransferOwnership(r.Context(), roomID, chi.URLParam(r, "userID"))
+	if err != nil {
+		h.renderRoomMembers(w, r, err)
+		return
+	}
+	http.Redirect(w, r, "/room/"+roomID, http.StatusFound)
+}
+
+// afterMembersAction goes back to the members page, with err on top when the
+// action failed.
+func (h *ApiHandler) afterMembersAction(w http.ResponseWriter, r *http.Request, err error) {
+	if err != nil {
+		h.renderRoomMembers(w, r, err)
+		return
+	}
+	http.Redirect(w, r, "/room/"+chi.URLParam(r, "id")+"/members", http.StatusFound)
+}
+
+// renderRoomMembers fills in the room, its participants and the powers of the
+// current user, with err on top when it is set.
+func (h *ApiHandler) renderRoomMembers(w http.ResponseWriter, r *http.Request, err error) {
+	ctx := r.Context()
+	sessionValue := ctx.Value(configs.UserCtxKey).(domain.SessionValue)
+	data := RoomMembersTemplateData{
+		BaseTemplateData: BaseTemplateData{
+			IsAuthenticated: true,
+			Username:        sessionValue.Username,
+			AvatarURL:       sessionValue.Avatar,
+		},
+	}
+	roomID := chi.URLParam(r, "id")
+	useCase := domain.Bridge[domain.RoomUseCase](configs.ROOMS_DB_NAME, h.useCases)
+	powers, powersErr := useCase.GetRoomPowers(ctx, roomID)
+	if powersErr == nil && !powers.Moderate {
+		powersErr = h.errHandler.New(http.StatusForbidden, "forbidden!")
+	}
+	if powersErr != nil {
+		h.handleFormError(w, r, powersErr, "room_members.html", &data.BaseTemplateData, &data)
+		return
+	}
+	data.Powers = powers
+	for _, role := range domain.RoomAssignableRoles {
+		if role != domain.RoomRoleCoHost || powers.Delete {
+			data.Roles = append(data.Roles, role)
+		}
+	}
+	room, roomErr := useCase.GetRoomById(ctx, roomID)
+	if err == nil {
+		err = roomErr
+	}
+	data.Room = room
+	participants, listErr := useCase.ListRoomParticipants(ctx, roomID)
+	if err == nil {
+		err = listErr
+	}
+	data.Participants = participants
+	if err != nil {
+		h.handleFormError(w, r, err, "room_members.html", &data.BaseTemplateData, &data)
+		return
+	}
+	h.renderTemplate(w, r, "room_members.html", data)
+}
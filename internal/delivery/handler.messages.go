@@ -0,0 +1,96 @@
+package delivery
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/elyarsadig/studybud-go/configs"
+	"github.com/elyarsadig/studybud-go/internal/domain"
+	"github.com/go-chi/chi/v5"
+)
+
+func (h *ApiHandler) EditMessagePage(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	sessionValue := ctx.Value(configs.UserCtxKey).(domain.SessionValue)
+	data := MessageFormTemplateData{
+		BaseTemplateData: BaseTemplateData{
+			IsAuthenticated: true,
+			Username:        sessionValue.Username,
+			AvatarURL:       sessionValue.Avatar,
+		},
+	}
+	useCase := domain.Bridge[domain.MessageUseCase](configs.MESSAGES_DB_NAME, h.useCases)
+	message, err := useCase.GetUserMessage(ctx, chi.URLParam(r, "id"))
+	if err == nil && message.UserID != uint(sessionValue.ID) {
+		err = h.errHandler.New(http.StatusForbidden, "only the author can edit a message")
+	}
+	if err != nil {
+		h.handleFormError(w, r, err, "message_form.html", &data.BaseTemplateData, &data)
+		return
+	}
+	data.Message = message
+	h.renderTemplate(w, r, "message_form.html", data)
+}
+
+func (h *ApiHandler) EditMessage(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	sessionValue := ctx.Value(configs.UserCtxKey).(domain.SessionValue)
+	useCase := domain.Bridge[domain.MessageUseCase](configs.MESSAGES_DB_NAME, h.useCases)
+	message, err := useCase.EditMessage(ctx, chi.URLParam(r, "id"), r.FormValue("body"))
+	if err != nil {
+		data := MessageFormTemplateData{
+			BaseTemplateData: BaseTemplateData{
+				IsAuthenticated: true,
+				Username:        sessionValue.Username,
+				AvatarURL:       sessionValue.Avatar,
+			},
+		}
+		data.Message.Body = r.FormValue("body")
+		h.handleFormError(w, r, err, "message_form.html", &data.BaseTemplateData, &data)
+		return
+	}
+	h.publishRoomEvent(ctx, newMessageEvent(domain.MessageEditedEvent, message, sessionValue))
+	http.Redirect(w, r, messageURL(message), http.StatusFound)
+}
+
+func (h *ApiHandler) MessageHistoryPage(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	sessionValue := ctx.Value(configs.UserCtxKey).(domain.SessionValue)
+	data := MessageHistoryTemplateData{
+		BaseTemplateData: BaseTemplateData{
+			IsAuthenticated: true,
+			Username:        sessionValue.Username,
+			AvatarURL:       sessionValue.Avatar,
+		},
+	}
+	useCase := domain.Bridge[domain.MessageUseCase](configs.MESSAGES_DB_NAME, h.useCases)
+	message, revisions, err := useCase.ListRevisions(ctx, chi.URLParam(r, "id"))
+	if err != nil {
+		h.handleFormError(w, r, err, "message_history.html", &data.BaseTemplateData, &data)
+		return
+	}
+	data.Message = message
+	data.Revisions = revisions
+	h.renderTemplate(w, r, "message_history.html", data)
+}
+
+func (h *ApiHandler) ReactToMessage(w http.ResponseWriter, r *http.Request) {
+	useCase := domain.Bridge[domain.MessageUseCase](configs.MESSAGES_DB_NAME, h.useCases)
+	message, err := useCase.ToggleReaction(r.Context(), chi.URLParam(r, "id"), r.FormValue("emoji"))
+	if err != nil {
+		h.handleError(w, r, err, "room.html", BaseTemplateData{})
+		return
+	}
+	http.Redirect(w, r, messageURL(message), http.StatusFound)
+}
+
+// messageURL points at message in its room, with its thread unfolded when it
+// is a reply.
+func messageURL(message domain.Message) string {
+	url := "/room/" + strconv.Itoa(int(message.RoomID))
+	if message.IsReply() {
+		thread := strconv.Itoa(int(*message.ParentID))
+		return url + "?thread=" + thread + "#message-" + thread
+	}
+	return url + "#message-" + strconv.Itoa(int(message.ID))
+}
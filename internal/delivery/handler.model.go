@@ -1,17 +1,121 @@
 package delivery
 
-import "github.com/elyarsadig/studybud-go/internal/domain"
+import (
+	"html/template"
+	"net/url"
+
+	"github.com/elyarsadig/studybud-go/internal/domain"
+)
 
 type BaseTemplateData struct {
-	Message         string
-	IsAuthenticated bool
-	AvatarURL       string
-	Username        string
+	Message             string
+	IsAuthenticated     bool
+	AvatarURL           string
+	Username            string
+	CSRFToken           string
+	UnreadNotifications int64
+}
+
+func (d *BaseTemplateData) baseData() *BaseTemplateData {
+	return d
+}
+
+// LoginTemplateData offers the single sign-on providers next to the login
+// form.
+type LoginTemplateData struct {
+	BaseTemplateData
+	Providers []domain.OIDCProvider
+}
+
+type ResetPasswordTemplateData struct {
+	BaseTemplateData
+	Token string
+}
+
+type VerifyEmailTemplateData struct {
+	BaseTemplateData
+	Email    string
+	Verified bool
+}
+
+type SessionsTemplateData struct {
+	BaseTemplateData
+	Sessions []domain.Session
+}
+
+// TokensTemplateData lists the personal access tokens of the user. NewToken
+// holds the value of a token just created, it is only shown once.
+type TokensTemplateData struct {
+	BaseTemplateData
+	Tokens   []domain.PersonalAccessToken
+	Scopes   []string
+	NewToken string
+}
+
+type NotificationsTemplateData struct {
+	BaseTemplateData
+	Notifications []domain.Notification
+	Count         int64
+	NextPageURL   string
+}
+
+// SearchTemplateData keeps the search as it was typed in, so the form shows
+// it again. Searched is set once results were looked up.
+type SearchTemplateData struct {
+	BaseTemplateData
+	Query       string
+	Kind        string
+	Topic       string
+	Host        string
+	Room        string
+	From        string
+	To          string
+	Kinds       []string
+	Searched    bool
+	Results     domain.SearchResults
+	NextPageURL string
+}
+
+// KindURL points the current search at one kind of results.
+func (d SearchTemplateData) KindURL(kind string) string {
+	queryParams := url.Values{}
+	for key, value := range map[string]string{
+		"q":     d.Query,
+		"type":  kind,
+		"topic": d.Topic,
+		"host":  d.Host,
+		"room":  d.Room,
+		"from":  d.From,
+		"to":    d.To,
+	} {
+		if value != "" {
+			queryParams.Set(key, value)
+		}
+	}
+	return "/search?" + queryParams.Encode()
+}
+
+type TwoFactorTemplateData struct {
+	BaseTemplateData
+	Status domain.TwoFactorStatus
+}
+
+type TwoFactorSetupTemplateData struct {
+	BaseTemplateData
+	Secret string
+	// QRCode is a data URL of the PNG, typed so html/template keeps it.
+	QRCode template.URL
+}
+
+type RecoveryCodesTemplateData struct {
+	BaseTemplateData
+	Codes []string
 }
 
 type Topics struct {
 	BaseTemplateData
 	domain.Topics
+	NextPageURL string
 }
 
 type HomeTemplateData struct {
@@ -20,6 +124,7 @@ type HomeTemplateData struct {
 	TopicsCount int64
 	RoomList    []domain.RoomWithDetails
 	RoomCount   int64
+	NextPageURL string
 	MessageList []domain.Message
 }
 
@@ -42,6 +147,8 @@ type UpdateProfileTemplateData struct {
 	Username string
 	Email    string
 	Bio      string
+
+	TwoFactorEnabled bool
 }
 
 type DeleteForm struct {
@@ -52,6 +159,7 @@ type DeleteForm struct {
 type ActivitiesTemplateData struct {
 	BaseTemplateData
 	MessageList []domain.Message
+	NextPageURL string
 }
 
 type UserProfileTemplateData struct {
@@ -61,12 +169,132 @@ type UserProfileTemplateData struct {
 	User        domain.User
 	RoomList    []domain.RoomWithDetails
 	RoomCount   int64
+	NextPageURL string
 	MessageList []domain.Message
 }
 
+// RoomTemplateData is the room page. MemberRole is the role of the user in
+// the room, empty when they have not joined it, and Powers decides which
+// moderation controls they get.
 type RoomTemplateData struct {
+	BaseTemplateData
+	Room           domain.Room
+	MessageList    []domain.Message
+	MessageCount   int64
+	PinnedMessages []domain.Message
+	Participants   []domain.RoomParticipant
+	MemberRole     string
+	Powers         domain.RoomPowers
+	NextPageURL    string
+	// OpenThread is the message whose thread is unfolded, after replying to it.
+	OpenThread     uint
+	ReactionEmojis []string
+}
+
+type MyRoomsTemplateData struct {
+	BaseTemplateData
+	RoomList    []domain.RoomWithDetails
+	RoomCount   int64
+	NextPageURL string
+}
+
+// RoomJoinTemplateData is shown instead of a room the user may not read. Room
+// is only set when they came with an invite link in Token.
+type RoomJoinTemplateData struct {
+	BaseTemplateData
+	RoomID         string
+	Room           domain.Room
+	Token          string
+	PendingRequest bool
+}
+
+type InviteExpiration struct {
+	Hours int
+	Label string
+}
+
+// RoomInvitesTemplateData is the page where the host hands out invite links
+// and answers join requests. InviteLink holds a link just created.
+type RoomInvitesTemplateData struct {
+	BaseTemplateData
+	Room         domain.Room
+	JoinRequests []domain.RoomJoinRequest
+	Expirations  []InviteExpiration
+	InviteLink   string
+}
+
+// RoomMembersTemplateData is the page where the host and their co-hosts and
+// moderators manage the participants of a room.
+type RoomMembersTemplateData struct {
 	BaseTemplateData
 	Room         domain.Room
-	MessageList  []domain.Message
-	Participants []domain.User
+	Participants []domain.RoomParticipant
+	Powers       domain.RoomPowers
+	Roles        []string
+}
+
+type MessageFormTemplateData struct {
+	BaseTemplateData
+	Message domain.Message
+}
+
+type MessageHistoryTemplateData struct {
+	BaseTemplateData
+	Message   domain.Message
+	Revisions []domain.MessageRevision
+}
+
+type AdminSection struct {
+	Title string
+	URL   string
+	Count int64
+}
+
+type AdminIndexTemplateData struct {
+	BaseTemplateData
+	Sections []AdminSection
+	AuditLog AdminListTemplateData
+}
+
+// AdminListTemplateData drives admin_list.html for every model. Rows hold
+// preformatted cells so the template stays model agnostic.
+type AdminListTemplateData struct {
+	BaseTemplateData
+	Title       string
+	Query       string
+	Searchable  bool
+	ActionURL   string
+	Actions     []AdminAction
+	TargetLabel string
+	Columns     []string
+	Rows        []AdminRow
+	Count       int64
+	NextPageURL string
+}
+
+type AdminAction struct {
+	Value string
+	Label string
+}
+
+type AdminRow struct {
+	ID      uint
+	EditURL string
+	Cells   []string
+}
+
+type AdminFormTemplateData struct {
+	BaseTemplateData
+	Title     string
+	BackURL   string
+	DeleteURL string
+	Fields    []AdminField
+}
+
+type AdminField struct {
+	Name    string
+	Label   string
+	Type    string
+	Value   string
+	Checked bool
 }
@@ -0,0 +1,58 @@
+package delivery
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/elyarsadig/studybud-go/configs"
+	"github.com/elyarsadig/studybud-go/internal/domain"
+	"github.com/go-chi/chi/v5"
+)
+
+func (h *ApiHandler) NotificationsPage(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	sessionValue := ctx.Value(configs.UserCtxKey).(domain.SessionValue)
+	data := NotificationsTemplateData{
+		BaseTemplateData: BaseTemplateData{
+			IsAuthenticated: true,
+			Username:        sessionValue.Username,
+			AvatarURL:       sessionValue.Avatar,
+		},
+	}
+	useCase := domain.Bridge[domain.NotificationUseCase](configs.NOTIFICATIONS_DB_NAME, h.useCases)
+	notifications, err := useCase.ListNotifications(ctx, pageFromRequest(r))
+	if err != nil {
+		h.handleFormError(w, r, err, "notifications.html", &data.BaseTemplateData, &data)
+		return
+	}
+	data.Notifications = notifications.List
+	data.Count = notifications.Count
+	data.NextPageURL = nextPageURL(r, notifications.NextCursor)
+	h.renderTemplate(w, r, "notifications.html", data)
+}
+
+// OpenNotification marks a notification read and takes the user to the
+// message it is about.
+func (h *ApiHandler) OpenNotification(w http.ResponseWriter, r *http.Request) {
+	useCase := domain.Bridge[domain.NotificationUseCase](configs.NOTIFICATIONS_DB_NAME, h.useCases)
+	notification, err := useCase.OpenNotification(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		h.handleError(w, r, err, "not_found.html", BaseTemplateData{})
+		return
+	}
+	if notification.Message != nil {
+		http.Redirect(w, r, messageURL(*notification.Message), http.StatusFound)
+		return
+	}
+	http.Redirect(w, r, "/room/"+strconv.Itoa(int(notification.RoomID)), http.StatusFound)
+}
+
+func (h *ApiHandler) MarkAllNotificationsRead(w http.ResponseWriter, r *http.Request) {
+	useCase := domain.Bridge[domain.NotificationUseCase](configs.NOTIFICATIONS_DB_NAME, h.useCases)
+	err := useCase.MarkAllRead(r.Context())
+	if err != nil {
+		h.handleError(w, r, err, "not_found.html", BaseTemplateData{})
+		return
+	}
+	http.Redirect(w, r, "/notifications", http.StatusFound)
+}
@@ -0,0 +1,93 @@
+package delivery
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"time"
+
+	"github.com/elyarsadig/studybud-go/configs"
+	"github.com/elyarsadig/studybud-go/internal/domain"
+	"github.com/go-chi/chi/v5"
+)
+
+// oidcStateCookieName binds the login to the browser that started it, so a
+// callback URL from someone else's login cannot be used to log a victim into
+// the attacker's account.
+const oidcStateCookieName = "oidc_state"
+
+// OIDCLogin sends the user to the provider to log in.
+func (h *ApiHandler) OIDCLogin(w http.ResponseWriter, r *http.Request) {
+	useCase := domain.Bridge[domain.UserUseCase](configs.USERS_DB_NAME, h.useCases)
+	authURL, state, err := useCase.BeginOIDCLogin(r.Context(), chi.URLParam(r, "provider"))
+	if err != nil {
+		data := h.newLoginData("")
+		h.handleFormError(w, r, err, "login.html", &data.BaseTemplateData, &data)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookieName,
+		Value:    state,
+		Path:     "/login/oidc",
+		MaxAge:   int((10 * time.Minute).Seconds()),
+		Secure:   true,
+		HttpOnly: true,
+		// Lax still sends the cookie on the top level redirect back from
+		// the provider.
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// OIDCCallback finishes the login when the provider sends the user back.
+func (h *ApiHandler) OIDCCallback(w http.ResponseWriter, r *http.Request) {
+	data := h.newLoginData("")
+	query := r.URL.Query()
+	cookie, err := r.Cookie(oidcStateCookieName)
+	h.clearOIDCStateCookie(w)
+	if query.Get("error") != "" {
+		data.Message = "the login was cancelled"
+		w.WriteHeader(http.StatusBadRequest)
+		h.renderTemplate(w, r, "login.html", data)
+		return
+	}
+	state := query.Get("state")
+	if err != nil || len(state) == 0 || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(state)) != 1 {
+		data.Message = "your login has expired, try again"
+		w.WriteHeader(http.StatusBadRequest)
+		h.renderTemplate(w, r, "login.html", data)
+		return
+	}
+	useCase := domain.Bridge[domain.UserUseCase](configs.USERS_DB_NAME, h.useCases)
+	result, err := useCase.FinishOIDCLogin(withClientInfo(r), chi.URLParam(r, "provider"), state, query.Get("code"))
+	if err != nil {
+		h.handleFormError(w, r, err, "login.html", &data.BaseTemplateData, &data)
+		return
+	}
+	if result.TwoFactorToken != "" {
+		h.setTwoFactorCookie(w, result.TwoFactorToken)
+		http.Redirect(w, r, "/login/2fa", http.StatusFound)
+		return
+	}
+	h.setCookie(w, result.SessionKey)
+	http.Redirect(w, r, "/home", http.StatusFound)
+}
+
+func (h *ApiHandler) newLoginData(message string) LoginTemplateData {
+	useCase := domain.Bridge[domain.UserUseCase](configs.USERS_DB_NAME, h.useCases)
+	return LoginTemplateData{
+		BaseTemplateData: BaseTemplateData{Message: message},
+		Providers:        useCase.OIDCProviders(),
+	}
+}
+
+func (h *ApiHandler) clearOIDCStateCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookieName,
+		Path:     "/login/oidc",
+		MaxAge:   -1,
+		Expires:  time.Unix(0, 0),
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
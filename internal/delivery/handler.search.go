@@ -0,0 +1,117 @@
+package delivery
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/elyarsadig/studybud-go/configs"
+	"github.com/elyarsadig/studybud-go/internal/domain"
+)
+
+// searchDateLayout is how the from and to dates of a search are written.
+const searchDateLayout = "2006-01-02"
+
+// SearchPage shows what matches a search across rooms, messages and people.
+// Without a type the best few of each are shown, with links to all of them.
+func (h *ApiHandler) SearchPage(w http.ResponseWriter, r *http.Request) {
+	sessionValue, ok := h.extractSessionFromCookie(r)
+	queryParams := r.URL.Query()
+	data := SearchTemplateData{
+		BaseTemplateData: BaseTemplateData{
+			Username:        sessionValue.Username,
+			IsAuthenticated: ok,
+			AvatarURL:       sessionValue.Avatar,
+		},
+		Query: queryParams.Get("q"),
+		Kind:  queryParams.Get("type"),
+		Topic: queryParams.Get("topic"),
+		Host:  queryParams.Get("host"),
+		Room:  queryParams.Get("room"),
+		From:  queryParams.Get("from"),
+		To:    queryParams.Get("to"),
+		Kinds: domain.SearchKinds,
+	}
+	if strings.TrimSpace(data.Query) == "" {
+		h.renderTemplate(w, r, "search.html", data)
+		return
+	}
+	ctx := r.Context()
+	if ok {
+		ctx = context.WithValue(ctx, configs.UserCtxKey, sessionValue)
+	}
+	filter, err := h.searchFilterFromRequest(r)
+	if err != nil {
+		h.handleFormError(w, r, err, "search.html", &data.BaseTemplateData, &data)
+		return
+	}
+	useCase := domain.Bridge[domain.SearchUseCase](configs.SEARCH_DB_NAME, h.useCases)
+	results, err := useCase.Search(ctx, filter, pageFromRequest(r))
+	if err != nil {
+		h.handleFormError(w, r, err, "search.html", &data.BaseTemplateData, &data)
+		return
+	}
+	data.Searched = true
+	data.Results = results
+	switch filter.Kind {
+	case domain.SearchRooms:
+		data.NextPageURL = nextPageURL(r, results.Rooms.NextCursor)
+	case domain.SearchMessages:
+		data.NextPageURL = nextPageURL(r, results.Messages.NextCursor)
+	case domain.SearchUsers:
+		data.NextPageURL = nextPageURL(r, results.Users.NextCursor)
+	}
+	h.renderTemplate(w, r, "search.html", data)
+}
+
+func (h *ApiHandler) APISearch(w http.ResponseWriter, r *http.Request) {
+	filter, err := h.searchFilterFromRequest(r)
+	if err != nil {
+		h.writeJSONError(w, err)
+		return
+	}
+	useCase := domain.Bridge[domain.SearchUseCase](configs.SEARCH_DB_NAME, h.useCases)
+	results, err := useCase.Search(h.optionalSession(r), filter, pageFromRequest(r))
+	if err != nil {
+		h.writeJSONError(w, err)
+		return
+	}
+	h.writeJSON(w, http.StatusOK, newSearchResponse(filter, results))
+}
+
+// searchFilterFromRequest reads a search from the query string: q, type,
+// topic, host, room and the from and to dates. The to date is included in
+// the search.
+func (h *ApiHandler) searchFilterFromRequest(r *http.Request) (domain.SearchFilter, error) {
+	queryParams := r.URL.Query()
+	filter := domain.SearchFilter{
+		Query: queryParams.Get("q"),
+		Kind:  queryParams.Get("type"),
+		Topic: strings.TrimSpace(queryParams.Get("topic")),
+		Host:  strings.TrimPrefix(strings.TrimSpace(queryParams.Get("host")), "@"),
+	}
+	if room := queryParams.Get("room"); room != "" {
+		roomID, err := strconv.ParseUint(room, 10, 64)
+		if err != nil {
+			return domain.SearchFilter{}, h.errHandler.New(http.StatusBadRequest, "invalid room")
+		}
+		filter.RoomID = uint(roomID)
+	}
+	if from := queryParams.Get("from"); from != "" {
+		date, err := time.Parse(searchDateLayout, from)
+		if err != nil {
+			return domain.SearchFilter{}, h.errHandler.New(http.StatusBadRequest, "dates must be written as YYYY-MM-DD")
+		}
+		filter.From = date
+	}
+	if to := queryParams.Get("to"); to != "" {
+		date, err := time.Parse(searchDateLayout, to)
+		if err != nil {
+			return domain.SearchFilter{}, h.errHandler.New(http.StatusBadRequest, "dates must be written as YYYY-MM-DD")
+		}
+		filter.To = date.AddDate(0, 0, 1)
+	}
+	return filter, nil
+}
@@ -0,0 +1,126 @@
+package delivery
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/elyarsadig/studybud-go/configs"
+	"github.com/elyarsadig/studybud-go/internal/domain"
+	"github.com/go-chi/chi/v5"
+	"github.com/gorilla/websocket"
+)
+
+type socketMessage struct {
+	Body     string `json:"body"`
+	ParentID *uint  `json:"parent_id"`
+}
+
+func (h *ApiHandler) RoomSocket(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	sv := ctx.Value(configs.UserCtxKey).(domain.SessionValue)
+	roomID := chi.URLParam(r, "id")
+	roomUseCase := domain.Bridge[domain.RoomUseCase](configs.ROOMS_DB_NAME, h.useCases)
+	room, err := roomUseCase.GetRoomById(ctx, roomID)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		h.logger.Error(err.Error())
+		return
+	}
+	client := &roomClient{
+		conn:    conn,
+		roomID:  room.ID,
+		session: sv,
+		send:    make(chan []byte, sendBufferSize),
+	}
+	h.hub.register(client)
+	go client.writePump()
+	h.readPump(ctx, client)
+}
+
+func (h *ApiHandler) readPump(ctx context.Context, client *roomClient) {
+	defer func() {
+		h.hub.unregister(client)
+		client.conn.Close()
+	}()
+	client.conn.SetReadLimit(maxMessageSize)
+	client.conn.SetReadDeadline(time.Now().Add(pongWait))
+	client.conn.SetPongHandler(func(string) error {
+		return client.conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+	useCase := domain.Bridge[domain.MessageUseCase](configs.MESSAGES_DB_NAME, h.useCases)
+	for {
+		_, payload, err := client.conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
+				h.logger.Error(err.Error())
+			}
+			return
+		}
+		var incoming socketMessage
+		if err := json.Unmarshal(payload, &incoming); err != nil {
+			continue
+		}
+		body := strings.TrimSpace(incoming.Body)
+		if len(body) == 0 {
+			continue
+		}
+		// Anybody who may read the room watches it live, posting takes the
+		// same permission as the form does.
+		err = h.checkPermission(ctx, client.session, domain.PermAddMessage)
+		if err != nil {
+			continue
+		}
+		message := &domain.Message{RoomID: client.roomID, Body: body, ParentID: incoming.ParentID}
+		err = useCase.CreateMessage(ctx, message)
+		if err != nil {
+			h.logger.Error(err.Error())
+			continue
+		}
+		h.publishRoomEvent(ctx, newMessageEvent(domain.MessageCreatedEvent, *message, client.session))
+	}
+}
+
+func (h *ApiHandler) publishRoomEvent(ctx context.Context, event domain.RoomEvent) {
+	err := h.hub.Publish(ctx, event)
+	if err != nil {
+		h.logger.Error(err.Error())
+	}
+}
+
+// publishAccessChanged has every instance close the sockets in the room that
+// lost access, those of userID or of whoever may no longer read the room
+// when userID is zero.
+func (h *ApiHandler) publishAccessChanged(ctx context.Context, roomID string, userID uint) {
+	id, err := strconv.ParseUint(roomID, 10, 64)
+	if err != nil {
+		return
+	}
+	h.publishRoomEvent(ctx, domain.RoomEvent{Type: domain.RoomAccessChangedEvent, RoomID: uint(id), UserID: userID})
+}
+
+// publishKick closes the sockets the participant kicked by r has open in the
+// room. A muted participant keeps theirs, they may still read along.
+func (h *ApiHandler) publishKick(r *http.Request) {
+	userID, err := strconv.ParseUint(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		return
+	}
+	h.publishAccessChanged(r.Context(), chi.URLParam(r, "id"), uint(userID))
+}
+
+// canReadRoom tells whether the user behind the client may still read the
+// room it watches.
+func (h *ApiHandler) canReadRoom(client *roomClient) bool {
+	ctx := context.WithValue(context.Background(), configs.UserCtxKey, client.session)
+	useCase := domain.Bridge[domain.RoomUseCase](configs.ROOMS_DB_NAME, h.useCases)
+	_, err := useCase.GetRoomById(ctx, strconv.FormatUint(uint64(client.roomID), 10))
+	return err == nil
+}
@@ -0,0 +1,145 @@
+package delivery
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/elyarsadig/studybud-go/configs"
+	"github.com/elyarsadig/studybud-go/internal/domain"
+	"github.com/elyarsadig/studybud-go/transport"
+	"github.com/go-chi/chi/v5"
+)
+
+// sessionOnlyPaths guard what keeps an account in its owner's hands: two
+// factor authentication, sessions and tokens. A leaked token must not be
+// able to take them over, so only a logged in session reaches them.
+var sessionOnlyPaths = []string{
+	"/2fa",
+	"/sessions",
+	"/tokens",
+	"/apis/v1/users/me/sessions",
+	"/apis/v1/users/me/tokens",
+}
+
+// TokenAuth authenticates requests carrying a personal access token in an
+// "Authorization: Bearer" header. The owner is put in the request context the
+// same way ProtectedHandler does for a session, so every handler works with
+// either. The token must hold the scope the request needs: read for safe
+// methods, write for anything else and admin for the admin panel. Tokens are
+// refused on sessionOnlyPaths. Other bearer tokens are left to
+// APIProtectedHandler.
+func (h *ApiHandler) TokenAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := bearerToken(r)
+		if !ok || !strings.HasPrefix(token, domain.PersonalAccessTokenPrefix) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if isSessionOnly(r.URL.Path) {
+			h.writeJSONError(w, h.errHandler.New(http.StatusForbidden, "personal access tokens cannot manage two-factor authentication, sessions or tokens, log in to do it"))
+			return
+		}
+		ctx := r.Context()
+		useCase := domain.Bridge[domain.TokenUseCase](configs.PERSONAL_ACCESS_TOKENS_DB_NAME, h.useCases)
+		sessionValue, err := useCase.Authenticate(ctx, token)
+		if err != nil {
+			h.writeJSONError(w, err)
+			return
+		}
+		for _, scope := range requiredScopes(r) {
+			if !sessionValue.HasScope(scope) {
+				h.writeJSONError(w, h.errHandler.New(http.StatusForbidden, "this token does not have the "+scope+" scope"))
+				return
+			}
+		}
+		ctx = context.WithValue(ctx, configs.UserCtxKey, sessionValue)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func isSessionOnly(path string) bool {
+	for _, prefix := range sessionOnlyPaths {
+		if path == prefix || strings.HasPrefix(path, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func requiredScopes(r *http.Request) []string {
+	scopes := []string{domain.TokenScopeRead}
+	if !transport.IsSafeMethod(r.Method) {
+		scopes = []string{domain.TokenScopeWrite}
+	}
+	if r.URL.Path == "/admin" || strings.HasPrefix(r.URL.Path, "/admin/") {
+		scopes = append(scopes, domain.TokenScopeAdmin)
+	}
+	return scopes
+}
+
+func (h *ApiHandler) TokensPage(w http.ResponseWriter, r *http.Request) {
+	data := h.newTokensData(r)
+	h.renderTokens(w, r, data, nil)
+}
+
+func (h *ApiHandler) CreateToken(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	data := h.newTokensData(r)
+	err := r.ParseForm()
+	if err != nil {
+		h.renderTokens(w, r, data, h.errHandler.New(http.StatusBadRequest, "invalid form"))
+		return
+	}
+	useCase := domain.Bridge[domain.TokenUseCase](configs.PERSONAL_ACCESS_TOKENS_DB_NAME, h.useCases)
+	token, value, err := useCase.CreateToken(ctx, &domain.TokenForm{
+		Name:   r.PostForm.Get("name"),
+		Scopes: r.PostForm["scopes"],
+	})
+	if err != nil {
+		h.renderTokens(w, r, data, err)
+		return
+	}
+	data.NewToken = value
+	data.Message = "token " + token.Name + " created, copy it now, it will not be shown again"
+	h.renderTokens(w, r, data, nil)
+}
+
+func (h *ApiHandler) RevokeToken(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	useCase := domain.Bridge[domain.TokenUseCase](configs.PERSONAL_ACCESS_TOKENS_DB_NAME, h.useCases)
+	err := useCase.RevokeToken(ctx, chi.URLParam(r, "id"))
+	if err != nil {
+		h.renderTokens(w, r, h.newTokensData(r), err)
+		return
+	}
+	http.Redirect(w, r, "/tokens", http.StatusFound)
+}
+
+func (h *ApiHandler) newTokensData(r *http.Request) TokensTemplateData {
+	sessionValue := r.Context().Value(configs.UserCtxKey).(domain.SessionValue)
+	return TokensTemplateData{
+		BaseTemplateData: BaseTemplateData{
+			IsAuthenticated: true,
+			Username:        sessionValue.Username,
+			AvatarURL:       sessionValue.Avatar,
+		},
+		Scopes: domain.TokenScopes,
+	}
+}
+
+// renderTokens lists the tokens of the user below data, with err on top when
+// it is set.
+func (h *ApiHandler) renderTokens(w http.ResponseWriter, r *http.Request, data TokensTemplateData, err error) {
+	useCase := domain.Bridge[domain.TokenUseCase](configs.PERSONAL_ACCESS_TOKENS_DB_NAME, h.useCases)
+	tokens, listErr := useCase.ListTokens(r.Context())
+	if err == nil {
+		err = listErr
+	}
+	data.Tokens = tokens
+	if err != nil {
+		h.handleFormError(w, r, err, "tokens.html", &data.BaseTemplateData, &data)
+		return
+	}
+	h.renderTemplate(w, r, "tokens.html", data)
+}
@@ -0,0 +1,202 @@
+package delivery
+
+import (
+	"encoding/base64"
+	"html/template"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/elyarsadig/studybud-go/configs"
+	"github.com/elyarsadig/studybud-go/internal/domain"
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+const twoFactorCookieName = "login_2fa"
+
+func (h *ApiHandler) TwoFactorLoginPage(w http.ResponseWriter, r *http.Request) {
+	_, err := r.Cookie(twoFactorCookieName)
+	if err != nil {
+		http.Redirect(w, r, "/login", http.StatusFound)
+		return
+	}
+	h.renderTemplate(w, r, "two_factor_login.html", BaseTemplateData{})
+}
+
+func (h *ApiHandler) TwoFactorLogin(w http.ResponseWriter, r *http.Request) {
+	cookie, err := r.Cookie(twoFactorCookieName)
+	if err != nil {
+		http.Redirect(w, r, "/login", http.StatusFound)
+		return
+	}
+	useCase := domain.Bridge[domain.UserUseCase](configs.USERS_DB_NAME, h.useCases)
+	sessionKey, err := useCase.VerifyTwoFactorLogin(withClientInfo(r), cookie.Value, &domain.TwoFactorForm{
+		Code: r.FormValue("code"),
+	})
+	if err != nil {
+		h.handleError(w, r, err, "two_factor_login.html", BaseTemplateData{})
+		return
+	}
+	h.clearTwoFactorCookie(w)
+	h.setCookie(w, sessionKey)
+	http.Redirect(w, r, "/home", http.StatusFound)
+}
+
+func (h *ApiHandler) TwoFactorPage(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	sessionValue := ctx.Value(configs.UserCtxKey).(domain.SessionValue)
+	data := TwoFactorTemplateData{
+		BaseTemplateData: BaseTemplateData{
+			IsAuthenticated: true,
+			Username:        sessionValue.Username,
+			AvatarURL:       sessionValue.Avatar,
+		},
+	}
+	useCase := domain.Bridge[domain.UserUseCase](configs.USERS_DB_NAME, h.useCases)
+	status, err := useCase.TwoFactorStatus(ctx, strconv.Itoa(sessionValue.ID))
+	if err != nil {
+		h.handleFormError(w, r, err, "two_factor.html", &data.BaseTemplateData, &data)
+		return
+	}
+	data.Status = status
+	if status.Required && !status.Enabled {
+		data.Message = "two-factor authentication is required for staff accounts"
+	}
+	h.renderTemplate(w, r, "two_factor.html", data)
+}
+
+func (h *ApiHandler) TwoFactorSetupPage(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	data := h.newTwoFactorSetupData(r)
+	useCase := domain.Bridge[domain.UserUseCase](configs.USERS_DB_NAME, h.useCases)
+	enrollment, err := useCase.BeginTOTPEnrollment(ctx)
+	if err != nil {
+		h.handleFormError(w, r, err, "two_factor_setup.html", &data.BaseTemplateData, &data)
+		return
+	}
+	err = h.fillTwoFactorSetupData(&data, enrollment)
+	if err != nil {
+		h.handleFormError(w, r, err, "two_factor_setup.html", &data.BaseTemplateData, &data)
+		return
+	}
+	h.renderTemplate(w, r, "two_factor_setup.html", data)
+}
+
+func (h *ApiHandler) TwoFactorSetup(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	useCase := domain.Bridge[domain.UserUseCase](configs.USERS_DB_NAME, h.useCases)
+	codes, err := useCase.ConfirmTOTPEnrollment(ctx, &domain.TwoFactorForm{Code: r.FormValue("code")})
+	if err != nil {
+		// Show the QR code again next to the error, the pending secret is
+		// still valid unless it expired.
+		data := h.newTwoFactorSetupData(r)
+		if enrollment, beginErr := useCase.BeginTOTPEnrollment(ctx); beginErr == nil {
+			_ = h.fillTwoFactorSetupData(&data, enrollment)
+		}
+		h.handleFormError(w, r, err, "two_factor_setup.html", &data.BaseTemplateData, &data)
+		return
+	}
+	h.renderRecoveryCodes(w, r, codes, "two-factor authentication is now enabled")
+}
+
+func (h *ApiHandler) DisableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	useCase := domain.Bridge[domain.UserUseCase](configs.USERS_DB_NAME, h.useCases)
+	err := useCase.DisableTOTP(ctx, &domain.TwoFactorForm{Code: r.FormValue("code")})
+	if err != nil {
+		h.handleTwoFactorError(w, r, err)
+		return
+	}
+	http.Redirect(w, r, "/2fa", http.StatusFound)
+}
+
+func (h *ApiHandler) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	useCase := domain.Bridge[domain.UserUseCase](configs.USERS_DB_NAME, h.useCases)
+	codes, err := useCase.RegenerateRecoveryCodes(ctx, &domain.TwoFactorForm{Code: r.FormValue("code")})
+	if err != nil {
+		h.handleTwoFactorError(w, r, err)
+		return
+	}
+	h.renderRecoveryCodes(w, r, codes, "your old recovery codes no longer work")
+}
+
+// handleTwoFactorError renders the two-factor page again with err on top.
+func (h *ApiHandler) handleTwoFactorError(w http.ResponseWriter, r *http.Request, err error) {
+	ctx := r.Context()
+	sessionValue := ctx.Value(configs.UserCtxKey).(domain.SessionValue)
+	data := TwoFactorTemplateData{
+		BaseTemplateData: BaseTemplateData{
+			IsAuthenticated: true,
+			Username:        sessionValue.Username,
+			AvatarURL:       sessionValue.Avatar,
+		},
+	}
+	useCase := domain.Bridge[domain.UserUseCase](configs.USERS_DB_NAME, h.useCases)
+	status, statusErr := useCase.TwoFactorStatus(ctx, strconv.Itoa(sessionValue.ID))
+	if statusErr == nil {
+		data.Status = status
+	}
+	h.handleFormError(w, r, err, "two_factor.html", &data.BaseTemplateData, &data)
+}
+
+func (h *ApiHandler) renderRecoveryCodes(w http.ResponseWriter, r *http.Request, codes []string, message string) {
+	sessionValue := r.Context().Value(configs.UserCtxKey).(domain.SessionValue)
+	h.renderTemplate(w, r, "recovery_codes.html", RecoveryCodesTemplateData{
+		BaseTemplateData: BaseTemplateData{
+			Message:         message,
+			IsAuthenticated: true,
+			Username:        sessionValue.Username,
+			AvatarURL:       sessionValue.Avatar,
+		},
+		Codes: codes,
+	})
+}
+
+func (h *ApiHandler) newTwoFactorSetupData(r *http.Request) TwoFactorSetupTemplateData {
+	sessionValue := r.Context().Value(configs.UserCtxKey).(domain.SessionValue)
+	return TwoFactorSetupTemplateData{
+		BaseTemplateData: BaseTemplateData{
+			IsAuthenticated: true,
+			Username:        sessionValue.Username,
+			AvatarURL:       sessionValue.Avatar,
+		},
+	}
+}
+
+// fillTwoFactorSetupData renders the otpauth URI as a QR code on the server,
+// the secret never reaches a third party QR service.
+func (h *ApiHandler) fillTwoFactorSetupData(data *TwoFactorSetupTemplateData, enrollment domain.TOTPEnrollment) error {
+	png, err := qrcode.Encode(enrollment.URL, qrcode.Medium, 256)
+	if err != nil {
+		h.logger.Error(err.Error())
+		return h.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+	}
+	data.Secret = enrollment.Secret
+	data.QRCode = template.URL("data:image/png;base64," + base64.StdEncoding.EncodeToString(png))
+	return nil
+}
+
+func (h *ApiHandler) setTwoFactorCookie(w http.ResponseWriter, token string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     twoFactorCookieName,
+		Value:    token,
+		Path:     "/login/2fa",
+		MaxAge:   int((5 * time.Minute).Seconds()),
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func (h *ApiHandler) clearTwoFactorCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     twoFactorCookieName,
+		Path:     "/login/2fa",
+		MaxAge:   -1,
+		Expires:  time.Unix(0, 0),
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
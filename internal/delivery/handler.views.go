@@ -1,62 +1,72 @@
 package delivery
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"strconv"
-	"time"
 
 	"github.com/elyarsadig/studybud-go/configs"
 	"github.com/elyarsadig/studybud-go/internal/domain"
+	"github.com/elyarsadig/studybud-go/pkg/errorHandler"
 	"github.com/go-chi/chi/v5"
 )
 
 func (h *ApiHandler) LoginPage(w http.ResponseWriter, r *http.Request) {
-	h.renderTemplate(w, "login.html", BaseTemplateData{})
+	h.renderTemplate(w, r, "login.html", h.newLoginData(""))
 }
 
 func (h *ApiHandler) LoginUser(w http.ResponseWriter, r *http.Request) {
-	data := BaseTemplateData{}
-	ctx := r.Context()
+	data := h.newLoginData("")
+	ctx := withClientInfo(r)
 	email := r.FormValue("email")
 	password := r.FormValue("password")
 	form := &domain.UserLoginForm{
 		Email:    email,
 		Password: password,
+		IP:       clientIP(r),
 	}
 	useCase := domain.Bridge[domain.UserUseCase](configs.USERS_DB_NAME, h.useCases)
-	sessionKey, err := useCase.Login(ctx, form)
+	result, err := useCase.Login(ctx, form)
 	if err != nil {
-		h.handleError(w, err, "login.html", data)
+		h.handleFormError(w, r, err, "login.html", &data.BaseTemplateData, &data)
+		return
+	}
+	if result.TwoFactorToken != "" {
+		h.setTwoFactorCookie(w, result.TwoFactorToken)
+		http.Redirect(w, r, "/login/2fa", http.StatusFound)
+		return
+	}
+	h.setCookie(w, result.SessionKey)
+	if result.SetupTwoFactor {
+		http.Redirect(w, r, "/2fa", http.StatusFound)
 		return
 	}
-	h.setCookie(w, sessionKey)
 	http.Redirect(w, r, "/home", http.StatusFound)
 }
 
 func (h *ApiHandler) Logout(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
 	seesion, ok := h.extractSessionFromCookie(r)
-	cookie := &http.Cookie{}
 	if ok {
-		err := h.redis.Remove(ctx, "session", seesion.SessionKey)
+		ctx := context.WithValue(r.Context(), configs.UserCtxKey, seesion)
+		useCase := domain.Bridge[domain.UserUseCase](configs.USERS_DB_NAME, h.useCases)
+		err := useCase.Logout(ctx)
 		if err != nil {
 			h.logger.Error(err.Error())
 			return
 		}
 	}
-	cookie.MaxAge = -1
-	cookie.Expires = time.Unix(0, 0)
-	http.SetCookie(w, cookie)
+	h.clearCookie(w)
 	http.Redirect(w, r, "/login", http.StatusFound)
 }
 
 func (h *ApiHandler) RegisterPage(w http.ResponseWriter, r *http.Request) {
-	h.renderTemplate(w, "register.html", BaseTemplateData{})
+	h.renderTemplate(w, r, "register.html", BaseTemplateData{})
 }
 
 func (h *ApiHandler) RegisterUser(w http.ResponseWriter, r *http.Request) {
 	data := BaseTemplateData{}
-	ctx := r.Context()
+	ctx := withClientInfo(r)
 	name := r.FormValue("name")
 	userName := r.FormValue("username")
 	email := r.FormValue("email")
@@ -72,11 +82,181 @@ func (h *ApiHandler) RegisterUser(w http.ResponseWriter, r *http.Request) {
 	useCase := domain.Bridge[domain.UserUseCase](configs.USERS_DB_NAME, h.useCases)
 	sessionKey, err := useCase.RegisterUser(ctx, form)
 	if err != nil {
-		h.handleError(w, err, "register.html", data)
+		h.handleError(w, r, err, "register.html", data)
 		return
 	}
 	h.setCookie(w, sessionKey)
-	http.Redirect(w, r, "/user-update", http.StatusFound)
+	http.Redirect(w, r, "/verify-email", http.StatusFound)
+}
+
+func (h *ApiHandler) VerifyEmailPage(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	sessionValue := ctx.Value(configs.UserCtxKey).(domain.SessionValue)
+	data := VerifyEmailTemplateData{
+		BaseTemplateData: BaseTemplateData{
+			IsAuthenticated: true,
+			Username:        sessionValue.Username,
+			AvatarURL:       sessionValue.Avatar,
+		},
+		Email:    sessionValue.Email,
+		Verified: !sessionValue.PendingVerification,
+	}
+	h.renderTemplate(w, r, "verify_email.html", data)
+}
+
+func (h *ApiHandler) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	sessionValue := ctx.Value(configs.UserCtxKey).(domain.SessionValue)
+	data := VerifyEmailTemplateData{
+		BaseTemplateData: BaseTemplateData{
+			IsAuthenticated: true,
+			Username:        sessionValue.Username,
+			AvatarURL:       sessionValue.Avatar,
+		},
+		Email:    sessionValue.Email,
+		Verified: !sessionValue.PendingVerification,
+	}
+	useCase := domain.Bridge[domain.UserUseCase](configs.USERS_DB_NAME, h.useCases)
+	err := useCase.SendEmailVerification(ctx, strconv.Itoa(sessionValue.ID))
+	if err != nil {
+		h.handleFormError(w, r, err, "verify_email.html", &data.BaseTemplateData, &data)
+		return
+	}
+	data.Message = "a new verification link has been sent to " + sessionValue.Email
+	h.renderTemplate(w, r, "verify_email.html", data)
+}
+
+// ConfirmEmail is the target of the emailed link. It works without a session
+// so the link can be opened on another device.
+func (h *ApiHandler) ConfirmEmail(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	data := VerifyEmailTemplateData{}
+	sessionValue, ok := h.extractSessionFromCookie(r)
+	if ok {
+		ctx = context.WithValue(ctx, configs.UserCtxKey, sessionValue)
+		data.BaseTemplateData = BaseTemplateData{
+			IsAuthenticated: true,
+			Username:        sessionValue.Username,
+			AvatarURL:       sessionValue.Avatar,
+		}
+		data.Email = sessionValue.Email
+		data.Verified = !sessionValue.PendingVerification
+	}
+	useCase := domain.Bridge[domain.UserUseCase](configs.USERS_DB_NAME, h.useCases)
+	user, sessionKey, err := useCase.VerifyEmail(ctx, r.URL.Query().Get("token"))
+	if err != nil {
+		h.handleFormError(w, r, err, "verify_email.html", &data.BaseTemplateData, &data)
+		return
+	}
+	if sessionKey != "" {
+		h.setCookie(w, sessionKey)
+	}
+	data.Email = user.Email
+	data.Verified = true
+	data.Message = "your email address has been verified"
+	h.renderTemplate(w, r, "verify_email.html", data)
+}
+
+func (h *ApiHandler) SessionsPage(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	sessionValue := ctx.Value(configs.UserCtxKey).(domain.SessionValue)
+	data := SessionsTemplateData{
+		BaseTemplateData: BaseTemplateData{
+			IsAuthenticated: true,
+			Username:        sessionValue.Username,
+			AvatarURL:       sessionValue.Avatar,
+		},
+	}
+	useCase := domain.Bridge[domain.UserUseCase](configs.USERS_DB_NAME, h.useCases)
+	sessions, err := useCase.ListSessions(ctx)
+	if err != nil {
+		h.handleFormError(w, r, err, "sessions.html", &data.BaseTemplateData, &data)
+		return
+	}
+	data.Sessions = sessions
+	h.renderTemplate(w, r, "sessions.html", data)
+}
+
+func (h *ApiHandler) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	useCase := domain.Bridge[domain.UserUseCase](configs.USERS_DB_NAME, h.useCases)
+	err := useCase.RevokeSession(ctx, chi.URLParam(r, "id"))
+	if err != nil {
+		sessionValue := ctx.Value(configs.UserCtxKey).(domain.SessionValue)
+		data := SessionsTemplateData{
+			BaseTemplateData: BaseTemplateData{
+				IsAuthenticated: true,
+				Username:        sessionValue.Username,
+				AvatarURL:       sessionValue.Avatar,
+			},
+		}
+		h.handleFormError(w, r, err, "sessions.html", &data.BaseTemplateData, &data)
+		return
+	}
+	// Revoking the current session lands on the login page through
+	// ProtectedHandler.
+	http.Redirect(w, r, "/sessions", http.StatusFound)
+}
+
+func (h *ApiHandler) RevokeAllSessions(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	sessionValue := ctx.Value(configs.UserCtxKey).(domain.SessionValue)
+	useCase := domain.Bridge[domain.UserUseCase](configs.USERS_DB_NAME, h.useCases)
+	err := useCase.RevokeAllSessions(ctx, strconv.Itoa(sessionValue.ID))
+	if err != nil {
+		data := SessionsTemplateData{
+			BaseTemplateData: BaseTemplateData{
+				IsAuthenticated: true,
+				Username:        sessionValue.Username,
+				AvatarURL:       sessionValue.Avatar,
+			},
+		}
+		h.handleFormError(w, r, err, "sessions.html", &data.BaseTemplateData, &data)
+		return
+	}
+	h.clearCookie(w)
+	h.renderTemplate(w, r, "login.html", h.newLoginData("you have been logged out on every device"))
+}
+
+func (h *ApiHandler) ForgotPasswordPage(w http.ResponseWriter, r *http.Request) {
+	h.renderTemplate(w, r, "forgot_password.html", BaseTemplateData{})
+}
+
+func (h *ApiHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	email := r.FormValue("email")
+	useCase := domain.Bridge[domain.UserUseCase](configs.USERS_DB_NAME, h.useCases)
+	err := useCase.RequestPasswordReset(ctx, email)
+	if err != nil {
+		h.handleError(w, r, err, "forgot_password.html", BaseTemplateData{})
+		return
+	}
+	h.renderTemplate(w, r, "forgot_password.html", BaseTemplateData{
+		Message: "if an account exists for " + email + ", a reset link is on its way",
+	})
+}
+
+func (h *ApiHandler) ResetPasswordPage(w http.ResponseWriter, r *http.Request) {
+	h.renderTemplate(w, r, "reset_password.html", ResetPasswordTemplateData{
+		Token: r.URL.Query().Get("token"),
+	})
+}
+
+func (h *ApiHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	form := &domain.PasswordResetForm{
+		Token:     r.FormValue("token"),
+		Password1: r.FormValue("password1"),
+		Password2: r.FormValue("password2"),
+	}
+	useCase := domain.Bridge[domain.UserUseCase](configs.USERS_DB_NAME, h.useCases)
+	err := useCase.ResetPassword(ctx, form)
+	if err != nil {
+		data := ResetPasswordTemplateData{Token: form.Token}
+		h.handleFormError(w, r, err, "reset_password.html", &data.BaseTemplateData, &data)
+		return
+	}
+	h.renderTemplate(w, r, "login.html", h.newLoginData("your password has been reset, you can login now"))
 }
 
 func (h *ApiHandler) Topics(w http.ResponseWriter, r *http.Request) {
@@ -96,23 +276,24 @@ func (h *ApiHandler) Topics(w http.ResponseWriter, r *http.Request) {
 	var topics domain.Topics
 	var err error
 	if len(name) == 0 {
-		topics, err = useCase.ListAllTopics(ctx)
+		topics, err = useCase.ListAllTopics(ctx, pageFromRequest(r))
 		if err != nil {
-			h.handleError(w, err, "topics.html", data)
+			h.handleError(w, r, err, "topics.html", data)
 			return
 		}
 	} else {
-		topics, err = useCase.SearchTopicByName(ctx, name)
+		topics, err = useCase.SearchTopicByName(ctx, name, pageFromRequest(r))
 		if err != nil {
-			h.handleError(w, err, "topics.html", data)
+			h.handleError(w, r, err, "topics.html", data)
 			return
 		}
 	}
 	tmplData := Topics{
 		BaseTemplateData: data,
 		Topics:           topics,
+		NextPageURL:      nextPageURL(r, topics.NextCursor),
 	}
-	h.renderTemplate(w, "topics.html", tmplData)
+	h.renderTemplate(w, r, "topics.html", tmplData)
 }
 
 func (h *ApiHandler) HomePage(w http.ResponseWriter, r *http.Request) {
@@ -128,30 +309,34 @@ func (h *ApiHandler) HomePage(w http.ResponseWriter, r *http.Request) {
 	queryParams := r.URL.Query()
 	searchQuery := queryParams.Get("q")
 	ctx := r.Context()
+	if ok {
+		ctx = context.WithValue(ctx, configs.UserCtxKey, sessionValue)
+	}
 	topicUseCase := domain.Bridge[domain.TopicUseCase](configs.TOPICS_DB_NAME, h.useCases)
 	roomUseCase := domain.Bridge[domain.RoomUseCase](configs.ROOMS_DB_NAME, h.useCases)
 	messageUseCase := domain.Bridge[domain.MessageUseCase](configs.MESSAGES_DB_NAME, h.useCases)
-	topics, err := topicUseCase.ListAllTopics(ctx)
+	topics, err := topicUseCase.ListAllTopics(ctx, sidebarPage)
 	if err != nil {
-		h.handleError(w, err, "home.html", baseData)
+		h.handleError(w, r, err, "home.html", baseData)
 		return
 	}
 	data.TopicList = topics.List
 	data.TopicsCount = topics.Count
-	rooms, err := roomUseCase.ListRooms(ctx, searchQuery)
+	rooms, err := roomUseCase.ListRooms(ctx, searchQuery, pageFromRequest(r))
 	if err != nil {
-		h.handleError(w, err, "home.html", baseData)
+		h.handleError(w, r, err, "home.html", baseData)
 		return
 	}
 	data.RoomCount = rooms.Count
 	data.RoomList = rooms.List
-	messages, err := messageUseCase.ListAllMessages(ctx)
+	data.NextPageURL = nextPageURL(r, rooms.NextCursor)
+	messages, err := messageUseCase.ListAllMessages(ctx, sidebarPage)
 	if err != nil {
-		h.handleError(w, err, "home.html", baseData)
+		h.handleError(w, r, err, "home.html", baseData)
 		return
 	}
 	data.MessageList = messages.MessageList
-	h.renderTemplate(w, "home.html", data)
+	h.renderTemplate(w, r, "home.html", data)
 }
 
 func (h *ApiHandler) CreateRoomPage(w http.ResponseWriter, r *http.Request) {
@@ -166,13 +351,13 @@ func (h *ApiHandler) CreateRoomPage(w http.ResponseWriter, r *http.Request) {
 		BaseTemplateData: baseData,
 	}
 	useCase := domain.Bridge[domain.TopicUseCase](configs.TOPICS_DB_NAME, h.useCases)
-	topics, err := useCase.ListAllTopics(ctx)
+	topics, err := useCase.ListAllTopics(ctx, domain.Page{Limit: domain.MaxPageSize})
 	if err != nil {
-		h.handleError(w, err, "room_form.html", baseData)
+		h.handleError(w, r, err, "room_form.html", baseData)
 		return
 	}
 	data.TopicList = topics.List
-	h.renderTemplate(w, "room_form.html", data)
+	h.renderTemplate(w, r, "room_form.html", data)
 }
 
 func (h *ApiHandler) CreateRoom(w http.ResponseWriter, r *http.Request) {
@@ -187,11 +372,12 @@ func (h *ApiHandler) CreateRoom(w http.ResponseWriter, r *http.Request) {
 		TopicName:   r.FormValue("topic"),
 		Name:        r.FormValue("name"),
 		Description: r.FormValue("description"),
+		Visibility:  r.FormValue("visibility"),
 	}
 	useCase := domain.Bridge[domain.RoomUseCase](configs.ROOMS_DB_NAME, h.useCases)
 	err := useCase.CreateRoom(ctx, roomForm)
 	if err != nil {
-		h.handleError(w, err, "room_form.html", data)
+		h.handleError(w, r, err, "room_form.html", data)
 		return
 	}
 	http.Redirect(w, r, "/home", http.StatusFound)
@@ -203,7 +389,7 @@ func (h *ApiHandler) UpdateProfilePage(w http.ResponseWriter, r *http.Request) {
 	useCase := domain.Bridge[domain.UserUseCase](configs.USERS_DB_NAME, h.useCases)
 	user, err := useCase.GetUserByEmail(ctx, sessionValue.Email)
 	if err != nil {
-		h.handleError(w, err, "update_user.html", BaseTemplateData{})
+		h.handleError(w, r, err, "update_user.html", BaseTemplateData{})
 		return
 	}
 	data := UpdateProfileTemplateData{
@@ -217,8 +403,10 @@ func (h *ApiHandler) UpdateProfilePage(w http.ResponseWriter, r *http.Request) {
 		Name:     user.Name,
 		Email:    user.Email,
 		Bio:      user.Bio,
+
+		TwoFactorEnabled: user.TOTPEnabled,
 	}
-	h.renderTemplate(w, "update_user.html", data)
+	h.renderTemplate(w, r, "update_user.html", data)
 }
 
 func (h *ApiHandler) UpdateProfile(w http.ResponseWriter, r *http.Request) {
@@ -232,10 +420,12 @@ func (h *ApiHandler) UpdateProfile(w http.ResponseWriter, r *http.Request) {
 	useCase := domain.Bridge[domain.UserUseCase](configs.USERS_DB_NAME, h.useCases)
 	sessionKey, err := useCase.UpdateInfo(ctx, &updateUser)
 	if err != nil {
-		h.handleError(w, err, "update_user.html", BaseTemplateData{})
+		h.handleError(w, r, err, "update_user.html", BaseTemplateData{})
 		return
 	}
-	h.setCookie(w, sessionKey)
+	if sessionKey != "" {
+		h.setCookie(w, sessionKey)
+	}
 	http.Redirect(w, r, "/home", http.StatusFound)
 }
 
@@ -251,28 +441,59 @@ func (h *ApiHandler) DeleteMessagePage(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 	message, err := useCase.GetUserMessage(ctx, id)
 	if err != nil {
-		h.handleError(w, err, "not_found.html", baseData)
+		h.handleError(w, r, err, "not_found.html", baseData)
 		return
 	}
 	data := DeleteForm{
 		BaseTemplateData: baseData,
 		Obj:              message.Body,
 	}
-	h.renderTemplate(w, "delete.html", data)
+	h.renderTemplate(w, r, "delete.html", data)
 }
 
 func (h *ApiHandler) DeleteMessage(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	id := chi.URLParam(r, "id")
 	useCase := domain.Bridge[domain.MessageUseCase](configs.MESSAGES_DB_NAME, h.useCases)
-	err := useCase.Delete(ctx, id)
+	message, err := useCase.GetUserMessage(ctx, id)
 	if err != nil {
-		h.handleError(w, err, "delete.html", BaseTemplateData{})
+		h.handleError(w, r, err, "delete.html", BaseTemplateData{})
 		return
 	}
+	err = useCase.Delete(ctx, id)
+	if err != nil {
+		h.handleError(w, r, err, "delete.html", BaseTemplateData{})
+		return
+	}
+	sv := ctx.Value(configs.UserCtxKey).(domain.SessionValue)
+	h.publishRoomEvent(ctx, newMessageEvent(domain.MessageDeletedEvent, domain.Message{ID: message.ID, RoomID: message.RoomID}, sv))
 	http.Redirect(w, r, "/home", http.StatusFound)
 }
 
+func (h *ApiHandler) PinMessage(w http.ResponseWriter, r *http.Request) {
+	h.setMessagePinned(w, r, true)
+}
+
+func (h *ApiHandler) UnpinMessage(w http.ResponseWriter, r *http.Request) {
+	h.setMessagePinned(w, r, false)
+}
+
+func (h *ApiHandler) setMessagePinned(w http.ResponseWriter, r *http.Request, pinned bool) {
+	ctx := r.Context()
+	useCase := domain.Bridge[domain.MessageUseCase](configs.MESSAGES_DB_NAME, h.useCases)
+	message, err := useCase.PinMessage(ctx, chi.URLParam(r, "id"), pinned)
+	if err != nil {
+		sv := ctx.Value(configs.UserCtxKey).(domain.SessionValue)
+		h.handleError(w, r, err, "forbidden.html", BaseTemplateData{
+			IsAuthenticated: true,
+			Username:        sv.Username,
+			AvatarURL:       sv.Avatar,
+		})
+		return
+	}
+	http.Redirect(w, r, "/room/"+strconv.Itoa(int(message.RoomID)), http.StatusFound)
+}
+
 func (h *ApiHandler) ActivitiesPage(w http.ResponseWriter, r *http.Request) {
 	sessionValue, ok := h.extractSessionFromCookie(r)
 	baseData := BaseTemplateData{
@@ -281,17 +502,21 @@ func (h *ApiHandler) ActivitiesPage(w http.ResponseWriter, r *http.Request) {
 		IsAuthenticated: ok,
 	}
 	ctx := r.Context()
+	if ok {
+		ctx = context.WithValue(ctx, configs.UserCtxKey, sessionValue)
+	}
 	useCase := domain.Bridge[domain.MessageUseCase](configs.MESSAGES_DB_NAME, h.useCases)
-	messages, err := useCase.ListAllMessages(ctx)
+	messages, err := useCase.ListAllMessages(ctx, pageFromRequest(r))
 	if err != nil {
-		h.handleError(w, err, "activity.html", baseData)
+		h.handleError(w, r, err, "activity.html", baseData)
 		return
 	}
 	data := ActivitiesTemplateData{
 		BaseTemplateData: baseData,
 		MessageList:      messages.MessageList,
+		NextPageURL:      nextPageURL(r, messages.NextCursor),
 	}
-	h.renderTemplate(w, "activity.html", data)
+	h.renderTemplate(w, r, "activity.html", data)
 }
 
 func (h *ApiHandler) UserProfilePage(w http.ResponseWriter, r *http.Request) {
@@ -302,6 +527,9 @@ func (h *ApiHandler) UserProfilePage(w http.ResponseWriter, r *http.Request) {
 		Username:        sv.Username,
 		IsAuthenticated: ok,
 	}
+	if ok {
+		ctx = context.WithValue(ctx, configs.UserCtxKey, sv)
+	}
 	data := UserProfileTemplateData{
 		BaseTemplateData: baseData,
 	}
@@ -310,24 +538,24 @@ func (h *ApiHandler) UserProfilePage(w http.ResponseWriter, r *http.Request) {
 	messageUC := domain.Bridge[domain.MessageUseCase](configs.MESSAGES_DB_NAME, h.useCases)
 	userUC := domain.Bridge[domain.UserUseCase](configs.USERS_DB_NAME, h.useCases)
 	roomUC := domain.Bridge[domain.RoomUseCase](configs.ROOMS_DB_NAME, h.useCases)
-	topics, err := topicUC.ListAllTopics(ctx)
+	topics, err := topicUC.ListAllTopics(ctx, sidebarPage)
 	if err != nil {
-		h.handleError(w, err, "profile.html", baseData)
+		h.handleError(w, r, err, "profile.html", baseData)
 		return
 	}
 	user, err := userUC.GetUserById(ctx, userID)
 	if err != nil {
-		h.handleError(w, err, "profile.html", baseData)
+		h.handleError(w, r, err, "profile.html", baseData)
 		return
 	}
-	rooms, err := roomUC.ListUserRooms(ctx, userID)
+	rooms, err := roomUC.ListUserRooms(ctx, userID, pageFromRequest(r))
 	if err != nil {
-		h.handleError(w, err, "profile.html", baseData)
+		h.handleError(w, r, err, "profile.html", baseData)
 		return
 	}
-	messages, err := messageUC.ListUserMessages(ctx, userID)
+	messages, err := messageUC.ListUserMessages(ctx, userID, sidebarPage)
 	if err != nil {
-		h.handleError(w, err, "profile.html", baseData)
+		h.handleError(w, r, err, "profile.html", baseData)
 		return
 	}
 	data.TopicList = topics.List
@@ -335,8 +563,9 @@ func (h *ApiHandler) UserProfilePage(w http.ResponseWriter, r *http.Request) {
 	data.User = user
 	data.RoomList = rooms.List
 	data.RoomCount = rooms.Count
+	data.NextPageURL = nextPageURL(r, rooms.NextCursor)
 	data.MessageList = messages.MessageList
-	h.renderTemplate(w, "profile.html", data)
+	h.renderTemplate(w, r, "profile.html", data)
 }
 
 func (h *ApiHandler) RoomPage(w http.ResponseWriter, r *http.Request) {
@@ -347,31 +576,57 @@ func (h *ApiHandler) RoomPage(w http.ResponseWriter, r *http.Request) {
 		AvatarURL:       sv.Avatar,
 		Username:        sv.Username,
 	}
+	if ok {
+		ctx = context.WithValue(ctx, configs.UserCtxKey, sv)
+	}
 	roomID := chi.URLParam(r, "id")
 	roomUseCase := domain.Bridge[domain.RoomUseCase](configs.ROOMS_DB_NAME, h.useCases)
 	messageUseCase := domain.Bridge[domain.MessageUseCase](configs.MESSAGES_DB_NAME, h.useCases)
 	room, err := roomUseCase.GetRoomById(ctx, roomID)
+	var errWithDetails *errorHandler.Error
+	if errors.As(err, &errWithDetails) && errWithDetails.HTTPStatus() == http.StatusForbidden {
+		h.privateRoomPage(w, r.WithContext(ctx), baseData, roomID)
+		return
+	}
 	if err != nil {
-		h.handleError(w, err, "room.html", baseData)
+		h.handleError(w, r, err, "room.html", baseData)
 		return
 	}
 	participants, err := roomUseCase.ListRoomParticipants(ctx, roomID)
 	if err != nil {
-		h.handleError(w, err, "room.html", baseData)
+		h.handleError(w, r, err, "room.html", baseData)
+		return
+	}
+	messages, err := messageUseCase.ListRoomMessages(ctx, roomID, pageFromRequest(r))
+	if err != nil {
+		h.handleError(w, r, err, "room.html", baseData)
+		return
+	}
+	pinned, err := messageUseCase.ListPinnedMessages(ctx, roomID)
+	if err != nil {
+		h.handleError(w, r, err, "room.html", baseData)
 		return
 	}
-	messages, err := messageUseCase.ListRoomMessages(ctx, roomID)
+	powers, err := roomUseCase.GetRoomPowers(ctx, roomID)
 	if err != nil {
-		h.handleError(w, err, "room.html", baseData)
+		h.handleError(w, r, err, "room.html", baseData)
 		return
 	}
 	data := RoomTemplateData{
 		BaseTemplateData: baseData,
 		Room:             room,
 		MessageList:      messages.MessageList,
+		MessageCount:     messages.Count,
+		PinnedMessages:   pinned,
 		Participants:     participants,
-	}
-	h.renderTemplate(w, "room.html", data)
+		MemberRole:       powers.Role,
+		Powers:           powers,
+		NextPageURL:      nextPageURL(r, messages.NextCursor),
+	}
+	openThread, _ := strconv.Atoi(r.URL.Query().Get("thread"))
+	data.OpenThread = uint(openThread)
+	data.ReactionEmojis = domain.ReactionEmojis
+	h.renderTemplate(w, r, "room.html", data)
 }
 
 func (h *ApiHandler) CreateMessage(w http.ResponseWriter, r *http.Request) {
@@ -379,16 +634,88 @@ func (h *ApiHandler) CreateMessage(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 	roomID, _ := strconv.Atoi(id)
 	useCase := domain.Bridge[domain.MessageUseCase](configs.MESSAGES_DB_NAME, h.useCases)
+	uploads, closeUploads, err := h.uploadsFromForm(r)
+	if err != nil {
+		h.handleError(w, r, err, "room.html", BaseTemplateData{})
+		return
+	}
+	defer closeUploads()
 	body := r.FormValue("body")
-	message := &domain.Message{RoomID: uint(roomID), Body: body}
-	err := useCase.CreateMessage(ctx, message)
+	message := &domain.Message{RoomID: uint(roomID), Body: body, ParentID: parentIDFromForm(r)}
+	err = useCase.CreateMessage(ctx, message, uploads...)
 	if err != nil {
-		h.handleError(w, err, "room.html", BaseTemplateData{})
+		h.handleError(w, r, err, "room.html", BaseTemplateData{})
+		return
+	}
+	sv := ctx.Value(configs.UserCtxKey).(domain.SessionValue)
+	h.publishRoomEvent(ctx, newMessageEvent(domain.MessageCreatedEvent, *message, sv))
+	if message.IsReply() {
+		thread := strconv.Itoa(int(*message.ParentID))
+		http.Redirect(w, r, "/room/"+id+"?thread="+thread+"#message-"+thread, http.StatusFound)
 		return
 	}
 	http.Redirect(w, r, "/room/"+id, http.StatusFound)
 }
 
+// parentIDFromForm reads the message a posted message replies to, nil when
+// it starts a new thread.
+func parentIDFromForm(r *http.Request) *uint {
+	id, err := strconv.ParseUint(r.FormValue("parent_id"), 10, 0)
+	if err != nil {
+		return nil
+	}
+	parentID := uint(id)
+	return &parentID
+}
+
+func (h *ApiHandler) JoinRoom(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	roomID := chi.URLParam(r, "id")
+	useCase := domain.Bridge[domain.RoomUseCase](configs.ROOMS_DB_NAME, h.useCases)
+	err := useCase.JoinRoom(ctx, roomID)
+	if err != nil {
+		h.handleError(w, r, err, "room.html", BaseTemplateData{})
+		return
+	}
+	http.Redirect(w, r, "/room/"+roomID, http.StatusFound)
+}
+
+func (h *ApiHandler) LeaveRoom(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	roomID := chi.URLParam(r, "id")
+	useCase := domain.Bridge[domain.RoomUseCase](configs.ROOMS_DB_NAME, h.useCases)
+	err := useCase.LeaveRoom(ctx, roomID)
+	if err != nil {
+		h.handleError(w, r, err, "room.html", BaseTemplateData{})
+		return
+	}
+	sv := ctx.Value(configs.UserCtxKey).(domain.SessionValue)
+	h.publishAccessChanged(ctx, roomID, uint(sv.ID))
+	http.Redirect(w, r, "/my-rooms", http.StatusFound)
+}
+
+func (h *ApiHandler) MyRoomsPage(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	sv := ctx.Value(configs.UserCtxKey).(domain.SessionValue)
+	data := MyRoomsTemplateData{
+		BaseTemplateData: BaseTemplateData{
+			IsAuthenticated: true,
+			AvatarURL:       sv.Avatar,
+			Username:        sv.Username,
+		},
+	}
+	useCase := domain.Bridge[domain.RoomUseCase](configs.ROOMS_DB_NAME, h.useCases)
+	rooms, err := useCase.ListJoinedRooms(ctx, pageFromRequest(r))
+	if err != nil {
+		h.handleFormError(w, r, err, "my_rooms.html", &data.BaseTemplateData, &data)
+		return
+	}
+	data.RoomList = rooms.List
+	data.RoomCount = rooms.Count
+	data.NextPageURL = nextPageURL(r, rooms.NextCursor)
+	h.renderTemplate(w, r, "my_rooms.html", data)
+}
+
 func (h *ApiHandler) DeleteRoomPage(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	request := ctx.Value(configs.UserCtxKey).(domain.SessionValue)
@@ -401,14 +728,14 @@ func (h *ApiHandler) DeleteRoomPage(w http.ResponseWriter, r *http.Request) {
 	roomID := chi.URLParam(r, "id")
 	room, err := usecase.GetUserRoom(ctx, roomID)
 	if err != nil {
-		h.handleError(w, err, "not_found.html", baseData)
+		h.handleError(w, r, err, "not_found.html", baseData)
 		return
 	}
 	data := DeleteForm{
 		BaseTemplateData: baseData,
 		Obj:              room.Name,
 	}
-	h.renderTemplate(w, "delete.html", data)
+	h.renderTemplate(w, r, "delete.html", data)
 }
 
 func (h *ApiHandler) DeleteRoom(w http.ResponseWriter, r *http.Request) {
@@ -417,7 +744,7 @@ func (h *ApiHandler) DeleteRoom(w http.ResponseWriter, r *http.Request) {
 	useCase := domain.Bridge[domain.RoomUseCase](configs.ROOMS_DB_NAME, h.useCases)
 	err := useCase.DeleteUserRoom(ctx, roomID)
 	if err != nil {
-		h.handleError(w, err, "room.html", BaseTemplateData{})
+		h.handleError(w, r, err, "room.html", BaseTemplateData{})
 		return
 	}
 	http.Redirect(w, r, "/home", http.StatusFound)
@@ -439,12 +766,12 @@ func (h *ApiHandler) UpdateRoomPage(w http.ResponseWriter, r *http.Request) {
 	}
 	room, err := roomUsecase.GetUserRoom(ctx, roomID)
 	if err != nil {
-		h.handleError(w, err, "room_form.html", baseData)
+		h.handleError(w, r, err, "room_form.html", baseData)
 		return
 	}
-	topics, err := topicUsecase.ListAllTopics(ctx)
+	topics, err := topicUsecase.ListAllTopics(ctx, domain.Page{Limit: domain.MaxPageSize})
 	if err != nil {
-		h.handleError(w, err, "room_form.html", baseData)
+		h.handleError(w, r, err, "room_form.html", baseData)
 		return
 	}
 	data.TopicList = topics.List
@@ -452,8 +779,9 @@ func (h *ApiHandler) UpdateRoomPage(w http.ResponseWriter, r *http.Request) {
 		TopicName:   room.Topic.Name,
 		Name:        room.Name,
 		Description: room.Description,
+		Visibility:  room.Visibility,
 	}
-	h.renderTemplate(w, "room_form.html", data)
+	h.renderTemplate(w, r, "room_form.html", data)
 }
 
 func (h *ApiHandler) UpdateRoom(w http.ResponseWriter, r *http.Request) {
@@ -464,11 +792,13 @@ func (h *ApiHandler) UpdateRoom(w http.ResponseWriter, r *http.Request) {
 		Name:        r.FormValue("name"),
 		TopicName:   r.FormValue("topic"),
 		Description: r.FormValue("description"),
+		Visibility:  r.FormValue("visibility"),
 	}
 	err := useCase.UpdateRoom(ctx, roomID, roomForm)
 	if err != nil {
-		h.handleError(w, err, "room_form.html", BaseTemplateData{})
+		h.handleError(w, r, err, "room_form.html", BaseTemplateData{})
 		return
 	}
+	h.publishAccessChanged(ctx, roomID, 0)
 	http.Redirect(w, r, "/home", http.StatusFound)
 }
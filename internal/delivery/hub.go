@@ -0,0 +1,225 @@
+package delivery
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/elyarsadig/studybud-go/internal/domain"
+	"github.com/elyarsadig/studybud-go/pkg/logger"
+	redispkg "github.com/elyarsadig/studybud-go/pkg/redis"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	writeWait      = 10 * time.Second
+	pongWait       = 60 * time.Second
+	pingPeriod     = (pongWait * 9) / 10
+	maxMessageSize = 4096
+	sendBufferSize = 32
+)
+
+// RoomHub keeps track of the websocket clients connected to this instance and
+// fans room events out to them. Events are published through redis so every
+// running instance delivers them to its own clients.
+type RoomHub struct {
+	redis   *redispkg.Redis
+	logger  logger.Logger
+	canRead func(client *roomClient) bool
+	mu      sync.RWMutex
+	rooms   map[uint]map[*roomClient]struct{}
+}
+
+type roomClient struct {
+	conn    *websocket.Conn
+	roomID  uint
+	session domain.SessionValue
+	send    chan []byte
+}
+
+// NewRoomHub returns a hub that asks canRead whether a client may still
+// watch its room whenever the access to that room changes.
+func NewRoomHub(redis *redispkg.Redis, logger logger.Logger, canRead func(client *roomClient) bool) *RoomHub {
+	return &RoomHub{
+		redis:   redis,
+		logger:  logger,
+		canRead: canRead,
+		rooms:   make(map[uint]map[*roomClient]struct{}),
+	}
+}
+
+func (h *RoomHub) Run(ctx context.Context) {
+	pubsub := h.redis.Subscribe(ctx, domain.RoomEventsChannel)
+	defer pubsub.Close()
+	ch := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-ch:
+			if !ok {
+				return
+			}
+			h.dispatch([]byte(msg.Payload))
+		}
+	}
+}
+
+func (h *RoomHub) Publish(ctx context.Context, event domain.RoomEvent) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return h.redis.Publish(ctx, domain.RoomEventsChannel, payload)
+}
+
+func (h *RoomHub) dispatch(payload []byte) {
+	var event domain.RoomEvent
+	err := json.Unmarshal(payload, &event)
+	if err != nil {
+		h.logger.Error(err.Error())
+		return
+	}
+	switch event.Type {
+	case domain.RoomAccessChangedEvent:
+		h.disconnect(event)
+		return
+	case domain.SessionsRevokedEvent:
+		h.disconnectSessions(event)
+		return
+	}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for client := range h.rooms[event.RoomID] {
+		select {
+		case client.send <- payload:
+		default:
+			h.logger.Warn("dropping slow websocket client", "room", event.RoomID, "user", client.session.Username)
+			client.conn.Close()
+		}
+	}
+}
+
+// disconnect closes the sockets of the user named by the event, or of
+// everybody in the room who may no longer read it when no user is named.
+func (h *RoomHub) disconnect(event domain.RoomEvent) {
+	h.mu.RLock()
+	var clients []*roomClient
+	for client := range h.rooms[event.RoomID] {
+		if event.UserID == 0 || uint(client.session.ID) == event.UserID {
+			clients = append(clients, client)
+		}
+	}
+	h.mu.RUnlock()
+	for _, client := range clients {
+		if event.UserID == 0 && h.canRead(client) {
+			continue
+		}
+		h.unregister(client)
+	}
+}
+
+// disconnectSessions closes the sockets of the user named by the event in
+// every room, or only those opened with the session key of the event.
+func (h *RoomHub) disconnectSessions(event domain.RoomEvent) {
+	h.mu.RLock()
+	var clients []*roomClient
+	for _, room := range h.rooms {
+		for client := range room {
+			if uint(client.session.ID) != event.UserID {
+				continue
+			}
+			if event.SessionKey == "" || client.session.SessionKey == event.SessionKey {
+				clients = append(clients, client)
+			}
+		}
+	}
+	h.mu.RUnlock()
+	for _, client := range clients {
+		h.unregister(client)
+	}
+}
+
+func (h *RoomHub) register(client *roomClient) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	clients, ok := h.rooms[client.roomID]
+	if !ok {
+		clients = make(map[*roomClient]struct{})
+		h.rooms[client.roomID] = clients
+	}
+	clients[client] = struct{}{}
+}
+
+func (h *RoomHub) unregister(client *roomClient) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	clients, ok := h.rooms[client.roomID]
+	if !ok {
+		return
+	}
+	if _, ok := clients[client]; !ok {
+		return
+	}
+	delete(clients, client)
+	close(client.send)
+	if len(clients) == 0 {
+		delete(h.rooms, client.roomID)
+	}
+}
+
+func (c *roomClient) writePump() {
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.conn.Close()
+	}()
+	for {
+		select {
+		case payload, ok := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if !ok {
+				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				return
+			}
+			if err := c.conn.WriteMessage(websocket.TextMessage, payload); err != nil {
+				return
+			}
+		case <-ticker.C:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func newMessageEvent(eventType string, message domain.Message, sv domain.SessionValue) domain.RoomEvent {
+	event := domain.RoomEvent{
+		Type:   eventType,
+		RoomID: message.RoomID,
+		Message: domain.MessageEvent{
+			ID:       message.ID,
+			Body:     message.Body,
+			BodyHTML: string(message.BodyHTML),
+			UserID:   uint(sv.ID),
+			Username: sv.Username,
+			Avatar:   sv.Avatar,
+			Since:    message.Since,
+			ParentID: message.ParentID,
+			Edited:   message.Edited != nil,
+		},
+	}
+	for _, attachment := range message.Attachments {
+		event.Message.Attachments = append(event.Message.Attachments, domain.AttachmentEvent{
+			ID:           attachment.ID,
+			Name:         attachment.Name,
+			ContentType:  attachment.ContentType,
+			Size:         attachment.SizeLabel,
+			URL:          attachmentURL(attachment),
+			ThumbnailURL: thumbnailURL(attachment),
+		})
+	}
+	return event
+}
@@ -0,0 +1,20 @@
+package domain
+
+type AdminUserForm struct {
+	Name        string
+	Username    string
+	Email       string
+	Bio         string
+	IsActive    bool
+	IsStaff     bool
+	IsSuperuser bool
+	RequireTOTP bool
+}
+
+type AdminMessageForm struct {
+	Body string
+}
+
+type AdminTopicForm struct {
+	Name string
+}
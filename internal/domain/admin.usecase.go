@@ -0,0 +1,28 @@
+package domain
+
+import "context"
+
+// AdminUseCase backs the staff console. Every method that changes data also
+// writes an AuditLog entry for the staff member found in the context.
+type AdminUseCase interface {
+	Bridger
+	ListUsers(ctx context.Context, searchQuery string, page Page) (Users, error)
+	GetUser(ctx context.Context, id string) (User, error)
+	UpdateUser(ctx context.Context, id string, form AdminUserForm) error
+	DeleteUser(ctx context.Context, id string) error
+	SetUsersActive(ctx context.Context, ids []string, active bool) error
+	ListRooms(ctx context.Context, searchQuery string, page Page) (Rooms, error)
+	GetRoom(ctx context.Context, id string) (Room, error)
+	UpdateRoom(ctx context.Context, id string, form RoomForm) error
+	DeleteRooms(ctx context.Context, ids []string) error
+	ListMessages(ctx context.Context, searchQuery string, page Page) (Messages, error)
+	GetMessage(ctx context.Context, id string) (Message, error)
+	UpdateMessage(ctx context.Context, id string, form AdminMessageForm) error
+	DeleteMessages(ctx context.Context, ids []string) ([]Message, error)
+	ListTopics(ctx context.Context, searchQuery string, page Page) (Topics, error)
+	GetTopic(ctx context.Context, id string) (Topic, error)
+	UpdateTopic(ctx context.Context, id string, form AdminTopicForm) error
+	DeleteTopics(ctx context.Context, ids []string) error
+	MergeTopics(ctx context.Context, ids []string, targetName string) error
+	ListAuditLogs(ctx context.Context, page Page) (AuditLogs, error)
+}
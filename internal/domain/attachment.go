@@ -0,0 +1,47 @@
+package domain
+
+import (
+	"io"
+	"strings"
+	"time"
+)
+
+// Attachment is a file posted along with a message. The content lives in the
+// file storage under StorageKey, images also get a smaller copy under
+// ThumbnailKey. When its message is deleted MessageID is cleared and the
+// attachment is swept along with its files later on.
+type Attachment struct {
+	ID           uint      `gorm:"primaryKey"`
+	MessageID    *uint     `gorm:"index:idx_attachment_message_id"`
+	Message      *Message  `gorm:"foreignKey:MessageID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL"`
+	Name         string    `gorm:"type:varchar(255);not null"`
+	ContentType  string    `gorm:"type:varchar(100);not null"`
+	Size         int64     `gorm:"not null"`
+	StorageKey   string    `gorm:"type:varchar(255);not null;uniqueIndex"`
+	ThumbnailKey string    `gorm:"type:varchar(255);not null;default:''"`
+	Created      time.Time `gorm:"type:timestamp with time zone;not null;autoCreateTime"`
+	SizeLabel    string    `gorm:"-"`
+}
+
+func (a Attachment) HasThumbnail() bool {
+	return a.ThumbnailKey != ""
+}
+
+func (a Attachment) IsImage() bool {
+	return strings.HasPrefix(a.ContentType, "image/")
+}
+
+// Upload is a file sent to be attached to a new message. Name is the one the
+// client gave, its content type is never trusted and sniffed from Content.
+type Upload struct {
+	Name    string
+	Content io.Reader
+}
+
+// AttachmentLimits bounds the files attached to a single message. MaxSize is
+// in bytes and applies to each file.
+type AttachmentLimits struct {
+	MaxSize      int64
+	MaxFiles     int
+	AllowedTypes []string
+}
@@ -0,0 +1,28 @@
+package domain
+
+import "time"
+
+const (
+	AuditActionUpdate     = "update"
+	AuditActionDelete     = "delete"
+	AuditActionActivate   = "activate"
+	AuditActionDeactivate = "deactivate"
+	AuditActionMerge      = "merge"
+)
+
+type AuditLog struct {
+	ID         uint      `gorm:"primaryKey"`
+	ActorID    uint      `gorm:"not null;index:idx_audit_logs_actor_id"`
+	Action     string    `gorm:"type:varchar(50);not null"`
+	ObjectType string    `gorm:"type:varchar(100);not null"`
+	ObjectID   string    `gorm:"type:varchar(100);not null"`
+	Detail     string    `gorm:"type:text"`
+	Created    time.Time `gorm:"type:timestamp with time zone;not null;autoCreateTime;index:idx_audit_logs_created"`
+	Actor      User      `gorm:"foreignKey:ActorID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;deferrable:InitiallyDeferred"`
+}
+
+type AuditLogs struct {
+	List       []AuditLog
+	Count      int64
+	NextCursor string
+}
@@ -0,0 +1,9 @@
+package domain
+
+import "context"
+
+type AuditRepository interface {
+	Bridger
+	Create(ctx context.Context, entry *AuditLog) error
+	List(ctx context.Context, page Page) (AuditLogs, error)
+}
@@ -1,5 +1,7 @@
 package domain
 
+import "strings"
+
 type AuthGroup struct {
 	ID   uint   `gorm:"primaryKey"`
 	Name string `gorm:"type:varchar(150);not null;unique"`
@@ -20,3 +22,33 @@ type AuthGroupPermission struct {
 	Group        AuthGroup      `gorm:"foreignKey:GroupID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;deferrable:InitiallyDeferred"`
 	Permission   AuthPermission `gorm:"foreignKey:PermissionID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;deferrable:InitiallyDeferred"`
 }
+
+const (
+	MembersGroup    = "members"
+	ModeratorsGroup = "moderators"
+)
+
+const (
+	PermViewRoom      = "rooms.view_room"
+	PermAddRoom       = "rooms.add_room"
+	PermChangeRoom    = "rooms.change_room"
+	PermDeleteRoom    = "rooms.delete_room"
+	PermViewMessage   = "messages.view_message"
+	PermAddMessage    = "messages.add_message"
+	PermChangeMessage = "messages.change_message"
+	PermDeleteMessage = "messages.delete_message"
+	PermViewTopic     = "topics.view_topic"
+	PermAddTopic      = "topics.add_topic"
+	PermChangeTopic   = "topics.change_topic"
+	PermDeleteTopic   = "topics.delete_topic"
+)
+
+// ParsePermission splits a permission string such as "rooms.delete_room" into
+// its content type app label and the permission codename.
+func ParsePermission(perm string) (appLabel string, codename string, ok bool) {
+	appLabel, codename, ok = strings.Cut(perm, ".")
+	if !ok || len(appLabel) == 0 || len(codename) == 0 {
+		return "", "", false
+	}
+	return appLabel, codename, true
+}
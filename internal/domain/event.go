@@ -0,0 +1,51 @@
+package domain
+
+// RoomEventsChannel is the redis channel room events are published on.
+const RoomEventsChannel = "room_events"
+
+const (
+	MessageCreatedEvent = "message.created"
+	MessageDeletedEvent = "message.deleted"
+	MessageEditedEvent  = "message.edited"
+
+	// RoomAccessChangedEvent tells the instances that somebody may have lost
+	// access to a room. It names the user who left or was kicked, without a
+	// user everybody watching the room is checked again.
+	RoomAccessChangedEvent = "room.access_changed"
+	// SessionsRevokedEvent tells the instances to close the sockets of the
+	// user it names, only those opened with SessionKey when it is set.
+	SessionsRevokedEvent = "sessions.revoked"
+)
+
+type RoomEvent struct {
+	Type       string       `json:"type"`
+	RoomID     uint         `json:"room_id"`
+	UserID     uint         `json:"user_id,omitempty"`
+	SessionKey string       `json:"session_key,omitempty"`
+	Message    MessageEvent `json:"message"`
+}
+
+type MessageEvent struct {
+	ID       uint   `json:"id"`
+	Body     string `json:"body,omitempty"`
+	BodyHTML string `json:"body_html,omitempty"`
+	UserID   uint   `json:"user_id,omitempty"`
+	Username string `json:"username,omitempty"`
+	Avatar   string `json:"avatar,omitempty"`
+	Since    string `json:"since,omitempty"`
+	ParentID *uint  `json:"parent_id,omitempty"`
+	Edited   bool   `json:"edited,omitempty"`
+
+	Attachments []AttachmentEvent `json:"attachments,omitempty"`
+}
+
+// AttachmentEvent describes a file attached to a message, ThumbnailURL is only
+// set on images that have a thumbnail.
+type AttachmentEvent struct {
+	ID           uint   `json:"id"`
+	Name         string `json:"name"`
+	ContentType  string `json:"content_type"`
+	Size         string `json:"size"`
+	URL          string `json:"url"`
+	ThumbnailURL string `json:"thumbnail_url,omitempty"`
+}
@@ -0,0 +1,22 @@
+package domain
+
+import "time"
+
+// UserIdentity links a user to their account at an OpenID Connect provider.
+// Subject is the stable "sub" claim of the provider, the email is kept for
+// display only.
+type UserIdentity struct {
+	ID       uint      `gorm:"primaryKey"`
+	UserID   uint      `gorm:"not null;index:idx_user_identities_user_id"`
+	Provider string    `gorm:"type:varchar(50);not null;uniqueIndex:idx_user_identities_provider_subject"`
+	Subject  string    `gorm:"type:varchar(255);not null;uniqueIndex:idx_user_identities_provider_subject"`
+	Email    string    `gorm:"type:varchar(254)"`
+	Created  time.Time `gorm:"type:timestamp with time zone;not null;autoCreateTime"`
+	User     User      `gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;deferrable:InitiallyDeferred"`
+}
+
+// OIDCProvider is a single sign-on provider as offered on the login page.
+type OIDCProvider struct {
+	Name        string
+	DisplayName string
+}
@@ -1,6 +1,8 @@
 package domain
 
 import (
+	"html/template"
+	"slices"
 	"time"
 )
 
@@ -14,9 +16,75 @@ type Message struct {
 	Room    Room      `gorm:"foreignKey:RoomID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;deferrable:InitiallyDeferred"`
 	User    User      `gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;deferrable:InitiallyDeferred"`
 	Since   string    `gorm:"-"`
+
+	// BodyHTML is Body rendered from Markdown and sanitized, it is kept along
+	// so pages do not render it again on every load.
+	BodyHTML template.HTML `gorm:"type:text;not null;default:''"`
+
+	// Pinned messages are kept on top of the room by its moderators.
+	Pinned bool `gorm:"not null;default:false"`
+
+	// ParentID is set on replies and points at the message that started the
+	// thread. Threads are one level deep, a reply to a reply joins the thread
+	// of its parent.
+	ParentID   *uint     `gorm:"index:idx_message_parent_id"`
+	Parent     *Message  `gorm:"foreignKey:ParentID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+	ReplyCount int64     `gorm:"->;-:migration"`
+	Replies    []Message `gorm:"-"`
+
+	// Edited is set the last time the author changed the body, the bodies it
+	// had before are kept as MessageRevision. Editable tells the current user
+	// may still edit it.
+	Edited   *time.Time `gorm:"type:timestamp with time zone"`
+	Editable bool       `gorm:"-"`
+
+	Reactions   []ReactionCount `gorm:"-"`
+	Attachments []Attachment    `gorm:"-"`
+}
+
+func (m Message) IsReply() bool {
+	return m.ParentID != nil
+}
+
+// MessageRevision is a body a message had before it was edited.
+type MessageRevision struct {
+	ID        uint      `gorm:"primaryKey"`
+	MessageID uint      `gorm:"not null;index:idx_message_revision_message_id"`
+	Message   Message   `gorm:"foreignKey:MessageID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+	Body      string    `gorm:"type:text;not null"`
+	Created   time.Time `gorm:"type:timestamp with time zone;not null;autoCreateTime"`
+	Since     string    `gorm:"-"`
+}
+
+// ReactionEmojis are the emojis messages can be reacted with.
+var ReactionEmojis = []string{"👍", "❤️", "😂", "🎉", "🤔", "👀"}
+
+func IsReactionEmoji(emoji string) bool {
+	return slices.Contains(ReactionEmojis, emoji)
+}
+
+// MessageReaction is an emoji a user put under a message, a user reacts with
+// each emoji once.
+type MessageReaction struct {
+	MessageID uint      `gorm:"primaryKey"`
+	UserID    uint      `gorm:"primaryKey;index:idx_message_reaction_user_id"`
+	Emoji     string    `gorm:"primaryKey;type:varchar(16)"`
+	Message   Message   `gorm:"foreignKey:MessageID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+	User      User      `gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+	Created   time.Time `gorm:"type:timestamp with time zone;not null;autoCreateTime"`
+}
+
+// ReactionCount sums up the reactions with one emoji under a message, Reacted
+// tells the current user is one of them.
+type ReactionCount struct {
+	MessageID uint
+	Emoji     string
+	Count     int64
+	Reacted   bool
 }
 
 type Messages struct {
 	MessageList []Message
 	Count       int64
+	NextCursor  string
 }
@@ -1,13 +1,32 @@
 package domain
 
-import "context"
+import (
+	"context"
+	"html/template"
+)
 
 type MessageRepository interface {
 	Bridger
-	ListUserMessages(ctx context.Context, userID string) (Messages, error)
-	ListRoomMessages(ctx context.Context, roomID string) (Messages, error)
+	ListUserMessages(ctx context.Context, userID string, viewerID uint, page Page) (Messages, error)
+	ListRoomMessages(ctx context.Context, roomID string, page Page) (Messages, error)
 	CreateMessage(ctx context.Context, message *Message) error
-	ListAllMessages(ctx context.Context) (Messages, error)
+	ListAllMessages(ctx context.Context, page Page) (Messages, error)
+	ListVisibleMessages(ctx context.Context, viewerID uint, page Page) (Messages, error)
 	Get(ctx context.Context, id string) (Message, error)
 	Delete(ctx context.Context, id string) error
+	SearchMessages(ctx context.Context, searchQuery string, page Page) (Messages, error)
+	Update(ctx context.Context, message Message) error
+	Edit(ctx context.Context, message Message, body string, bodyHTML template.HTML) (Message, error)
+	ListRevisions(ctx context.Context, messageID uint) ([]MessageRevision, error)
+	ToggleReaction(ctx context.Context, reaction MessageReaction) error
+	ListReactionCounts(ctx context.Context, messageIDs []uint, viewerID uint) ([]ReactionCount, error)
+	SetPinned(ctx context.Context, id uint, pinned bool) error
+	ListPinnedMessages(ctx context.Context, roomID string) ([]Message, error)
+	ListReplies(ctx context.Context, parentIDs []uint) ([]Message, error)
+	GetThread(ctx context.Context, id string) ([]Message, error)
+	DeleteMany(ctx context.Context, ids []uint) error
+	GetAttachment(ctx context.Context, id string) (Attachment, error)
+	ListAttachments(ctx context.Context, messageIDs []uint) ([]Attachment, error)
+	ListOrphanedAttachments(ctx context.Context, limit int) ([]Attachment, error)
+	DeleteAttachments(ctx context.Context, ids []uint) error
 }
@@ -1,13 +1,24 @@
 package domain
 
-import "context"
+import (
+	"context"
+	"io"
+)
 
 type MessageUseCase interface {
 	Bridger
-	ListAllMessages(ctx context.Context) (Messages, error)
-	ListUserMessages(ctx context.Context, userID string) (Messages, error)
-	ListRoomMessages(ctx context.Context, roomID string) (Messages, error)
-	CreateMessage(ctx context.Context, message *Message) error
+	ListAllMessages(ctx context.Context, page Page) (Messages, error)
+	ListUserMessages(ctx context.Context, userID string, page Page) (Messages, error)
+	ListRoomMessages(ctx context.Context, roomID string, page Page) (Messages, error)
+	CreateMessage(ctx context.Context, message *Message, uploads ...Upload) error
 	GetUserMessage(ctx context.Context, id string) (Message, error)
 	Delete(ctx context.Context, id string) error
+	EditMessage(ctx context.Context, id, body string) (Message, error)
+	ListRevisions(ctx context.Context, id string) (Message, []MessageRevision, error)
+	ToggleReaction(ctx context.Context, id, emoji string) (Message, error)
+	PinMessage(ctx context.Context, id string, pinned bool) (Message, error)
+	ListPinnedMessages(ctx context.Context, roomID string) ([]Message, error)
+	GetThread(ctx context.Context, id string) (Message, error)
+	OpenAttachment(ctx context.Context, id string, thumbnail bool) (Attachment, io.ReadCloser, error)
+	SweepAttachments(ctx context.Context) (int, error)
 }
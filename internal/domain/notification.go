@@ -0,0 +1,33 @@
+package domain
+
+import "time"
+
+// Kinds of notification.
+const (
+	NotificationMention = "mention"
+)
+
+// Notification tells UserID that ActorID did something that concerns them,
+// like mentioning them in a message.
+type Notification struct {
+	ID        uint      `gorm:"primaryKey"`
+	UserID    uint      `gorm:"not null;index:idx_notifications_user_id_read"`
+	Read      bool      `gorm:"not null;default:false;index:idx_notifications_user_id_read"`
+	Kind      string    `gorm:"type:varchar(32);not null"`
+	ActorID   uint      `gorm:"not null"`
+	RoomID    uint      `gorm:"not null"`
+	MessageID *uint     `gorm:"index:idx_notifications_message_id"`
+	Created   time.Time `gorm:"type:timestamp with time zone;not null;autoCreateTime"`
+	User      User      `gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+	Actor     User      `gorm:"foreignKey:ActorID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+	Room      Room      `gorm:"foreignKey:RoomID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+	Message   *Message  `gorm:"foreignKey:MessageID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+	Since     string    `gorm:"-"`
+}
+
+type Notifications struct {
+	List       []Notification
+	Count      int64
+	Unread     int64
+	NextCursor string
+}
@@ -0,0 +1,13 @@
+package domain
+
+import "context"
+
+type NotificationRepository interface {
+	Bridger
+	CreateMany(ctx context.Context, notifications []Notification) error
+	ListByUser(ctx context.Context, userID uint, page Page) (Notifications, error)
+	Get(ctx context.Context, userID uint, id string) (Notification, error)
+	CountUnread(ctx context.Context, userID uint) (int64, error)
+	MarkRead(ctx context.Context, userID uint, id uint) error
+	MarkAllRead(ctx context.Context, userID uint) error
+}
@@ -0,0 +1,11 @@
+package domain
+
+import "context"
+
+type NotificationUseCase interface {
+	Bridger
+	ListNotifications(ctx context.Context, page Page) (Notifications, error)
+	CountUnread(ctx context.Context) (int64, error)
+	OpenNotification(ctx context.Context, id string) (Notification, error)
+	MarkAllRead(ctx context.Context) error
+}
@@ -0,0 +1,21 @@
+package domain
+
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+type Page struct {
+	Cursor string
+	Limit  int
+}
+
+func (p Page) Size() int {
+	if p.Limit <= 0 {
+		return DefaultPageSize
+	}
+	if p.Limit > MaxPageSize {
+		return MaxPageSize
+	}
+	return p.Limit
+}
@@ -0,0 +1,11 @@
+package domain
+
+import "context"
+
+type PermissionRepository interface {
+	Bridger
+	UserHasPermission(ctx context.Context, userID string, appLabel, codename string) (bool, error)
+	UserIsActive(ctx context.Context, userID string) (bool, error)
+	ListUserPermissions(ctx context.Context, userID string) ([]string, error)
+	AddUserToGroup(ctx context.Context, userID uint, groupName string) error
+}
@@ -0,0 +1,9 @@
+package domain
+
+import "context"
+
+type PermissionUseCase interface {
+	Bridger
+	HasPerm(ctx context.Context, userID string, perm string) (bool, error)
+	ListUserPermissions(ctx context.Context, userID string) ([]string, error)
+}
@@ -1,6 +1,48 @@
 package domain
 
-import "time"
+import (
+	"html/template"
+	"time"
+)
+
+// Room visibilities. Public rooms are listed and readable by anyone, unlisted
+// rooms are readable by anyone with the link but never listed, and private
+// rooms are only readable by their host and participants.
+const (
+	RoomVisibilityPublic   = "public"
+	RoomVisibilityUnlisted = "unlisted"
+	RoomVisibilityPrivate  = "private"
+)
+
+var RoomVisibilities = []string{RoomVisibilityPublic, RoomVisibilityUnlisted, RoomVisibilityPrivate}
+
+// Room roles. The host owns the room, co-hosts help running it, moderators
+// keep the conversation in order and members may read and post.
+const (
+	RoomRoleHost      = "host"
+	RoomRoleCoHost    = "cohost"
+	RoomRoleModerator = "moderator"
+	RoomRoleMember    = "member"
+)
+
+// RoomAssignableRoles are the roles a participant can be appointed to, the
+// host role only changes hands with the room.
+var RoomAssignableRoles = []string{RoomRoleCoHost, RoomRoleModerator, RoomRoleMember}
+
+var roomRoleRanks = map[string]int{
+	RoomRoleMember:    0,
+	RoomRoleModerator: 1,
+	RoomRoleCoHost:    2,
+	RoomRoleHost:      3,
+}
+
+// RoomRoleRank orders the room roles from member (0) to host.
+func RoomRoleRank(role string) int {
+	return roomRoleRanks[role]
+}
+
+// RoomInviteExpirations are the lifetimes a host can pick for an invite link.
+var RoomInviteExpirations = []time.Duration{24 * time.Hour, 7 * 24 * time.Hour, 30 * 24 * time.Hour}
 
 type Room struct {
 	ID          uint      `gorm:"primaryKey"`
@@ -10,17 +52,80 @@ type Room struct {
 	Created     time.Time `gorm:"type:timestamp with time zone;not null;autoCreateTime"`
 	HostID      uint      `gorm:"index:idx_room_host_id"`
 	TopicID     uint      `gorm:"index:idx_room_topic_id"`
-	Host        User      `gorm:"foreignKey:HostID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;deferrable:InitiallyDeferred"`
+	Visibility  string    `gorm:"type:varchar(10);not null;default:public;index:idx_room_visibility"`
+	Host        User      `gorm:"foreignKey:HostID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;deferrable:InitiallyDeferred"`
 	Topic       Topic     `gorm:"foreignKey:TopicID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;deferrable:InitiallyDeferred"`
 	Since       string    `gorm:"-"`
+
+	// DescriptionHTML is Description rendered from Markdown and sanitized.
+	DescriptionHTML template.HTML `gorm:"type:text;not null;default:''"`
+
+	// InviteGeneration is part of every invite link, bumping it revokes the
+	// links handed out so far.
+	InviteGeneration uint `gorm:"not null;default:0"`
+	// Archived rooms lost their host with nobody left to take over. They stay
+	// readable but nobody can join or post until staff hand them to someone.
+	Archived bool `gorm:"not null;default:false"`
 }
 
+func (r Room) IsPrivate() bool {
+	return r.Visibility == RoomVisibilityPrivate
+}
+
+// RoomParticipant is the membership of a user in a room. Users join and leave
+// rooms explicitly, the host is a participant from the start.
 type RoomParticipant struct {
-	ID     uint `gorm:"primaryKey"`
-	RoomID uint `gorm:"not null;index:idx_room_participants_room_id"`
-	UserID uint `gorm:"not null;index:idx_room_participants_user_id"`
-	Room   Room `gorm:"foreignKey:RoomID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;deferrable:InitiallyDeferred"`
-	User   User `gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;deferrable:InitiallyDeferred"`
+	ID     uint   `gorm:"primaryKey"`
+	RoomID uint   `gorm:"not null;index:idx_room_participants_room_id"`
+	UserID uint   `gorm:"not null;index:idx_room_participants_user_id"`
+	Role   string `gorm:"type:varchar(10);not null;default:member"`
+	// Muted participants stay in the room but may not post.
+	Muted bool `gorm:"not null;default:false"`
+	Room  Room `gorm:"foreignKey:RoomID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;deferrable:InitiallyDeferred"`
+	User  User `gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;deferrable:InitiallyDeferred"`
+}
+
+// RoomPowers is what the current user may do in a room, granted by their role
+// in it or by their global permissions.
+type RoomPowers struct {
+	// Role is the role of the user in the room, empty when they have not
+	// joined it.
+	Role string
+	// Moderate allows deleting any message, pinning messages and muting or
+	// kicking participants of a lower role.
+	Moderate bool
+	// Manage allows editing the room, handing out invites, answering join
+	// requests and appointing moderators.
+	Manage bool
+	// Delete allows deleting the room and appointing co-hosts.
+	Delete bool
+	// Staff is set when the powers come from global permissions, staff may
+	// act on every participant but the host.
+	Staff bool
+	// Muted is set when the user is a participant muted by a moderator.
+	Muted bool
+}
+
+// NewRoomPowers returns the powers that come with a room role.
+func NewRoomPowers(role string) RoomPowers {
+	return RoomPowers{
+		Role:     role,
+		Moderate: RoomRoleRank(role) >= RoomRoleRank(RoomRoleModerator),
+		Manage:   RoomRoleRank(role) >= RoomRoleRank(RoomRoleCoHost),
+		Delete:   role == RoomRoleHost,
+	}
+}
+
+// Outranks reports whether the powers are enough to act on a participant with
+// role, e.g. to mute them or change their role.
+func (p RoomPowers) Outranks(role string) bool {
+	if role == RoomRoleHost {
+		return false
+	}
+	if p.Staff {
+		return true
+	}
+	return RoomRoleRank(p.Role) > RoomRoleRank(role)
 }
 
 type RoomWithDetails struct {
@@ -30,12 +135,34 @@ type RoomWithDetails struct {
 }
 
 type Rooms struct {
-	List  []RoomWithDetails
-	Count int64
+	List       []RoomWithDetails
+	Count      int64
+	NextCursor string
 }
 
 type RoomForm struct {
 	TopicName   string
 	Name        string
 	Description string
+	Visibility  string
+}
+
+// RoomJoinRequest is a request to join a private room, waiting for the host
+// to approve or deny it.
+type RoomJoinRequest struct {
+	ID      uint      `gorm:"primaryKey"`
+	RoomID  uint      `gorm:"not null;uniqueIndex:idx_room_join_request"`
+	UserID  uint      `gorm:"not null;uniqueIndex:idx_room_join_request"`
+	Created time.Time `gorm:"type:timestamp with time zone;not null;autoCreateTime"`
+	Room    Room      `gorm:"foreignKey:RoomID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;deferrable:InitiallyDeferred"`
+	User    User      `gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;deferrable:InitiallyDeferred"`
+	Since   string    `gorm:"-"`
+}
+
+// RoomInviteClaims is encrypted into an invite link. The link stops working
+// when it expires or the host resets the room's invite links.
+type RoomInviteClaims struct {
+	RoomID     uint  `json:"room_id"`
+	Generation uint  `json:"generation"`
+	ExpiresAt  int64 `json:"expires_at"`
 }
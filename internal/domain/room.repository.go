@@ -4,12 +4,28 @@ import "context"
 
 type RoomRepository interface {
 	Bridger
-	ListAllRooms(ctx context.Context) (Rooms, error)
+	ListAllRooms(ctx context.Context, page Page) (Rooms, error)
 	CreateRoom(ctx context.Context, room *Room) error
 	UpdateRoom(ctx context.Context, room Room) error
-	ListUserRooms(ctx context.Context, userID string) (Rooms, error)
+	ListVisibleRooms(ctx context.Context, viewerID uint, searchQuery string, page Page) (Rooms, error)
+	ListUserRooms(ctx context.Context, userID string, viewerID uint, page Page) (Rooms, error)
 	GetRoomById(ctx context.Context, roomID string) (Room, error)
 	ListRoomParticipants(ctx context.Context, roomID string) ([]RoomParticipant, error)
-	SearchRoom(ctx context.Context, searchQuery string) (Rooms, error)
+	SearchRoom(ctx context.Context, searchQuery string, page Page) (Rooms, error)
 	DeleteUserRoom(ctx context.Context, roomID, hostID string) error
+	DeleteMany(ctx context.Context, ids []uint) error
+	ListMemberRooms(ctx context.Context, userID string, page Page) (Rooms, error)
+	IsParticipant(ctx context.Context, roomID, userID uint) (bool, error)
+	GetParticipant(ctx context.Context, roomID, userID uint) (RoomParticipant, error)
+	AddParticipant(ctx context.Context, roomID, userID uint) error
+	RemoveParticipant(ctx context.Context, roomID, userID uint) error
+	SetParticipantRole(ctx context.Context, roomID, userID uint, role string) error
+	SetParticipantMuted(ctx context.Context, roomID, userID uint, muted bool) error
+	TransferHost(ctx context.Context, room Room, userID uint) error
+	ResetInvites(ctx context.Context, roomID uint) error
+	CreateJoinRequest(ctx context.Context, request *RoomJoinRequest) error
+	HasJoinRequest(ctx context.Context, roomID, userID uint) (bool, error)
+	ListJoinRequests(ctx context.Context, roomID uint) ([]RoomJoinRequest, error)
+	GetJoinRequest(ctx context.Context, roomID uint, id string) (RoomJoinRequest, error)
+	DeleteJoinRequest(ctx context.Context, id uint) error
 }
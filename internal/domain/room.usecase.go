@@ -1,16 +1,36 @@
 package domain
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 type RoomUseCase interface {
 	Bridger
-	ListRooms(ctx context.Context, searchQuery string) (Rooms, error)
+	ListRooms(ctx context.Context, searchQuery string, page Page) (Rooms, error)
 	CreateRoom(ctx context.Context, form RoomForm) error
-	ListUserRooms(ctx context.Context, userID string) (Rooms, error)
+	ListUserRooms(ctx context.Context, userID string, page Page) (Rooms, error)
 	GetRoomById(ctx context.Context, roomID string) (Room, error)
-	ListRoomParticipants(ctx context.Context, roomID string) ([]User, error)
-	SearchRoom(ctx context.Context, searchQuery string) (Rooms, error)
+	ListRoomParticipants(ctx context.Context, roomID string) ([]RoomParticipant, error)
+	SearchRoom(ctx context.Context, searchQuery string, page Page) (Rooms, error)
 	UpdateRoom(ctx context.Context, id string, roomForm RoomForm) error
 	GetUserRoom(ctx context.Context, roomID string) (Room, error)
 	DeleteUserRoom(ctx context.Context, roomID string) error
+	JoinRoom(ctx context.Context, roomID string) error
+	LeaveRoom(ctx context.Context, roomID string) error
+	GetRoomPowers(ctx context.Context, roomID string) (RoomPowers, error)
+	SetParticipantRole(ctx context.Context, roomID, userID, role string) error
+	MuteParticipant(ctx context.Context, roomID, userID string, muted bool) error
+	KickParticipant(ctx context.Context, roomID, userID string) error
+	TransferOwnership(ctx context.Context, roomID, userID string) error
+	ListJoinedRooms(ctx context.Context, page Page) (Rooms, error)
+	CreateInvite(ctx context.Context, roomID string, expiresIn time.Duration) (string, error)
+	ResetInvites(ctx context.Context, roomID string) error
+	GetInvitedRoom(ctx context.Context, token string) (Room, error)
+	AcceptInvite(ctx context.Context, token string) (Room, error)
+	RequestToJoin(ctx context.Context, roomID string) error
+	HasPendingJoinRequest(ctx context.Context, roomID string) (bool, error)
+	ListJoinRequests(ctx context.Context, roomID string) ([]RoomJoinRequest, error)
+	ApproveJoinRequest(ctx context.Context, roomID, requestID string) error
+	DenyJoinRequest(ctx context.Context, roomID, requestID string) error
 }
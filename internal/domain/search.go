@@ -0,0 +1,62 @@
+package domain
+
+import (
+	"html/template"
+	"slices"
+	"time"
+)
+
+// Kinds of search results.
+const (
+	SearchRooms    = "rooms"
+	SearchMessages = "messages"
+	SearchUsers    = "users"
+)
+
+var SearchKinds = []string{SearchRooms, SearchMessages, SearchUsers}
+
+func IsSearchKind(kind string) bool {
+	return slices.Contains(SearchKinds, kind)
+}
+
+// SearchFilter narrows a search. Kind limits it to one kind of results, all
+// of them are searched when it is empty. Topic, Host (a username) and RoomID
+// narrow the rooms searched and the messages posted in them, users are not
+// searched when any of them is set. From and To bound when the results were
+// created, To is excluded and either may be zero.
+type SearchFilter struct {
+	Query  string
+	Kind   string
+	Topic  string
+	Host   string
+	RoomID uint
+	From   time.Time
+	To     time.Time
+}
+
+// InRooms tells the filter only accepts results from some rooms.
+func (f SearchFilter) InRooms() bool {
+	return f.Topic != "" || f.Host != "" || f.RoomID != 0
+}
+
+// SearchHit is a result along with how relevant it is to the search and a
+// snippet of its text with the matches marked.
+type SearchHit[T any] struct {
+	Item    T
+	Rank    float32
+	Snippet template.HTML
+}
+
+// SearchHits is a page of results, the most relevant first. Count is the
+// number of results across all pages.
+type SearchHits[T any] struct {
+	List       []SearchHit[T]
+	Count      int64
+	NextCursor string
+}
+
+type SearchResults struct {
+	Rooms    SearchHits[RoomWithDetails]
+	Messages SearchHits[Message]
+	Users    SearchHits[User]
+}
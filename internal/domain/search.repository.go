@@ -0,0 +1,10 @@
+package domain
+
+import "context"
+
+type SearchRepository interface {
+	Bridger
+	SearchRooms(ctx context.Context, filter SearchFilter, viewerID uint, page Page) (SearchHits[RoomWithDetails], error)
+	SearchMessages(ctx context.Context, filter SearchFilter, viewerID uint, page Page) (SearchHits[Message], error)
+	SearchUsers(ctx context.Context, filter SearchFilter, page Page) (SearchHits[User], error)
+}
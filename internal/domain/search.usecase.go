@@ -0,0 +1,8 @@
+package domain
+
+import "context"
+
+type SearchUseCase interface {
+	Bridger
+	Search(ctx context.Context, filter SearchFilter, page Page) (SearchResults, error)
+}
@@ -0,0 +1,49 @@
+package domain
+
+import (
+	"strings"
+	"time"
+)
+
+// Scopes of a personal access token. Read allows safe requests, write allows
+// requests that change data and admin additionally opens the admin panel to
+// staff accounts. The permissions of the owner still apply on top.
+const (
+	TokenScopeRead  = "read"
+	TokenScopeWrite = "write"
+	TokenScopeAdmin = "admin"
+)
+
+var TokenScopes = []string{TokenScopeRead, TokenScopeWrite, TokenScopeAdmin}
+
+// PersonalAccessTokenPrefix starts every personal access token, it tells them
+// apart from session tokens sent as a bearer token.
+const PersonalAccessTokenPrefix = "sbp_"
+
+// PersonalAccessToken lets scripts authenticate as a user. Only the SHA-256
+// hash of the token is stored, Prefix keeps its first characters so the user
+// can recognise it in the list.
+type PersonalAccessToken struct {
+	ID        uint       `gorm:"primaryKey"`
+	UserID    uint       `gorm:"not null;index:idx_personal_access_tokens_user_id"`
+	Name      string     `gorm:"type:varchar(100);not null"`
+	TokenHash string     `gorm:"type:varchar(64);not null;uniqueIndex:idx_personal_access_tokens_token_hash"`
+	Prefix    string     `gorm:"type:varchar(16);not null"`
+	Scopes    string     `gorm:"type:varchar(50);not null"`
+	LastUsed  *time.Time `gorm:"type:timestamp with time zone"`
+	Created   time.Time  `gorm:"type:timestamp with time zone;not null;autoCreateTime"`
+	User      User       `gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;deferrable:InitiallyDeferred"`
+}
+
+// ScopeList returns the scopes stored as a comma separated list.
+func (t PersonalAccessToken) ScopeList() []string {
+	if len(t.Scopes) == 0 {
+		return nil
+	}
+	return strings.Split(t.Scopes, ",")
+}
+
+type TokenForm struct {
+	Name   string
+	Scopes []string
+}
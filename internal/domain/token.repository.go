@@ -0,0 +1,16 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+type TokenRepository interface {
+	Bridger
+	Create(ctx context.Context, token *PersonalAccessToken) error
+	ListByUser(ctx context.Context, userID string) ([]PersonalAccessToken, error)
+	GetByHash(ctx context.Context, hash string) (PersonalAccessToken, error)
+	Delete(ctx context.Context, userID string, id string) error
+	DeleteByUser(ctx context.Context, userIDs []uint) error
+	TouchLastUsed(ctx context.Context, id uint, lastUsed time.Time) error
+}
@@ -0,0 +1,11 @@
+package domain
+
+import "context"
+
+type TokenUseCase interface {
+	Bridger
+	CreateToken(ctx context.Context, form *TokenForm) (PersonalAccessToken, string, error)
+	ListTokens(ctx context.Context) ([]PersonalAccessToken, error)
+	RevokeToken(ctx context.Context, id string) error
+	Authenticate(ctx context.Context, token string) (SessionValue, error)
+}
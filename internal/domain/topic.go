@@ -6,11 +6,13 @@ type Topic struct {
 }
 
 type Topics struct {
-	List  []TopicWithDetails
-	Count int64
+	List       []TopicWithDetails
+	Count      int64
+	NextCursor string
 }
 
 type TopicWithDetails struct {
+	ID        uint
 	Name      string
 	RoomCount int64
 }
@@ -4,7 +4,11 @@ import "context"
 
 type TopicRepository interface {
 	Bridger
-	ListAllTopics(ctx context.Context) (Topics, error)
-	SearchTopicByName(ctx context.Context, name string) (Topics, error)
+	ListAllTopics(ctx context.Context, page Page) (Topics, error)
+	SearchTopicByName(ctx context.Context, name string, page Page) (Topics, error)
 	CreateTopicIfNotExists(ctx context.Context, topic *Topic) error
+	GetTopicById(ctx context.Context, id string) (Topic, error)
+	UpdateTopic(ctx context.Context, topic Topic) error
+	DeleteMany(ctx context.Context, ids []uint) error
+	MergeTopics(ctx context.Context, ids []uint, targetID uint) error
 }
@@ -4,6 +4,6 @@ import "context"
 
 type TopicUseCase interface {
 	Bridger
-	ListAllTopics(ctx context.Context) (Topics, error)
-	SearchTopicByName(ctx context.Context, name string) (Topics, error)
+	ListAllTopics(ctx context.Context, page Page) (Topics, error)
+	SearchTopicByName(ctx context.Context, name string, page Page) (Topics, error)
 }
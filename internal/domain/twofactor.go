@@ -0,0 +1,40 @@
+package domain
+
+import "time"
+
+// RecoveryCode is a one-time code that replaces the authenticator when it is
+// lost. Only the bcrypt hash is stored, a code is deleted once used.
+type RecoveryCode struct {
+	ID       uint      `gorm:"primaryKey"`
+	UserID   uint      `gorm:"not null;index:idx_recovery_codes_user_id"`
+	CodeHash string    `gorm:"type:varchar(128);not null"`
+	Created  time.Time `gorm:"type:timestamp with time zone;not null;autoCreateTime"`
+	User     User      `gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;deferrable:InitiallyDeferred"`
+}
+
+// LoginResult is returned by a successful password check. Accounts with
+// two-factor authentication get a TwoFactorToken to finish the login with a
+// code, everyone else gets the SessionKey right away. SetupTwoFactor is set
+// when the account must enroll before it can do anything else.
+type LoginResult struct {
+	SessionKey     string
+	TwoFactorToken string
+	SetupTwoFactor bool
+}
+
+// TOTPEnrollment is a pending authenticator setup. URL is the otpauth URI
+// shown as a QR code, Secret is the same key for manual entry.
+type TOTPEnrollment struct {
+	Secret string
+	URL    string
+}
+
+type TwoFactorStatus struct {
+	Enabled           bool
+	Required          bool
+	RecoveryCodesLeft int
+}
+
+type TwoFactorForm struct {
+	Code string
+}
@@ -17,6 +17,22 @@ type User struct {
 	Bio         string    `gorm:"type:text"`
 	Name        string    `gorm:"type:varchar(200)"`
 	Avatar      string    `gorm:"type:varchar(100)"`
+	// PendingEmailVerification is set on registration and cleared once the
+	// emailed link is opened. Until then the account holds no permissions.
+	PendingEmailVerification bool `gorm:"type:boolean;not null;default:false"`
+	// TOTPSecret is the base32 authenticator key, TOTPEnabled is only set
+	// once the user proved they can generate codes from it.
+	TOTPSecret  string `gorm:"type:varchar(64)"`
+	TOTPEnabled bool   `gorm:"type:boolean;not null;default:false"`
+	// RequireTOTP is set by an admin to hold the user at the two-factor
+	// setup until they enroll, staff may be required to by configuration.
+	RequireTOTP bool `gorm:"type:boolean;not null;default:false"`
+}
+
+type Users struct {
+	List       []User
+	Count      int64
+	NextCursor string
 }
 
 type UserGroup struct {
@@ -42,6 +58,52 @@ type SessionValue struct {
 	Email      string `json:"email"`
 	Name       string `json:"name"`
 	Avatar     string `json:"avatar"`
+
+	PendingVerification bool `json:"pending_verification"`
+
+	UserAgent string    `json:"user_agent"`
+	IP        string    `json:"ip"`
+	Created   time.Time `json:"created"`
+	LastSeen  time.Time `json:"last_seen"`
+
+	// TokenID and Scopes are only set when the request was authenticated
+	// with a personal access token instead of a session.
+	TokenID uint     `json:"token_id,omitempty"`
+	Scopes  []string `json:"scopes,omitempty"`
+}
+
+// HasScope reports whether the caller may act with scope. Sessions are not
+// restricted, personal access tokens only hold the scopes they were minted
+// with.
+func (s SessionValue) HasScope(scope string) bool {
+	if s.TokenID == 0 {
+		return true
+	}
+	for _, granted := range s.Scopes {
+		if granted == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientInfo describes the device a request comes from. Handlers that start a
+// session put it in the request context under configs.ClientCtxKey.
+type ClientInfo struct {
+	IP        string
+	UserAgent string
+}
+
+// Session is one login of a user as shown on the sessions page. ID is derived
+// from the session key so the key itself never leaves the server.
+type Session struct {
+	ID        string
+	UserAgent string
+	IP        string
+	Created   time.Time
+	LastSeen  time.Time
+	Since     string
+	Current   bool
 }
 
 type UserRegisterForm struct {
@@ -55,6 +117,23 @@ type UserRegisterForm struct {
 type UserLoginForm struct {
 	Email    string
 	Password string
+	// IP is the address the attempt came from, failed attempts are counted
+	// per account and per IP.
+	IP string
+}
+
+type PasswordResetForm struct {
+	Token     string
+	Password1 string
+	Password2 string
+}
+
+// EmailVerificationClaims is encrypted into the link sent to confirm an email
+// address. The token stops working when the address changes or it expires.
+type EmailVerificationClaims struct {
+	UserID    uint   `json:"user_id"`
+	Email     string `json:"email"`
+	ExpiresAt int64  `json:"expires_at"`
 }
 
 type UpdateUser struct {
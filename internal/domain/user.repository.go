@@ -2,6 +2,7 @@ package domain
 
 import (
 	"context"
+	"time"
 )
 
 type UserRepository interface {
@@ -9,5 +10,22 @@ type UserRepository interface {
 	Create(ctx context.Context, obj *User) (User, error)
 	GetUserByEmail(ctx context.Context, email string) (User, error)
 	GetUserById(ctx context.Context, id string) (User, error)
+	ListActiveByUsernames(ctx context.Context, usernames []string) ([]User, error)
+	UsernameExists(ctx context.Context, username string) (bool, error)
 	Update(ctx context.Context, user User) error
+	UpdatePassword(ctx context.Context, id string, password string) error
+	MarkEmailVerified(ctx context.Context, id string) error
+	DeleteUnverifiedBefore(ctx context.Context, before time.Time) (int64, error)
+	ListUsers(ctx context.Context, searchQuery string, page Page) (Users, error)
+	UpdateAccount(ctx context.Context, user User) error
+	SetActive(ctx context.Context, ids []uint, active bool) error
+	Delete(ctx context.Context, id string) error
+	EnableTOTP(ctx context.Context, id string, secret string, codeHashes []string) error
+	DisableTOTP(ctx context.Context, id string) error
+	ReplaceRecoveryCodes(ctx context.Context, id string, codeHashes []string) error
+	ListRecoveryCodes(ctx context.Context, id string) ([]RecoveryCode, error)
+	UseRecoveryCode(ctx context.Context, codeID uint) (bool, error)
+	GetUserByIdentity(ctx context.Context, provider string, subject string) (User, error)
+	LinkIdentity(ctx context.Context, identity *UserIdentity) error
+	CreateWithIdentity(ctx context.Context, user *User, identity *UserIdentity) error
 }
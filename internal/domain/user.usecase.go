@@ -7,8 +7,27 @@ import (
 type UserUseCase interface {
 	Bridger
 	RegisterUser(ctx context.Context, form *UserRegisterForm) (string, error)
-	Login(ctx context.Context, form *UserLoginForm) (string, error)
+	Login(ctx context.Context, form *UserLoginForm) (LoginResult, error)
+	VerifyTwoFactorLogin(ctx context.Context, token string, form *TwoFactorForm) (string, error)
+	OIDCProviders() []OIDCProvider
+	BeginOIDCLogin(ctx context.Context, provider string) (string, string, error)
+	FinishOIDCLogin(ctx context.Context, provider string, state string, code string) (LoginResult, error)
 	GetUserByEmail(ctx context.Context, email string) (User, error)
 	UpdateInfo(ctx context.Context, obj *UpdateUser) (string, error)
 	GetUserById(ctx context.Context, id string) (User, error)
+	RequestPasswordReset(ctx context.Context, email string) error
+	ResetPassword(ctx context.Context, form *PasswordResetForm) error
+	SendEmailVerification(ctx context.Context, userID string) error
+	VerifyEmail(ctx context.Context, token string) (User, string, error)
+	ExpireUnverifiedUsers(ctx context.Context) (int64, error)
+	Logout(ctx context.Context) error
+	ListSessions(ctx context.Context) ([]Session, error)
+	RevokeSession(ctx context.Context, id string) error
+	RevokeAllSessions(ctx context.Context, userID string) error
+	TwoFactorStatus(ctx context.Context, userID string) (TwoFactorStatus, error)
+	TwoFactorSetupPending(ctx context.Context, userID string) (bool, error)
+	BeginTOTPEnrollment(ctx context.Context) (TOTPEnrollment, error)
+	ConfirmTOTPEnrollment(ctx context.Context, form *TwoFactorForm) ([]string, error)
+	DisableTOTP(ctx context.Context, form *TwoFactorForm) error
+	RegenerateRecoveryCodes(ctx context.Context, form *TwoFactorForm) ([]string, error)
 }
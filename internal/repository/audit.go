@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/elyarsadig/studybud-go/internal/domain"
+	"github.com/elyarsadig/studybud-go/pkg/errorHandler"
+	"github.com/elyarsadig/studybud-go/pkg/logger"
+	"github.com/elyarsadig/studybud-go/pkg/pagination"
+	"gorm.io/gorm"
+)
+
+type AuditRepository struct {
+	db         *gorm.DB
+	errHandler errorHandler.Handler
+	logger     logger.Logger
+}
+
+func NewAudit(db *gorm.DB, errHandler errorHandler.Handler, logger logger.Logger) domain.AuditRepository {
+	return &AuditRepository{
+		db:         db,
+		errHandler: errHandler,
+		logger:     logger,
+	}
+}
+
+func (r *AuditRepository) None() {}
+
+func (r *AuditRepository) Create(ctx context.Context, entry *domain.AuditLog) error {
+	err := r.db.WithContext(ctx).Omit("Actor").Create(entry).Error
+	if err != nil {
+		r.logger.Error(err.Error())
+		return r.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+	}
+	return nil
+}
+
+// List returns one page of audit entries, newest first, using keyset
+// pagination on (created, id).
+func (r *AuditRepository) List(ctx context.Context, page domain.Page) (domain.AuditLogs, error) {
+	logs := domain.AuditLogs{}
+	err := r.db.WithContext(ctx).Model(&domain.AuditLog{}).Count(&logs.Count).Error
+	if err != nil {
+		r.logger.Error(err.Error())
+		return domain.AuditLogs{}, r.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+	}
+	limit := page.Size()
+	query := r.db.WithContext(ctx).
+		Model(&domain.AuditLog{}).
+		Preload("Actor").
+		Order("audit_logs.created DESC, audit_logs.id DESC").
+		Limit(limit + 1)
+	if len(page.Cursor) != 0 {
+		created, id, err := decodeTimeCursor(page.Cursor)
+		if err != nil {
+			return domain.AuditLogs{}, r.errHandler.New(http.StatusBadRequest, err.Error())
+		}
+		query = query.Where("(audit_logs.created, audit_logs.id) < (?, ?)", created, id)
+	}
+	err = query.Find(&logs.List).Error
+	if err != nil {
+		r.logger.Error(err.Error())
+		return domain.AuditLogs{}, r.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+	}
+	if len(logs.List) > limit {
+		logs.List = logs.List[:limit]
+		last := logs.List[limit-1]
+		logs.NextCursor = pagination.Encode(pagination.NewTimeCursor(last.Created, last.ID))
+	}
+	return logs, nil
+}
@@ -2,11 +2,15 @@ package repository
 
 import (
 	"context"
+	"errors"
+	"html/template"
 	"net/http"
+	"time"
 
 	"github.com/elyarsadig/studybud-go/internal/domain"
 	"github.com/elyarsadig/studybud-go/pkg/errorHandler"
 	"github.com/elyarsadig/studybud-go/pkg/logger"
+	"github.com/elyarsadig/studybud-go/pkg/pagination"
 	"gorm.io/gorm"
 )
 
@@ -26,22 +30,8 @@ func NewMessage(db *gorm.DB, errHandler errorHandler.Handler, logger logger.Logg
 
 func (r *MessageRepository) None() {}
 
-func (r *MessageRepository) ListAllMessages(ctx context.Context) (domain.Messages, error) {
-	messages := domain.Messages{}
-	err := r.db.WithContext(ctx).
-		Model(&domain.Message{}).
-		Preload("Room").
-		Preload("User").
-		Order("created DESC").
-		Limit(5).
-		Find(&messages.MessageList).
-		Offset(0).Limit(1).Count(&messages.Count).Error
-	if err != nil {
-		r.logger.Error(err.Error())
-		return domain.Messages{}, r.errHandler.New(http.StatusInternalServerError, "something went wrong!")
-	}
-
-	return messages, nil
+func (r *MessageRepository) ListAllMessages(ctx context.Context, page domain.Page) (domain.Messages, error) {
+	return r.listMessages(ctx, page, true)
 }
 
 func (r *MessageRepository) Get(ctx context.Context, id string) (domain.Message, error) {
@@ -63,58 +53,293 @@ func (r *MessageRepository) Delete(ctx context.Context, id string) error {
 	return nil
 }
 
-func (r *MessageRepository) ListUserMessages(ctx context.Context, userID string) (domain.Messages, error) {
-	messages := domain.Messages{}
+func (r *MessageRepository) SearchMessages(ctx context.Context, searchQuery string, page domain.Page) (domain.Messages, error) {
+	return r.listMessages(ctx, page, true, func(db *gorm.DB) *gorm.DB {
+		return matching(searchQuery, "messages.search_vector @@ search_query")(db)
+	})
+}
+
+func (r *MessageRepository) Update(ctx context.Context, message domain.Message) error {
+	err := r.db.WithContext(ctx).Model(&domain.Message{ID: message.ID}).Updates(map[string]any{"body": message.Body, "body_html": message.BodyHTML}).Error
+	if err != nil {
+		r.logger.Error(err.Error())
+		return r.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+	}
+	return nil
+}
+
+// Edit replaces the body of message and keeps the one it had as a revision.
+func (r *MessageRepository) Edit(ctx context.Context, message domain.Message, body string, bodyHTML template.HTML) (domain.Message, error) {
+	edited := time.Now()
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Create(&domain.MessageRevision{MessageID: message.ID, Body: message.Body}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Model(&domain.Message{ID: message.ID}).Updates(map[string]any{"body": body, "body_html": bodyHTML, "edited": edited}).Error
+	})
+	if err != nil {
+		r.logger.Error(err.Error())
+		return domain.Message{}, r.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+	}
+	message.Body = body
+	message.BodyHTML = bodyHTML
+	message.Edited = &edited
+	return message, nil
+}
+
+// ListRevisions lists the bodies a message had before its edits, newest
+// first.
+func (r *MessageRepository) ListRevisions(ctx context.Context, messageID uint) ([]domain.MessageRevision, error) {
+	var revisions []domain.MessageRevision
+	err := r.db.WithContext(ctx).Where("message_id = ?", messageID).Order("created DESC, id DESC").Find(&revisions).Error
+	if err != nil {
+		r.logger.Error(err.Error())
+		return nil, r.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+	}
+	return revisions, nil
+}
+
+// ToggleReaction takes reaction back when the user already reacted with that
+// emoji and adds it otherwise.
+func (r *MessageRepository) ToggleReaction(ctx context.Context, reaction domain.MessageReaction) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Where(&reaction).Delete(&domain.MessageReaction{})
+		if result.Error != nil || result.RowsAffected != 0 {
+			return result.Error
+		}
+		return tx.Create(&reaction).Error
+	})
+	if err != nil {
+		r.logger.Error(err.Error())
+		return r.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+	}
+	return nil
+}
+
+// ListReactionCounts sums up the reactions under all the given messages in a
+// single query, each message's emojis in the order they were first used.
+func (r *MessageRepository) ListReactionCounts(ctx context.Context, messageIDs []uint, viewerID uint) ([]domain.ReactionCount, error) {
+	var counts []domain.ReactionCount
+	if len(messageIDs) == 0 {
+		return counts, nil
+	}
 	err := r.db.WithContext(ctx).
-		Model(&domain.Message{}).
-		Preload("Room").
-		Preload("User").
-		Where("user_id = ?", userID).
-		Order("created DESC").
-		Limit(5).
-		Find(&messages.MessageList).
-		Offset(0).Limit(1).Count(&messages.Count).Error
+		Model(&domain.MessageReaction{}).
+		Select("message_id, emoji, COUNT(*) AS count, BOOL_OR(user_id = ?) AS reacted", viewerID).
+		Where("message_id IN ?", messageIDs).
+		Group("message_id, emoji").
+		Order("message_id, MIN(created)").
+		Scan(&counts).Error
 	if err != nil {
 		r.logger.Error(err.Error())
-		return domain.Messages{}, r.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+		return nil, r.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+	}
+	return counts, nil
+}
+
+func (r *MessageRepository) SetPinned(ctx context.Context, id uint, pinned bool) error {
+	err := r.db.WithContext(ctx).Model(&domain.Message{ID: id}).UpdateColumn("pinned", pinned).Error
+	if err != nil {
+		r.logger.Error(err.Error())
+		return r.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+	}
+	return nil
+}
+
+// ListPinnedMessages lists the pinned messages of a room, oldest first.
+func (r *MessageRepository) ListPinnedMessages(ctx context.Context, roomID string) ([]domain.Message, error) {
+	var messages []domain.Message
+	err := r.db.WithContext(ctx).Preload("User").Where("room_id = ? AND pinned", roomID).Order("created, id").Find(&messages).Error
+	if err != nil {
+		r.logger.Error(err.Error())
+		return nil, r.errHandler.New(http.StatusInternalServerError, "something went wrong!")
 	}
 	return messages, nil
 }
 
-func (r *MessageRepository) ListRoomMessages(ctx context.Context, roomID string) (domain.Messages, error) {
-	var messages domain.Messages
-	err := r.db.WithContext(ctx).Model(&domain.Message{}).Preload("User").Where("room_id = ?", roomID).Find(&messages.MessageList).Error
+// ListReplies loads the replies to all the given messages at once, oldest
+// first, so a page of threads costs a single query.
+func (r *MessageRepository) ListReplies(ctx context.Context, parentIDs []uint) ([]domain.Message, error) {
+	var replies []domain.Message
+	if len(parentIDs) == 0 {
+		return replies, nil
+	}
+	err := r.db.WithContext(ctx).Select("messages.*").Joins("User").Where("messages.parent_id IN ?", parentIDs).Order("messages.created, messages.id").Find(&replies).Error
+	if err != nil {
+		r.logger.Error(err.Error())
+		return nil, r.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+	}
+	return replies, nil
+}
+
+// GetThread loads the whole thread id belongs to in one round-trip: the
+// message that started it first, then its replies oldest first. id may be
+// the start of the thread or any reply in it.
+func (r *MessageRepository) GetThread(ctx context.Context, id string) ([]domain.Message, error) {
+	var thread []domain.Message
+	root := r.db.Model(&domain.Message{}).Select("COALESCE(parent_id, id)").Where("id = ?", id)
+	err := r.db.WithContext(ctx).
+		Select("messages.*").
+		Joins("User").
+		Where("messages.id = (?) OR messages.parent_id = (?)", root, root).
+		Order("messages.parent_id IS NOT NULL, messages.created, messages.id").
+		Find(&thread).Error
+	if err != nil {
+		r.logger.Error(err.Error())
+		return nil, r.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+	}
+	if len(thread) == 0 {
+		return nil, r.errHandler.New(http.StatusNotFound, "not found")
+	}
+	return thread, nil
+}
+
+func (r *MessageRepository) DeleteMany(ctx context.Context, ids []uint) error {
+	err := r.db.WithContext(ctx).Where("id IN ?", ids).Delete(&domain.Message{}).Error
+	if err != nil {
+		r.logger.Error(err.Error())
+		return r.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+	}
+	return nil
+}
+
+func (r *MessageRepository) ListVisibleMessages(ctx context.Context, viewerID uint, page domain.Page) (domain.Messages, error) {
+	return r.listMessages(ctx, page, true, inVisibleRooms(viewerID))
+}
+
+func (r *MessageRepository) ListUserMessages(ctx context.Context, userID string, viewerID uint, page domain.Page) (domain.Messages, error) {
+	return r.listMessages(ctx, page, true, inVisibleRooms(viewerID), func(db *gorm.DB) *gorm.DB {
+		return db.Where("messages.user_id = ?", userID)
+	})
+}
+
+// ListRoomMessages lists the messages that start a thread in the room,
+// replies are loaded with ListReplies.
+func (r *MessageRepository) ListRoomMessages(ctx context.Context, roomID string, page domain.Page) (domain.Messages, error) {
+	return r.listMessages(ctx, page, false, func(db *gorm.DB) *gorm.DB {
+		return db.Where("messages.room_id = ? AND messages.parent_id IS NULL", roomID)
+	})
+}
+
+// inVisibleRooms limits messages to the rooms viewerID may find in listings,
+// see visibleTo.
+func inVisibleRooms(viewerID uint) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return visibleTo(viewerID)(db.Joins("JOIN rooms ON rooms.id = messages.room_id"))
+	}
+}
+
+// listMessages returns one page of messages ordered from newest to oldest
+// using keyset pagination on (created, id). Count holds the total number of
+// messages matching the filters and every message its number of replies.
+func (r *MessageRepository) listMessages(ctx context.Context, page domain.Page, withRoom bool, filters ...func(*gorm.DB) *gorm.DB) (domain.Messages, error) {
+	messages := domain.Messages{}
+	err := r.db.WithContext(ctx).Model(&domain.Message{}).Scopes(filters...).Count(&messages.Count).Error
+	if err != nil {
+		r.logger.Error(err.Error())
+		return domain.Messages{}, r.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+	}
+	limit := page.Size()
+	query := r.db.WithContext(ctx).
+		Model(&domain.Message{}).
+		Scopes(filters...).
+		Select("messages.*, (SELECT COUNT(*) FROM messages AS replies WHERE replies.parent_id = messages.id) AS reply_count").
+		Preload("User").
+		Order("messages.created DESC, messages.id DESC").
+		Limit(limit + 1)
+	if withRoom {
+		query = query.Preload("Room")
+	}
+	if len(page.Cursor) != 0 {
+		created, id, err := decodeTimeCursor(page.Cursor)
+		if err != nil {
+			return domain.Messages{}, r.errHandler.New(http.StatusBadRequest, err.Error())
+		}
+		query = query.Where("(messages.created, messages.id) < (?, ?)", created, id)
+	}
+	err = query.Find(&messages.MessageList).Error
 	if err != nil {
 		r.logger.Error(err.Error())
 		return domain.Messages{}, r.errHandler.New(http.StatusInternalServerError, "something went wrong!")
 	}
+	if len(messages.MessageList) > limit {
+		messages.MessageList = messages.MessageList[:limit]
+		last := messages.MessageList[limit-1]
+		messages.NextCursor = pagination.Encode(pagination.NewTimeCursor(last.Created, last.ID))
+	}
 	return messages, nil
 }
 
+// CreateMessage saves message along with its attachments.
 func (r *MessageRepository) CreateMessage(ctx context.Context, message *domain.Message) error {
-	tx := r.db.WithContext(ctx).Begin()
-
-	if err := tx.Create(message).Error; err != nil {
-		tx.Rollback()
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Create(message).Error
+		if err != nil || len(message.Attachments) == 0 {
+			return err
+		}
+		for i := range message.Attachments {
+			message.Attachments[i].MessageID = &message.ID
+		}
+		return tx.Create(&message.Attachments).Error
+	})
+	if err != nil {
 		r.logger.Error(err.Error())
 		return r.errHandler.New(http.StatusInternalServerError, "something went wrong!")
 	}
+	return nil
+}
+
+// GetAttachment loads an attachment with the message it belongs to, the ones
+// whose message is gone are not found.
+func (r *MessageRepository) GetAttachment(ctx context.Context, id string) (domain.Attachment, error) {
+	var attachment domain.Attachment
+	err := r.db.WithContext(ctx).Preload("Message").Where("id = ? AND message_id IS NOT NULL", id).First(&attachment).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return domain.Attachment{}, r.errHandler.New(http.StatusNotFound, "not found")
+	}
+	if err != nil {
+		r.logger.Error(err.Error())
+		return domain.Attachment{}, r.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+	}
+	return attachment, nil
+}
 
-	roomParticipant := &domain.RoomParticipant{
-		RoomID: message.RoomID,
-		UserID: message.UserID,
+// ListAttachments loads the attachments of all the given messages at once, in
+// the order they were attached.
+func (r *MessageRepository) ListAttachments(ctx context.Context, messageIDs []uint) ([]domain.Attachment, error) {
+	var attachments []domain.Attachment
+	if len(messageIDs) == 0 {
+		return attachments, nil
+	}
+	err := r.db.WithContext(ctx).Where("message_id IN ?", messageIDs).Order("id").Find(&attachments).Error
+	if err != nil {
+		r.logger.Error(err.Error())
+		return nil, r.errHandler.New(http.StatusInternalServerError, "something went wrong!")
 	}
+	return attachments, nil
+}
 
-	if err := tx.Where(roomParticipant).FirstOrCreate(roomParticipant).Error; err != nil {
-		tx.Rollback()
+// ListOrphanedAttachments lists up to limit attachments whose message has been
+// deleted.
+func (r *MessageRepository) ListOrphanedAttachments(ctx context.Context, limit int) ([]domain.Attachment, error) {
+	var attachments []domain.Attachment
+	err := r.db.WithContext(ctx).Where("message_id IS NULL").Order("id").Limit(limit).Find(&attachments).Error
+	if err != nil {
 		r.logger.Error(err.Error())
-		return r.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+		return nil, r.errHandler.New(http.StatusInternalServerError, "something went wrong!")
 	}
+	return attachments, nil
+}
 
-	if err := tx.Commit().Error; err != nil {
+func (r *MessageRepository) DeleteAttachments(ctx context.Context, ids []uint) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	err := r.db.WithContext(ctx).Where("id IN ?", ids).Delete(&domain.Attachment{}).Error
+	if err != nil {
 		r.logger.Error(err.Error())
 		return r.errHandler.New(http.StatusInternalServerError, "something went wrong!")
 	}
-
 	return nil
 }
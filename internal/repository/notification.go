@@ -0,0 +1,124 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/elyarsadig/studybud-go/internal/domain"
+	"github.com/elyarsadig/studybud-go/pkg/errorHandler"
+	"github.com/elyarsadig/studybud-go/pkg/logger"
+	"github.com/elyarsadig/studybud-go/pkg/pagination"
+	"gorm.io/gorm"
+)
+
+type NotificationRepository struct {
+	db         *gorm.DB
+	errHandler errorHandler.Handler
+	logger     logger.Logger
+}
+
+func NewNotification(db *gorm.DB, errHandler errorHandler.Handler, logger logger.Logger) domain.NotificationRepository {
+	return &NotificationRepository{
+		db:         db,
+		errHandler: errHandler,
+		logger:     logger,
+	}
+}
+
+func (r *NotificationRepository) None() {}
+
+func (r *NotificationRepository) CreateMany(ctx context.Context, notifications []domain.Notification) error {
+	if len(notifications) == 0 {
+		return nil
+	}
+	err := r.db.WithContext(ctx).Omit("User", "Actor", "Room", "Message").Create(&notifications).Error
+	if err != nil {
+		r.logger.Error(err.Error())
+		return r.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+	}
+	return nil
+}
+
+// ListByUser returns one page of the notifications of userID, newest first,
+// along with how many of them are unread.
+func (r *NotificationRepository) ListByUser(ctx context.Context, userID uint, page domain.Page) (domain.Notifications, error) {
+	notifications := domain.Notifications{}
+	err := r.db.WithContext(ctx).
+		Model(&domain.Notification{}).
+		Where("user_id = ?", userID).
+		Select("COUNT(*) AS count, COUNT(*) FILTER (WHERE NOT read) AS unread").
+		Row().Scan(&notifications.Count, &notifications.Unread)
+	if err != nil {
+		r.logger.Error(err.Error())
+		return domain.Notifications{}, r.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+	}
+	limit := page.Size()
+	query := r.db.WithContext(ctx).
+		Preload("Actor").
+		Preload("Room").
+		Preload("Message.User").
+		Where("user_id = ?", userID).
+		Order("created DESC, id DESC").
+		Limit(limit + 1)
+	if len(page.Cursor) != 0 {
+		created, id, err := decodeTimeCursor(page.Cursor)
+		if err != nil {
+			return domain.Notifications{}, r.errHandler.New(http.StatusBadRequest, err.Error())
+		}
+		query = query.Where("(created, id) < (?, ?)", created, id)
+	}
+	err = query.Find(&notifications.List).Error
+	if err != nil {
+		r.logger.Error(err.Error())
+		return domain.Notifications{}, r.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+	}
+	if len(notifications.List) > limit {
+		notifications.List = notifications.List[:limit]
+		last := notifications.List[limit-1]
+		notifications.NextCursor = pagination.Encode(pagination.NewTimeCursor(last.Created, last.ID))
+	}
+	return notifications, nil
+}
+
+// Get loads the notification id, as long as it was sent to userID.
+func (r *NotificationRepository) Get(ctx context.Context, userID uint, id string) (domain.Notification, error) {
+	var notification domain.Notification
+	err := r.db.WithContext(ctx).Preload("Message.User").Where("id = ? AND user_id = ?", id, userID).First(&notification).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return domain.Notification{}, r.errHandler.New(http.StatusNotFound, "notification not found")
+	}
+	if err != nil {
+		r.logger.Error(err.Error())
+		return domain.Notification{}, r.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+	}
+	return notification, nil
+}
+
+func (r *NotificationRepository) CountUnread(ctx context.Context, userID uint) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&domain.Notification{}).Where("user_id = ? AND NOT read", userID).Count(&count).Error
+	if err != nil {
+		r.logger.Error(err.Error())
+		return 0, r.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+	}
+	return count, nil
+}
+
+func (r *NotificationRepository) MarkRead(ctx context.Context, userID uint, id uint) error {
+	err := r.db.WithContext(ctx).Model(&domain.Notification{}).Where("id = ? AND user_id = ?", id, userID).UpdateColumn("read", true).Error
+	if err != nil {
+		r.logger.Error(err.Error())
+		return r.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+	}
+	return nil
+}
+
+func (r *NotificationRepository) MarkAllRead(ctx context.Context, userID uint) error {
+	err := r.db.WithContext(ctx).Model(&domain.Notification{}).Where("user_id = ? AND NOT read", userID).UpdateColumn("read", true).Error
+	if err != nil {
+		r.logger.Error(err.Error())
+		return r.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+	}
+	return nil
+}
@@ -0,0 +1,19 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/elyarsadig/studybud-go/pkg/pagination"
+)
+
+func decodeTimeCursor(token string) (time.Time, uint, error) {
+	cursor, err := pagination.Decode(token)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+	created, err := cursor.Time()
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+	return created, cursor.ID, nil
+}
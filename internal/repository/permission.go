@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/elyarsadig/studybud-go/internal/domain"
+	"github.com/elyarsadig/studybud-go/pkg/errorHandler"
+	"github.com/elyarsadig/studybud-go/pkg/logger"
+	"gorm.io/gorm"
+)
+
+type PermissionRepository struct {
+	db         *gorm.DB
+	errHandler errorHandler.Handler
+	logger     logger.Logger
+}
+
+func NewPermission(db *gorm.DB, errHandler errorHandler.Handler, logger logger.Logger) domain.PermissionRepository {
+	return &PermissionRepository{
+		db:         db,
+		errHandler: errHandler,
+		logger:     logger,
+	}
+}
+
+func (r *PermissionRepository) None() {}
+
+// UserHasPermission reports whether an active, verified user holds the
+// permission either directly, through one of their groups, or by being a
+// superuser.
+func (r *PermissionRepository) UserHasPermission(ctx context.Context, userID string, appLabel, codename string) (bool, error) {
+	var allowed bool
+	err := r.db.WithContext(ctx).Raw(`
+		SELECT EXISTS (
+			SELECT 1 FROM users
+			WHERE users.id = @user AND users.is_active AND NOT users.pending_email_verification AND (
+				users.is_superuser
+				OR EXISTS (
+					SELECT 1 FROM user_permissions
+					JOIN auth_permissions ON auth_permissions.id = user_permissions.permission_id
+					JOIN content_types ON content_types.id = auth_permissions.content_type_id
+					WHERE user_permissions.user_id = users.id
+						AND content_types.app_label = @app AND auth_permissions.codename = @codename
+				)
+				OR EXISTS (
+					SELECT 1 FROM user_groups
+					JOIN auth_group_permissions ON auth_group_permissions.group_id = user_groups.group_id
+					JOIN auth_permissions ON auth_permissions.id = auth_group_permissions.permission_id
+					JOIN content_types ON content_types.id = auth_permissions.content_type_id
+					WHERE user_groups.user_id = users.id
+						AND content_types.app_label = @app AND auth_permissions.codename = @codename
+				)
+			)
+		)`,
+		map[string]any{"user": userID, "app": appLabel, "codename": codename},
+	).Scan(&allowed).Error
+	if err != nil {
+		r.logger.Error(err.Error())
+		return false, r.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+	}
+	return allowed, nil
+}
+
+// UserIsActive reports whether the account of userID exists and is active.
+func (r *PermissionRepository) UserIsActive(ctx context.Context, userID string) (bool, error) {
+	var active bool
+	err := r.db.WithContext(ctx).Raw("SELECT EXISTS (SELECT 1 FROM users WHERE id = ? AND is_active)", userID).Scan(&active).Error
+	if err != nil {
+		r.logger.Error(err.Error())
+		return false, r.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+	}
+	return active, nil
+}
+
+func (r *PermissionRepository) ListUserPermissions(ctx context.Context, userID string) ([]string, error) {
+	var permissions []string
+	err := r.db.WithContext(ctx).Raw(`
+		SELECT DISTINCT content_types.app_label || '.' || auth_permissions.codename
+		FROM auth_permissions
+		JOIN content_types ON content_types.id = auth_permissions.content_type_id
+		WHERE auth_permissions.id IN (
+			SELECT user_permissions.permission_id FROM user_permissions WHERE user_permissions.user_id = @user
+			UNION
+			SELECT auth_group_permissions.permission_id FROM auth_group_permissions
+			JOIN user_groups ON user_groups.group_id = auth_group_permissions.group_id
+			WHERE user_groups.user_id = @user
+		)
+		ORDER BY 1`,
+		map[string]any{"user": userID},
+	).Scan(&permissions).Error
+	if err != nil {
+		r.logger.Error(err.Error())
+		return nil, r.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+	}
+	return permissions, nil
+}
+
+func (r *PermissionRepository) AddUserToGroup(ctx context.Context, userID uint, groupName string) error {
+	var group domain.AuthGroup
+	err := r.db.WithContext(ctx).Where("name = ?", groupName).First(&group).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return r.errHandler.New(http.StatusNotFound, "group not found")
+	}
+	if err != nil {
+		r.logger.Error(err.Error())
+		return r.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+	}
+	userGroup := domain.UserGroup{UserID: userID, GroupID: group.ID}
+	err = r.db.WithContext(ctx).Where(userGroup).FirstOrCreate(&userGroup).Error
+	if err != nil {
+		r.logger.Error(err.Error())
+		return r.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+	}
+	return nil
+}
@@ -2,12 +2,15 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"net/http"
 
 	"github.com/elyarsadig/studybud-go/internal/domain"
 	"github.com/elyarsadig/studybud-go/pkg/errorHandler"
 	"github.com/elyarsadig/studybud-go/pkg/logger"
+	"github.com/elyarsadig/studybud-go/pkg/pagination"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type RoomRepository struct {
@@ -26,27 +29,19 @@ func NewRoom(db *gorm.DB, errHandler errorHandler.Handler, logger logger.Logger)
 
 func (r *RoomRepository) None() {}
 
-func (r *RoomRepository) ListAllRooms(ctx context.Context) (domain.Rooms, error) {
-	rooms := domain.Rooms{}
-	err := r.db.WithContext(ctx).
-		Model(&domain.Room{}).
-		Preload("Host").
-		Preload("Topic").
-		Joins("LEFT JOIN room_participants ON room_participants.room_id = rooms.id").
-		Select("rooms.*, COUNT(room_participants.id) as participants_count").
-		Group("rooms.id").
-		Order("rooms.created DESC").
-		Find(&rooms.List).
-		Count(&rooms.Count).Error
-	if err != nil {
-		r.logger.Error(err.Error())
-		return domain.Rooms{}, r.errHandler.New(http.StatusInternalServerError, "something went wrong!")
-	}
-	return rooms, nil
+func (r *RoomRepository) ListAllRooms(ctx context.Context, page domain.Page) (domain.Rooms, error) {
+	return r.listRooms(ctx, page)
 }
 
+// CreateRoom creates room with its host as the first participant.
 func (r *RoomRepository) CreateRoom(ctx context.Context, room *domain.Room) error {
-	err := r.db.WithContext(ctx).Create(&room).Error
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Create(room).Error
+		if err != nil {
+			return err
+		}
+		return tx.Create(&domain.RoomParticipant{RoomID: room.ID, UserID: room.HostID, Role: domain.RoomRoleHost}).Error
+	})
 	if err != nil {
 		r.logger.Error(err.Error())
 		return r.errHandler.New(http.StatusInternalServerError, "something went wrong")
@@ -54,29 +49,25 @@ func (r *RoomRepository) CreateRoom(ctx context.Context, room *domain.Room) erro
 	return nil
 }
 
-func (r *RoomRepository) ListUserRooms(ctx context.Context, userID string) (domain.Rooms, error) {
-	rooms := domain.Rooms{}
-	err := r.db.WithContext(ctx).
-		Model(&domain.Room{}).
-		Preload("Host").
-		Preload("Topic").
-		Where("rooms.host_id = ?", userID).
-		Joins("LEFT JOIN room_participants ON room_participants.room_id = rooms.id").
-		Select("rooms.*, COUNT(room_participants.id) as participants_count").
-		Group("rooms.id").
-		Order("rooms.created DESC").
-		Find(&rooms.List).
-		Count(&rooms.Count).Error
-	if err != nil {
-		r.logger.Error(err.Error())
-		return domain.Rooms{}, r.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+func (r *RoomRepository) ListVisibleRooms(ctx context.Context, viewerID uint, searchQuery string, page domain.Page) (domain.Rooms, error) {
+	if len(searchQuery) == 0 {
+		return r.listRooms(ctx, page, visibleTo(viewerID))
 	}
-	return rooms, nil
+	return r.listRooms(ctx, page, visibleTo(viewerID), searchRooms(searchQuery))
+}
+
+func (r *RoomRepository) ListUserRooms(ctx context.Context, userID string, viewerID uint, page domain.Page) (domain.Rooms, error) {
+	return r.listRooms(ctx, page, visibleTo(viewerID), func(db *gorm.DB) *gorm.DB {
+		return db.Where("rooms.host_id = ?", userID)
+	})
 }
 
 func (r *RoomRepository) GetRoomById(ctx context.Context, roomID string) (domain.Room, error) {
 	var tempRoom domain.Room
 	err := r.db.WithContext(ctx).Model(&domain.Room{}).Preload("Host").Preload("Topic").Where("id = ?", roomID).First(&tempRoom).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return domain.Room{}, r.errHandler.New(http.StatusNotFound, "room not found")
+	}
 	if err != nil {
 		r.logger.Error(err.Error())
 		return domain.Room{}, r.errHandler.New(http.StatusInternalServerError, "something went wrong!")
@@ -84,10 +75,18 @@ func (r *RoomRepository) GetRoomById(ctx context.Context, roomID string) (domain
 	return tempRoom, nil
 }
 
+// ListRoomParticipants lists the host first, then the co-hosts, the
+// moderators and the members in the order they joined.
 func (r *RoomRepository) ListRoomParticipants(ctx context.Context, roomID string) ([]domain.RoomParticipant, error) {
 	var users []domain.RoomParticipant
-	err := r.db.WithContext(ctx).Model(&domain.RoomParticipant{}).Preload("User").Where("room_id = ?", roomID).Find(&users).Error
+	err := r.db.WithContext(ctx).
+		Model(&domain.RoomParticipant{}).
+		Preload("User").
+		Where("room_id = ?", roomID).
+		Order(clause.Expr{SQL: "CASE role WHEN ? THEN 0 WHEN ? THEN 1 WHEN ? THEN 2 ELSE 3 END, id", Vars: []any{domain.RoomRoleHost, domain.RoomRoleCoHost, domain.RoomRoleModerator}}).
+		Find(&users).Error
 	if err != nil {
+		r.logger.Error(err.Error())
 		return nil, r.errHandler.New(http.StatusInternalServerError, "something went wrong!")
 	}
 	return users, nil
@@ -102,8 +101,59 @@ func (r *RoomRepository) DeleteUserRoom(ctx context.Context, roomID, hostID stri
 	return nil
 }
 
+func (r *RoomRepository) DeleteMany(ctx context.Context, ids []uint) error {
+	err := r.db.WithContext(ctx).Where("id IN ?", ids).Delete(&domain.Room{}).Error
+	if err != nil {
+		r.logger.Error(err.Error())
+		return r.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+	}
+	return nil
+}
+
 func (r *RoomRepository) UpdateRoom(ctx context.Context, room domain.Room) error {
-	err := r.db.Model(&room).WithContext(ctx).Updates(domain.Room{Name: room.Name, TopicID: room.TopicID, Description: room.Description}).Error
+	err := r.db.Model(&room).WithContext(ctx).Updates(domain.Room{Name: room.Name, TopicID: room.TopicID, Description: room.Description, DescriptionHTML: room.DescriptionHTML, Visibility: room.Visibility}).Error
+	if err != nil {
+		r.logger.Error(err.Error())
+		return r.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+	}
+	return nil
+}
+
+func (r *RoomRepository) SearchRoom(ctx context.Context, searchQuery string, page domain.Page) (domain.Rooms, error) {
+	return r.listRooms(ctx, page, searchRooms(searchQuery))
+}
+
+func (r *RoomRepository) ListMemberRooms(ctx context.Context, userID string, page domain.Page) (domain.Rooms, error) {
+	return r.listRooms(ctx, page, func(db *gorm.DB) *gorm.DB {
+		return db.Where("EXISTS (SELECT 1 FROM room_participants rp WHERE rp.room_id = rooms.id AND rp.user_id = ?)", userID)
+	})
+}
+
+func (r *RoomRepository) IsParticipant(ctx context.Context, roomID, userID uint) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&domain.RoomParticipant{}).Where("room_id = ? AND user_id = ?", roomID, userID).Count(&count).Error
+	if err != nil {
+		r.logger.Error(err.Error())
+		return false, r.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+	}
+	return count > 0, nil
+}
+
+func (r *RoomRepository) GetParticipant(ctx context.Context, roomID, userID uint) (domain.RoomParticipant, error) {
+	var participant domain.RoomParticipant
+	err := r.db.WithContext(ctx).Where("room_id = ? AND user_id = ?", roomID, userID).First(&participant).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return domain.RoomParticipant{}, r.errHandler.New(http.StatusNotFound, "not a member of this room")
+	}
+	if err != nil {
+		r.logger.Error(err.Error())
+		return domain.RoomParticipant{}, r.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+	}
+	return participant, nil
+}
+
+func (r *RoomRepository) RemoveParticipant(ctx context.Context, roomID, userID uint) error {
+	err := r.db.WithContext(ctx).Where("room_id = ? AND user_id = ?", roomID, userID).Delete(&domain.RoomParticipant{}).Error
 	if err != nil {
 		r.logger.Error(err.Error())
 		return r.errHandler.New(http.StatusInternalServerError, "something went wrong!")
@@ -111,20 +161,236 @@ func (r *RoomRepository) UpdateRoom(ctx context.Context, room domain.Room) error
 	return nil
 }
 
-func (r *RoomRepository) SearchRoom(ctx context.Context, searchQuery string) (domain.Rooms, error) {
+func (r *RoomRepository) SetParticipantRole(ctx context.Context, roomID, userID uint, role string) error {
+	err := r.db.WithContext(ctx).Model(&domain.RoomParticipant{}).Where("room_id = ? AND user_id = ?", roomID, userID).Update("role", role).Error
+	if err != nil {
+		r.logger.Error(err.Error())
+		return r.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+	}
+	return nil
+}
+
+func (r *RoomRepository) SetParticipantMuted(ctx context.Context, roomID, userID uint, muted bool) error {
+	err := r.db.WithContext(ctx).Model(&domain.RoomParticipant{}).Where("room_id = ? AND user_id = ?", roomID, userID).Update("muted", muted).Error
+	if err != nil {
+		r.logger.Error(err.Error())
+		return r.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+	}
+	return nil
+}
+
+// TransferHost makes the participant userID the host of room. The previous
+// host, if any, stays in the room as a co-host. An archived room is taken
+// out of the archive.
+func (r *RoomRepository) TransferHost(ctx context.Context, room domain.Room, userID uint) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if room.HostID != 0 {
+			err := tx.Model(&domain.RoomParticipant{}).Where("room_id = ? AND user_id = ?", room.ID, room.HostID).Update("role", domain.RoomRoleCoHost).Error
+			if err != nil {
+				return err
+			}
+		}
+		return setHost(tx, room.ID, userID)
+	})
+	if err != nil {
+		r.logger.Error(err.Error())
+		return r.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+	}
+	return nil
+}
+
+// AddParticipant makes userID a participant of the room and drops their
+// pending join request, if any.
+func (r *RoomRepository) AddParticipant(ctx context.Context, roomID, userID uint) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		participant := &domain.RoomParticipant{RoomID: roomID, UserID: userID}
+		err := tx.Where(participant).FirstOrCreate(participant).Error
+		if err != nil {
+			return err
+		}
+		return tx.Where("room_id = ? AND user_id = ?", roomID, userID).Delete(&domain.RoomJoinRequest{}).Error
+	})
+	if err != nil {
+		r.logger.Error(err.Error())
+		return r.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+	}
+	return nil
+}
+
+func (r *RoomRepository) ResetInvites(ctx context.Context, roomID uint) error {
+	err := r.db.WithContext(ctx).Model(&domain.Room{ID: roomID}).UpdateColumn("invite_generation", gorm.Expr("invite_generation + 1")).Error
+	if err != nil {
+		r.logger.Error(err.Error())
+		return r.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+	}
+	return nil
+}
+
+// CreateJoinRequest queues a join request, asking again while one is pending
+// is a no-op.
+func (r *RoomRepository) CreateJoinRequest(ctx context.Context, request *domain.RoomJoinRequest) error {
+	err := r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(request).Error
+	if err != nil {
+		r.logger.Error(err.Error())
+		return r.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+	}
+	return nil
+}
+
+func (r *RoomRepository) HasJoinRequest(ctx context.Context, roomID, userID uint) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&domain.RoomJoinRequest{}).Where("room_id = ? AND user_id = ?", roomID, userID).Count(&count).Error
+	if err != nil {
+		r.logger.Error(err.Error())
+		return false, r.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+	}
+	return count > 0, nil
+}
+
+func (r *RoomRepository) ListJoinRequests(ctx context.Context, roomID uint) ([]domain.RoomJoinRequest, error) {
+	var requests []domain.RoomJoinRequest
+	err := r.db.WithContext(ctx).Preload("User").Where("room_id = ?", roomID).Order("created").Find(&requests).Error
+	if err != nil {
+		r.logger.Error(err.Error())
+		return nil, r.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+	}
+	return requests, nil
+}
+
+func (r *RoomRepository) GetJoinRequest(ctx context.Context, roomID uint, id string) (domain.RoomJoinRequest, error) {
+	var request domain.RoomJoinRequest
+	err := r.db.WithContext(ctx).Where("id = ? AND room_id = ?", id, roomID).First(&request).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return domain.RoomJoinRequest{}, r.errHandler.New(http.StatusNotFound, "join request not found")
+	}
+	if err != nil {
+		r.logger.Error(err.Error())
+		return domain.RoomJoinRequest{}, r.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+	}
+	return request, nil
+}
+
+func (r *RoomRepository) DeleteJoinRequest(ctx context.Context, id uint) error {
+	err := r.db.WithContext(ctx).Delete(&domain.RoomJoinRequest{}, id).Error
+	if err != nil {
+		r.logger.Error(err.Error())
+		return r.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+	}
+	return nil
+}
+
+// handOverHostedRooms finds a new host for every room hosted by userID before
+// the account is deleted. The successor is the participant with the highest
+// role, preferring active and unmuted accounts, with the most messages in the
+// room. Rooms nobody else takes part in are archived without a host so the
+// conversation in them survives.
+func handOverHostedRooms(tx *gorm.DB, userID uint) error {
+	var roomIDs []uint
+	err := tx.Model(&domain.Room{}).Where("host_id = ?", userID).Pluck("id", &roomIDs).Error
+	if err != nil {
+		return err
+	}
+	for _, roomID := range roomIDs {
+		var successors []uint
+		err := tx.Raw(`SELECT rp.user_id FROM room_participants rp
+			JOIN users ON users.id = rp.user_id
+			WHERE rp.room_id = ? AND rp.user_id <> ?
+			ORDER BY rp.muted, NOT users.is_active,
+				CASE rp.role WHEN ? THEN 0 WHEN ? THEN 1 ELSE 2 END,
+				(SELECT COUNT(*) FROM messages WHERE messages.room_id = rp.room_id AND messages.user_id = rp.user_id) DESC,
+				rp.id
+			LIMIT 1`,
+			roomID, userID, domain.RoomRoleCoHost, domain.RoomRoleModerator).Scan(&successors).Error
+		if err != nil {
+			return err
+		}
+		if len(successors) == 0 {
+			err = tx.Exec("UPDATE rooms SET host_id = NULL, archived = true WHERE id = ?", roomID).Error
+		} else {
+			err = setHost(tx, roomID, successors[0])
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setHost makes userID the host of the room and gives them the host role,
+// adding them as a participant when they are not one yet.
+func setHost(tx *gorm.DB, roomID, userID uint) error {
+	err := tx.Model(&domain.Room{ID: roomID}).UpdateColumns(map[string]any{"host_id": userID, "archived": false}).Error
+	if err != nil {
+		return err
+	}
+	participant := &domain.RoomParticipant{RoomID: roomID, UserID: userID}
+	err = tx.Where(participant).FirstOrCreate(participant).Error
+	if err != nil {
+		return err
+	}
+	return tx.Model(participant).Updates(map[string]any{"role": domain.RoomRoleHost, "muted": false}).Error
+}
+
+// searchRooms matches rooms by the words of their name, description and
+// topic. The topic links of the pages search for the topic name, which is
+// also matched as a whole so topics like "C++" still find their rooms.
+func searchRooms(searchQuery string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		db = db.Joins("JOIN topics ON topics.id = rooms.topic_id")
+		return matching(searchQuery, "(rooms.search_vector @@ search_query OR topics.search_vector @@ search_query OR LOWER(topics.name) = LOWER(?))", searchQuery)(db)
+	}
+}
+
+// visibleTo limits a query on rooms to the ones viewerID may find in
+// listings: public rooms and the rooms they host or take part in. Anonymous
+// viewers pass 0.
+func visibleTo(viewerID uint) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if viewerID == 0 {
+			return db.Where("rooms.visibility = ?", domain.RoomVisibilityPublic)
+		}
+		return db.Where("rooms.visibility = ? OR rooms.host_id = ? OR EXISTS (SELECT 1 FROM room_participants rp WHERE rp.room_id = rooms.id AND rp.user_id = ?)",
+			domain.RoomVisibilityPublic, viewerID, viewerID)
+	}
+}
+
+// listRooms returns one page of rooms ordered from newest to oldest using
+// keyset pagination on (created, id). Count holds the total number of rooms
+// matching the filters, not just the ones on the page.
+func (r *RoomRepository) listRooms(ctx context.Context, page domain.Page, filters ...func(*gorm.DB) *gorm.DB) (domain.Rooms, error) {
 	rooms := domain.Rooms{}
+	err := r.db.WithContext(ctx).Model(&domain.Room{}).Scopes(filters...).Count(&rooms.Count).Error
+	if err != nil {
+		r.logger.Error(err.Error())
+		return domain.Rooms{}, r.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+	}
+	limit := page.Size()
 	query := r.db.WithContext(ctx).
 		Model(&domain.Room{}).
-		Select("rooms.*, topics.name as topic_name, users.name as host_name").
-		Joins("JOIN topics ON topics.id = rooms.topic_id").
-		Joins("JOIN users ON users.id = rooms.host_id").
-		Where("rooms.name ILIKE ? OR topics.name ILIKE ?", "%"+searchQuery+"%", "%"+searchQuery+"%").
+		Scopes(filters...).
 		Preload("Host").
-		Preload("Topic")
-	result := query.Find(&rooms.List).Count(&rooms.Count)
-	if result.Error != nil {
-		r.logger.Error(result.Error.Error())
+		Preload("Topic").
+		Joins("LEFT JOIN room_participants ON room_participants.room_id = rooms.id").
+		Select("rooms.*, COUNT(room_participants.id) as participants_count").
+		Group("rooms.id").
+		Order("rooms.created DESC, rooms.id DESC").
+		Limit(limit + 1)
+	if len(page.Cursor) != 0 {
+		created, id, err := decodeTimeCursor(page.Cursor)
+		if err != nil {
+			return domain.Rooms{}, r.errHandler.New(http.StatusBadRequest, err.Error())
+		}
+		query = query.Where("(rooms.created, rooms.id) < (?, ?)", created, id)
+	}
+	err = query.Find(&rooms.List).Error
+	if err != nil {
+		r.logger.Error(err.Error())
 		return domain.Rooms{}, r.errHandler.New(http.StatusInternalServerError, "something went wrong!")
 	}
+	if len(rooms.List) > limit {
+		rooms.List = rooms.List[:limit]
+		last := rooms.List[limit-1]
+		rooms.NextCursor = pagination.Encode(pagination.NewTimeCursor(last.Created, last.ID))
+	}
 	return rooms, nil
 }
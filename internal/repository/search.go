@@ -0,0 +1,226 @@
+package repository
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/elyarsadig/studybud-go/internal/domain"
+	"github.com/elyarsadig/studybud-go/pkg/errorHandler"
+	"github.com/elyarsadig/studybud-go/pkg/logger"
+	"github.com/elyarsadig/studybud-go/pkg/pagination"
+	"github.com/elyarsadig/studybud-go/pkg/search"
+	"gorm.io/gorm"
+)
+
+// searchLanguage is the text search configuration the search_vector columns
+// are built with, see migrations.addSearchVectors. Queries must use the same
+// one to find the words as they were stored.
+const searchLanguage = "english"
+
+type SearchRepository struct {
+	db         *gorm.DB
+	errHandler errorHandler.Handler
+	logger     logger.Logger
+}
+
+func NewSearch(db *gorm.DB, errHandler errorHandler.Handler, logger logger.Logger) domain.SearchRepository {
+	return &SearchRepository{
+		db:         db,
+		errHandler: errHandler,
+		logger:     logger,
+	}
+}
+
+func (r *SearchRepository) None() {}
+
+// rankedRow is a result before its record is loaded.
+type rankedRow struct {
+	ID      uint
+	Rank    float32
+	Snippet string
+}
+
+// SearchRooms finds the rooms viewerID may find in listings by their name,
+// description or topic. Snippets are cut from the description.
+func (r *SearchRepository) SearchRooms(ctx context.Context, filter domain.SearchFilter, viewerID uint, page domain.Page) (domain.SearchHits[domain.RoomWithDetails], error) {
+	rows, count, nextCursor, err := r.rank(ctx, "rooms", "rooms.description", page,
+		matching(filter.Query, "(rooms.search_vector @@ search_query OR EXISTS (SELECT 1 FROM topics WHERE topics.id = rooms.topic_id AND topics.search_vector @@ search_query))"),
+		visibleTo(viewerID),
+		inFilteredRooms("rooms.id", filter),
+		createdBetween("rooms.created", filter),
+	)
+	if err != nil {
+		return domain.SearchHits[domain.RoomWithDetails]{}, err
+	}
+	var rooms []domain.RoomWithDetails
+	err = r.db.WithContext(ctx).
+		Model(&domain.Room{}).
+		Preload("Host").
+		Preload("Topic").
+		Joins("LEFT JOIN room_participants ON room_participants.room_id = rooms.id").
+		Select("rooms.*, COUNT(room_participants.id) as participants_count").
+		Where("rooms.id IN ?", rowIDs(rows)).
+		Group("rooms.id").
+		Find(&rooms).Error
+	if err != nil {
+		r.logger.Error(err.Error())
+		return domain.SearchHits[domain.RoomWithDetails]{}, r.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+	}
+	hits := domain.SearchHits[domain.RoomWithDetails]{Count: count, NextCursor: nextCursor}
+	hits.List = collectHits(rows, rooms, func(room domain.RoomWithDetails) uint { return room.ID })
+	return hits, nil
+}
+
+// SearchMessages finds messages by their body in the rooms viewerID may find
+// in listings.
+func (r *SearchRepository) SearchMessages(ctx context.Context, filter domain.SearchFilter, viewerID uint, page domain.Page) (domain.SearchHits[domain.Message], error) {
+	rows, count, nextCursor, err := r.rank(ctx, "messages", "messages.body", page,
+		matching(filter.Query, "messages.search_vector @@ search_query"),
+		inVisibleRooms(viewerID),
+		inFilteredRooms("messages.room_id", filter),
+		createdBetween("messages.created", filter),
+	)
+	if err != nil {
+		return domain.SearchHits[domain.Message]{}, err
+	}
+	var messages []domain.Message
+	err = r.db.WithContext(ctx).Preload("User").Preload("Room").Where("id IN ?", rowIDs(rows)).Find(&messages).Error
+	if err != nil {
+		r.logger.Error(err.Error())
+		return domain.SearchHits[domain.Message]{}, r.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+	}
+	hits := domain.SearchHits[domain.Message]{Count: count, NextCursor: nextCursor}
+	hits.List = collectHits(rows, messages, func(message domain.Message) uint { return message.ID })
+	return hits, nil
+}
+
+// SearchUsers finds active users by their username, name or bio. Snippets are
+// cut from the bio.
+func (r *SearchRepository) SearchUsers(ctx context.Context, filter domain.SearchFilter, page domain.Page) (domain.SearchHits[domain.User], error) {
+	rows, count, nextCursor, err := r.rank(ctx, "users", "users.bio", page,
+		matching(filter.Query, "users.search_vector @@ search_query AND users.is_active AND NOT users.pending_email_verification"),
+		createdBetween("users.date_joined", filter),
+	)
+	if err != nil {
+		return domain.SearchHits[domain.User]{}, err
+	}
+	var users []domain.User
+	err = r.db.WithContext(ctx).Where("id IN ?", rowIDs(rows)).Find(&users).Error
+	if err != nil {
+		r.logger.Error(err.Error())
+		return domain.SearchHits[domain.User]{}, r.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+	}
+	hits := domain.SearchHits[domain.User]{Count: count, NextCursor: nextCursor}
+	hits.List = collectHits(rows, users, func(user domain.User) uint { return user.ID })
+	return hits, nil
+}
+
+// rank returns one page of the rows of table matching the filters, the most
+// relevant first, using keyset pagination on (rank, id), along with the
+// number of matching rows and the cursor of the next page. Snippets are cut
+// from the document column.
+func (r *SearchRepository) rank(ctx context.Context, table, document string, page domain.Page, filters ...func(*gorm.DB) *gorm.DB) ([]rankedRow, int64, string, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Table(table).Scopes(filters...).Count(&count).Error
+	if err != nil {
+		r.logger.Error(err.Error())
+		return nil, 0, "", r.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+	}
+	rank := "ts_rank_cd(" + table + ".search_vector, search_query)"
+	limit := page.Size()
+	query := r.db.WithContext(ctx).
+		Table(table).
+		Scopes(filters...).
+		Select(table+".id, "+rank+" AS rank, ts_headline(?::regconfig, COALESCE("+document+", ''), search_query, ?) AS snippet", searchLanguage, search.HeadlineOptions).
+		Order("rank DESC, " + table + ".id DESC").
+		Limit(limit + 1)
+	if len(page.Cursor) != 0 {
+		cursor, err := pagination.Decode(page.Cursor)
+		if err != nil {
+			return nil, 0, "", r.errHandler.New(http.StatusBadRequest, err.Error())
+		}
+		cursorRank, err := cursor.Rank()
+		if err != nil {
+			return nil, 0, "", r.errHandler.New(http.StatusBadRequest, err.Error())
+		}
+		query = query.Where("("+rank+", "+table+".id) < (?::real, ?)", cursorRank, cursor.ID)
+	}
+	var rows []rankedRow
+	err = query.Scan(&rows).Error
+	if err != nil {
+		r.logger.Error(err.Error())
+		return nil, 0, "", r.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+	}
+	nextCursor := ""
+	if len(rows) > limit {
+		rows = rows[:limit]
+		last := rows[limit-1]
+		nextCursor = pagination.Encode(pagination.NewRankCursor(last.Rank, last.ID))
+	}
+	return rows, count, nextCursor, nil
+}
+
+// matching joins the tsquery of searchQuery as search_query and keeps the rows
+// meeting condition, args fill in its placeholders.
+func matching(searchQuery, condition string, args ...any) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Joins("CROSS JOIN to_tsquery(?::regconfig, ?) AS search_query", searchLanguage, search.Query(searchQuery)).Where(condition, args...)
+	}
+}
+
+// inFilteredRooms keeps the rows whose room, held in column, is accepted by
+// the topic, host and room of filter.
+func inFilteredRooms(column string, filter domain.SearchFilter) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if filter.RoomID != 0 {
+			db = db.Where(column+" = ?", filter.RoomID)
+		}
+		if filter.Topic != "" {
+			db = db.Where(column+" IN (SELECT r.id FROM rooms r JOIN topics t ON t.id = r.topic_id WHERE LOWER(t.name) = LOWER(?))", filter.Topic)
+		}
+		if filter.Host != "" {
+			db = db.Where(column+" IN (SELECT r.id FROM rooms r JOIN users u ON u.id = r.host_id WHERE u.username = ?)", filter.Host)
+		}
+		return db
+	}
+}
+
+// createdBetween keeps the rows whose column falls within the dates of
+// filter.
+func createdBetween(column string, filter domain.SearchFilter) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if !filter.From.IsZero() {
+			db = db.Where(column+" >= ?", filter.From)
+		}
+		if !filter.To.IsZero() {
+			db = db.Where(column+" < ?", filter.To)
+		}
+		return db
+	}
+}
+
+func rowIDs(rows []rankedRow) []uint {
+	ids := make([]uint, 0, len(rows))
+	for _, row := range rows {
+		ids = append(ids, row.ID)
+	}
+	return ids
+}
+
+// collectHits puts the records loaded for rows back in the order of rows,
+// with their rank and snippet.
+func collectHits[T any](rows []rankedRow, records []T, id func(T) uint) []domain.SearchHit[T] {
+	byID := make(map[uint]T, len(records))
+	for _, record := range records {
+		byID[id(record)] = record
+	}
+	hits := make([]domain.SearchHit[T], 0, len(rows))
+	for _, row := range rows {
+		record, ok := byID[row.ID]
+		if !ok {
+			continue
+		}
+		hits = append(hits, domain.SearchHit[T]{Item: record, Rank: row.Rank, Snippet: search.Highlight(row.Snippet)})
+	}
+	return hits
+}
@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/elyarsadig/studybud-go/internal/domain"
+	"github.com/elyarsadig/studybud-go/pkg/errorHandler"
+	"github.com/elyarsadig/studybud-go/pkg/logger"
+	"gorm.io/gorm"
+)
+
+type TokenRepository struct {
+	db         *gorm.DB
+	errHandler errorHandler.Handler
+	logger     logger.Logger
+}
+
+func NewToken(db *gorm.DB, errHandler errorHandler.Handler, logger logger.Logger) domain.TokenRepository {
+	return &TokenRepository{
+		db:         db,
+		errHandler: errHandler,
+		logger:     logger,
+	}
+}
+
+func (r *TokenRepository) None() {}
+
+func (r *TokenRepository) Create(ctx context.Context, token *domain.PersonalAccessToken) error {
+	err := r.db.WithContext(ctx).Omit("User").Create(token).Error
+	if err != nil {
+		r.logger.Error(err.Error())
+		return r.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+	}
+	return nil
+}
+
+func (r *TokenRepository) ListByUser(ctx context.Context, userID string) ([]domain.PersonalAccessToken, error) {
+	var tokens []domain.PersonalAccessToken
+	err := r.db.WithContext(ctx).
+		Where("user_id = ?", userID).
+		Order("created DESC, id DESC").
+		Find(&tokens).Error
+	if err != nil {
+		r.logger.Error(err.Error())
+		return nil, r.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+	}
+	return tokens, nil
+}
+
+// GetByHash looks a token up by the hash of its value and loads its owner.
+func (r *TokenRepository) GetByHash(ctx context.Context, hash string) (domain.PersonalAccessToken, error) {
+	var token domain.PersonalAccessToken
+	err := r.db.WithContext(ctx).Preload("User").Where("token_hash = ?", hash).First(&token).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return domain.PersonalAccessToken{}, r.errHandler.New(http.StatusNotFound, "token not found")
+	}
+	if err != nil {
+		r.logger.Error(err.Error())
+		return domain.PersonalAccessToken{}, r.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+	}
+	return token, nil
+}
+
+// Delete removes the token id of userID, the owner check keeps users from
+// revoking each other's tokens.
+func (r *TokenRepository) Delete(ctx context.Context, userID string, id string) error {
+	result := r.db.WithContext(ctx).Where("id = ? AND user_id = ?", id, userID).Delete(&domain.PersonalAccessToken{})
+	if result.Error != nil {
+		r.logger.Error(result.Error.Error())
+		return r.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+	}
+	if result.RowsAffected == 0 {
+		return r.errHandler.New(http.StatusNotFound, "token not found")
+	}
+	return nil
+}
+
+// DeleteByUser revokes every token of the given users.
+func (r *TokenRepository) DeleteByUser(ctx context.Context, userIDs []uint) error {
+	err := r.db.WithContext(ctx).Where("user_id IN ?", userIDs).Delete(&domain.PersonalAccessToken{}).Error
+	if err != nil {
+		r.logger.Error(err.Error())
+		return r.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+	}
+	return nil
+}
+
+func (r *TokenRepository) TouchLastUsed(ctx context.Context, id uint, lastUsed time.Time) error {
+	err := r.db.WithContext(ctx).Model(&domain.PersonalAccessToken{}).Where("id = ?", id).Update("last_used", lastUsed).Error
+	if err != nil {
+		r.logger.Error(err.Error())
+		return r.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+	}
+	return nil
+}
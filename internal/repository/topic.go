@@ -2,11 +2,13 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"net/http"
 
 	"github.com/elyarsadig/studybud-go/internal/domain"
 	"github.com/elyarsadig/studybud-go/pkg/errorHandler"
 	"github.com/elyarsadig/studybud-go/pkg/logger"
+	"github.com/elyarsadig/studybud-go/pkg/pagination"
 	"gorm.io/gorm"
 )
 
@@ -26,38 +28,52 @@ func NewTopic(db *gorm.DB, errHandler errorHandler.Handler, logger logger.Logger
 
 func (r *TopicRepository) None() {}
 
-func (r *TopicRepository) ListAllTopics(ctx context.Context) (domain.Topics, error) {
+func (r *TopicRepository) ListAllTopics(ctx context.Context, page domain.Page) (domain.Topics, error) {
+	return r.listTopics(ctx, page)
+}
+
+func (r *TopicRepository) SearchTopicByName(ctx context.Context, name string, page domain.Page) (domain.Topics, error) {
+	return r.listTopics(ctx, page, func(db *gorm.DB) *gorm.DB {
+		return matching(name, "(topics.search_vector @@ search_query OR LOWER(topics.name) = LOWER(?))", name)(db)
+	})
+}
+
+// listTopics returns one page of topics in alphabetical order. Topics have no
+// creation time, so the keyset is (name, id) instead of (created, id).
+func (r *TopicRepository) listTopics(ctx context.Context, page domain.Page, filters ...func(*gorm.DB) *gorm.DB) (domain.Topics, error) {
 	topics := domain.Topics{}
-	err := r.db.WithContext(ctx).
-		Model(&domain.Topic{}).
-		Select("topics.id, topics.name, COUNT(rooms.id) as room_count").
-		Joins("LEFT JOIN rooms ON rooms.topic_id = topics.id").
-		Group("topics.id, topics.name").
-		Order("topics.name").
-		Scan(&topics.List).Error
+	err := r.db.WithContext(ctx).Model(&domain.Topic{}).Scopes(filters...).Count(&topics.Count).Error
 	if err != nil {
 		r.logger.Error(err.Error())
 		return domain.Topics{}, r.errHandler.New(http.StatusInternalServerError, "something went wrong!")
 	}
-	topics.Count = int64(len(topics.List))
-	return topics, nil
-}
-
-func (r *TopicRepository) SearchTopicByName(ctx context.Context, name string) (domain.Topics, error) {
-	topics := domain.Topics{}
-	err := r.db.WithContext(ctx).
+	limit := page.Size()
+	query := r.db.WithContext(ctx).
 		Model(&domain.Topic{}).
+		Scopes(filters...).
 		Select("topics.id, topics.name, COUNT(rooms.id) as room_count").
-		Joins("LEFT JOIN rooms ON rooms.topic_id = topics.id").
-		Where("topics.name ILIKE ?", "%"+name+"%").
+		// Only public rooms are counted so the count matches the listing.
+		Joins("LEFT JOIN rooms ON rooms.topic_id = topics.id AND rooms.visibility = ?", domain.RoomVisibilityPublic).
 		Group("topics.id, topics.name").
-		Order("topics.name").
-		Scan(&topics.List).Error
+		Order("topics.name, topics.id").
+		Limit(limit + 1)
+	if len(page.Cursor) != 0 {
+		cursor, err := pagination.Decode(page.Cursor)
+		if err != nil {
+			return domain.Topics{}, r.errHandler.New(http.StatusBadRequest, err.Error())
+		}
+		query = query.Where("(topics.name, topics.id) > (?, ?)", cursor.Value, cursor.ID)
+	}
+	err = query.Scan(&topics.List).Error
 	if err != nil {
 		r.logger.Error(err.Error())
 		return domain.Topics{}, r.errHandler.New(http.StatusInternalServerError, "something went wrong!")
 	}
-	topics.Count = int64(len(topics.List))
+	if len(topics.List) > limit {
+		topics.List = topics.List[:limit]
+		last := topics.List[limit-1]
+		topics.NextCursor = pagination.Encode(pagination.Cursor{Value: last.Name, ID: last.ID})
+	}
 	return topics, nil
 }
 
@@ -69,3 +85,62 @@ func (r *TopicRepository) CreateTopicIfNotExists(ctx context.Context, topic *dom
 	}
 	return nil
 }
+
+func (r *TopicRepository) GetTopicById(ctx context.Context, id string) (domain.Topic, error) {
+	var topic domain.Topic
+	err := r.db.WithContext(ctx).Where("id = ?", id).First(&topic).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return domain.Topic{}, r.errHandler.New(http.StatusNotFound, "topic not found")
+	}
+	if err != nil {
+		r.logger.Error(err.Error())
+		return domain.Topic{}, r.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+	}
+	return topic, nil
+}
+
+func (r *TopicRepository) UpdateTopic(ctx context.Context, topic domain.Topic) error {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&domain.Topic{}).Where("name = ? AND id <> ?", topic.Name, topic.ID).Count(&count).Error
+	if err != nil {
+		r.logger.Error(err.Error())
+		return r.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+	}
+	if count != 0 {
+		return r.errHandler.New(http.StatusConflict, "a topic with this name already exists, merge them instead")
+	}
+	err = r.db.WithContext(ctx).Model(&domain.Topic{ID: topic.ID}).Update("name", topic.Name).Error
+	if err != nil {
+		r.logger.Error(err.Error())
+		return r.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+	}
+	return nil
+}
+
+// DeleteMany removes the topics together with their rooms, rooms reference
+// their topic with ON DELETE CASCADE.
+func (r *TopicRepository) DeleteMany(ctx context.Context, ids []uint) error {
+	err := r.db.WithContext(ctx).Where("id IN ?", ids).Delete(&domain.Topic{}).Error
+	if err != nil {
+		r.logger.Error(err.Error())
+		return r.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+	}
+	return nil
+}
+
+// MergeTopics moves the rooms of the given topics to the target topic and
+// removes the merged topics in a single transaction.
+func (r *TopicRepository) MergeTopics(ctx context.Context, ids []uint, targetID uint) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&domain.Room{}).Where("topic_id IN ?", ids).Update("topic_id", targetID).Error
+		if err != nil {
+			return err
+		}
+		return tx.Where("id IN ? AND id <> ?", ids, targetID).Delete(&domain.Topic{}).Error
+	})
+	if err != nil {
+		r.logger.Error(err.Error())
+		return r.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+	}
+	return nil
+}
@@ -2,11 +2,15 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/elyarsadig/studybud-go/internal/domain"
 	"github.com/elyarsadig/studybud-go/pkg/errorHandler"
 	"github.com/elyarsadig/studybud-go/pkg/logger"
+	"github.com/elyarsadig/studybud-go/pkg/pagination"
 	"gorm.io/gorm"
 )
 
@@ -44,6 +48,9 @@ func (r *UserRepository) Create(ctx context.Context, obj *domain.User) (domain.U
 func (r *UserRepository) GetUserByEmail(ctx context.Context, email string) (domain.User, error) {
 	var tempUser domain.User
 	err := r.db.WithContext(ctx).Where("email = ?", email).First(&tempUser).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return domain.User{}, r.errHandler.New(http.StatusNotFound, "user not found")
+	}
 	if err != nil {
 		r.logger.Error(err.Error())
 		return domain.User{}, r.errHandler.New(http.StatusInternalServerError, "something went wrong!")
@@ -60,12 +67,312 @@ func (r *UserRepository) Update(ctx context.Context, user domain.User) error {
 	return nil
 }
 
+func (r *UserRepository) UpdatePassword(ctx context.Context, id string, password string) error {
+	err := r.db.WithContext(ctx).Model(&domain.User{}).Where("id = ?", id).Update("password", password).Error
+	if err != nil {
+		r.logger.Error(err.Error())
+		return r.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+	}
+	return nil
+}
+
+func (r *UserRepository) MarkEmailVerified(ctx context.Context, id string) error {
+	err := r.db.WithContext(ctx).Model(&domain.User{}).Where("id = ?", id).Update("pending_email_verification", false).Error
+	if err != nil {
+		r.logger.Error(err.Error())
+		return r.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+	}
+	return nil
+}
+
+// DeleteUnverifiedBefore removes accounts that registered before the given
+// time and never confirmed their email address.
+func (r *UserRepository) DeleteUnverifiedBefore(ctx context.Context, before time.Time) (int64, error) {
+	var deleted int64
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var ids []uint
+		err := tx.Model(&domain.User{}).Where("pending_email_verification AND date_joined < ?", before).Pluck("id", &ids).Error
+		if err != nil || len(ids) == 0 {
+			return err
+		}
+		for _, id := range ids {
+			err = handOverHostedRooms(tx, id)
+			if err != nil {
+				return err
+			}
+		}
+		result := tx.Where("id IN ?", ids).Delete(&domain.User{})
+		deleted = result.RowsAffected
+		return result.Error
+	})
+	if err != nil {
+		r.logger.Error(err.Error())
+		return 0, r.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+	}
+	return deleted, nil
+}
+
+// ListActiveByUsernames loads the active accounts among usernames, unknown
+// usernames are skipped.
+func (r *UserRepository) ListActiveByUsernames(ctx context.Context, usernames []string) ([]domain.User, error) {
+	var users []domain.User
+	if len(usernames) == 0 {
+		return users, nil
+	}
+	err := r.db.WithContext(ctx).Where("username IN ? AND is_active", usernames).Find(&users).Error
+	if err != nil {
+		r.logger.Error(err.Error())
+		return nil, r.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+	}
+	return users, nil
+}
+
+// UsernameExists reports whether any account, active or not, goes by
+// username. Case is ignored so mentions cannot mix two accounts up.
+func (r *UserRepository) UsernameExists(ctx context.Context, username string) (bool, error) {
+	var exists bool
+	err := r.db.WithContext(ctx).Raw("SELECT EXISTS (SELECT 1 FROM users WHERE LOWER(username) = LOWER(?))", username).Scan(&exists).Error
+	if err != nil {
+		r.logger.Error(err.Error())
+		return false, r.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+	}
+	return exists, nil
+}
+
 func (r *UserRepository) GetUserById(ctx context.Context, id string) (domain.User, error) {
 	var tempUser domain.User
 	err := r.db.Model(&domain.User{}).WithContext(ctx).Where("id = ?", id).First(&tempUser).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return domain.User{}, r.errHandler.New(http.StatusNotFound, "user not found")
+	}
 	if err != nil {
 		r.logger.Error(err.Error())
 		return domain.User{}, r.errHandler.New(http.StatusInternalServerError, "something went wrong!")
 	}
 	return tempUser, nil
 }
+
+// ListUsers returns one page of users ordered from the most recently joined
+// using keyset pagination on (date_joined, id).
+func (r *UserRepository) ListUsers(ctx context.Context, searchQuery string, page domain.Page) (domain.Users, error) {
+	filter := func(db *gorm.DB) *gorm.DB {
+		if len(searchQuery) == 0 {
+			return db
+		}
+		pattern := "%" + searchQuery + "%"
+		return db.Where("users.username ILIKE ? OR users.email ILIKE ? OR users.name ILIKE ?", pattern, pattern, pattern)
+	}
+	users := domain.Users{}
+	err := r.db.WithContext(ctx).Model(&domain.User{}).Scopes(filter).Count(&users.Count).Error
+	if err != nil {
+		r.logger.Error(err.Error())
+		return domain.Users{}, r.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+	}
+	limit := page.Size()
+	query := r.db.WithContext(ctx).
+		Model(&domain.User{}).
+		Scopes(filter).
+		Order("users.date_joined DESC, users.id DESC").
+		Limit(limit + 1)
+	if len(page.Cursor) != 0 {
+		joined, id, err := decodeTimeCursor(page.Cursor)
+		if err != nil {
+			return domain.Users{}, r.errHandler.New(http.StatusBadRequest, err.Error())
+		}
+		query = query.Where("(users.date_joined, users.id) < (?, ?)", joined, id)
+	}
+	err = query.Find(&users.List).Error
+	if err != nil {
+		r.logger.Error(err.Error())
+		return domain.Users{}, r.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+	}
+	if len(users.List) > limit {
+		users.List = users.List[:limit]
+		last := users.List[limit-1]
+		users.NextCursor = pagination.Encode(pagination.NewTimeCursor(last.DateJoined, last.ID))
+	}
+	return users, nil
+}
+
+// UpdateAccount saves the profile and account flags of a user by id. Unlike
+// Update it also writes zero values, so flags can be switched off.
+func (r *UserRepository) UpdateAccount(ctx context.Context, user domain.User) error {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&domain.User{}).Where("email = ? AND id <> ?", user.Email, user.ID).Count(&count).Error
+	if err != nil {
+		r.logger.Error(err.Error())
+		return r.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+	}
+	if count != 0 {
+		return r.errHandler.New(http.StatusConflict, "email already in use")
+	}
+	err = r.db.WithContext(ctx).
+		Model(&domain.User{ID: user.ID}).
+		Select("name", "username", "email", "bio", "is_active", "is_staff", "is_superuser", "require_totp").
+		Updates(user).Error
+	if err != nil {
+		r.logger.Error(err.Error())
+		return r.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+	}
+	return nil
+}
+
+func (r *UserRepository) SetActive(ctx context.Context, ids []uint, active bool) error {
+	err := r.db.WithContext(ctx).Model(&domain.User{}).Where("id IN ?", ids).Update("is_active", active).Error
+	if err != nil {
+		r.logger.Error(err.Error())
+		return r.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+	}
+	return nil
+}
+
+// Delete removes the account after handing the rooms it hosts over to
+// someone else, see handOverHostedRooms.
+func (r *UserRepository) Delete(ctx context.Context, id string) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var user domain.User
+		err := tx.Where("id = ?", id).First(&user).Error
+		if err != nil {
+			return err
+		}
+		err = handOverHostedRooms(tx, user.ID)
+		if err != nil {
+			return err
+		}
+		return tx.Delete(&user).Error
+	})
+	if err != nil {
+		r.logger.Error(err.Error())
+		return r.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+	}
+	return nil
+}
+
+// EnableTOTP turns on two-factor authentication with secret and replaces the
+// recovery codes of the user in one transaction.
+func (r *UserRepository) EnableTOTP(ctx context.Context, id string, secret string, codeHashes []string) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&domain.User{}).Where("id = ?", id).
+			Updates(map[string]any{"totp_secret": secret, "totp_enabled": true}).Error
+		if err != nil {
+			return err
+		}
+		return replaceRecoveryCodes(tx, id, codeHashes)
+	})
+	if err != nil {
+		r.logger.Error(err.Error())
+		return r.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+	}
+	return nil
+}
+
+func (r *UserRepository) DisableTOTP(ctx context.Context, id string) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&domain.User{}).Where("id = ?", id).
+			Updates(map[string]any{"totp_secret": "", "totp_enabled": false}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", id).Delete(&domain.RecoveryCode{}).Error
+	})
+	if err != nil {
+		r.logger.Error(err.Error())
+		return r.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+	}
+	return nil
+}
+
+func (r *UserRepository) ReplaceRecoveryCodes(ctx context.Context, id string, codeHashes []string) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return replaceRecoveryCodes(tx, id, codeHashes)
+	})
+	if err != nil {
+		r.logger.Error(err.Error())
+		return r.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+	}
+	return nil
+}
+
+func (r *UserRepository) ListRecoveryCodes(ctx context.Context, id string) ([]domain.RecoveryCode, error) {
+	var codes []domain.RecoveryCode
+	err := r.db.WithContext(ctx).Where("user_id = ?", id).Find(&codes).Error
+	if err != nil {
+		r.logger.Error(err.Error())
+		return nil, r.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+	}
+	return codes, nil
+}
+
+// UseRecoveryCode deletes a recovery code and reports whether it was still
+// there, so two concurrent logins cannot both spend the same code.
+func (r *UserRepository) UseRecoveryCode(ctx context.Context, codeID uint) (bool, error) {
+	result := r.db.WithContext(ctx).Where("id = ?", codeID).Delete(&domain.RecoveryCode{})
+	if result.Error != nil {
+		r.logger.Error(result.Error.Error())
+		return false, r.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func replaceRecoveryCodes(tx *gorm.DB, userID string, codeHashes []string) error {
+	err := tx.Where("user_id = ?", userID).Delete(&domain.RecoveryCode{}).Error
+	if err != nil {
+		return err
+	}
+	id, err := strconv.ParseUint(userID, 10, 64)
+	if err != nil {
+		return err
+	}
+	codes := make([]domain.RecoveryCode, 0, len(codeHashes))
+	for _, hash := range codeHashes {
+		codes = append(codes, domain.RecoveryCode{UserID: uint(id), CodeHash: hash})
+	}
+	if len(codes) == 0 {
+		return nil
+	}
+	return tx.Omit("User").Create(&codes).Error
+}
+
+// GetUserByIdentity returns the user linked to subject at provider.
+func (r *UserRepository) GetUserByIdentity(ctx context.Context, provider string, subject string) (domain.User, error) {
+	var identity domain.UserIdentity
+	err := r.db.WithContext(ctx).Preload("User").
+		Where("provider = ? AND subject = ?", provider, subject).
+		First(&identity).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return domain.User{}, r.errHandler.New(http.StatusNotFound, "user not found")
+	}
+	if err != nil {
+		r.logger.Error(err.Error())
+		return domain.User{}, r.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+	}
+	return identity.User, nil
+}
+
+func (r *UserRepository) LinkIdentity(ctx context.Context, identity *domain.UserIdentity) error {
+	err := r.db.WithContext(ctx).Omit("User").Create(identity).Error
+	if err != nil {
+		r.logger.Error(err.Error())
+		return r.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+	}
+	return nil
+}
+
+// CreateWithIdentity creates user and links identity to it in one
+// transaction, so a failed link does not leave an account nobody can log in
+// to.
+func (r *UserRepository) CreateWithIdentity(ctx context.Context, user *domain.User, identity *domain.UserIdentity) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Create(user).Error
+		if err != nil {
+			return err
+		}
+		identity.UserID = user.ID
+		return tx.Omit("User").Create(identity).Error
+	})
+	if err != nil {
+		r.logger.Error(err.Error())
+		return r.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+	}
+	return nil
+}
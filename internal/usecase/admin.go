@@ -0,0 +1,461 @@
+package usecase
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/elyarsadig/studybud-go/configs"
+	"github.com/elyarsadig/studybud-go/internal/domain"
+	"github.com/elyarsadig/studybud-go/pkg/errorHandler"
+	"github.com/elyarsadig/studybud-go/pkg/logger"
+	"github.com/elyarsadig/studybud-go/pkg/markdown"
+	redispkg "github.com/elyarsadig/studybud-go/pkg/redis"
+	"github.com/elyarsadig/studybud-go/pkg/utils"
+)
+
+// auditDetailLength bounds how much of a message body is copied into the
+// audit log.
+const auditDetailLength = 200
+
+type AdminUseCase struct {
+	repositories map[string]domain.Bridger
+	errHandler   errorHandler.Handler
+	redis        *redispkg.Redis
+	logger       logger.Logger
+}
+
+func NewAdmin(errHandler errorHandler.Handler, redis *redispkg.Redis, logger logger.Logger, repositories ...domain.Bridger) domain.AdminUseCase {
+	admin := &AdminUseCase{
+		repositories: make(map[string]domain.Bridger),
+		errHandler:   errHandler,
+		redis:        redis,
+		logger:       logger,
+	}
+
+	for _, repository := range repositories {
+		switch repository.(type) {
+		case domain.UserRepository:
+			admin.repositories[configs.USERS_DB_NAME] = repository
+		case domain.RoomRepository:
+			admin.repositories[configs.ROOMS_DB_NAME] = repository
+		case domain.MessageRepository:
+			admin.repositories[configs.MESSAGES_DB_NAME] = repository
+		case domain.TopicRepository:
+			admin.repositories[configs.TOPICS_DB_NAME] = repository
+		case domain.AuditRepository:
+			admin.repositories[configs.AUDIT_LOGS_DB_NAME] = repository
+		case domain.TokenRepository:
+			admin.repositories[configs.PERSONAL_ACCESS_TOKENS_DB_NAME] = repository
+		}
+	}
+
+	return admin
+}
+
+func (u *AdminUseCase) None() {}
+
+func (u *AdminUseCase) ListUsers(ctx context.Context, searchQuery string, page domain.Page) (domain.Users, error) {
+	repo := domain.Bridge[domain.UserRepository](configs.USERS_DB_NAME, u.repositories)
+	return repo.ListUsers(ctx, searchQuery, page)
+}
+
+func (u *AdminUseCase) GetUser(ctx context.Context, id string) (domain.User, error) {
+	repo := domain.Bridge[domain.UserRepository](configs.USERS_DB_NAME, u.repositories)
+	return repo.GetUserById(ctx, id)
+}
+
+func (u *AdminUseCase) UpdateUser(ctx context.Context, id string, form domain.AdminUserForm) error {
+	repo := domain.Bridge[domain.UserRepository](configs.USERS_DB_NAME, u.repositories)
+	user, err := repo.GetUserById(ctx, id)
+	if err != nil {
+		return err
+	}
+	err = u.checkManageable(ctx, []uint{user.ID}, !form.IsActive || !(form.IsStaff || form.IsSuperuser))
+	if err != nil {
+		return err
+	}
+	if form.IsSuperuser && !user.IsSuperuser {
+		actor, err := u.actor(ctx)
+		if err != nil {
+			return err
+		}
+		if !actor.IsSuperuser {
+			return u.errHandler.New(http.StatusForbidden, "only superusers can grant superuser status")
+		}
+	}
+	err = utils.ValidateUsername(form.Username)
+	if err != nil {
+		return u.errHandler.New(http.StatusBadRequest, err.Error())
+	}
+	err = utils.ValidateEmail(form.Email)
+	if err != nil {
+		return u.errHandler.New(http.StatusBadRequest, err.Error())
+	}
+	user.Name = form.Name
+	user.Username = form.Username
+	user.Email = form.Email
+	user.Bio = form.Bio
+	deactivated := user.IsActive && !form.IsActive
+	user.IsActive = form.IsActive
+	user.IsStaff = form.IsStaff
+	user.IsSuperuser = form.IsSuperuser
+	user.RequireTOTP = form.RequireTOTP
+	err = repo.UpdateAccount(ctx, user)
+	if err != nil {
+		return err
+	}
+	if deactivated {
+		err = u.signOut(ctx, []uint{user.ID})
+		if err != nil {
+			return err
+		}
+	}
+	return u.audit(ctx, domain.AuditActionUpdate, configs.USERS_DB_NAME, user.ID, user.Username)
+}
+
+func (u *AdminUseCase) DeleteUser(ctx context.Context, id string) error {
+	repo := domain.Bridge[domain.UserRepository](configs.USERS_DB_NAME, u.repositories)
+	user, err := repo.GetUserById(ctx, id)
+	if err != nil {
+		return err
+	}
+	err = u.checkManageable(ctx, []uint{user.ID}, true)
+	if err != nil {
+		return err
+	}
+	err = repo.Delete(ctx, id)
+	if err != nil {
+		return err
+	}
+	return u.audit(ctx, domain.AuditActionDelete, configs.USERS_DB_NAME, user.ID, user.Username)
+}
+
+func (u *AdminUseCase) SetUsersActive(ctx context.Context, ids []string, active bool) error {
+	userIDs, err := u.parseIDs(ids)
+	if err != nil {
+		return err
+	}
+	err = u.checkManageable(ctx, userIDs, !active)
+	if err != nil {
+		return err
+	}
+	repo := domain.Bridge[domain.UserRepository](configs.USERS_DB_NAME, u.repositories)
+	err = repo.SetActive(ctx, userIDs, active)
+	if err != nil {
+		return err
+	}
+	if !active {
+		err = u.signOut(ctx, userIDs)
+		if err != nil {
+			return err
+		}
+	}
+	action := domain.AuditActionDeactivate
+	if active {
+		action = domain.AuditActionActivate
+	}
+	for _, id := range userIDs {
+		err = u.audit(ctx, action, configs.USERS_DB_NAME, id, "")
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// signOut ends the sessions and revokes the access tokens of deactivated
+// users, so they lose access right away rather than when their session
+// expires.
+func (u *AdminUseCase) signOut(ctx context.Context, ids []uint) error {
+	for _, id := range ids {
+		err := revokeAllSessions(ctx, u.redis, u.errHandler, u.logger, strconv.Itoa(int(id)))
+		if err != nil {
+			return err
+		}
+	}
+	tokenRepo := domain.Bridge[domain.TokenRepository](configs.PERSONAL_ACCESS_TOKENS_DB_NAME, u.repositories)
+	return tokenRepo.DeleteByUser(ctx, ids)
+}
+
+// checkManageable stops staff members from changing superuser accounts and
+// from locking themselves out when lockout is set.
+func (u *AdminUseCase) checkManageable(ctx context.Context, ids []uint, lockout bool) error {
+	actor, err := u.actor(ctx)
+	if err != nil {
+		return err
+	}
+	if lockout && slices.Contains(ids, actor.ID) {
+		return u.errHandler.New(http.StatusBadRequest, "you cannot lock yourself out of the admin console")
+	}
+	if actor.IsSuperuser {
+		return nil
+	}
+	repo := domain.Bridge[domain.UserRepository](configs.USERS_DB_NAME, u.repositories)
+	for _, id := range ids {
+		user, err := repo.GetUserById(ctx, strconv.Itoa(int(id)))
+		if err != nil {
+			return err
+		}
+		if user.IsSuperuser {
+			return u.errHandler.New(http.StatusForbidden, "only superusers can manage superuser accounts")
+		}
+	}
+	return nil
+}
+
+func (u *AdminUseCase) ListRooms(ctx context.Context, searchQuery string, page domain.Page) (domain.Rooms, error) {
+	repo := domain.Bridge[domain.RoomRepository](configs.ROOMS_DB_NAME, u.repositories)
+	if len(searchQuery) == 0 {
+		return repo.ListAllRooms(ctx, page)
+	}
+	return repo.SearchRoom(ctx, searchQuery, page)
+}
+
+func (u *AdminUseCase) GetRoom(ctx context.Context, id string) (domain.Room, error) {
+	repo := domain.Bridge[domain.RoomRepository](configs.ROOMS_DB_NAME, u.repositories)
+	return repo.GetRoomById(ctx, id)
+}
+
+func (u *AdminUseCase) UpdateRoom(ctx context.Context, id string, form domain.RoomForm) error {
+	repo := domain.Bridge[domain.RoomRepository](configs.ROOMS_DB_NAME, u.repositories)
+	topicRepo := domain.Bridge[domain.TopicRepository](configs.TOPICS_DB_NAME, u.repositories)
+	room, err := repo.GetRoomById(ctx, id)
+	if err != nil {
+		return err
+	}
+	if len(form.Name) == 0 || len(form.TopicName) == 0 {
+		return u.errHandler.New(http.StatusBadRequest, "name and topic are required")
+	}
+	topic := &domain.Topic{Name: form.TopicName}
+	err = topicRepo.CreateTopicIfNotExists(ctx, topic)
+	if err != nil {
+		return err
+	}
+	room.TopicID = topic.ID
+	room.Name = form.Name
+	room.Description = form.Description
+	room.DescriptionHTML = markdown.Render(form.Description)
+	err = repo.UpdateRoom(ctx, room)
+	if err != nil {
+		return err
+	}
+	return u.audit(ctx, domain.AuditActionUpdate, configs.ROOMS_DB_NAME, room.ID, room.Name)
+}
+
+func (u *AdminUseCase) DeleteRooms(ctx context.Context, ids []string) error {
+	roomIDs, err := u.parseIDs(ids)
+	if err != nil {
+		return err
+	}
+	repo := domain.Bridge[domain.RoomRepository](configs.ROOMS_DB_NAME, u.repositories)
+	rooms := make([]domain.Room, 0, len(roomIDs))
+	for _, id := range roomIDs {
+		room, err := repo.GetRoomById(ctx, strconv.Itoa(int(id)))
+		if err != nil {
+			return err
+		}
+		rooms = append(rooms, room)
+	}
+	err = repo.DeleteMany(ctx, roomIDs)
+	if err != nil {
+		return err
+	}
+	for _, room := range rooms {
+		err = u.audit(ctx, domain.AuditActionDelete, configs.ROOMS_DB_NAME, room.ID, room.Name)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (u *AdminUseCase) ListMessages(ctx context.Context, searchQuery string, page domain.Page) (domain.Messages, error) {
+	repo := domain.Bridge[domain.MessageRepository](configs.MESSAGES_DB_NAME, u.repositories)
+	if len(searchQuery) == 0 {
+		return repo.ListAllMessages(ctx, page)
+	}
+	return repo.SearchMessages(ctx, searchQuery, page)
+}
+
+func (u *AdminUseCase) GetMessage(ctx context.Context, id string) (domain.Message, error) {
+	repo := domain.Bridge[domain.MessageRepository](configs.MESSAGES_DB_NAME, u.repositories)
+	return repo.Get(ctx, id)
+}
+
+func (u *AdminUseCase) UpdateMessage(ctx context.Context, id string, form domain.AdminMessageForm) error {
+	repo := domain.Bridge[domain.MessageRepository](configs.MESSAGES_DB_NAME, u.repositories)
+	message, err := repo.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if len(strings.TrimSpace(form.Body)) == 0 {
+		return u.errHandler.New(http.StatusBadRequest, "message body is required")
+	}
+	message.Body = form.Body
+	message.BodyHTML = markdown.Render(form.Body)
+	err = repo.Update(ctx, message)
+	if err != nil {
+		return err
+	}
+	return u.audit(ctx, domain.AuditActionUpdate, configs.MESSAGES_DB_NAME, message.ID, utils.Truncate(message.Body, auditDetailLength))
+}
+
+func (u *AdminUseCase) DeleteMessages(ctx context.Context, ids []string) ([]domain.Message, error) {
+	messageIDs, err := u.parseIDs(ids)
+	if err != nil {
+		return nil, err
+	}
+	repo := domain.Bridge[domain.MessageRepository](configs.MESSAGES_DB_NAME, u.repositories)
+	messages := make([]domain.Message, 0, len(messageIDs))
+	for _, id := range messageIDs {
+		message, err := repo.Get(ctx, strconv.Itoa(int(id)))
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, message)
+	}
+	err = repo.DeleteMany(ctx, messageIDs)
+	if err != nil {
+		return nil, err
+	}
+	for _, message := range messages {
+		err = u.audit(ctx, domain.AuditActionDelete, configs.MESSAGES_DB_NAME, message.ID, utils.Truncate(message.Body, auditDetailLength))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return messages, nil
+}
+
+func (u *AdminUseCase) ListTopics(ctx context.Context, searchQuery string, page domain.Page) (domain.Topics, error) {
+	repo := domain.Bridge[domain.TopicRepository](configs.TOPICS_DB_NAME, u.repositories)
+	if len(searchQuery) == 0 {
+		return repo.ListAllTopics(ctx, page)
+	}
+	return repo.SearchTopicByName(ctx, searchQuery, page)
+}
+
+func (u *AdminUseCase) GetTopic(ctx context.Context, id string) (domain.Topic, error) {
+	repo := domain.Bridge[domain.TopicRepository](configs.TOPICS_DB_NAME, u.repositories)
+	return repo.GetTopicById(ctx, id)
+}
+
+func (u *AdminUseCase) UpdateTopic(ctx context.Context, id string, form domain.AdminTopicForm) error {
+	repo := domain.Bridge[domain.TopicRepository](configs.TOPICS_DB_NAME, u.repositories)
+	topic, err := repo.GetTopicById(ctx, id)
+	if err != nil {
+		return err
+	}
+	name := strings.TrimSpace(form.Name)
+	if len(name) == 0 {
+		return u.errHandler.New(http.StatusBadRequest, "topic name is required")
+	}
+	topic.Name = name
+	err = repo.UpdateTopic(ctx, topic)
+	if err != nil {
+		return err
+	}
+	return u.audit(ctx, domain.AuditActionUpdate, configs.TOPICS_DB_NAME, topic.ID, topic.Name)
+}
+
+func (u *AdminUseCase) DeleteTopics(ctx context.Context, ids []string) error {
+	topicIDs, err := u.parseIDs(ids)
+	if err != nil {
+		return err
+	}
+	repo := domain.Bridge[domain.TopicRepository](configs.TOPICS_DB_NAME, u.repositories)
+	topics := make([]domain.Topic, 0, len(topicIDs))
+	for _, id := range topicIDs {
+		topic, err := repo.GetTopicById(ctx, strconv.Itoa(int(id)))
+		if err != nil {
+			return err
+		}
+		topics = append(topics, topic)
+	}
+	err = repo.DeleteMany(ctx, topicIDs)
+	if err != nil {
+		return err
+	}
+	for _, topic := range topics {
+		err = u.audit(ctx, domain.AuditActionDelete, configs.TOPICS_DB_NAME, topic.ID, topic.Name)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MergeTopics moves every room of the selected topics into the topic named
+// targetName, creating it when needed, and removes the merged topics.
+func (u *AdminUseCase) MergeTopics(ctx context.Context, ids []string, targetName string) error {
+	topicIDs, err := u.parseIDs(ids)
+	if err != nil {
+		return err
+	}
+	targetName = strings.TrimSpace(targetName)
+	if len(targetName) == 0 {
+		return u.errHandler.New(http.StatusBadRequest, "choose the topic to merge into")
+	}
+	repo := domain.Bridge[domain.TopicRepository](configs.TOPICS_DB_NAME, u.repositories)
+	target := &domain.Topic{Name: targetName}
+	err = repo.CreateTopicIfNotExists(ctx, target)
+	if err != nil {
+		return err
+	}
+	err = repo.MergeTopics(ctx, topicIDs, target.ID)
+	if err != nil {
+		return err
+	}
+	for _, id := range topicIDs {
+		if id == target.ID {
+			continue
+		}
+		err = u.audit(ctx, domain.AuditActionMerge, configs.TOPICS_DB_NAME, id, "into "+target.Name)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (u *AdminUseCase) ListAuditLogs(ctx context.Context, page domain.Page) (domain.AuditLogs, error) {
+	repo := domain.Bridge[domain.AuditRepository](configs.AUDIT_LOGS_DB_NAME, u.repositories)
+	return repo.List(ctx, page)
+}
+
+func (u *AdminUseCase) actor(ctx context.Context) (domain.User, error) {
+	sv := ctx.Value(configs.UserCtxKey).(domain.SessionValue)
+	repo := domain.Bridge[domain.UserRepository](configs.USERS_DB_NAME, u.repositories)
+	return repo.GetUserById(ctx, strconv.Itoa(sv.ID))
+}
+
+func (u *AdminUseCase) audit(ctx context.Context, action, objectType string, objectID uint, detail string) error {
+	sv := ctx.Value(configs.UserCtxKey).(domain.SessionValue)
+	repo := domain.Bridge[domain.AuditRepository](configs.AUDIT_LOGS_DB_NAME, u.repositories)
+	entry := &domain.AuditLog{
+		ActorID:    uint(sv.ID),
+		Action:     action,
+		ObjectType: objectType,
+		ObjectID:   strconv.Itoa(int(objectID)),
+		Detail:     detail,
+	}
+	return repo.Create(ctx, entry)
+}
+
+func (u *AdminUseCase) parseIDs(ids []string) ([]uint, error) {
+	if len(ids) == 0 {
+		return nil, u.errHandler.New(http.StatusBadRequest, "select at least one item")
+	}
+	result := make([]uint, 0, len(ids))
+	for _, id := range ids {
+		value, err := strconv.ParseUint(id, 10, 64)
+		if err != nil {
+			return nil, u.errHandler.New(http.StatusBadRequest, "invalid id "+id)
+		}
+		result = append(result, uint(value))
+	}
+	return result, nil
+}
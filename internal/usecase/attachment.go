@@ -0,0 +1,189 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/elyarsadig/studybud-go/configs"
+	"github.com/elyarsadig/studybud-go/internal/domain"
+	"github.com/elyarsadig/studybud-go/pkg/storage"
+	"github.com/elyarsadig/studybud-go/pkg/thumbnail"
+	"github.com/elyarsadig/studybud-go/pkg/utils"
+	"github.com/google/uuid"
+)
+
+const (
+	// thumbnailSize is the largest side of the image thumbnails, in pixels.
+	thumbnailSize = 320
+	// attachmentSweepBatch is how many orphaned attachments a sweep removes
+	// at most.
+	attachmentSweepBatch = 100
+)
+
+// storeUploads checks the uploads against the attachment limits and puts them
+// in the file storage. The content type of each file is sniffed from its
+// first bytes, whatever name it was sent with. Should any upload be refused,
+// the ones already stored are removed again.
+func (u *MessageUseCase) storeUploads(ctx context.Context, uploads []domain.Upload) ([]domain.Attachment, error) {
+	if len(uploads) > u.limits.MaxFiles {
+		return nil, u.errHandler.New(http.StatusBadRequest, "you can attach up to "+strconv.Itoa(u.limits.MaxFiles)+" files to a message")
+	}
+	attachments := make([]domain.Attachment, 0, len(uploads))
+	for _, upload := range uploads {
+		attachment, err := u.storeUpload(ctx, upload)
+		if err != nil {
+			u.deleteFiles(ctx, attachments)
+			return nil, err
+		}
+		attachments = append(attachments, attachment)
+	}
+	return attachments, nil
+}
+
+func (u *MessageUseCase) storeUpload(ctx context.Context, upload domain.Upload) (domain.Attachment, error) {
+	name := utils.CleanFilename(upload.Name)
+	data, err := io.ReadAll(io.LimitReader(upload.Content, u.limits.MaxSize+1))
+	if err != nil {
+		u.logger.Error(err.Error())
+		return domain.Attachment{}, u.errHandler.New(http.StatusBadRequest, "could not read "+name)
+	}
+	if int64(len(data)) > u.limits.MaxSize {
+		return domain.Attachment{}, u.errHandler.New(http.StatusRequestEntityTooLarge, name+" is larger than "+utils.FormatSize(u.limits.MaxSize))
+	}
+	contentType := http.DetectContentType(data)
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || !slices.Contains(u.limits.AllowedTypes, mediaType) {
+		return domain.Attachment{}, u.errHandler.New(http.StatusUnsupportedMediaType, name+" is not a file type you can attach")
+	}
+	attachment := domain.Attachment{
+		Name:        name,
+		ContentType: contentType,
+		Size:        int64(len(data)),
+		StorageKey:  "attachments/" + uuid.New().String(),
+	}
+	err = u.files.Put(ctx, attachment.StorageKey, bytes.NewReader(data), attachment.Size, contentType)
+	if err != nil {
+		u.logger.Error(err.Error())
+		return domain.Attachment{}, u.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+	}
+	if thumbnail.IsImage(mediaType) {
+		u.storeThumbnail(ctx, &attachment, data)
+	}
+	return attachment, nil
+}
+
+// storeThumbnail adds a thumbnail to an image attachment. An image that
+// cannot be shrunk is still attached, it just has no thumbnail.
+func (u *MessageUseCase) storeThumbnail(ctx context.Context, attachment *domain.Attachment, data []byte) {
+	thumb, contentType, err := thumbnail.Generate(data, thumbnailSize)
+	if err != nil {
+		u.logger.WarnContext(ctx, "could not generate thumbnail", "name", attachment.Name, "error", err.Error())
+		return
+	}
+	key := "thumbnails/" + uuid.New().String()
+	err = u.files.Put(ctx, key, bytes.NewReader(thumb), int64(len(thumb)), contentType)
+	if err != nil {
+		u.logger.Error(err.Error())
+		return
+	}
+	attachment.ThumbnailKey = key
+}
+
+// deleteFiles removes the stored files of attachments. It tries all of them
+// and returns whatever failed.
+func (u *MessageUseCase) deleteFiles(ctx context.Context, attachments []domain.Attachment) error {
+	var errs []error
+	for _, attachment := range attachments {
+		for _, key := range []string{attachment.StorageKey, attachment.ThumbnailKey} {
+			if key == "" {
+				continue
+			}
+			err := u.files.Delete(ctx, key)
+			if err != nil {
+				u.logger.Error(err.Error())
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// loadAttachments fills in the attachments of all the given messages with a
+// single query.
+func (u *MessageUseCase) loadAttachments(ctx context.Context, messages ...*domain.Message) error {
+	byID := make(map[uint]*domain.Message, len(messages))
+	ids := make([]uint, 0, len(messages))
+	for _, message := range messages {
+		byID[message.ID] = message
+		ids = append(ids, message.ID)
+	}
+	repo := domain.Bridge[domain.MessageRepository](configs.MESSAGES_DB_NAME, u.repositories)
+	attachments, err := repo.ListAttachments(ctx, ids)
+	if err != nil {
+		return err
+	}
+	for _, attachment := range attachments {
+		attachment.SizeLabel = utils.FormatSize(attachment.Size)
+		message := byID[*attachment.MessageID]
+		message.Attachments = append(message.Attachments, attachment)
+	}
+	return nil
+}
+
+// OpenAttachment opens the content of an attachment, or of its thumbnail when
+// thumb is set, for anybody who may read the room it was posted in.
+func (u *MessageUseCase) OpenAttachment(ctx context.Context, id string, thumb bool) (domain.Attachment, io.ReadCloser, error) {
+	repo := domain.Bridge[domain.MessageRepository](configs.MESSAGES_DB_NAME, u.repositories)
+	attachment, err := repo.GetAttachment(ctx, id)
+	if err != nil {
+		return domain.Attachment{}, nil, err
+	}
+	_, err = u.getReadableRoom(ctx, strconv.Itoa(int(attachment.Message.RoomID)))
+	if err != nil {
+		return domain.Attachment{}, nil, err
+	}
+	key := attachment.StorageKey
+	if thumb {
+		if !attachment.HasThumbnail() {
+			return domain.Attachment{}, nil, u.errHandler.New(http.StatusNotFound, "not found")
+		}
+		key = attachment.ThumbnailKey
+	}
+	content, err := u.files.Open(ctx, key)
+	if errors.Is(err, storage.ErrNotFound) {
+		return domain.Attachment{}, nil, u.errHandler.New(http.StatusNotFound, "not found")
+	}
+	if err != nil {
+		u.logger.Error(err.Error())
+		return domain.Attachment{}, nil, u.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+	}
+	return attachment, content, nil
+}
+
+// SweepAttachments removes the attachments left behind by deleted messages
+// along with their files, and returns how many it removed. An attachment
+// whose files could not be deleted is kept for the next sweep.
+func (u *MessageUseCase) SweepAttachments(ctx context.Context) (int, error) {
+	repo := domain.Bridge[domain.MessageRepository](configs.MESSAGES_DB_NAME, u.repositories)
+	attachments, err := repo.ListOrphanedAttachments(ctx, attachmentSweepBatch)
+	if err != nil {
+		return 0, err
+	}
+	ids := make([]uint, 0, len(attachments))
+	for _, attachment := range attachments {
+		if u.deleteFiles(ctx, []domain.Attachment{attachment}) == nil {
+			ids = append(ids, attachment.ID)
+		}
+	}
+	err = repo.DeleteAttachments(ctx, ids)
+	if err != nil {
+		return 0, err
+	}
+	return len(ids), nil
+}
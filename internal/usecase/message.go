@@ -3,25 +3,42 @@ package usecase
 import (
 	"context"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/elyarsadig/studybud-go/configs"
 	"github.com/elyarsadig/studybud-go/internal/domain"
 	"github.com/elyarsadig/studybud-go/pkg/errorHandler"
 	"github.com/elyarsadig/studybud-go/pkg/logger"
+	"github.com/elyarsadig/studybud-go/pkg/markdown"
+	"github.com/elyarsadig/studybud-go/pkg/storage"
 	"github.com/elyarsadig/studybud-go/pkg/utils"
 )
 
+// maxMentions caps how many users a single message notifies.
+const maxMentions = 20
+
 type MessageUseCase struct {
 	repositories map[string]domain.Bridger
 	errHandler   errorHandler.Handler
+	editWindow   time.Duration
+	limits       domain.AttachmentLimits
+	files        storage.Storage
 	logger       logger.Logger
 }
 
-func NewMessage(errHandler errorHandler.Handler, logger logger.Logger, repositories ...domain.Bridger) domain.MessageUseCase {
+// NewMessage builds the message use case, authors may edit their messages for
+// editWindow after posting them. Files attached to messages are kept in files
+// within limits.
+func NewMessage(errHandler errorHandler.Handler, editWindow time.Duration, limits domain.AttachmentLimits, files storage.Storage, logger logger.Logger, repositories ...domain.Bridger) domain.MessageUseCase {
 	m := &MessageUseCase{
 		repositories: make(map[string]domain.Bridger),
 		errHandler:   errHandler,
+		editWindow:   editWindow,
+		limits:       limits,
+		files:        files,
 		logger:       logger,
 	}
 
@@ -29,6 +46,14 @@ func NewMessage(errHandler errorHandler.Handler, logger logger.Logger, repositor
 		switch repository.(type) {
 		case domain.MessageRepository:
 			m.repositories[configs.MESSAGES_DB_NAME] = repository
+		case domain.RoomRepository:
+			m.repositories[configs.ROOMS_DB_NAME] = repository
+		case domain.PermissionRepository:
+			m.repositories[configs.AUTH_PERMISSIONS_DB_NAME] = repository
+		case domain.UserRepository:
+			m.repositories[configs.USERS_DB_NAME] = repository
+		case domain.NotificationRepository:
+			m.repositories[configs.NOTIFICATIONS_DB_NAME] = repository
 		}
 	}
 
@@ -37,9 +62,11 @@ func NewMessage(errHandler errorHandler.Handler, logger logger.Logger, repositor
 
 func (u *MessageUseCase) None() {}
 
-func (u *MessageUseCase) ListAllMessages(ctx context.Context) (domain.Messages, error) {
+// ListAllMessages is the activity feed, it only shows messages from the rooms
+// the current user would find in the room listing.
+func (u *MessageUseCase) ListAllMessages(ctx context.Context, page domain.Page) (domain.Messages, error) {
 	repo := domain.Bridge[domain.MessageRepository](configs.MESSAGES_DB_NAME, u.repositories)
-	messages, err := repo.ListAllMessages(ctx)
+	messages, err := repo.ListVisibleMessages(ctx, viewerID(ctx), page)
 	if err != nil {
 		return domain.Messages{}, err
 	}
@@ -50,34 +77,156 @@ func (u *MessageUseCase) ListAllMessages(ctx context.Context) (domain.Messages,
 }
 
 func (u *MessageUseCase) GetUserMessage(ctx context.Context, id string) (domain.Message, error) {
+	message, _, err := u.getAuthorizedMessage(ctx, id)
+	return message, err
+}
+
+// Delete removes a message, either by its author or by someone who moderates
+// its room and outranks the author.
+func (u *MessageUseCase) Delete(ctx context.Context, id string) error {
 	repo := domain.Bridge[domain.MessageRepository](configs.MESSAGES_DB_NAME, u.repositories)
+	_, _, err := u.getAuthorizedMessage(ctx, id)
+	if err != nil {
+		return err
+	}
+	return repo.Delete(ctx, id)
+}
+
+// EditMessage replaces the body of a message. Only its author may edit it,
+// within the edit window and as long as they may still post in the room.
+func (u *MessageUseCase) EditMessage(ctx context.Context, id, body string) (domain.Message, error) {
 	sessionValue := ctx.Value(configs.UserCtxKey).(domain.SessionValue)
+	body = strings.TrimSpace(body)
+	if len(body) == 0 {
+		return domain.Message{}, u.errHandler.New(http.StatusBadRequest, "message body is required")
+	}
+	repo := domain.Bridge[domain.MessageRepository](configs.MESSAGES_DB_NAME, u.repositories)
 	message, err := repo.Get(ctx, id)
 	if err != nil {
 		return domain.Message{}, err
 	}
-	if message.User.Username != sessionValue.Username {
+	if message.UserID != uint(sessionValue.ID) {
+		return domain.Message{}, u.errHandler.New(http.StatusForbidden, "only the author can edit a message")
+	}
+	if !u.isEditable(message) {
+		return domain.Message{}, u.errHandler.New(http.StatusForbidden, "this message can no longer be edited")
+	}
+	_, err = u.getPostableRoom(ctx, message.RoomID, message.UserID)
+	if err != nil {
+		return domain.Message{}, err
+	}
+	if body == message.Body {
+		return message, nil
+	}
+	return repo.Edit(ctx, message, body, markdown.Render(body))
+}
+
+// ListRevisions returns a message with the bodies it had before each edit,
+// newest first. Only the moderators of its room may look at them.
+func (u *MessageUseCase) ListRevisions(ctx context.Context, id string) (domain.Message, []domain.MessageRevision, error) {
+	message, powers, err := u.getAuthorizedMessage(ctx, id)
+	if err != nil {
+		return domain.Message{}, nil, err
+	}
+	if !powers.Moderate {
+		return domain.Message{}, nil, u.errHandler.New(http.StatusForbidden, "forbidden!")
+	}
+	repo := domain.Bridge[domain.MessageRepository](configs.MESSAGES_DB_NAME, u.repositories)
+	revisions, err := repo.ListRevisions(ctx, message.ID)
+	if err != nil {
+		return domain.Message{}, nil, err
+	}
+	message.Since = utils.FormatDuration(time.Since(message.Created))
+	for i, revision := range revisions {
+		revisions[i].Since = utils.FormatDuration(time.Since(revision.Created))
+	}
+	return message, revisions, nil
+}
+
+// isEditable tells whether message is still within the edit window.
+func (u *MessageUseCase) isEditable(message domain.Message) bool {
+	return time.Since(message.Created) < u.editWindow
+}
+
+// PinMessage pins a message on top of its room or unpins it. Only the
+// moderators of the room may pin, authors included.
+func (u *MessageUseCase) PinMessage(ctx context.Context, id string, pinned bool) (domain.Message, error) {
+	message, powers, err := u.getAuthorizedMessage(ctx, id)
+	if err != nil {
+		return domain.Message{}, err
+	}
+	if !powers.Moderate {
 		return domain.Message{}, u.errHandler.New(http.StatusForbidden, "forbidden!")
 	}
+	repo := domain.Bridge[domain.MessageRepository](configs.MESSAGES_DB_NAME, u.repositories)
+	err = repo.SetPinned(ctx, message.ID, pinned)
+	if err != nil {
+		return domain.Message{}, err
+	}
+	message.Pinned = pinned
 	return message, nil
 }
 
-func (u *MessageUseCase) Delete(ctx context.Context, id string) error {
+// ListPinnedMessages lists the messages pinned on top of a room the current
+// user may read.
+func (u *MessageUseCase) ListPinnedMessages(ctx context.Context, roomID string) ([]domain.Message, error) {
+	_, err := u.getReadableRoom(ctx, roomID)
+	if err != nil {
+		return nil, err
+	}
+	repo := domain.Bridge[domain.MessageRepository](configs.MESSAGES_DB_NAME, u.repositories)
+	messages, err := repo.ListPinnedMessages(ctx, roomID)
+	if err != nil {
+		return nil, err
+	}
+	for i, message := range messages {
+		messages[i].Since = utils.FormatDuration(time.Since(message.Created))
+	}
+	return messages, nil
+}
+
+// getAuthorizedMessage loads a message the current user may manage, along
+// with their powers in its room: either they wrote it or they moderate the
+// room and outrank its author.
+func (u *MessageUseCase) getAuthorizedMessage(ctx context.Context, id string) (domain.Message, domain.RoomPowers, error) {
 	sessionValue := ctx.Value(configs.UserCtxKey).(domain.SessionValue)
 	repo := domain.Bridge[domain.MessageRepository](configs.MESSAGES_DB_NAME, u.repositories)
 	message, err := repo.Get(ctx, id)
 	if err != nil {
-		return err
+		return domain.Message{}, domain.RoomPowers{}, err
 	}
-	if message.User.Username != sessionValue.Username {
-		return u.errHandler.New(http.StatusUnauthorized, "forbidden!")
+	roomRepo := domain.Bridge[domain.RoomRepository](configs.ROOMS_DB_NAME, u.repositories)
+	room, err := roomRepo.GetRoomById(ctx, strconv.Itoa(int(message.RoomID)))
+	if err != nil {
+		return domain.Message{}, domain.RoomPowers{}, err
 	}
-	return repo.Delete(ctx, id)
+	permRepo := domain.Bridge[domain.PermissionRepository](configs.AUTH_PERMISSIONS_DB_NAME, u.repositories)
+	powers, err := roomPowers(ctx, u.errHandler, roomRepo, permRepo, room)
+	if err != nil {
+		return domain.Message{}, domain.RoomPowers{}, err
+	}
+	if message.UserID == uint(sessionValue.ID) {
+		return message, powers, nil
+	}
+	if !powers.Moderate {
+		return domain.Message{}, domain.RoomPowers{}, u.errHandler.New(http.StatusForbidden, "forbidden!")
+	}
+	author, err := roomRepo.GetParticipant(ctx, room.ID, message.UserID)
+	if err != nil && !isNotFound(err) {
+		return domain.Message{}, domain.RoomPowers{}, err
+	}
+	if message.UserID == room.HostID {
+		author.Role = domain.RoomRoleHost
+	}
+	if !powers.Outranks(author.Role) {
+		return domain.Message{}, domain.RoomPowers{}, u.errHandler.New(http.StatusForbidden, "you cannot act on messages of a participant of your own role or above")
+	}
+	return message, powers, nil
 }
 
-func (u *MessageUseCase) ListUserMessages(ctx context.Context, userID string) (domain.Messages, error) {
+func (u *MessageUseCase) ListUserMessages(ctx context.Context, userID string, page domain.Page) (domain.Messages, error) {
 	repo := domain.Bridge[domain.MessageRepository](configs.MESSAGES_DB_NAME, u.repositories)
-	messages, err := repo.ListUserMessages(ctx, userID)
+	messages, err := repo.ListUserMessages(ctx, userID, viewerID(ctx), page)
 	if err != nil {
 		return domain.Messages{}, err
 	}
@@ -87,21 +236,260 @@ func (u *MessageUseCase) ListUserMessages(ctx context.Context, userID string) (d
 	return messages, nil
 }
 
-func (u *MessageUseCase) ListRoomMessages(ctx context.Context, roomID string) (domain.Messages, error) {
+// ListRoomMessages returns a page of the room's conversation. Pages are
+// fetched from the newest message backwards but each page is returned in
+// chronological order so it reads top to bottom.
+func (u *MessageUseCase) ListRoomMessages(ctx context.Context, roomID string, page domain.Page) (domain.Messages, error) {
+	_, err := u.getReadableRoom(ctx, roomID)
+	if err != nil {
+		return domain.Messages{}, err
+	}
 	repo := domain.Bridge[domain.MessageRepository](configs.MESSAGES_DB_NAME, u.repositories)
-	messages, err := repo.ListRoomMessages(ctx, roomID)
+	messages, err := repo.ListRoomMessages(ctx, roomID, page)
 	if err != nil {
 		return domain.Messages{}, err
 	}
+	slices.Reverse(messages.MessageList)
+	viewer := viewerID(ctx)
+	parentIDs := make([]uint, 0, len(messages.MessageList))
+	threads := make(map[uint]int, len(messages.MessageList))
 	for i, message := range messages.MessageList {
 		messages.MessageList[i].Since = utils.FormatDuration(time.Since(message.Created))
+		messages.MessageList[i].Editable = message.UserID == viewer && u.isEditable(message)
+		if message.ReplyCount > 0 {
+			parentIDs = append(parentIDs, message.ID)
+			threads[message.ID] = i
+		}
+	}
+	replies, err := repo.ListReplies(ctx, parentIDs)
+	if err != nil {
+		return domain.Messages{}, err
+	}
+	for _, reply := range replies {
+		reply.Since = utils.FormatDuration(time.Since(reply.Created))
+		reply.Editable = reply.UserID == viewer && u.isEditable(reply)
+		thread := &messages.MessageList[threads[*reply.ParentID]]
+		thread.Replies = append(thread.Replies, reply)
+	}
+	shown := make([]*domain.Message, 0, len(messages.MessageList)+len(replies))
+	for i := range messages.MessageList {
+		shown = append(shown, &messages.MessageList[i])
+		for j := range messages.MessageList[i].Replies {
+			shown = append(shown, &messages.MessageList[i].Replies[j])
+		}
+	}
+	err = u.loadReactions(ctx, shown...)
+	if err != nil {
+		return domain.Messages{}, err
+	}
+	err = u.loadAttachments(ctx, shown...)
+	if err != nil {
+		return domain.Messages{}, err
 	}
 	return messages, nil
 }
 
-func (u *MessageUseCase) CreateMessage(ctx context.Context, message *domain.Message) error {
+// ToggleReaction adds the current user's reaction with emoji to a message, or
+// takes it back when they already reacted with it. Reacting is like posting,
+// only members who may post in the room can react.
+func (u *MessageUseCase) ToggleReaction(ctx context.Context, id, emoji string) (domain.Message, error) {
+	sessionValue := ctx.Value(configs.UserCtxKey).(domain.SessionValue)
+	if !domain.IsReactionEmoji(emoji) {
+		return domain.Message{}, u.errHandler.New(http.StatusBadRequest, "unknown reaction")
+	}
+	repo := domain.Bridge[domain.MessageRepository](configs.MESSAGES_DB_NAME, u.repositories)
+	message, err := repo.Get(ctx, id)
+	if err != nil {
+		return domain.Message{}, err
+	}
+	_, err = u.getPostableRoom(ctx, message.RoomID, uint(sessionValue.ID))
+	if err != nil {
+		return domain.Message{}, err
+	}
+	err = repo.ToggleReaction(ctx, domain.MessageReaction{MessageID: message.ID, UserID: uint(sessionValue.ID), Emoji: emoji})
+	if err != nil {
+		return domain.Message{}, err
+	}
+	err = u.loadReactions(ctx, &message)
+	if err != nil {
+		return domain.Message{}, err
+	}
+	return message, nil
+}
+
+// loadReactions fills in the reactions of all the given messages with a single
+// query.
+func (u *MessageUseCase) loadReactions(ctx context.Context, messages ...*domain.Message) error {
+	byID := make(map[uint]*domain.Message, len(messages))
+	ids := make([]uint, 0, len(messages))
+	for _, message := range messages {
+		byID[message.ID] = message
+		ids = append(ids, message.ID)
+	}
+	repo := domain.Bridge[domain.MessageRepository](configs.MESSAGES_DB_NAME, u.repositories)
+	counts, err := repo.ListReactionCounts(ctx, ids, viewerID(ctx))
+	if err != nil {
+		return err
+	}
+	for _, count := range counts {
+		message := byID[count.MessageID]
+		message.Reactions = append(message.Reactions, count)
+	}
+	return nil
+}
+
+// GetThread returns the message that started the thread id belongs to, with
+// all its replies.
+func (u *MessageUseCase) GetThread(ctx context.Context, id string) (domain.Message, error) {
+	repo := domain.Bridge[domain.MessageRepository](configs.MESSAGES_DB_NAME, u.repositories)
+	thread, err := repo.GetThread(ctx, id)
+	if err != nil {
+		return domain.Message{}, err
+	}
+	_, err = u.getReadableRoom(ctx, strconv.Itoa(int(thread[0].RoomID)))
+	if err != nil {
+		return domain.Message{}, err
+	}
+	for i, message := range thread {
+		thread[i].Since = utils.FormatDuration(time.Since(message.Created))
+	}
+	root := thread[0]
+	root.Replies = thread[1:]
+	root.ReplyCount = int64(len(root.Replies))
+	shown := []*domain.Message{&root}
+	for i := range root.Replies {
+		shown = append(shown, &root.Replies[i])
+	}
+	err = u.loadReactions(ctx, shown...)
+	if err != nil {
+		return domain.Message{}, err
+	}
+	err = u.loadAttachments(ctx, shown...)
+	if err != nil {
+		return domain.Message{}, err
+	}
+	return root, nil
+}
+
+// CreateMessage posts message in its room. Only members of the room who have
+// not been muted may post, and nobody may post in an archived room. A reply
+// must answer a message of the same room and joins the thread it is in. The
+// uploads are attached to the message, a message with attachments may go
+// without a body.
+func (u *MessageUseCase) CreateMessage(ctx context.Context, message *domain.Message, uploads ...domain.Upload) error {
 	sv := ctx.Value(configs.UserCtxKey).(domain.SessionValue)
+	message.Body = strings.TrimSpace(message.Body)
+	if len(message.Body) == 0 && len(uploads) == 0 {
+		return u.errHandler.New(http.StatusBadRequest, "message body is required")
+	}
 	message.UserID = uint(sv.ID)
+	room, err := u.getPostableRoom(ctx, message.RoomID, message.UserID)
+	if err != nil {
+		return err
+	}
 	repo := domain.Bridge[domain.MessageRepository](configs.MESSAGES_DB_NAME, u.repositories)
-	return repo.CreateMessage(ctx, message)
+	if message.IsReply() {
+		parent, err := repo.Get(ctx, strconv.Itoa(int(*message.ParentID)))
+		if err != nil {
+			return err
+		}
+		if parent.RoomID != message.RoomID {
+			return u.errHandler.New(http.StatusBadRequest, "you can only reply to messages of this room")
+		}
+		if parent.IsReply() {
+			message.ParentID = parent.ParentID
+		}
+	}
+	message.BodyHTML = markdown.Render(message.Body)
+	message.Attachments, err = u.storeUploads(ctx, uploads)
+	if err != nil {
+		return err
+	}
+	err = repo.CreateMessage(ctx, message)
+	if err != nil {
+		u.deleteFiles(ctx, message.Attachments)
+		return err
+	}
+	for i, attachment := range message.Attachments {
+		message.Attachments[i].SizeLabel = utils.FormatSize(attachment.Size)
+	}
+	u.notifyMentions(ctx, room, *message)
+	return nil
+}
+
+// notifyMentions lets the users mentioned in message know about it. Nobody is
+// notified of mentioning themselves, and in a private room only participants
+// are since nobody else can read the message. A failure is only logged, the
+// message is posted anyway.
+func (u *MessageUseCase) notifyMentions(ctx context.Context, room domain.Room, message domain.Message) {
+	usernames := utils.ParseMentions(message.Body)
+	if len(usernames) > maxMentions {
+		usernames = usernames[:maxMentions]
+	}
+	userRepo := domain.Bridge[domain.UserRepository](configs.USERS_DB_NAME, u.repositories)
+	users, err := userRepo.ListActiveByUsernames(ctx, usernames)
+	if err != nil {
+		return
+	}
+	roomRepo := domain.Bridge[domain.RoomRepository](configs.ROOMS_DB_NAME, u.repositories)
+	notifications := make([]domain.Notification, 0, len(users))
+	for _, user := range users {
+		if user.ID == message.UserID {
+			continue
+		}
+		if room.IsPrivate() {
+			_, err := roomRepo.GetParticipant(ctx, room.ID, user.ID)
+			if err != nil {
+				continue
+			}
+		}
+		notifications = append(notifications, domain.Notification{
+			UserID:    user.ID,
+			Kind:      domain.NotificationMention,
+			ActorID:   message.UserID,
+			RoomID:    room.ID,
+			MessageID: &message.ID,
+		})
+	}
+	notificationRepo := domain.Bridge[domain.NotificationRepository](configs.NOTIFICATIONS_DB_NAME, u.repositories)
+	_ = notificationRepo.CreateMany(ctx, notifications)
+}
+
+// getPostableRoom loads the room and checks userID may post in it: they take
+// part in it, have not been muted and the room is not archived.
+func (u *MessageUseCase) getPostableRoom(ctx context.Context, roomID, userID uint) (domain.Room, error) {
+	roomRepo := domain.Bridge[domain.RoomRepository](configs.ROOMS_DB_NAME, u.repositories)
+	room, err := roomRepo.GetRoomById(ctx, strconv.Itoa(int(roomID)))
+	if err != nil {
+		return domain.Room{}, err
+	}
+	if room.Archived {
+		return domain.Room{}, u.errHandler.New(http.StatusForbidden, "this room is archived")
+	}
+	participant, err := roomRepo.GetParticipant(ctx, room.ID, userID)
+	if isNotFound(err) {
+		return domain.Room{}, u.errHandler.New(http.StatusForbidden, "join the room to post messages")
+	}
+	if err != nil {
+		return domain.Room{}, err
+	}
+	if participant.Muted {
+		return domain.Room{}, u.errHandler.New(http.StatusForbidden, "you have been muted in this room")
+	}
+	return room, nil
+}
+
+// getReadableRoom loads a room and checks the current user may read it.
+func (u *MessageUseCase) getReadableRoom(ctx context.Context, roomID string) (domain.Room, error) {
+	roomRepo := domain.Bridge[domain.RoomRepository](configs.ROOMS_DB_NAME, u.repositories)
+	room, err := roomRepo.GetRoomById(ctx, roomID)
+	if err != nil {
+		return domain.Room{}, err
+	}
+	permRepo := domain.Bridge[domain.PermissionRepository](configs.AUTH_PERMISSIONS_DB_NAME, u.repositories)
+	err = checkRoomAccess(ctx, u.errHandler, roomRepo, permRepo, room)
+	if err != nil {
+		return domain.Room{}, err
+	}
+	return room, nil
 }
@@ -0,0 +1,83 @@
+package usecase
+
+import (
+	"context"
+	"time"
+
+	"github.com/elyarsadig/studybud-go/configs"
+	"github.com/elyarsadig/studybud-go/internal/domain"
+	"github.com/elyarsadig/studybud-go/pkg/errorHandler"
+	"github.com/elyarsadig/studybud-go/pkg/logger"
+	"github.com/elyarsadig/studybud-go/pkg/utils"
+)
+
+type NotificationUseCase struct {
+	repositories map[string]domain.Bridger
+	errHandler   errorHandler.Handler
+	logger       logger.Logger
+}
+
+func NewNotification(errHandler errorHandler.Handler, logger logger.Logger, repositories ...domain.Bridger) domain.NotificationUseCase {
+	n := &NotificationUseCase{
+		repositories: make(map[string]domain.Bridger),
+		errHandler:   errHandler,
+		logger:       logger,
+	}
+
+	for _, repository := range repositories {
+		switch repository.(type) {
+		case domain.NotificationRepository:
+			n.repositories[configs.NOTIFICATIONS_DB_NAME] = repository
+		}
+	}
+
+	return n
+}
+
+func (u *NotificationUseCase) None() {}
+
+// ListNotifications returns a page of the current user's notifications,
+// newest first.
+func (u *NotificationUseCase) ListNotifications(ctx context.Context, page domain.Page) (domain.Notifications, error) {
+	sessionValue := ctx.Value(configs.UserCtxKey).(domain.SessionValue)
+	repo := domain.Bridge[domain.NotificationRepository](configs.NOTIFICATIONS_DB_NAME, u.repositories)
+	notifications, err := repo.ListByUser(ctx, uint(sessionValue.ID), page)
+	if err != nil {
+		return domain.Notifications{}, err
+	}
+	for i, notification := range notifications.List {
+		notifications.List[i].Since = utils.FormatDuration(time.Since(notification.Created))
+	}
+	return notifications, nil
+}
+
+func (u *NotificationUseCase) CountUnread(ctx context.Context) (int64, error) {
+	sessionValue := ctx.Value(configs.UserCtxKey).(domain.SessionValue)
+	repo := domain.Bridge[domain.NotificationRepository](configs.NOTIFICATIONS_DB_NAME, u.repositories)
+	return repo.CountUnread(ctx, uint(sessionValue.ID))
+}
+
+// OpenNotification marks a notification of the current user as read and
+// returns it, so they can be taken to what it is about.
+func (u *NotificationUseCase) OpenNotification(ctx context.Context, id string) (domain.Notification, error) {
+	sessionValue := ctx.Value(configs.UserCtxKey).(domain.SessionValue)
+	repo := domain.Bridge[domain.NotificationRepository](configs.NOTIFICATIONS_DB_NAME, u.repositories)
+	notification, err := repo.Get(ctx, uint(sessionValue.ID), id)
+	if err != nil {
+		return domain.Notification{}, err
+	}
+	if !notification.Read {
+		err = repo.MarkRead(ctx, notification.UserID, notification.ID)
+		if err != nil {
+			return domain.Notification{}, err
+		}
+		notification.Read = true
+	}
+	return notification, nil
+}
+
+func (u *NotificationUseCase) MarkAllRead(ctx context.Context) error {
+	sessionValue := ctx.Value(configs.UserCtxKey).(domain.SessionValue)
+	repo := domain.Bridge[domain.NotificationRepository](configs.NOTIFICATIONS_DB_NAME, u.repositories)
+	return repo.MarkAllRead(ctx, uint(sessionValue.ID))
+}
@@ -0,0 +1,56 @@
+package usecase
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/elyarsadig/studybud-go/configs"
+	"github.com/elyarsadig/studybud-go/internal/domain"
+	"github.com/elyarsadig/studybud-go/pkg/errorHandler"
+	"github.com/elyarsadig/studybud-go/pkg/logger"
+)
+
+type PermissionUseCase struct {
+	repositories map[string]domain.Bridger
+	errHandler   errorHandler.Handler
+	logger       logger.Logger
+}
+
+func NewPermission(errHandler errorHandler.Handler, logger logger.Logger, repositories ...domain.Bridger) domain.PermissionUseCase {
+	permission := &PermissionUseCase{
+		repositories: make(map[string]domain.Bridger),
+		errHandler:   errHandler,
+		logger:       logger,
+	}
+
+	for _, repository := range repositories {
+		switch repository.(type) {
+		case domain.PermissionRepository:
+			permission.repositories[configs.AUTH_PERMISSIONS_DB_NAME] = repository
+		}
+	}
+
+	return permission
+}
+
+func (u *PermissionUseCase) None() {}
+
+func (u *PermissionUseCase) HasPerm(ctx context.Context, userID string, perm string) (bool, error) {
+	repo := domain.Bridge[domain.PermissionRepository](configs.AUTH_PERMISSIONS_DB_NAME, u.repositories)
+	return hasPerm(ctx, u.errHandler, repo, userID, perm)
+}
+
+func (u *PermissionUseCase) ListUserPermissions(ctx context.Context, userID string) ([]string, error) {
+	repo := domain.Bridge[domain.PermissionRepository](configs.AUTH_PERMISSIONS_DB_NAME, u.repositories)
+	return repo.ListUserPermissions(ctx, userID)
+}
+
+// hasPerm is shared by the use cases that combine ownership checks with
+// permissions, e.g. letting moderators delete messages they did not write.
+func hasPerm(ctx context.Context, errHandler errorHandler.Handler, repo domain.PermissionRepository, userID string, perm string) (bool, error) {
+	appLabel, codename, ok := domain.ParsePermission(perm)
+	if !ok {
+		return false, errHandler.New(http.StatusInternalServerError, "invalid permission "+perm)
+	}
+	return repo.UserHasPermission(ctx, userID, appLabel, codename)
+}
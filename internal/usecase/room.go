@@ -2,27 +2,37 @@ package usecase
 
 import (
 	"context"
+	"encoding/base64"
 	"net/http"
+	"net/url"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/elyarsadig/studybud-go/configs"
 	"github.com/elyarsadig/studybud-go/internal/domain"
+	"github.com/elyarsadig/studybud-go/pkg/encryption"
 	"github.com/elyarsadig/studybud-go/pkg/errorHandler"
 	"github.com/elyarsadig/studybud-go/pkg/logger"
+	"github.com/elyarsadig/studybud-go/pkg/markdown"
 	"github.com/elyarsadig/studybud-go/pkg/utils"
 )
 
 type RoomUseCase struct {
 	repositories map[string]domain.Bridger
 	errHandler   errorHandler.Handler
+	invites      *encryption.AES[domain.RoomInviteClaims]
+	publicURL    string
 	logger       logger.Logger
 }
 
-func NewRoom(errHandler errorHandler.Handler, logger logger.Logger, repositories ...domain.Bridger) domain.RoomUseCase {
+func NewRoom(errHandler errorHandler.Handler, invites *encryption.AES[domain.RoomInviteClaims], publicURL string, logger logger.Logger, repositories ...domain.Bridger) domain.RoomUseCase {
 	room := &RoomUseCase{
 		repositories: make(map[string]domain.Bridger),
 		errHandler:   errHandler,
+		invites:      invites,
+		publicURL:    strings.TrimSuffix(publicURL, "/"),
 		logger:       logger,
 	}
 
@@ -32,6 +42,8 @@ func NewRoom(errHandler errorHandler.Handler, logger logger.Logger, repositories
 			room.repositories[configs.ROOMS_DB_NAME] = repository
 		case domain.TopicRepository:
 			room.repositories[configs.TOPICS_DB_NAME] = repository
+		case domain.PermissionRepository:
+			room.repositories[configs.AUTH_PERMISSIONS_DB_NAME] = repository
 		}
 	}
 
@@ -40,20 +52,13 @@ func NewRoom(errHandler errorHandler.Handler, logger logger.Logger, repositories
 
 func (u *RoomUseCase) None() {}
 
-func (u *RoomUseCase) ListRooms(ctx context.Context, searchQuery string) (domain.Rooms, error) {
+// ListRooms lists the public rooms and the rooms the current user, if any,
+// hosts or takes part in.
+func (u *RoomUseCase) ListRooms(ctx context.Context, searchQuery string, page domain.Page) (domain.Rooms, error) {
 	repo := domain.Bridge[domain.RoomRepository](configs.ROOMS_DB_NAME, u.repositories)
-	rooms := domain.Rooms{}
-	var err error
-	if len(searchQuery) == 0 {
-		rooms, err = repo.ListAllRooms(ctx)
-		if err != nil {
-			return domain.Rooms{}, err
-		}
-	} else {
-		rooms, err = repo.SearchRoom(ctx, searchQuery)
-		if err != nil {
-			return domain.Rooms{}, err
-		}
+	rooms, err := repo.ListVisibleRooms(ctx, viewerID(ctx), searchQuery, page)
+	if err != nil {
+		return domain.Rooms{}, err
 	}
 	for i, room := range rooms.List {
 		rooms.List[i].Since = utils.FormatDuration(time.Since(room.Created))
@@ -69,19 +74,25 @@ func (u *RoomUseCase) CreateRoom(ctx context.Context, form domain.RoomForm) erro
 	if err != nil {
 		return err
 	}
+	visibility, err := u.visibility(form.Visibility, domain.RoomVisibilityPublic)
+	if err != nil {
+		return err
+	}
 	roomRepo := domain.Bridge[domain.RoomRepository](configs.ROOMS_DB_NAME, u.repositories)
 	room := domain.Room{
-		Name:        form.Name,
-		TopicID:     topic.ID,
-		HostID:      uint(sessionValue.ID),
-		Description: form.Description,
+		Name:            form.Name,
+		TopicID:         topic.ID,
+		HostID:          uint(sessionValue.ID),
+		Description:     form.Description,
+		Visibility:      visibility,
+		DescriptionHTML: markdown.Render(form.Description),
 	}
 	return roomRepo.CreateRoom(ctx, &room)
 }
 
-func (u *RoomUseCase) ListUserRooms(ctx context.Context, userID string) (domain.Rooms, error) {
+func (u *RoomUseCase) ListUserRooms(ctx context.Context, userID string, page domain.Page) (domain.Rooms, error) {
 	repo := domain.Bridge[domain.RoomRepository](configs.ROOMS_DB_NAME, u.repositories)
-	rooms, err := repo.ListUserRooms(ctx, userID)
+	rooms, err := repo.ListUserRooms(ctx, userID, viewerID(ctx), page)
 	if err != nil {
 		return domain.Rooms{}, err
 	}
@@ -91,9 +102,10 @@ func (u *RoomUseCase) ListUserRooms(ctx context.Context, userID string) (domain.
 	return rooms, nil
 }
 
+// GetRoomById returns a room the current user may read. Private rooms fail
+// with http.StatusForbidden for anyone but their members.
 func (u *RoomUseCase) GetRoomById(ctx context.Context, roomID string) (domain.Room, error) {
-	repo := domain.Bridge[domain.RoomRepository](configs.ROOMS_DB_NAME, u.repositories)
-	room, err := repo.GetRoomById(ctx, roomID)
+	room, err := u.getReadableRoom(ctx, roomID)
 	if err != nil {
 		return domain.Room{}, err
 	}
@@ -101,43 +113,213 @@ func (u *RoomUseCase) GetRoomById(ctx context.Context, roomID string) (domain.Ro
 	return room, nil
 }
 
-func (u *RoomUseCase) ListRoomParticipants(ctx context.Context, roomID string) ([]domain.User, error) {
-	repo := domain.Bridge[domain.RoomRepository](configs.ROOMS_DB_NAME, u.repositories)
-	participants, err := repo.ListRoomParticipants(ctx, roomID)
+func (u *RoomUseCase) ListRoomParticipants(ctx context.Context, roomID string) ([]domain.RoomParticipant, error) {
+	_, err := u.getReadableRoom(ctx, roomID)
 	if err != nil {
 		return nil, err
 	}
-	users := make([]domain.User, 0, len(participants))
-	for _, participant := range participants {
-		users = append(users, participant.User)
+	repo := domain.Bridge[domain.RoomRepository](configs.ROOMS_DB_NAME, u.repositories)
+	return repo.ListRoomParticipants(ctx, roomID)
+}
+
+// JoinRoom makes the current user a member of a public or unlisted room.
+// Private rooms are joined through an invite link or an approved join
+// request.
+func (u *RoomUseCase) JoinRoom(ctx context.Context, roomID string) error {
+	sv := ctx.Value(configs.UserCtxKey).(domain.SessionValue)
+	repo := domain.Bridge[domain.RoomRepository](configs.ROOMS_DB_NAME, u.repositories)
+	room, err := repo.GetRoomById(ctx, roomID)
+	if err != nil {
+		return err
+	}
+	if room.Archived {
+		return u.errHandler.New(http.StatusForbidden, "this room is archived")
+	}
+	if room.IsPrivate() {
+		return u.errHandler.New(http.StatusForbidden, "this room is private, ask the host to let you in")
 	}
-	return users, nil
+	return repo.AddParticipant(ctx, room.ID, uint(sv.ID))
 }
 
-func (u *RoomUseCase) GetUserRoom(ctx context.Context, roomID string) (domain.Room, error) {
+func (u *RoomUseCase) LeaveRoom(ctx context.Context, roomID string) error {
 	sv := ctx.Value(configs.UserCtxKey).(domain.SessionValue)
 	repo := domain.Bridge[domain.RoomRepository](configs.ROOMS_DB_NAME, u.repositories)
 	room, err := repo.GetRoomById(ctx, roomID)
 	if err != nil {
-		return domain.Room{}, err
+		return err
 	}
-	if room.Host.Username != sv.Username {
-		return domain.Room{}, u.errHandler.New(http.StatusForbidden, "forbidden!")
+	if room.HostID == uint(sv.ID) {
+		return u.errHandler.New(http.StatusBadRequest, "the host cannot leave the room, hand it over to someone or delete it instead")
 	}
-	return room, nil
+	_, err = repo.GetParticipant(ctx, room.ID, uint(sv.ID))
+	if err != nil {
+		return err
+	}
+	return repo.RemoveParticipant(ctx, room.ID, uint(sv.ID))
 }
 
-func (u *RoomUseCase) DeleteUserRoom(ctx context.Context, roomID string) error {
+// ListJoinedRooms lists the rooms the current user is a member of.
+func (u *RoomUseCase) ListJoinedRooms(ctx context.Context, page domain.Page) (domain.Rooms, error) {
 	sv := ctx.Value(configs.UserCtxKey).(domain.SessionValue)
-	hostID := strconv.Itoa(sv.ID)
 	repo := domain.Bridge[domain.RoomRepository](configs.ROOMS_DB_NAME, u.repositories)
-	return repo.DeleteUserRoom(ctx, roomID, hostID)
+	rooms, err := repo.ListMemberRooms(ctx, strconv.Itoa(sv.ID), page)
+	if err != nil {
+		return domain.Rooms{}, err
+	}
+	for i, room := range rooms.List {
+		rooms.List[i].Since = utils.FormatDuration(time.Since(room.Created))
+	}
+	return rooms, nil
+}
+
+// GetRoomPowers returns what the current user may do in the room.
+func (u *RoomUseCase) GetRoomPowers(ctx context.Context, roomID string) (domain.RoomPowers, error) {
+	room, err := u.getReadableRoom(ctx, roomID)
+	if err != nil {
+		return domain.RoomPowers{}, err
+	}
+	repo := domain.Bridge[domain.RoomRepository](configs.ROOMS_DB_NAME, u.repositories)
+	permRepo := domain.Bridge[domain.PermissionRepository](configs.AUTH_PERMISSIONS_DB_NAME, u.repositories)
+	return roomPowers(ctx, u.errHandler, repo, permRepo, room)
+}
+
+// SetParticipantRole appoints a participant co-host, moderator or plain
+// member. Co-hosts are appointed by the host, the rest by anyone who manages
+// the room and outranks the participant.
+func (u *RoomUseCase) SetParticipantRole(ctx context.Context, roomID, userID, role string) error {
+	if !slices.Contains(domain.RoomAssignableRoles, role) {
+		return u.errHandler.New(http.StatusBadRequest, "invalid room role")
+	}
+	room, powers, err := u.getAuthorizedRoom(ctx, roomID, canManage)
+	if err != nil {
+		return err
+	}
+	if role == domain.RoomRoleCoHost && !powers.Delete {
+		return u.errHandler.New(http.StatusForbidden, "only the host can appoint co-hosts")
+	}
+	participant, err := u.getOutrankedParticipant(ctx, room, powers, userID)
+	if err != nil {
+		return err
+	}
+	repo := domain.Bridge[domain.RoomRepository](configs.ROOMS_DB_NAME, u.repositories)
+	return repo.SetParticipantRole(ctx, room.ID, participant.UserID, role)
+}
+
+// MuteParticipant stops a participant from posting in the room, or lets them
+// post again.
+func (u *RoomUseCase) MuteParticipant(ctx context.Context, roomID, userID string, muted bool) error {
+	room, powers, err := u.getAuthorizedRoom(ctx, roomID, canModerate)
+	if err != nil {
+		return err
+	}
+	participant, err := u.getOutrankedParticipant(ctx, room, powers, userID)
+	if err != nil {
+		return err
+	}
+	repo := domain.Bridge[domain.RoomRepository](configs.ROOMS_DB_NAME, u.repositories)
+	return repo.SetParticipantMuted(ctx, room.ID, participant.UserID, muted)
+}
+
+// KickParticipant removes a participant from the room. They can come back
+// like anyone else, through the room's visibility, an invite or a join
+// request.
+func (u *RoomUseCase) KickParticipant(ctx context.Context, roomID, userID string) error {
+	room, powers, err := u.getAuthorizedRoom(ctx, roomID, canModerate)
+	if err != nil {
+		return err
+	}
+	participant, err := u.getOutrankedParticipant(ctx, room, powers, userID)
+	if err != nil {
+		return err
+	}
+	repo := domain.Bridge[domain.RoomRepository](configs.ROOMS_DB_NAME, u.repositories)
+	return repo.RemoveParticipant(ctx, room.ID, participant.UserID)
+}
+
+// TransferOwnership hands the room over to one of its participants, the
+// current host stays on as a co-host. Staff allowed to delete rooms may also
+// hand over an archived room.
+func (u *RoomUseCase) TransferOwnership(ctx context.Context, roomID, userID string) error {
+	room, _, err := u.getAuthorizedRoom(ctx, roomID, canDelete)
+	if err != nil {
+		return err
+	}
+	id, err := strconv.Atoi(userID)
+	if err != nil {
+		return u.errHandler.New(http.StatusNotFound, "not a member of this room")
+	}
+	if uint(id) == room.HostID {
+		return u.errHandler.New(http.StatusBadRequest, "this user already hosts the room")
+	}
+	repo := domain.Bridge[domain.RoomRepository](configs.ROOMS_DB_NAME, u.repositories)
+	_, err = repo.GetParticipant(ctx, room.ID, uint(id))
+	if err != nil {
+		return err
+	}
+	return repo.TransferHost(ctx, room, uint(id))
+}
+
+func (u *RoomUseCase) GetUserRoom(ctx context.Context, roomID string) (domain.Room, error) {
+	room, _, err := u.getAuthorizedRoom(ctx, roomID, canManage)
+	return room, err
+}
+
+func (u *RoomUseCase) DeleteUserRoom(ctx context.Context, roomID string) error {
+	room, _, err := u.getAuthorizedRoom(ctx, roomID, canDelete)
+	if err != nil {
+		return err
+	}
+	repo := domain.Bridge[domain.RoomRepository](configs.ROOMS_DB_NAME, u.repositories)
+	if room.Archived {
+		return repo.DeleteMany(ctx, []uint{room.ID})
+	}
+	return repo.DeleteUserRoom(ctx, roomID, strconv.Itoa(int(room.HostID)))
+}
+
+// getAuthorizedRoom loads a room with the powers of the current user in it
+// and fails with http.StatusForbidden unless allowed accepts them.
+func (u *RoomUseCase) getAuthorizedRoom(ctx context.Context, roomID string, allowed func(domain.RoomPowers) bool) (domain.Room, domain.RoomPowers, error) {
+	repo := domain.Bridge[domain.RoomRepository](configs.ROOMS_DB_NAME, u.repositories)
+	room, err := repo.GetRoomById(ctx, roomID)
+	if err != nil {
+		return domain.Room{}, domain.RoomPowers{}, err
+	}
+	permRepo := domain.Bridge[domain.PermissionRepository](configs.AUTH_PERMISSIONS_DB_NAME, u.repositories)
+	powers, err := roomPowers(ctx, u.errHandler, repo, permRepo, room)
+	if err != nil {
+		return domain.Room{}, domain.RoomPowers{}, err
+	}
+	if !allowed(powers) {
+		return domain.Room{}, domain.RoomPowers{}, u.errHandler.New(http.StatusForbidden, "forbidden!")
+	}
+	return room, powers, nil
+}
+
+// getOutrankedParticipant loads the participant userID of room and checks
+// powers are enough to act on them.
+func (u *RoomUseCase) getOutrankedParticipant(ctx context.Context, room domain.Room, powers domain.RoomPowers, userID string) (domain.RoomParticipant, error) {
+	id, err := strconv.Atoi(userID)
+	if err != nil {
+		return domain.RoomParticipant{}, u.errHandler.New(http.StatusNotFound, "not a member of this room")
+	}
+	repo := domain.Bridge[domain.RoomRepository](configs.ROOMS_DB_NAME, u.repositories)
+	participant, err := repo.GetParticipant(ctx, room.ID, uint(id))
+	if err != nil {
+		return domain.RoomParticipant{}, err
+	}
+	if participant.UserID == room.HostID {
+		participant.Role = domain.RoomRoleHost
+	}
+	if !powers.Outranks(participant.Role) {
+		return domain.RoomParticipant{}, u.errHandler.New(http.StatusForbidden, "you cannot act on a participant of your own role or above")
+	}
+	return participant, nil
 }
 
 func (u *RoomUseCase) UpdateRoom(ctx context.Context, id string, roomForm domain.RoomForm) error {
 	repo := domain.Bridge[domain.RoomRepository](configs.ROOMS_DB_NAME, u.repositories)
 	topicRepo := domain.Bridge[domain.TopicRepository](configs.TOPICS_DB_NAME, u.repositories)
-	room, err := repo.GetRoomById(ctx, id)
+	room, _, err := u.getAuthorizedRoom(ctx, id, canManage)
 	if err != nil {
 		return err
 	}
@@ -146,13 +328,304 @@ func (u *RoomUseCase) UpdateRoom(ctx context.Context, id string, roomForm domain
 	if err != nil {
 		return err
 	}
+	room.Visibility, err = u.visibility(roomForm.Visibility, room.Visibility)
+	if err != nil {
+		return err
+	}
 	room.TopicID = topic.ID
 	room.Name = roomForm.Name
 	room.Description = roomForm.Description
+	room.DescriptionHTML = markdown.Render(roomForm.Description)
 	return repo.UpdateRoom(ctx, room)
 }
 
-func (u *RoomUseCase) SearchRoom(ctx context.Context, searchQuery string) (domain.Rooms, error) {
+func (u *RoomUseCase) SearchRoom(ctx context.Context, searchQuery string, page domain.Page) (domain.Rooms, error) {
+	repo := domain.Bridge[domain.RoomRepository](configs.ROOMS_DB_NAME, u.repositories)
+	return repo.ListVisibleRooms(ctx, viewerID(ctx), searchQuery, page)
+}
+
+// CreateInvite returns a link that makes whoever opens it a participant of
+// the room until it expires or the invite links of the room are reset.
+func (u *RoomUseCase) CreateInvite(ctx context.Context, roomID string, expiresIn time.Duration) (string, error) {
+	if !slices.Contains(domain.RoomInviteExpirations, expiresIn) {
+		return "", u.errHandler.New(http.StatusBadRequest, "invalid invite expiration")
+	}
+	room, _, err := u.getAuthorizedRoom(ctx, roomID, canManage)
+	if err != nil {
+		return "", err
+	}
+	ciphertext, err := u.invites.Encrypt(domain.RoomInviteClaims{
+		RoomID:     room.ID,
+		Generation: room.InviteGeneration,
+		ExpiresAt:  time.Now().Add(expiresIn).Unix(),
+	})
+	if err != nil {
+		u.logger.Error(err.Error())
+		return "", u.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+	}
+	return u.publicURL + "/invite?token=" + url.QueryEscape(base64.RawURLEncoding.EncodeToString(ciphertext)), nil
+}
+
+// ResetInvites revokes every invite link handed out for the room so far.
+func (u *RoomUseCase) ResetInvites(ctx context.Context, roomID string) error {
+	room, _, err := u.getAuthorizedRoom(ctx, roomID, canManage)
+	if err != nil {
+		return err
+	}
+	return domain.Bridge[domain.RoomRepository](configs.ROOMS_DB_NAME, u.repositories).ResetInvites(ctx, room.ID)
+}
+
+// GetInvitedRoom returns the room an invite link points to, without joining
+// it.
+func (u *RoomUseCase) GetInvitedRoom(ctx context.Context, token string) (domain.Room, error) {
+	invalid := u.errHandler.New(http.StatusBadRequest, "this invite link is invalid or has expired")
+	ciphertext, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return domain.Room{}, invalid
+	}
+	claims, err := u.invites.Decrypt(ciphertext)
+	if err != nil || time.Now().Unix() > claims.ExpiresAt {
+		return domain.Room{}, invalid
+	}
+	repo := domain.Bridge[domain.RoomRepository](configs.ROOMS_DB_NAME, u.repositories)
+	room, err := repo.GetRoomById(ctx, strconv.Itoa(int(claims.RoomID)))
+	if isNotFound(err) {
+		return domain.Room{}, invalid
+	}
+	if err != nil {
+		return domain.Room{}, err
+	}
+	if room.InviteGeneration != claims.Generation || room.Archived {
+		return domain.Room{}, invalid
+	}
+	return room, nil
+}
+
+// AcceptInvite makes the current user a participant of the room the invite
+// link points to.
+func (u *RoomUseCase) AcceptInvite(ctx context.Context, token string) (domain.Room, error) {
+	sv := ctx.Value(configs.UserCtxKey).(domain.SessionValue)
+	room, err := u.GetInvitedRoom(ctx, token)
+	if err != nil {
+		return domain.Room{}, err
+	}
+	repo := domain.Bridge[domain.RoomRepository](configs.ROOMS_DB_NAME, u.repositories)
+	err = repo.AddParticipant(ctx, room.ID, uint(sv.ID))
+	if err != nil {
+		return domain.Room{}, err
+	}
+	return room, nil
+}
+
+// RequestToJoin queues a request of the current user to join a private room
+// for the host to approve.
+func (u *RoomUseCase) RequestToJoin(ctx context.Context, roomID string) error {
+	sv := ctx.Value(configs.UserCtxKey).(domain.SessionValue)
 	repo := domain.Bridge[domain.RoomRepository](configs.ROOMS_DB_NAME, u.repositories)
-	return repo.SearchRoom(ctx, searchQuery)
+	room, err := repo.GetRoomById(ctx, roomID)
+	if err != nil {
+		return err
+	}
+	if room.Archived {
+		return u.errHandler.New(http.StatusForbidden, "this room is archived")
+	}
+	if !room.IsPrivate() {
+		return u.errHandler.New(http.StatusBadRequest, "this room is open to everyone")
+	}
+	member, err := u.isMember(ctx, room, uint(sv.ID))
+	if err != nil {
+		return err
+	}
+	if member {
+		return u.errHandler.New(http.StatusConflict, "you are already a member of this room")
+	}
+	return repo.CreateJoinRequest(ctx, &domain.RoomJoinRequest{RoomID: room.ID, UserID: uint(sv.ID)})
+}
+
+// HasPendingJoinRequest reports whether the current user asked to join the
+// room and the host has not answered yet.
+func (u *RoomUseCase) HasPendingJoinRequest(ctx context.Context, roomID string) (bool, error) {
+	sv := ctx.Value(configs.UserCtxKey).(domain.SessionValue)
+	id, err := strconv.Atoi(roomID)
+	if err != nil {
+		return false, nil
+	}
+	repo := domain.Bridge[domain.RoomRepository](configs.ROOMS_DB_NAME, u.repositories)
+	return repo.HasJoinRequest(ctx, uint(id), uint(sv.ID))
+}
+
+func (u *RoomUseCase) ListJoinRequests(ctx context.Context, roomID string) ([]domain.RoomJoinRequest, error) {
+	room, _, err := u.getAuthorizedRoom(ctx, roomID, canManage)
+	if err != nil {
+		return nil, err
+	}
+	repo := domain.Bridge[domain.RoomRepository](configs.ROOMS_DB_NAME, u.repositories)
+	requests, err := repo.ListJoinRequests(ctx, room.ID)
+	if err != nil {
+		return nil, err
+	}
+	for i, request := range requests {
+		requests[i].Since = utils.FormatDuration(time.Since(request.Created))
+	}
+	return requests, nil
+}
+
+func (u *RoomUseCase) ApproveJoinRequest(ctx context.Context, roomID, requestID string) error {
+	room, _, err := u.getAuthorizedRoom(ctx, roomID, canManage)
+	if err != nil {
+		return err
+	}
+	repo := domain.Bridge[domain.RoomRepository](configs.ROOMS_DB_NAME, u.repositories)
+	request, err := repo.GetJoinRequest(ctx, room.ID, requestID)
+	if err != nil {
+		return err
+	}
+	return repo.AddParticipant(ctx, room.ID, request.UserID)
+}
+
+func (u *RoomUseCase) DenyJoinRequest(ctx context.Context, roomID, requestID string) error {
+	room, _, err := u.getAuthorizedRoom(ctx, roomID, canManage)
+	if err != nil {
+		return err
+	}
+	repo := domain.Bridge[domain.RoomRepository](configs.ROOMS_DB_NAME, u.repositories)
+	request, err := repo.GetJoinRequest(ctx, room.ID, requestID)
+	if err != nil {
+		return err
+	}
+	return repo.DeleteJoinRequest(ctx, request.ID)
+}
+
+// getReadableRoom loads a room and checks the current user may read it.
+func (u *RoomUseCase) getReadableRoom(ctx context.Context, roomID string) (domain.Room, error) {
+	repo := domain.Bridge[domain.RoomRepository](configs.ROOMS_DB_NAME, u.repositories)
+	room, err := repo.GetRoomById(ctx, roomID)
+	if err != nil {
+		return domain.Room{}, err
+	}
+	permRepo := domain.Bridge[domain.PermissionRepository](configs.AUTH_PERMISSIONS_DB_NAME, u.repositories)
+	err = checkRoomAccess(ctx, u.errHandler, repo, permRepo, room)
+	if err != nil {
+		return domain.Room{}, err
+	}
+	return room, nil
+}
+
+func (u *RoomUseCase) isMember(ctx context.Context, room domain.Room, userID uint) (bool, error) {
+	if room.HostID == userID {
+		return true, nil
+	}
+	repo := domain.Bridge[domain.RoomRepository](configs.ROOMS_DB_NAME, u.repositories)
+	return repo.IsParticipant(ctx, room.ID, userID)
+}
+
+// visibility validates the visibility picked in a room form, fallback is used
+// when none was picked.
+func (u *RoomUseCase) visibility(visibility, fallback string) (string, error) {
+	if len(visibility) == 0 {
+		return fallback, nil
+	}
+	if !slices.Contains(domain.RoomVisibilities, visibility) {
+		return "", u.errHandler.New(http.StatusBadRequest, "invalid room visibility")
+	}
+	return visibility, nil
+}
+
+// checkRoomAccess fails with http.StatusForbidden unless the current user may
+// read room. Public and unlisted rooms are readable by anyone, private rooms
+// by their host, their participants and users allowed to change any room.
+func checkRoomAccess(ctx context.Context, errHandler errorHandler.Handler, repo domain.RoomRepository, permRepo domain.PermissionRepository, room domain.Room) error {
+	if !room.IsPrivate() {
+		return nil
+	}
+	forbidden := errHandler.New(http.StatusForbidden, "this room is private")
+	sv, ok := ctx.Value(configs.UserCtxKey).(domain.SessionValue)
+	if !ok {
+		return forbidden
+	}
+	if room.HostID == uint(sv.ID) {
+		return nil
+	}
+	participant, err := repo.IsParticipant(ctx, room.ID, uint(sv.ID))
+	if err != nil {
+		return err
+	}
+	if participant {
+		return nil
+	}
+	allowed, err := hasPerm(ctx, errHandler, permRepo, strconv.Itoa(sv.ID), domain.PermChangeRoom)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return forbidden
+	}
+	return nil
+}
+
+// roomPowers returns what the current user may do in room through their role
+// in it, topped up by their global permissions. Anonymous and deactivated
+// users have none.
+func roomPowers(ctx context.Context, errHandler errorHandler.Handler, repo domain.RoomRepository, permRepo domain.PermissionRepository, room domain.Room) (domain.RoomPowers, error) {
+	sv, ok := ctx.Value(configs.UserCtxKey).(domain.SessionValue)
+	if !ok {
+		return domain.RoomPowers{}, nil
+	}
+	active, err := permRepo.UserIsActive(ctx, strconv.Itoa(sv.ID))
+	if err != nil {
+		return domain.RoomPowers{}, err
+	}
+	if !active {
+		return domain.RoomPowers{}, nil
+	}
+	participant, err := repo.GetParticipant(ctx, room.ID, uint(sv.ID))
+	if err != nil && !isNotFound(err) {
+		return domain.RoomPowers{}, err
+	}
+	if room.HostID == uint(sv.ID) {
+		participant.Role = domain.RoomRoleHost
+	}
+	powers := domain.NewRoomPowers(participant.Role)
+	powers.Muted = participant.Muted
+	grants := []struct {
+		perm  string
+		power *bool
+	}{
+		{domain.PermDeleteRoom, &powers.Delete},
+		{domain.PermChangeRoom, &powers.Manage},
+		{domain.PermDeleteMessage, &powers.Moderate},
+	}
+	for _, grant := range grants {
+		if *grant.power {
+			continue
+		}
+		allowed, err := hasPerm(ctx, errHandler, permRepo, strconv.Itoa(sv.ID), grant.perm)
+		if err != nil {
+			return domain.RoomPowers{}, err
+		}
+		if allowed {
+			*grant.power = true
+			powers.Staff = true
+		}
+	}
+	// Powers build on each other, whoever may delete the room may manage it
+	// and whoever manages it may moderate it.
+	powers.Manage = powers.Manage || powers.Delete
+	powers.Moderate = powers.Moderate || powers.Manage
+	return powers, nil
+}
+
+func canModerate(powers domain.RoomPowers) bool { return powers.Moderate }
+
+func canManage(powers domain.RoomPowers) bool { return powers.Manage }
+
+func canDelete(powers domain.RoomPowers) bool { return powers.Delete }
+
+// viewerID returns the ID of the current user, or 0 for anonymous requests.
+func viewerID(ctx context.Context) uint {
+	sv, ok := ctx.Value(configs.UserCtxKey).(domain.SessionValue)
+	if !ok {
+		return 0
+	}
+	return uint(sv.ID)
 }
@@ -0,0 +1,93 @@
+package usecase
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/elyarsadig/studybud-go/configs"
+	"github.com/elyarsadig/studybud-go/internal/domain"
+	"github.com/elyarsadig/studybud-go/pkg/errorHandler"
+	"github.com/elyarsadig/studybud-go/pkg/logger"
+	"github.com/elyarsadig/studybud-go/pkg/search"
+	"github.com/elyarsadig/studybud-go/pkg/utils"
+)
+
+// searchPreviewSize is how many results of each kind are shown when all kinds
+// are searched at once.
+const searchPreviewSize = 5
+
+type SearchUseCase struct {
+	repositories map[string]domain.Bridger
+	errHandler   errorHandler.Handler
+	logger       logger.Logger
+}
+
+func NewSearch(errHandler errorHandler.Handler, logger logger.Logger, repositories ...domain.Bridger) domain.SearchUseCase {
+	search := &SearchUseCase{
+		repositories: make(map[string]domain.Bridger),
+		errHandler:   errHandler,
+		logger:       logger,
+	}
+
+	for _, repository := range repositories {
+		switch repository.(type) {
+		case domain.SearchRepository:
+			search.repositories[configs.SEARCH_DB_NAME] = repository
+		}
+	}
+
+	return search
+}
+
+func (u *SearchUseCase) None() {}
+
+// Search looks for rooms, messages and users matching the filter, only
+// finding what the user in ctx may find in listings. When the filter asks for
+// one kind of results they come a page at a time, otherwise the first few of
+// every kind are returned and page is ignored.
+func (u *SearchUseCase) Search(ctx context.Context, filter domain.SearchFilter, page domain.Page) (domain.SearchResults, error) {
+	filter.Query = strings.TrimSpace(filter.Query)
+	if search.Query(filter.Query) == "" {
+		return domain.SearchResults{}, u.errHandler.New(http.StatusBadRequest, "enter something to search for")
+	}
+	if filter.Kind != "" && !domain.IsSearchKind(filter.Kind) {
+		return domain.SearchResults{}, u.errHandler.New(http.StatusBadRequest, "results can be rooms, messages or users")
+	}
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.From.Before(filter.To) {
+		return domain.SearchResults{}, u.errHandler.New(http.StatusBadRequest, "the start date must be before the end date")
+	}
+	if filter.Kind == "" {
+		page = domain.Page{Limit: searchPreviewSize}
+	}
+	repo := domain.Bridge[domain.SearchRepository](configs.SEARCH_DB_NAME, u.repositories)
+	viewer := viewerID(ctx)
+	var results domain.SearchResults
+	var err error
+	if filter.Kind == "" || filter.Kind == domain.SearchRooms {
+		results.Rooms, err = repo.SearchRooms(ctx, filter, viewer, page)
+		if err != nil {
+			return domain.SearchResults{}, err
+		}
+		for i, hit := range results.Rooms.List {
+			results.Rooms.List[i].Item.Since = utils.FormatDuration(time.Since(hit.Item.Created))
+		}
+	}
+	if filter.Kind == "" || filter.Kind == domain.SearchMessages {
+		results.Messages, err = repo.SearchMessages(ctx, filter, viewer, page)
+		if err != nil {
+			return domain.SearchResults{}, err
+		}
+		for i, hit := range results.Messages.List {
+			results.Messages.List[i].Item.Since = utils.FormatDuration(time.Since(hit.Item.Created))
+		}
+	}
+	if (filter.Kind == "" || filter.Kind == domain.SearchUsers) && !filter.InRooms() {
+		results.Users, err = repo.SearchUsers(ctx, filter, page)
+		if err != nil {
+			return domain.SearchResults{}, err
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,164 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/elyarsadig/studybud-go/configs"
+	"github.com/elyarsadig/studybud-go/internal/domain"
+	"github.com/elyarsadig/studybud-go/pkg/errorHandler"
+	"github.com/elyarsadig/studybud-go/pkg/logger"
+)
+
+const (
+	// tokenTouchInterval is how stale the last used time of a token may get
+	// before a request updates it.
+	tokenTouchInterval = time.Minute
+	maxTokenNameLength = 100
+	// tokenPrefixLength is how much of a token is kept in clear to tell
+	// tokens apart in the list.
+	tokenPrefixLength = len(domain.PersonalAccessTokenPrefix) + 4
+)
+
+type TokenUseCase struct {
+	repositories map[string]domain.Bridger
+	errHandler   errorHandler.Handler
+	logger       logger.Logger
+}
+
+func NewToken(errHandler errorHandler.Handler, logger logger.Logger, repositories ...domain.Bridger) domain.TokenUseCase {
+	token := &TokenUseCase{
+		repositories: make(map[string]domain.Bridger),
+		errHandler:   errHandler,
+		logger:       logger,
+	}
+
+	for _, repository := range repositories {
+		switch repository.(type) {
+		case domain.TokenRepository:
+			token.repositories[configs.PERSONAL_ACCESS_TOKENS_DB_NAME] = repository
+		}
+	}
+
+	return token
+}
+
+func (u *TokenUseCase) None() {}
+
+// CreateToken mints a personal access token for the current user and returns
+// its value. Only the hash is stored, the value cannot be shown again.
+func (u *TokenUseCase) CreateToken(ctx context.Context, form *domain.TokenForm) (domain.PersonalAccessToken, string, error) {
+	sessionValue := ctx.Value(configs.UserCtxKey).(domain.SessionValue)
+	// A token must not mint another one, otherwise a read only token could
+	// hand itself write access.
+	if sessionValue.TokenID != 0 {
+		return domain.PersonalAccessToken{}, "", u.errHandler.New(http.StatusForbidden, "personal access tokens cannot create tokens, log in to create one")
+	}
+	err := validateTokenForm(form)
+	if err != nil {
+		return domain.PersonalAccessToken{}, "", u.errHandler.New(http.StatusBadRequest, err.Error())
+	}
+	random, err := generateToken()
+	if err != nil {
+		u.logger.Error(err.Error())
+		return domain.PersonalAccessToken{}, "", u.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+	}
+	value := domain.PersonalAccessTokenPrefix + random
+	token := domain.PersonalAccessToken{
+		UserID:    uint(sessionValue.ID),
+		Name:      strings.TrimSpace(form.Name),
+		TokenHash: hashToken(value),
+		Prefix:    value[:tokenPrefixLength],
+		Scopes:    strings.Join(orderedScopes(form.Scopes), ","),
+	}
+	repo := domain.Bridge[domain.TokenRepository](configs.PERSONAL_ACCESS_TOKENS_DB_NAME, u.repositories)
+	err = repo.Create(ctx, &token)
+	if err != nil {
+		return domain.PersonalAccessToken{}, "", err
+	}
+	u.logger.InfoContext(ctx, "personal access token created", "user", sessionValue.ID, "token", token.ID, "scopes", token.Scopes)
+	return token, value, nil
+}
+
+func (u *TokenUseCase) ListTokens(ctx context.Context) ([]domain.PersonalAccessToken, error) {
+	sessionValue := ctx.Value(configs.UserCtxKey).(domain.SessionValue)
+	repo := domain.Bridge[domain.TokenRepository](configs.PERSONAL_ACCESS_TOKENS_DB_NAME, u.repositories)
+	return repo.ListByUser(ctx, strconv.Itoa(sessionValue.ID))
+}
+
+func (u *TokenUseCase) RevokeToken(ctx context.Context, id string) error {
+	sessionValue := ctx.Value(configs.UserCtxKey).(domain.SessionValue)
+	repo := domain.Bridge[domain.TokenRepository](configs.PERSONAL_ACCESS_TOKENS_DB_NAME, u.repositories)
+	err := repo.Delete(ctx, strconv.Itoa(sessionValue.ID), id)
+	if err != nil {
+		return err
+	}
+	u.logger.InfoContext(ctx, "personal access token revoked", "user", sessionValue.ID, "token", id)
+	return nil
+}
+
+// Authenticate resolves a personal access token to the session value of its
+// owner, carrying the scopes of the token.
+func (u *TokenUseCase) Authenticate(ctx context.Context, value string) (domain.SessionValue, error) {
+	invalid := u.errHandler.New(http.StatusUnauthorized, "invalid or revoked token")
+	if !strings.HasPrefix(value, domain.PersonalAccessTokenPrefix) {
+		return domain.SessionValue{}, invalid
+	}
+	repo := domain.Bridge[domain.TokenRepository](configs.PERSONAL_ACCESS_TOKENS_DB_NAME, u.repositories)
+	token, err := repo.GetByHash(ctx, hashToken(value))
+	if isNotFound(err) {
+		return domain.SessionValue{}, invalid
+	}
+	if err != nil {
+		return domain.SessionValue{}, err
+	}
+	if !token.User.IsActive {
+		return domain.SessionValue{}, invalid
+	}
+	now := time.Now()
+	if token.LastUsed == nil || now.Sub(*token.LastUsed) > tokenTouchInterval {
+		// A failed update only makes the last used time stale.
+		_ = repo.TouchLastUsed(ctx, token.ID, now)
+	}
+	sessionValue := newSessionValue(token.User)
+	sessionValue.TokenID = token.ID
+	sessionValue.Scopes = token.ScopeList()
+	return sessionValue, nil
+}
+
+func validateTokenForm(form *domain.TokenForm) error {
+	name := strings.TrimSpace(form.Name)
+	if len(name) == 0 {
+		return errors.New("token name is required")
+	}
+	if utf8.RuneCountInString(name) > maxTokenNameLength {
+		return errors.New("token name must be at most " + strconv.Itoa(maxTokenNameLength) + " characters long")
+	}
+	if len(form.Scopes) == 0 {
+		return errors.New("select at least one scope")
+	}
+	for _, scope := range form.Scopes {
+		if !slices.Contains(domain.TokenScopes, scope) {
+			return errors.New("unknown scope " + strconv.Quote(scope))
+		}
+	}
+	return nil
+}
+
+// orderedScopes drops duplicates and lists scopes in the order of
+// domain.TokenScopes.
+func orderedScopes(scopes []string) []string {
+	ordered := make([]string, 0, len(domain.TokenScopes))
+	for _, scope := range domain.TokenScopes {
+		if slices.Contains(scopes, scope) {
+			ordered = append(ordered, scope)
+		}
+	}
+	return ordered
+}
@@ -34,12 +34,12 @@ func NewTopic(errHandler errorHandler.Handler, logger logger.Logger, repositorie
 
 func (u *TopicUseCase) None() {}
 
-func (u *TopicUseCase) ListAllTopics(ctx context.Context) (domain.Topics, error) {
+func (u *TopicUseCase) ListAllTopics(ctx context.Context, page domain.Page) (domain.Topics, error) {
 	repo := domain.Bridge[domain.TopicRepository](configs.TOPICS_DB_NAME, u.repositories)
-	return repo.ListAllTopics(ctx)
+	return repo.ListAllTopics(ctx, page)
 }
 
-func (u *TopicUseCase) SearchTopicByName(ctx context.Context, name string) (domain.Topics, error) {
+func (u *TopicUseCase) SearchTopicByName(ctx context.Context, name string, page domain.Page) (domain.Topics, error) {
 	repo := domain.Bridge[domain.TopicRepository](configs.TOPICS_DB_NAME, u.repositories)
-	return repo.SearchTopicByName(ctx, name)
+	return repo.SearchTopicByName(ctx, name, page)
 }
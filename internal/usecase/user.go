@@ -2,30 +2,72 @@ package usecase
 
 import (
 	"context"
+	"encoding/base64"
+	"errors"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/elyarsadig/studybud-go/configs"
 	"github.com/elyarsadig/studybud-go/internal/domain"
 	"github.com/elyarsadig/studybud-go/pkg/bcrypt"
+	"github.com/elyarsadig/studybud-go/pkg/encryption"
 	"github.com/elyarsadig/studybud-go/pkg/errorHandler"
 	"github.com/elyarsadig/studybud-go/pkg/logger"
+	"github.com/elyarsadig/studybud-go/pkg/mailer"
+	"github.com/elyarsadig/studybud-go/pkg/oidc"
 	redispkg "github.com/elyarsadig/studybud-go/pkg/redis"
+	"github.com/elyarsadig/studybud-go/pkg/utils"
+)
+
+const (
+	// passwordResetExpiration is how long a password reset link stays valid.
+	passwordResetExpiration = 30 * time.Minute
+	// emailVerificationExpiration is how long an email verification link
+	// stays valid.
+	emailVerificationExpiration = 24 * time.Hour
+	// sessionKeyAttempts is how many random keys are tried before giving up
+	// on creating a session. With 256 bit keys a second attempt is already
+	// astronomically unlikely.
+	sessionKeyAttempts = 3
+	// emailVerificationCooldown is the minimum time between two verification
+	// emails sent to the same account.
+	emailVerificationCooldown = time.Minute
+	// unverifiedAccountTTL is how long an account may stay unverified before
+	// it is removed.
+	unverifiedAccountTTL = 7 * 24 * time.Hour
 )
 
 type UserUseCase struct {
 	repositories          map[string]domain.Bridger
 	errHandler            errorHandler.Handler
 	redis                 *redispkg.Redis
+	mailer                mailer.Mailer
+	emailTokens           *encryption.AES[domain.EmailVerificationClaims]
+	publicURL             string
+	maxLoginAttempts      int
+	requireStaff2FA       bool
+	oidcProviders         []*oidc.Provider
 	logger                logger.Logger
 	sessionExpireDuration time.Duration
 }
 
-func NewUser(errHandler errorHandler.Handler, sessionExpireDuration time.Duration, redis *redispkg.Redis, logger logger.Logger, repositories ...domain.Bridger) domain.UserUseCase {
+func NewUser(errHandler errorHandler.Handler, sessionExpireDuration time.Duration, redis *redispkg.Redis, mailer mailer.Mailer, emailTokens *encryption.AES[domain.EmailVerificationClaims], publicURL string, maxLoginAttempts int, requireStaff2FA bool, oidcProviders []*oidc.Provider, logger logger.Logger, repositories ...domain.Bridger) domain.UserUseCase {
+	if maxLoginAttempts <= 0 {
+		maxLoginAttempts = defaultMaxLoginAttempts
+	}
 	user := &UserUseCase{
 		repositories:          make(map[string]domain.Bridger),
 		errHandler:            errHandler,
 		redis:                 redis,
+		mailer:                mailer,
+		emailTokens:           emailTokens,
+		publicURL:             strings.TrimSuffix(publicURL, "/"),
+		maxLoginAttempts:      maxLoginAttempts,
+		requireStaff2FA:       requireStaff2FA,
+		oidcProviders:         oidcProviders,
 		logger:                logger,
 		sessionExpireDuration: sessionExpireDuration,
 	}
@@ -34,6 +76,8 @@ func NewUser(errHandler errorHandler.Handler, sessionExpireDuration time.Duratio
 		switch repository.(type) {
 		case domain.UserRepository:
 			user.repositories[configs.USERS_DB_NAME] = repository
+		case domain.PermissionRepository:
+			user.repositories[configs.AUTH_PERMISSIONS_DB_NAME] = repository
 		}
 	}
 
@@ -64,39 +108,74 @@ func (u *UserUseCase) RegisterUser(ctx context.Context, form *domain.UserRegiste
 		Avatar:   configs.DefaultAvatar,
 		Password: hashedPassword,
 		IsActive: true,
+
+		PendingEmailVerification: true,
 	}
 	_, err = repo.Create(ctx, &user)
 	if err != nil {
 		return "", err
 	}
-	sessionValue := domain.SessionValue{
-		ID:       int(user.ID),
-		Username: user.Username,
-		Name:     user.Name,
-		Email:    user.Email,
-		Avatar:   user.Avatar,
+	permRepo := domain.Bridge[domain.PermissionRepository](configs.AUTH_PERMISSIONS_DB_NAME, u.repositories)
+	err = permRepo.AddUserToGroup(ctx, user.ID, domain.MembersGroup)
+	if err != nil {
+		return "", err
 	}
-	return u.setSession(ctx, sessionValue)
+	// The account exists at this point, a mail failure should not fail the
+	// registration. The user can ask for another link later.
+	err = u.sendVerificationEmail(ctx, user)
+	if err != nil {
+		u.logger.Error(err.Error())
+	}
+	return u.setSession(ctx, newSessionValue(user))
 }
 
-func (u *UserUseCase) Login(ctx context.Context, form *domain.UserLoginForm) (string, error) {
+// Login checks the credentials and starts a session, or a two-factor
+// challenge when the account has an authenticator. Failed attempts are
+// counted per account and per IP, too many of them lock the login out for a
+// while.
+func (u *UserUseCase) Login(ctx context.Context, form *domain.UserLoginForm) (domain.LoginResult, error) {
+	accountKey := accountLoginKey(form.Email)
+	keys := []string{accountKey}
+	if form.IP != "" {
+		keys = append(keys, ipLoginKey(form.IP))
+	}
+	err := u.checkLoginLockout(ctx, keys...)
+	if err != nil {
+		return domain.LoginResult{}, err
+	}
 	repo := domain.Bridge[domain.UserRepository](configs.USERS_DB_NAME, u.repositories)
 	user, err := repo.GetUserByEmail(ctx, form.Email)
+	if err == nil && !bcrypt.CheckPasswordHash(form.Password, user.Password) {
+		err = errors.New("wrong password")
+	}
 	if err != nil {
-		return "", u.errHandler.New(http.StatusBadRequest, "invalid credentials try again!")
+		// Unknown emails count as failures as well, otherwise the lockout
+		// would tell which accounts exist.
+		lockoutErr := u.recordFailedLogin(ctx, accountKey, form.IP)
+		if lockoutErr != nil {
+			return domain.LoginResult{}, lockoutErr
+		}
+		return domain.LoginResult{}, u.errHandler.New(http.StatusBadRequest, "invalid credentials try again!")
 	}
-	ok := bcrypt.CheckPasswordHash(form.Password, user.Password)
-	if !ok {
-		return "", u.errHandler.New(http.StatusBadRequest, "invalid credentials try again!")
+	if !user.IsActive {
+		return domain.LoginResult{}, u.errHandler.New(http.StatusForbidden, "this account has been deactivated")
+	}
+	// With two factors the failures are only forgotten once the code is
+	// accepted, otherwise every correct password would buy another round of
+	// code guesses.
+	if user.TOTPEnabled {
+		token, err := u.startTwoFactorChallenge(ctx, user)
+		if err != nil {
+			return domain.LoginResult{}, err
+		}
+		return domain.LoginResult{TwoFactorToken: token}, nil
 	}
-	sessionValue := domain.SessionValue{
-		ID:       int(user.ID),
-		Username: user.Username,
-		Name:     user.Name,
-		Email:    user.Email,
-		Avatar:   user.Avatar,
+	u.clearLoginFailures(ctx, accountKey)
+	sessionKey, err := u.setSession(ctx, newSessionValue(user))
+	if err != nil {
+		return domain.LoginResult{}, err
 	}
-	return u.setSession(ctx, sessionValue)
+	return domain.LoginResult{SessionKey: sessionKey, SetupTwoFactor: u.twoFactorRequired(user)}, nil
 }
 
 func (u *UserUseCase) GetUserByEmail(ctx context.Context, email string) (domain.User, error) {
@@ -114,12 +193,7 @@ func (u *UserUseCase) UpdateInfo(ctx context.Context, obj *domain.UpdateUser) (s
 		Bio:      obj.Bio,
 		Name:     obj.Name,
 	}
-	err := u.redis.Remove(ctx, "session", oldSession.SessionKey)
-	if err != nil {
-		u.logger.Error(err.Error())
-		return "", u.errHandler.New(http.StatusInternalServerError, "something went wrong!")
-	}
-	err = repo.Update(ctx, user)
+	err := repo.Update(ctx, user)
 	if err != nil {
 		return "", err
 	}
@@ -127,17 +201,158 @@ func (u *UserUseCase) UpdateInfo(ctx context.Context, obj *domain.UpdateUser) (s
 	if err != nil {
 		return "", err
 	}
-	newSessionValue := domain.SessionValue{
-		ID:       int(updateUser.ID),
-		Username: updateUser.Username,
-		Name:     updateUser.Name,
-		Email:    updateUser.Email,
-		Avatar:   updateUser.Avatar,
-	}
-	return u.setSession(ctx, newSessionValue)
+	return u.rotateSession(ctx, oldSession, updateUser)
 }
 
 func (u *UserUseCase) GetUserById(ctx context.Context, id string) (domain.User, error) {
 	repo := domain.Bridge[domain.UserRepository](configs.USERS_DB_NAME, u.repositories)
 	return repo.GetUserById(ctx, id)
 }
+
+// RequestPasswordReset mails a single-use reset link to the account. It
+// succeeds silently for unknown or deactivated emails so the form cannot be
+// used to find out who has an account.
+func (u *UserUseCase) RequestPasswordReset(ctx context.Context, email string) error {
+	repo := domain.Bridge[domain.UserRepository](configs.USERS_DB_NAME, u.repositories)
+	user, err := repo.GetUserByEmail(ctx, email)
+	var errWithDetails *errorHandler.Error
+	if errors.As(err, &errWithDetails) && errWithDetails.HTTPStatus() == http.StatusNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !user.IsActive {
+		return nil
+	}
+	token, err := generateToken()
+	if err != nil {
+		u.logger.Error(err.Error())
+		return u.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+	}
+	err = u.redis.Set(ctx, "password_reset", passwordResetExpiration, hashToken(token), strconv.Itoa(int(user.ID)))
+	if err != nil {
+		u.logger.Error(err.Error())
+		return u.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+	}
+	link := u.publicURL + "/reset-password?token=" + url.QueryEscape(token)
+	err = u.mailer.Send(ctx, mailer.Message{
+		To:      []string{user.Email},
+		Subject: "Reset your StudyBud password",
+		Body: "Hi " + user.Username + ",\n\n" +
+			"Someone asked to reset the password of your StudyBud account. Open the link below to choose a new one:\n\n" +
+			link + "\n\n" +
+			"The link expires in " + utils.FormatDuration(passwordResetExpiration) + " and can only be used once. " +
+			"If you did not ask for this, you can ignore this email.\n",
+	})
+	if err != nil {
+		u.logger.Error(err.Error())
+		return u.errHandler.New(http.StatusInternalServerError, "could not send the reset email, try again later")
+	}
+	return nil
+}
+
+func (u *UserUseCase) ResetPassword(ctx context.Context, form *domain.PasswordResetForm) error {
+	if form.Password1 != form.Password2 {
+		return u.errHandler.New(http.StatusBadRequest, "passwords do not match!")
+	}
+	err := utils.ValidatePassword(form.Password1)
+	if err != nil {
+		return u.errHandler.New(http.StatusBadRequest, err.Error())
+	}
+	ok, userID := u.redis.Pop(ctx, "password_reset", hashToken(form.Token))
+	if !ok {
+		return u.errHandler.New(http.StatusBadRequest, "this reset link is invalid or has expired")
+	}
+	hashedPassword, err := bcrypt.HashPassword(form.Password1)
+	if err != nil {
+		u.logger.Error(err.Error())
+		return u.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+	}
+	repo := domain.Bridge[domain.UserRepository](configs.USERS_DB_NAME, u.repositories)
+	err = repo.UpdatePassword(ctx, string(userID), hashedPassword)
+	if err != nil {
+		return err
+	}
+	// Whoever knew the old password may still be logged in somewhere.
+	err = u.RevokeAllSessions(ctx, string(userID))
+	if err != nil {
+		return err
+	}
+	// The reset link reached the inbox, which proves the address as well.
+	return repo.MarkEmailVerified(ctx, string(userID))
+}
+
+// SendEmailVerification mails a new verification link to an unverified
+// account. Requests are limited to one per emailVerificationCooldown.
+func (u *UserUseCase) SendEmailVerification(ctx context.Context, userID string) error {
+	repo := domain.Bridge[domain.UserRepository](configs.USERS_DB_NAME, u.repositories)
+	user, err := repo.GetUserById(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !user.PendingEmailVerification {
+		return u.errHandler.New(http.StatusConflict, "your email address is already verified")
+	}
+	ok, _ := u.redis.Inspect(ctx, "email_verification_cooldown", userID)
+	if ok {
+		return u.errHandler.New(http.StatusTooManyRequests, "a verification email was sent recently, check your inbox or try again in a minute")
+	}
+	err = u.sendVerificationEmail(ctx, user)
+	if err != nil {
+		u.logger.Error(err.Error())
+		return u.errHandler.New(http.StatusInternalServerError, "could not send the verification email, try again later")
+	}
+	return nil
+}
+
+// VerifyEmail confirms the address encoded in token. When the request comes
+// from the same user, their session is rotated and the new key is returned so
+// the restrictions are lifted without logging in again. The key is empty when
+// no session was rotated.
+func (u *UserUseCase) VerifyEmail(ctx context.Context, token string) (domain.User, string, error) {
+	invalid := u.errHandler.New(http.StatusBadRequest, "this verification link is invalid or has expired")
+	ciphertext, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return domain.User{}, "", invalid
+	}
+	claims, err := u.emailTokens.Decrypt(ciphertext)
+	if err != nil || time.Now().Unix() > claims.ExpiresAt {
+		return domain.User{}, "", invalid
+	}
+	repo := domain.Bridge[domain.UserRepository](configs.USERS_DB_NAME, u.repositories)
+	user, err := repo.GetUserById(ctx, strconv.Itoa(int(claims.UserID)))
+	var errWithDetails *errorHandler.Error
+	if errors.As(err, &errWithDetails) && errWithDetails.HTTPStatus() == http.StatusNotFound {
+		return domain.User{}, "", invalid
+	}
+	if err != nil {
+		return domain.User{}, "", err
+	}
+	if user.Email != claims.Email {
+		return domain.User{}, "", invalid
+	}
+	if user.PendingEmailVerification {
+		err = repo.MarkEmailVerified(ctx, strconv.Itoa(int(user.ID)))
+		if err != nil {
+			return domain.User{}, "", err
+		}
+		user.PendingEmailVerification = false
+	}
+	sessionValue, ok := ctx.Value(configs.UserCtxKey).(domain.SessionValue)
+	if ok && sessionValue.ID == int(user.ID) && sessionValue.PendingVerification {
+		sessionKey, err := u.rotateSession(ctx, sessionValue, user)
+		if err != nil {
+			return domain.User{}, "", err
+		}
+		return user, sessionKey, nil
+	}
+	return user, "", nil
+}
+
+// ExpireUnverifiedUsers deletes accounts that were never verified within
+// unverifiedAccountTTL and returns how many were removed.
+func (u *UserUseCase) ExpireUnverifiedUsers(ctx context.Context) (int64, error) {
+	repo := domain.Bridge[domain.UserRepository](configs.USERS_DB_NAME, u.repositories)
+	return repo.DeleteUnverifiedBefore(ctx, time.Now().Add(-unverifiedAccountTTL))
+}
@@ -0,0 +1,107 @@
+package usecase
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/elyarsadig/studybud-go/pkg/utils"
+)
+
+const (
+	// defaultMaxLoginAttempts is used when max_attempt_login_time is not set.
+	defaultMaxLoginAttempts = 5
+	// ipLoginAttemptsFactor multiplies the account limit for a single IP, so
+	// a shared address (an office, a campus NAT) is not locked out by one
+	// user mistyping their password.
+	ipLoginAttemptsFactor = 10
+	// loginFailureWindow is how long failed attempts are remembered.
+	loginFailureWindow = 15 * time.Minute
+	// baseLoginLockout is the first lockout, each following lockout within
+	// loginLockoutMemory doubles up to maxLoginLockout.
+	baseLoginLockout   = time.Minute
+	maxLoginLockout    = time.Hour
+	loginLockoutMemory = 24 * time.Hour
+)
+
+func accountLoginKey(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func ipLoginKey(ip string) string {
+	return "ip:" + ip
+}
+
+// checkLoginLockout returns a 429 error when any of keys is locked out.
+func (u *UserUseCase) checkLoginLockout(ctx context.Context, keys ...string) error {
+	for _, key := range keys {
+		ttl := u.redis.TTL(ctx, "login_lockout", key)
+		if ttl > 0 {
+			return u.lockoutError(ttl)
+		}
+	}
+	return nil
+}
+
+// recordLoginFailure counts a failed attempt for key. Once limit failures
+// are reached the key is locked out and the lockout error is returned, the
+// lockout doubles every time it happens again within loginLockoutMemory.
+func (u *UserUseCase) recordLoginFailure(ctx context.Context, key string, limit int) error {
+	failures, err := u.redis.Incr(ctx, "login_failures", key, loginFailureWindow)
+	if err != nil {
+		u.logger.Error(err.Error())
+		return nil
+	}
+	if failures < int64(limit) {
+		return nil
+	}
+	level, err := u.redis.Incr(ctx, "login_lockouts", key, loginLockoutMemory)
+	if err != nil {
+		u.logger.Error(err.Error())
+		return nil
+	}
+	lockout := baseLoginLockout
+	for i := int64(1); i < level && lockout < maxLoginLockout; i++ {
+		lockout *= 2
+	}
+	lockout = min(lockout, maxLoginLockout)
+	err = u.redis.Set(ctx, "login_lockout", lockout, key, level)
+	if err != nil {
+		u.logger.Error(err.Error())
+		return nil
+	}
+	_ = u.redis.Remove(ctx, "login_failures", key)
+	u.logger.WarnContext(ctx, "login locked out after repeated failures",
+		"key", key, "failures", failures, "lockouts", level, "duration", lockout.String())
+	return u.lockoutError(lockout)
+}
+
+// recordFailedLogin counts a wrong password or second factor against the
+// account and the IP it came from, ip may be empty. It returns the lockout
+// error once either of them is locked out.
+func (u *UserUseCase) recordFailedLogin(ctx context.Context, accountKey, ip string) error {
+	lockoutErr := u.recordLoginFailure(ctx, accountKey, u.maxLoginAttempts)
+	if ip != "" {
+		ipErr := u.recordLoginFailure(ctx, ipLoginKey(ip), u.maxLoginAttempts*ipLoginAttemptsFactor)
+		if lockoutErr == nil {
+			lockoutErr = ipErr
+		}
+	}
+	return lockoutErr
+}
+
+// clearLoginFailures forgets the failed attempts of an account after a
+// successful login. The IP counters are kept, a credential stuffing run
+// still hits the IP limit when some of the passwords it tries are valid.
+func (u *UserUseCase) clearLoginFailures(ctx context.Context, key string) {
+	_ = u.redis.Remove(ctx, "login_failures", key)
+	_ = u.redis.Remove(ctx, "login_lockouts", key)
+}
+
+func (u *UserUseCase) lockoutError(remaining time.Duration) error {
+	// Round up so the last minute reads "1 minute" rather than "30 seconds"
+	// followed by another lockout message.
+	remaining = (remaining + time.Minute - 1).Truncate(time.Minute)
+	return u.errHandler.New(http.StatusTooManyRequests, "too many failed login attempts, try again in "+utils.FormatDuration(remaining))
+}
@@ -0,0 +1,255 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/elyarsadig/studybud-go/configs"
+	"github.com/elyarsadig/studybud-go/internal/domain"
+	"github.com/elyarsadig/studybud-go/pkg/bcrypt"
+	"github.com/elyarsadig/studybud-go/pkg/errorHandler"
+	"github.com/elyarsadig/studybud-go/pkg/oidc"
+)
+
+// oidcLoginExpiration is how long the user may stay at the provider before
+// the login has to start over.
+const oidcLoginExpiration = 10 * time.Minute
+
+// oidcLogin is kept in Redis under the hash of the state while the user is at
+// the provider.
+type oidcLogin struct {
+	Provider string `json:"provider"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+}
+
+func (u *UserUseCase) OIDCProviders() []domain.OIDCProvider {
+	providers := make([]domain.OIDCProvider, 0, len(u.oidcProviders))
+	for _, provider := range u.oidcProviders {
+		providers = append(providers, domain.OIDCProvider{
+			Name:        provider.Name(),
+			DisplayName: provider.DisplayName(),
+		})
+	}
+	return providers
+}
+
+// BeginOIDCLogin returns the URL of the provider to send the user to and the
+// state the callback has to come back with.
+func (u *UserUseCase) BeginOIDCLogin(ctx context.Context, providerName string) (string, string, error) {
+	provider, err := u.oidcProvider(providerName)
+	if err != nil {
+		return "", "", err
+	}
+	state, err := generateToken()
+	if err != nil {
+		u.logger.Error(err.Error())
+		return "", "", u.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+	}
+	nonce, err := generateToken()
+	if err != nil {
+		u.logger.Error(err.Error())
+		return "", "", u.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+	}
+	login := oidcLogin{Provider: provider.Name(), Nonce: nonce, Verifier: oidc.NewVerifier()}
+	authURL, err := provider.AuthCodeURL(ctx, state, login.Nonce, login.Verifier)
+	if err != nil {
+		u.logger.Error(err.Error())
+		return "", "", u.errHandler.New(http.StatusBadGateway, "could not reach "+provider.DisplayName()+", try again later")
+	}
+	v, err := json.Marshal(login)
+	if err != nil {
+		u.logger.Error(err.Error())
+		return "", "", u.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+	}
+	err = u.redis.Set(ctx, "oidc_login", oidcLoginExpiration, hashToken(state), v)
+	if err != nil {
+		u.logger.Error(err.Error())
+		return "", "", u.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+	}
+	return authURL, state, nil
+}
+
+// FinishOIDCLogin exchanges the code from the callback and logs in the user
+// linked to the identity. A first login links the account with the same
+// verified email, or creates one.
+func (u *UserUseCase) FinishOIDCLogin(ctx context.Context, providerName string, state string, code string) (domain.LoginResult, error) {
+	expired := u.errHandler.New(http.StatusBadRequest, "your login has expired, try again")
+	provider, err := u.oidcProvider(providerName)
+	if err != nil {
+		return domain.LoginResult{}, err
+	}
+	// Popping makes the state single use.
+	ok, data := u.redis.Pop(ctx, "oidc_login", hashToken(state))
+	if !ok {
+		return domain.LoginResult{}, expired
+	}
+	var login oidcLogin
+	err = json.Unmarshal(data, &login)
+	if err != nil || login.Provider != provider.Name() {
+		return domain.LoginResult{}, expired
+	}
+	claims, err := provider.Exchange(ctx, code, login.Verifier, login.Nonce)
+	if err != nil {
+		u.logger.WarnContext(ctx, "single sign-on failed", "provider", provider.Name(), "error", err.Error())
+		return domain.LoginResult{}, u.errHandler.New(http.StatusBadRequest, provider.DisplayName()+" did not confirm your login, try again")
+	}
+	user, err := u.oidcUser(ctx, provider, claims)
+	if err != nil {
+		return domain.LoginResult{}, err
+	}
+	if !user.IsActive {
+		return domain.LoginResult{}, u.errHandler.New(http.StatusForbidden, "this account has been deactivated")
+	}
+	if user.TOTPEnabled {
+		token, err := u.startTwoFactorChallenge(ctx, user)
+		if err != nil {
+			return domain.LoginResult{}, err
+		}
+		return domain.LoginResult{TwoFactorToken: token}, nil
+	}
+	sessionKey, err := u.setSession(ctx, newSessionValue(user))
+	if err != nil {
+		return domain.LoginResult{}, err
+	}
+	return domain.LoginResult{SessionKey: sessionKey}, nil
+}
+
+// oidcUser returns the user linked to the identity in claims, linking or
+// provisioning one on the first login.
+func (u *UserUseCase) oidcUser(ctx context.Context, provider *oidc.Provider, claims oidc.Claims) (domain.User, error) {
+	repo := domain.Bridge[domain.UserRepository](configs.USERS_DB_NAME, u.repositories)
+	user, err := repo.GetUserByIdentity(ctx, provider.Name(), claims.Subject)
+	if !isNotFound(err) {
+		return user, err
+	}
+	// Without a verified email anyone could claim an account by setting its
+	// address at a provider that does not check it.
+	if len(claims.Email) == 0 || !claims.EmailVerified {
+		return domain.User{}, u.errHandler.New(http.StatusForbidden, provider.DisplayName()+" did not confirm your email address")
+	}
+	identity := domain.UserIdentity{
+		Provider: provider.Name(),
+		Subject:  claims.Subject,
+		Email:    claims.Email,
+	}
+	user, err = repo.GetUserByEmail(ctx, claims.Email)
+	if isNotFound(err) {
+		return u.provisionOIDCUser(ctx, claims, &identity)
+	}
+	if err != nil {
+		return domain.User{}, err
+	}
+	identity.UserID = user.ID
+	err = repo.LinkIdentity(ctx, &identity)
+	if err != nil {
+		return domain.User{}, err
+	}
+	if user.PendingEmailVerification {
+		user, err = u.claimUnverifiedUser(ctx, user)
+		if err != nil {
+			return domain.User{}, err
+		}
+	}
+	u.logger.InfoContext(ctx, "linked single sign-on identity", "user", user.ID, "provider", provider.Name())
+	return user, nil
+}
+
+// claimUnverifiedUser hands an account whose email was never verified to the
+// owner of the address as confirmed by the provider. Whoever registered it
+// might not own the address, so their password and sessions stop working.
+func (u *UserUseCase) claimUnverifiedUser(ctx context.Context, user domain.User) (domain.User, error) {
+	repo := domain.Bridge[domain.UserRepository](configs.USERS_DB_NAME, u.repositories)
+	userID := strconv.Itoa(int(user.ID))
+	password, err := unusablePassword()
+	if err != nil {
+		u.logger.Error(err.Error())
+		return domain.User{}, u.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+	}
+	err = repo.UpdatePassword(ctx, userID, password)
+	if err != nil {
+		return domain.User{}, err
+	}
+	err = repo.MarkEmailVerified(ctx, userID)
+	if err != nil {
+		return domain.User{}, err
+	}
+	err = u.RevokeAllSessions(ctx, userID)
+	if err != nil {
+		return domain.User{}, err
+	}
+	user.PendingEmailVerification = false
+	return user, nil
+}
+
+func (u *UserUseCase) provisionOIDCUser(ctx context.Context, claims oidc.Claims, identity *domain.UserIdentity) (domain.User, error) {
+	password, err := unusablePassword()
+	if err != nil {
+		u.logger.Error(err.Error())
+		return domain.User{}, u.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+	}
+	repo := domain.Bridge[domain.UserRepository](configs.USERS_DB_NAME, u.repositories)
+	username, err := claims.Username(func(username string) (bool, error) {
+		return repo.UsernameExists(ctx, username)
+	})
+	if errors.Is(err, oidc.ErrNoFreeUsername) {
+		u.logger.WarnContext(ctx, "no free username for single sign-on user", "provider", identity.Provider, "email", claims.Email)
+		return domain.User{}, u.errHandler.New(http.StatusConflict, "no username is left for your account, ask an administrator for help")
+	}
+	if err != nil {
+		return domain.User{}, err
+	}
+	name := strings.TrimSpace(claims.Name)
+	if len(name) == 0 {
+		name = username
+	}
+	user := domain.User{
+		Name:     name,
+		Username: username,
+		Email:    claims.Email,
+		Avatar:   configs.DefaultAvatar,
+		Password: password,
+		IsActive: true,
+	}
+	err = repo.CreateWithIdentity(ctx, &user, identity)
+	if err != nil {
+		return domain.User{}, err
+	}
+	permRepo := domain.Bridge[domain.PermissionRepository](configs.AUTH_PERMISSIONS_DB_NAME, u.repositories)
+	err = permRepo.AddUserToGroup(ctx, user.ID, domain.MembersGroup)
+	if err != nil {
+		return domain.User{}, err
+	}
+	u.logger.InfoContext(ctx, "provisioned user from single sign-on", "user", user.ID, "provider", identity.Provider)
+	return user, nil
+}
+
+func (u *UserUseCase) oidcProvider(name string) (*oidc.Provider, error) {
+	for _, provider := range u.oidcProviders {
+		if provider.Name() == name {
+			return provider, nil
+		}
+	}
+	return nil, u.errHandler.New(http.StatusNotFound, "unknown login provider")
+}
+
+// unusablePassword returns the hash of a random password nobody knows. Users
+// without a password log in through their provider, or set one with the
+// forgotten password link.
+func unusablePassword() (string, error) {
+	random, err := generateToken()
+	if err != nil {
+		return "", err
+	}
+	return bcrypt.HashPassword(random)
+}
+
+func isNotFound(err error) bool {
+	var errWithDetails *errorHandler.Error
+	return errors.As(err, &errWithDetails) && errWithDetails.HTTPStatus() == http.StatusNotFound
+}
@@ -2,15 +2,19 @@ package usecase
 
 import (
 	"context"
+	cryptorand "crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"net/http"
 	"strconv"
-	"strings"
-
-	"math/rand"
+	"time"
 
+	"github.com/elyarsadig/studybud-go/configs"
 	"github.com/elyarsadig/studybud-go/internal/domain"
+	"github.com/elyarsadig/studybud-go/pkg/mailer"
 	"github.com/elyarsadig/studybud-go/pkg/utils"
 )
 
@@ -37,29 +41,136 @@ func (u *UserUseCase) validateUserRegisterForm(form *domain.UserRegisterForm) er
 	return nil
 }
 
-func (u *UserUseCase) generateDigitString(length int) string {
-	result := strings.Builder{}
-	result.Grow(length)
-	for i := 0; i < length; i++ {
-		num := rand.Intn(10)
-		result.WriteString(strconv.Itoa(num))
-	}
-	return result.String()
+// generateToken returns a random URL safe token for links sent by email.
+func generateToken() (string, error) {
+	b := make([]byte, 32)
+	_, err := cryptorand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
 }
 
-func (u *UserUseCase) setSession(ctx context.Context, sessionValue domain.SessionValue) (string, error) {
-	key := u.generateDigitString(6)
-	sessionValue.SessionKey = key
-	v, err := json.Marshal(sessionValue)
+// hashToken is used as the Redis key of an emailed token, so a leaked Redis
+// snapshot does not contain usable links.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func newSessionValue(user domain.User) domain.SessionValue {
+	return domain.SessionValue{
+		ID:                  int(user.ID),
+		Username:            user.Username,
+		Name:                user.Name,
+		Email:               user.Email,
+		Avatar:              user.Avatar,
+		PendingVerification: user.PendingEmailVerification,
+	}
+}
+
+// sendVerificationEmail mails a link that confirms the current address of
+// user and starts the resend cooldown.
+func (u *UserUseCase) sendVerificationEmail(ctx context.Context, user domain.User) error {
+	ciphertext, err := u.emailTokens.Encrypt(domain.EmailVerificationClaims{
+		UserID:    user.ID,
+		Email:     user.Email,
+		ExpiresAt: time.Now().Add(emailVerificationExpiration).Unix(),
+	})
 	if err != nil {
-		u.logger.Error(err.Error())
+		return err
+	}
+	link := u.publicURL + "/verify-email/confirm?token=" + base64.RawURLEncoding.EncodeToString(ciphertext)
+	err = u.mailer.Send(ctx, mailer.Message{
+		To:      []string{user.Email},
+		Subject: "Verify your StudyBud email address",
+		Body: "Hi " + user.Username + ",\n\n" +
+			"Welcome to StudyBud! Open the link below to confirm your email address:\n\n" +
+			link + "\n\n" +
+			"The link expires in " + utils.FormatDuration(emailVerificationExpiration) + ". " +
+			"Until then you can browse rooms but not create rooms or post messages. " +
+			"Unverified accounts are removed after " + utils.FormatDuration(unverifiedAccountTTL) + ".\n",
+	})
+	if err != nil {
+		return err
+	}
+	return u.redis.Set(ctx, "email_verification_cooldown", emailVerificationCooldown, strconv.Itoa(int(user.ID)), 1)
+}
+
+// generateSessionKey returns a random session key with 256 bits of entropy.
+func generateSessionKey() (string, error) {
+	b := make([]byte, 32)
+	_, err := cryptorand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// rotateSession replaces the session in the request context with a new key
+// carrying the current state of user. It is used whenever the privileges of
+// a session change, so a key that leaked before the change stops working.
+func (u *UserUseCase) rotateSession(ctx context.Context, oldSession domain.SessionValue, user domain.User) (string, error) {
+	// Requests made with a personal access token have no session to
+	// rotate, and must not be able to turn the token into one.
+	if oldSession.TokenID != 0 {
+		return "", nil
+	}
+	err := u.removeSession(ctx, oldSession.ID, oldSession.SessionKey)
+	if err != nil {
+		return "", err
+	}
+	sessionValue := newSessionValue(user)
+	sessionValue.UserAgent = oldSession.UserAgent
+	sessionValue.IP = oldSession.IP
+	sessionValue.Created = oldSession.Created
+	return u.setSession(ctx, sessionValue)
+}
+
+// setSession stores sessionValue under a fresh key and returns the key. The
+// key is only written when it is not taken, so a collision can never hand one
+// user the session of another.
+func (u *UserUseCase) setSession(ctx context.Context, sessionValue domain.SessionValue) (string, error) {
+	now := time.Now()
+	if sessionValue.Created.IsZero() {
+		sessionValue.Created = now
+	}
+	sessionValue.LastSeen = now
+	if client, ok := ctx.Value(configs.ClientCtxKey).(domain.ClientInfo); ok && sessionValue.UserAgent == "" {
+		sessionValue.IP = client.IP
+		sessionValue.UserAgent = client.UserAgent
+	}
+	var key string
+	for attempt := 0; key == "" && attempt < sessionKeyAttempts; attempt++ {
+		candidate, err := generateSessionKey()
+		if err != nil {
+			u.logger.Error(err.Error())
+			return "", u.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+		}
+		sessionValue.SessionKey = candidate
+		v, err := json.Marshal(sessionValue)
+		if err != nil {
+			u.logger.Error(err.Error())
+			return "", u.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+		}
+		ok, err := u.redis.SetNX(ctx, "session", u.sessionExpireDuration, candidate, v)
+		if err != nil {
+			u.logger.Error(err.Error())
+			return "", u.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+		}
+		if ok {
+			key = candidate
+		}
+	}
+	if key == "" {
+		u.logger.Error("could not find a free session key", "user", sessionValue.ID)
 		return "", u.errHandler.New(http.StatusInternalServerError, "something went wrong!")
 	}
-	err = u.redis.Set(ctx, "session", u.sessionExpireDuration, key, v)
+	err := u.redis.AddToSet(ctx, "user_sessions", strconv.Itoa(sessionValue.ID), key, u.sessionExpireDuration)
 	if err != nil {
 		u.logger.Error(err.Error())
 		return "", u.errHandler.New(http.StatusInternalServerError, "something went wrong!")
 	}
-	u.logger.Info("session set in redis", "key", key, "user", sessionValue)
+	u.logger.Info("session set in redis", "user", sessionValue.ID)
 	return key, nil
 }
@@ -0,0 +1,170 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/elyarsadig/studybud-go/configs"
+	"github.com/elyarsadig/studybud-go/internal/domain"
+	"github.com/elyarsadig/studybud-go/pkg/errorHandler"
+	"github.com/elyarsadig/studybud-go/pkg/logger"
+	redispkg "github.com/elyarsadig/studybud-go/pkg/redis"
+	"github.com/elyarsadig/studybud-go/pkg/utils"
+)
+
+// Every session of a user is indexed in the "user_sessions:<user id>" set, so
+// they can be listed and revoked together. Members whose session has expired
+// are cleaned up lazily when the set is read.
+
+// Logout ends the session in the request context. A personal access token
+// has no session, it stays valid until it is revoked.
+func (u *UserUseCase) Logout(ctx context.Context) error {
+	sessionValue := ctx.Value(configs.UserCtxKey).(domain.SessionValue)
+	if sessionValue.TokenID != 0 {
+		return nil
+	}
+	return u.removeSession(ctx, sessionValue.ID, sessionValue.SessionKey)
+}
+
+// ListSessions returns the live sessions of the current user, most recently
+// used first.
+func (u *UserUseCase) ListSessions(ctx context.Context) ([]domain.Session, error) {
+	current := ctx.Value(configs.UserCtxKey).(domain.SessionValue)
+	sessionValues, err := u.userSessions(ctx, strconv.Itoa(current.ID))
+	if err != nil {
+		return nil, err
+	}
+	sessions := make([]domain.Session, 0, len(sessionValues))
+	for _, sessionValue := range sessionValues {
+		sessions = append(sessions, domain.Session{
+			ID:        sessionID(sessionValue.SessionKey),
+			UserAgent: sessionValue.UserAgent,
+			IP:        sessionValue.IP,
+			Created:   sessionValue.Created,
+			LastSeen:  sessionValue.LastSeen,
+			Since:     utils.FormatDuration(time.Since(sessionValue.LastSeen)),
+			Current:   sessionValue.SessionKey == current.SessionKey,
+		})
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastSeen.After(sessions[j].LastSeen)
+	})
+	return sessions, nil
+}
+
+// RevokeSession ends one session of the current user by its public ID.
+func (u *UserUseCase) RevokeSession(ctx context.Context, id string) error {
+	current := ctx.Value(configs.UserCtxKey).(domain.SessionValue)
+	sessionValues, err := u.userSessions(ctx, strconv.Itoa(current.ID))
+	if err != nil {
+		return err
+	}
+	for _, sessionValue := range sessionValues {
+		if sessionID(sessionValue.SessionKey) == id {
+			return u.removeSession(ctx, current.ID, sessionValue.SessionKey)
+		}
+	}
+	return u.errHandler.New(http.StatusNotFound, "session not found")
+}
+
+// RevokeAllSessions logs the user out on every device.
+func (u *UserUseCase) RevokeAllSessions(ctx context.Context, userID string) error {
+	return revokeAllSessions(ctx, u.redis, u.errHandler, u.logger, userID)
+}
+
+// revokeAllSessions ends every session of userID. It is shared with the admin
+// console, which logs deactivated users out.
+func revokeAllSessions(ctx context.Context, redis *redispkg.Redis, errHandler errorHandler.Handler, logger logger.Logger, userID string) error {
+	keys, err := redis.SetMembers(ctx, "user_sessions", userID)
+	if err != nil {
+		logger.Error(err.Error())
+		return errHandler.New(http.StatusInternalServerError, "something went wrong!")
+	}
+	for _, key := range keys {
+		// The session may have expired already, which is fine.
+		_ = redis.Remove(ctx, "session", key)
+	}
+	if len(keys) != 0 {
+		err = redis.RemoveFromSet(ctx, "user_sessions", userID, keys...)
+		if err != nil {
+			logger.Error(err.Error())
+			return errHandler.New(http.StatusInternalServerError, "something went wrong!")
+		}
+	}
+	id, err := strconv.ParseUint(userID, 10, 64)
+	if err == nil {
+		publishSessionsRevoked(ctx, redis, logger, uint(id), "")
+	}
+	logger.InfoContext(ctx, "revoked all sessions", "user", userID, "count", len(keys))
+	return nil
+}
+
+// publishSessionsRevoked has every instance close the sockets opened with the
+// revoked sessions of userID, all of them when key is empty. The sessions are
+// gone either way, a failure is only logged.
+func publishSessionsRevoked(ctx context.Context, redis *redispkg.Redis, logger logger.Logger, userID uint, key string) {
+	payload, err := json.Marshal(domain.RoomEvent{Type: domain.SessionsRevokedEvent, UserID: userID, SessionKey: key})
+	if err == nil {
+		err = redis.Publish(ctx, domain.RoomEventsChannel, payload)
+	}
+	if err != nil {
+		logger.Error(err.Error())
+	}
+}
+
+// userSessions loads every live session of userID and drops the index
+// entries of the ones that expired.
+func (u *UserUseCase) userSessions(ctx context.Context, userID string) ([]domain.SessionValue, error) {
+	keys, err := u.redis.SetMembers(ctx, "user_sessions", userID)
+	if err != nil {
+		u.logger.Error(err.Error())
+		return nil, u.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+	}
+	sessionValues := make([]domain.SessionValue, 0, len(keys))
+	var expired []string
+	for _, key := range keys {
+		ok, data := u.redis.Inspect(ctx, "session", key)
+		if !ok {
+			expired = append(expired, key)
+			continue
+		}
+		var sessionValue domain.SessionValue
+		err = json.Unmarshal(data, &sessionValue)
+		if err != nil {
+			u.logger.Error(err.Error())
+			continue
+		}
+		sessionValues = append(sessionValues, sessionValue)
+	}
+	if len(expired) != 0 {
+		err = u.redis.RemoveFromSet(ctx, "user_sessions", userID, expired...)
+		if err != nil {
+			u.logger.Error(err.Error())
+		}
+	}
+	return sessionValues, nil
+}
+
+func (u *UserUseCase) removeSession(ctx context.Context, userID int, key string) error {
+	err := u.redis.Remove(ctx, "session", key)
+	if err != nil {
+		u.logger.Error(err.Error())
+		return u.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+	}
+	err = u.redis.RemoveFromSet(ctx, "user_sessions", strconv.Itoa(userID), key)
+	if err != nil {
+		u.logger.Error(err.Error())
+		return u.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+	}
+	publishSessionsRevoked(ctx, u.redis, u.logger, uint(userID), key)
+	return nil
+}
+
+// sessionID is the public identifier of a session key.
+func sessionID(key string) string {
+	return hashToken(key)[:16]
+}
@@ -0,0 +1,350 @@
+package usecase
+
+import (
+	"context"
+	cryptorand "crypto/rand"
+	"encoding/base32"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/elyarsadig/studybud-go/configs"
+	"github.com/elyarsadig/studybud-go/internal/domain"
+	"github.com/elyarsadig/studybud-go/pkg/bcrypt"
+	"github.com/elyarsadig/studybud-go/pkg/totp"
+)
+
+const (
+	// twoFactorChallengeExpiration is how long a user has to enter their code
+	// after the password was accepted.
+	twoFactorChallengeExpiration = 5 * time.Minute
+	// twoFactorMaxAttempts is how many wrong codes end a challenge, the user
+	// then has to enter their password again.
+	twoFactorMaxAttempts = 5
+	// totpEnrollmentExpiration is how long a generated secret waits for the
+	// first code before the setup has to start over.
+	totpEnrollmentExpiration = 10 * time.Minute
+	// totpUsedExpiration covers every step a code is accepted in, a code is
+	// refused a second time for that long.
+	totpUsedExpiration = (2*totp.Skew + 1) * totp.Period
+	recoveryCodeCount  = 10
+	totpIssuer         = "StudyBud"
+)
+
+var recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// VerifyTwoFactorLogin finishes a login started by Login with a code from the
+// authenticator or a recovery code, and starts the session.
+func (u *UserUseCase) VerifyTwoFactorLogin(ctx context.Context, token string, form *domain.TwoFactorForm) (string, error) {
+	challenge := hashToken(token)
+	ok, userID := u.redis.Inspect(ctx, "login_2fa", challenge)
+	if !ok {
+		return "", u.errHandler.New(http.StatusBadRequest, "your login has expired, enter your password again")
+	}
+	repo := domain.Bridge[domain.UserRepository](configs.USERS_DB_NAME, u.repositories)
+	user, err := repo.GetUserById(ctx, string(userID))
+	if err != nil {
+		return "", err
+	}
+	// Wrong codes count towards the same lockout as wrong passwords, so a
+	// known password does not open endless rounds of code guesses.
+	accountKey := accountLoginKey(user.Email)
+	client, _ := ctx.Value(configs.ClientCtxKey).(domain.ClientInfo)
+	keys := []string{accountKey}
+	if client.IP != "" {
+		keys = append(keys, ipLoginKey(client.IP))
+	}
+	err = u.checkLoginLockout(ctx, keys...)
+	if err != nil {
+		return "", err
+	}
+	err = u.checkSecondFactor(ctx, user, form.Code)
+	if err != nil {
+		lockoutErr := u.recordFailedLogin(ctx, accountKey, client.IP)
+		if lockoutErr != nil {
+			_ = u.redis.Remove(ctx, "login_2fa", challenge)
+			return "", lockoutErr
+		}
+		attempts, incrErr := u.redis.Incr(ctx, "login_2fa_attempts", challenge, twoFactorChallengeExpiration)
+		if incrErr != nil {
+			u.logger.Error(incrErr.Error())
+		}
+		if attempts >= twoFactorMaxAttempts {
+			_ = u.redis.Remove(ctx, "login_2fa", challenge)
+			u.logger.WarnContext(ctx, "two-factor challenge abandoned after repeated wrong codes", "user", user.ID)
+			return "", u.errHandler.New(http.StatusTooManyRequests, "too many wrong codes, enter your password again")
+		}
+		return "", err
+	}
+	// The challenge is single use, a second request with the same token
+	// loses the race here.
+	ok, _ = u.redis.Pop(ctx, "login_2fa", challenge)
+	if !ok {
+		return "", u.errHandler.New(http.StatusBadRequest, "your login has expired, enter your password again")
+	}
+	u.clearLoginFailures(ctx, accountKey)
+	return u.setSession(ctx, newSessionValue(user))
+}
+
+func (u *UserUseCase) TwoFactorStatus(ctx context.Context, userID string) (domain.TwoFactorStatus, error) {
+	repo := domain.Bridge[domain.UserRepository](configs.USERS_DB_NAME, u.repositories)
+	user, err := repo.GetUserById(ctx, userID)
+	if err != nil {
+		return domain.TwoFactorStatus{}, err
+	}
+	status := domain.TwoFactorStatus{
+		Enabled:  user.TOTPEnabled,
+		Required: u.twoFactorRequired(user),
+	}
+	if user.TOTPEnabled {
+		codes, err := repo.ListRecoveryCodes(ctx, userID)
+		if err != nil {
+			return domain.TwoFactorStatus{}, err
+		}
+		status.RecoveryCodesLeft = len(codes)
+	}
+	return status, nil
+}
+
+// TwoFactorSetupPending tells whether the user must enroll two-factor
+// authentication before they may do anything else.
+func (u *UserUseCase) TwoFactorSetupPending(ctx context.Context, userID string) (bool, error) {
+	repo := domain.Bridge[domain.UserRepository](configs.USERS_DB_NAME, u.repositories)
+	user, err := repo.GetUserById(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	return u.twoFactorRequired(user) && !user.TOTPEnabled, nil
+}
+
+// BeginTOTPEnrollment returns the secret to load into an authenticator. The
+// same pending secret is returned until it expires, so reloading the page
+// does not invalidate a QR code that was already scanned.
+func (u *UserUseCase) BeginTOTPEnrollment(ctx context.Context) (domain.TOTPEnrollment, error) {
+	user, err := u.currentUser(ctx)
+	if err != nil {
+		return domain.TOTPEnrollment{}, err
+	}
+	if user.TOTPEnabled {
+		return domain.TOTPEnrollment{}, u.errHandler.New(http.StatusConflict, "two-factor authentication is already enabled")
+	}
+	userID := strconv.Itoa(int(user.ID))
+	ok, pending := u.redis.Inspect(ctx, "totp_enrollment", userID)
+	secret := string(pending)
+	if !ok {
+		secret, err = totp.GenerateSecret()
+		if err != nil {
+			u.logger.Error(err.Error())
+			return domain.TOTPEnrollment{}, u.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+		}
+		err = u.redis.Set(ctx, "totp_enrollment", totpEnrollmentExpiration, userID, secret)
+		if err != nil {
+			u.logger.Error(err.Error())
+			return domain.TOTPEnrollment{}, u.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+		}
+	}
+	return domain.TOTPEnrollment{
+		Secret: secret,
+		URL:    totp.URL(totpIssuer, user.Email, secret),
+	}, nil
+}
+
+// ConfirmTOTPEnrollment enables two-factor authentication once the user
+// entered a valid code for the pending secret, and returns the recovery
+// codes. They are only shown this once.
+func (u *UserUseCase) ConfirmTOTPEnrollment(ctx context.Context, form *domain.TwoFactorForm) ([]string, error) {
+	user, err := u.currentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	userID := strconv.Itoa(int(user.ID))
+	ok, secret := u.redis.Inspect(ctx, "totp_enrollment", userID)
+	if !ok {
+		return nil, u.errHandler.New(http.StatusBadRequest, "the setup has expired, scan the new QR code")
+	}
+	step, ok := totp.Validate(string(secret), form.Code, time.Now())
+	if !ok {
+		return nil, u.errHandler.New(http.StatusBadRequest, "invalid code, check the time on your device and try again")
+	}
+	err = u.markTOTPUsed(ctx, user.ID, step)
+	if err != nil {
+		return nil, err
+	}
+	codes, hashes, err := u.newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	repo := domain.Bridge[domain.UserRepository](configs.USERS_DB_NAME, u.repositories)
+	err = repo.EnableTOTP(ctx, userID, string(secret), hashes)
+	if err != nil {
+		return nil, err
+	}
+	_ = u.redis.Remove(ctx, "totp_enrollment", userID)
+	u.logger.InfoContext(ctx, "two-factor authentication enabled", "user", user.ID)
+	return codes, nil
+}
+
+func (u *UserUseCase) DisableTOTP(ctx context.Context, form *domain.TwoFactorForm) error {
+	user, err := u.currentUser(ctx)
+	if err != nil {
+		return err
+	}
+	if !user.TOTPEnabled {
+		return u.errHandler.New(http.StatusConflict, "two-factor authentication is not enabled")
+	}
+	if u.twoFactorRequired(user) {
+		return u.errHandler.New(http.StatusForbidden, "two-factor authentication is required for your account")
+	}
+	err = u.checkSecondFactor(ctx, user, form.Code)
+	if err != nil {
+		return err
+	}
+	repo := domain.Bridge[domain.UserRepository](configs.USERS_DB_NAME, u.repositories)
+	err = repo.DisableTOTP(ctx, strconv.Itoa(int(user.ID)))
+	if err != nil {
+		return err
+	}
+	u.logger.InfoContext(ctx, "two-factor authentication disabled", "user", user.ID)
+	return nil
+}
+
+func (u *UserUseCase) RegenerateRecoveryCodes(ctx context.Context, form *domain.TwoFactorForm) ([]string, error) {
+	user, err := u.currentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !user.TOTPEnabled {
+		return nil, u.errHandler.New(http.StatusConflict, "two-factor authentication is not enabled")
+	}
+	err = u.checkSecondFactor(ctx, user, form.Code)
+	if err != nil {
+		return nil, err
+	}
+	codes, hashes, err := u.newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	repo := domain.Bridge[domain.UserRepository](configs.USERS_DB_NAME, u.repositories)
+	err = repo.ReplaceRecoveryCodes(ctx, strconv.Itoa(int(user.ID)), hashes)
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// startTwoFactorChallenge remembers that user passed the password check and
+// returns the token that identifies the pending login.
+func (u *UserUseCase) startTwoFactorChallenge(ctx context.Context, user domain.User) (string, error) {
+	token, err := generateToken()
+	if err != nil {
+		u.logger.Error(err.Error())
+		return "", u.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+	}
+	err = u.redis.Set(ctx, "login_2fa", twoFactorChallengeExpiration, hashToken(token), strconv.Itoa(int(user.ID)))
+	if err != nil {
+		u.logger.Error(err.Error())
+		return "", u.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+	}
+	return token, nil
+}
+
+// checkSecondFactor accepts a current authenticator code or one of the
+// unused recovery codes of user.
+func (u *UserUseCase) checkSecondFactor(ctx context.Context, user domain.User, code string) error {
+	invalid := u.errHandler.New(http.StatusBadRequest, "invalid code, try again")
+	code = strings.TrimSpace(code)
+	if code == "" {
+		return invalid
+	}
+	if step, ok := totp.Validate(user.TOTPSecret, code, time.Now()); ok {
+		return u.markTOTPUsed(ctx, user.ID, step)
+	}
+	normalized := normalizeRecoveryCode(code)
+	if len(normalized) == 0 {
+		return invalid
+	}
+	repo := domain.Bridge[domain.UserRepository](configs.USERS_DB_NAME, u.repositories)
+	codes, err := repo.ListRecoveryCodes(ctx, strconv.Itoa(int(user.ID)))
+	if err != nil {
+		return err
+	}
+	for _, recoveryCode := range codes {
+		if !bcrypt.CheckPasswordHash(normalized, recoveryCode.CodeHash) {
+			continue
+		}
+		used, err := repo.UseRecoveryCode(ctx, recoveryCode.ID)
+		if err != nil {
+			return err
+		}
+		if !used {
+			return invalid
+		}
+		u.logger.InfoContext(ctx, "recovery code used", "user", user.ID, "left", len(codes)-1)
+		return nil
+	}
+	return invalid
+}
+
+// markTOTPUsed refuses a code that was already accepted once, so a code seen
+// over someone's shoulder cannot be replayed within its lifetime.
+func (u *UserUseCase) markTOTPUsed(ctx context.Context, userID uint, step int64) error {
+	key := strconv.Itoa(int(userID)) + ":" + strconv.FormatInt(step, 10)
+	ok, err := u.redis.SetNX(ctx, "totp_used", totpUsedExpiration, key, 1)
+	if err != nil {
+		u.logger.Error(err.Error())
+		return u.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+	}
+	if !ok {
+		return u.errHandler.New(http.StatusBadRequest, "this code was already used, wait for the next one")
+	}
+	return nil
+}
+
+// newRecoveryCodes returns fresh recovery codes formatted for the user, and
+// their bcrypt hashes for storage.
+func (u *UserUseCase) newRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		b := make([]byte, 5)
+		_, err := cryptorand.Read(b)
+		if err != nil {
+			u.logger.Error(err.Error())
+			return nil, nil, u.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+		}
+		code := strings.ToLower(recoveryCodeEncoding.EncodeToString(b))
+		hash, err := bcrypt.HashPassword(code)
+		if err != nil {
+			u.logger.Error(err.Error())
+			return nil, nil, u.errHandler.New(http.StatusInternalServerError, "something went wrong!")
+		}
+		codes = append(codes, code[:4]+"-"+code[4:])
+		hashes = append(hashes, hash)
+	}
+	return codes, hashes, nil
+}
+
+// normalizeRecoveryCode drops the dash and spaces users may type and lowers
+// the case, matching the form the code was hashed in.
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	code = strings.NewReplacer("-", "", " ", "").Replace(code)
+	if len(code) != 8 {
+		return ""
+	}
+	return code
+}
+
+// twoFactorRequired tells whether user must use two-factor authentication,
+// because an admin asked for it or because staff must when require_staff_2fa
+// is on.
+func (u *UserUseCase) twoFactorRequired(user domain.User) bool {
+	return user.RequireTOTP || u.requireStaff2FA && (user.IsStaff || user.IsSuperuser)
+}
+
+func (u *UserUseCase) currentUser(ctx context.Context) (domain.User, error) {
+	sessionValue := ctx.Value(configs.UserCtxKey).(domain.SessionValue)
+	repo := domain.Bridge[domain.UserRepository](configs.USERS_DB_NAME, u.repositories)
+	return repo.GetUserById(ctx, strconv.Itoa(sessionValue.ID))
+}
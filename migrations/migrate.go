@@ -1,8 +1,11 @@
 package migrations
 
 import (
+	"fmt"
+
 	"github.com/elyarsadig/studybud-go/internal/domain"
 	"github.com/elyarsadig/studybud-go/pkg/logger"
+	"github.com/elyarsadig/studybud-go/pkg/markdown"
 	"gorm.io/gorm"
 )
 
@@ -12,17 +15,142 @@ func Migrate(db *gorm.DB, logging logger.Logger) error {
 		&domain.AuthPermission{},
 		&domain.AuthGroupPermission{},
 		&domain.Message{},
+		&domain.MessageRevision{},
+		&domain.MessageReaction{},
+		&domain.Attachment{},
 		&domain.Room{},
 		&domain.RoomParticipant{},
+		&domain.RoomJoinRequest{},
 		&domain.Topic{},
 		&domain.User{},
 		&domain.UserGroup{},
 		&domain.UserPermission{},
 		&domain.ContentType{},
+		&domain.AuditLog{},
+		&domain.RecoveryCode{},
+		&domain.PersonalAccessToken{},
+		&domain.UserIdentity{},
+		&domain.Notification{},
 	)
 	if err != nil {
 		return err
 	}
+	err = RegisterPermissions(db)
+	if err != nil {
+		return err
+	}
+	err = backfillRoomHosts(db)
+	if err != nil {
+		return err
+	}
+	err = relaxRoomHostConstraint(db)
+	if err != nil {
+		return err
+	}
+	err = renderMarkdown(db)
+	if err != nil {
+		return err
+	}
+	err = addSearchVectors(db)
+	if err != nil {
+		return err
+	}
 	logging.Info("successfully migrated the DB")
 	return nil
 }
+
+// backfillRoomHosts makes every host a participant of their room with the host
+// role. Rooms created before membership was explicit only had participants
+// who posted.
+func backfillRoomHosts(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Exec(`UPDATE room_participants SET role = ? FROM rooms
+			WHERE rooms.id = room_participants.room_id AND rooms.host_id = room_participants.user_id AND room_participants.role <> ?`,
+			domain.RoomRoleHost, domain.RoomRoleHost).Error
+		if err != nil {
+			return err
+		}
+		return tx.Exec(`INSERT INTO room_participants (room_id, user_id, role)
+			SELECT rooms.id, rooms.host_id, ? FROM rooms
+			WHERE NOT EXISTS (SELECT 1 FROM room_participants rp WHERE rp.room_id = rooms.id AND rp.user_id = rooms.host_id)`,
+			domain.RoomRoleHost).Error
+	})
+}
+
+// relaxRoomHostConstraint recreates the foreign key from rooms to their host
+// with ON DELETE SET NULL. AutoMigrate does not change existing constraints
+// and databases created before rooms were handed over on account deletion
+// still cascade, which would delete every room of a deleted host.
+func relaxRoomHostConstraint(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Exec("ALTER TABLE rooms DROP CONSTRAINT IF EXISTS fk_rooms_host").Error
+		if err != nil {
+			return err
+		}
+		return tx.Exec(`ALTER TABLE rooms ADD CONSTRAINT fk_rooms_host FOREIGN KEY (host_id) REFERENCES users(id)
+			ON UPDATE CASCADE ON DELETE SET NULL`).Error
+	})
+}
+
+// renderMarkdown fills in the rendered HTML of the messages and rooms written
+// before it was kept along with their Markdown source.
+func renderMarkdown(db *gorm.DB) error {
+	var messages []domain.Message
+	err := db.Select("id", "body").Where("body_html = '' AND body <> ''").
+		FindInBatches(&messages, 500, func(_ *gorm.DB, _ int) error {
+			for _, message := range messages {
+				err := db.Model(&domain.Message{ID: message.ID}).UpdateColumn("body_html", markdown.Render(message.Body)).Error
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		}).Error
+	if err != nil {
+		return err
+	}
+	var rooms []domain.Room
+	return db.Select("id", "description").Where("description_html = '' AND description <> ''").
+		FindInBatches(&rooms, 500, func(_ *gorm.DB, _ int) error {
+			for _, room := range rooms {
+				err := db.Model(&domain.Room{ID: room.ID}).UpdateColumn("description_html", markdown.Render(room.Description)).Error
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		}).Error
+}
+
+// searchVectors are the documents full text search looks into, by table.
+// Names weigh more than descriptions and bios so they rank first.
+var searchVectors = []struct {
+	table      string
+	expression string
+}{
+	{"rooms", "setweight(to_tsvector('english', coalesce(name, '')), 'A') || setweight(to_tsvector('english', coalesce(description, '')), 'B')"},
+	{"messages", "to_tsvector('english', coalesce(body, ''))"},
+	{"topics", "to_tsvector('english', coalesce(name, ''))"},
+	{"users", "setweight(to_tsvector('simple', coalesce(username, '')), 'A') || setweight(to_tsvector('english', coalesce(name, '')), 'A') || setweight(to_tsvector('english', coalesce(bio, '')), 'C')"},
+}
+
+// addSearchVectors adds a search_vector column with a GIN index to the tables
+// of searchVectors. The columns are generated, Postgres keeps them up to date
+// on every write.
+func addSearchVectors(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, vector := range searchVectors {
+			err := tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (%s) STORED",
+				vector.table, vector.expression)).Error
+			if err != nil {
+				return err
+			}
+			err = tx.Exec(fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_%s_search_vector ON %s USING GIN (search_vector)",
+				vector.table, vector.table)).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,100 @@
+package migrations
+
+import (
+	"fmt"
+
+	"github.com/elyarsadig/studybud-go/internal/domain"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var defaultContentTypes = []domain.ContentType{
+	{AppLabel: "rooms", Model: "room"},
+	{AppLabel: "messages", Model: "message"},
+	{AppLabel: "topics", Model: "topic"},
+}
+
+var defaultActions = []string{"add", "change", "delete", "view"}
+
+var defaultGroupPermissions = map[string][]string{
+	domain.MembersGroup: {
+		domain.PermViewRoom, domain.PermAddRoom,
+		domain.PermViewMessage, domain.PermAddMessage,
+		domain.PermViewTopic, domain.PermAddTopic,
+	},
+	domain.ModeratorsGroup: {
+		domain.PermViewRoom, domain.PermAddRoom, domain.PermChangeRoom, domain.PermDeleteRoom,
+		domain.PermViewMessage, domain.PermAddMessage, domain.PermDeleteMessage,
+		domain.PermViewTopic, domain.PermAddTopic, domain.PermChangeTopic, domain.PermDeleteTopic,
+	},
+}
+
+// RegisterPermissions creates the content types, the add/change/delete/view
+// permissions for each of them and the default groups. It is idempotent and
+// runs after every migration.
+func RegisterPermissions(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		permissionIDs := make(map[string]uint)
+		for _, contentType := range defaultContentTypes {
+			err := tx.FirstOrCreate(&contentType, domain.ContentType{AppLabel: contentType.AppLabel, Model: contentType.Model}).Error
+			if err != nil {
+				return err
+			}
+			for _, action := range defaultActions {
+				permission := domain.AuthPermission{
+					ContentTypeID: contentType.ID,
+					Codename:      action + "_" + contentType.Model,
+					Name:          "Can " + action + " " + contentType.Model,
+				}
+				err := tx.Omit(clause.Associations).
+					FirstOrCreate(&permission, domain.AuthPermission{ContentTypeID: contentType.ID, Codename: permission.Codename}).Error
+				if err != nil {
+					return err
+				}
+				permissionIDs[contentType.AppLabel+"."+permission.Codename] = permission.ID
+			}
+		}
+		for groupName, permissions := range defaultGroupPermissions {
+			group := domain.AuthGroup{Name: groupName}
+			err := tx.FirstOrCreate(&group, domain.AuthGroup{Name: groupName}).Error
+			if err != nil {
+				return err
+			}
+			for _, perm := range permissions {
+				groupPermission := domain.AuthGroupPermission{GroupID: group.ID, PermissionID: permissionIDs[perm]}
+				err := tx.Omit(clause.Associations).FirstOrCreate(&groupPermission, groupPermission).Error
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return assignDefaultGroup(tx)
+	})
+}
+
+// assignDefaultGroup puts every user that is not in any group yet into the
+// members group so existing accounts keep the permissions the routes require.
+func assignDefaultGroup(db *gorm.DB) error {
+	return db.Exec(`
+		INSERT INTO user_groups (user_id, group_id)
+		SELECT users.id, auth_groups.id FROM users
+		JOIN auth_groups ON auth_groups.name = ?
+		WHERE NOT EXISTS (SELECT 1 FROM user_groups WHERE user_groups.user_id = users.id)`,
+		domain.MembersGroup,
+	).Error
+}
+
+// PromoteSuperuser gives an existing account staff and superuser status so
+// it can sign in to the admin console.
+func PromoteSuperuser(db *gorm.DB, email string) error {
+	result := db.Model(&domain.User{}).
+		Where("email = ?", email).
+		Updates(map[string]any{"is_staff": true, "is_superuser": true, "is_active": true})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("no user with email %s", email)
+	}
+	return nil
+}
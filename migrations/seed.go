@@ -24,15 +24,18 @@ func Seed(db *gorm.DB) error {
 	if err := createMessages(db); err != nil {
 		return err
 	}
-	return nil
+	if err := assignDefaultGroup(db); err != nil {
+		return err
+	}
+	return renderMarkdown(db)
 }
 
 func createUsers(db *gorm.DB) error {
 	password, _ := bcrypt.HashPassword("test123")
 	users := []domain.User{
-		{Username: "JaneDoe", Email: "jane.doe@example.com", Name: "Jane Doe", Avatar: "/static/images/avatar.svg", Bio: "Enthusiastic learner", DateJoined: time.Now(), Password: password},
-		{Username: "JohnSmith", Email: "john.smith@example.com", Name: "John Smith", Avatar: "/static/images/avatar.svg", Bio: "Loves coding", DateJoined: time.Now(), Password: password},
-		{Username: "AliceW", Email: "alice.w@example.com", Name: "Alice W", Avatar: "/static/images/avatar.svg", Bio: "Avid reader", DateJoined: time.Now(), Password: password},
+		{Username: "JaneDoe", Email: "jane.doe@example.com", Name: "Jane Doe", Avatar: "/static/images/avatar.svg", Bio: "Enthusiastic learner", DateJoined: time.Now(), Password: password, IsActive: true, IsStaff: true, IsSuperuser: true},
+		{Username: "JohnSmith", Email: "john.smith@example.com", Name: "John Smith", Avatar: "/static/images/avatar.svg", Bio: "Loves coding", DateJoined: time.Now(), Password: password, IsActive: true},
+		{Username: "AliceW", Email: "alice.w@example.com", Name: "Alice W", Avatar: "/static/images/avatar.svg", Bio: "Avid reader", DateJoined: time.Now(), Password: password, IsActive: true},
 	}
 	for _, user := range users {
 		err := db.FirstOrCreate(&user, domain.User{Email: user.Email}).Error
@@ -89,22 +92,22 @@ func createRooms(db *gorm.DB) error {
 
 func createRoomParticipants(db *gorm.DB) error {
 	roomParticipants := []domain.RoomParticipant{
-		{RoomID: 1, UserID: 1},
-		{RoomID: 1, UserID: 3},
-		{RoomID: 2, UserID: 1},
-		{RoomID: 2, UserID: 2},
-		{RoomID: 3, UserID: 1},
-		{RoomID: 3, UserID: 2},
-		{RoomID: 4, UserID: 2},
-		{RoomID: 4, UserID: 3},
-		{RoomID: 5, UserID: 1},
-		{RoomID: 6, UserID: 3},
-		{RoomID: 7, UserID: 1},
-		{RoomID: 8, UserID: 2},
-		{RoomID: 8, UserID: 3},
-		{RoomID: 9, UserID: 1},
-		{RoomID: 9, UserID: 3},
-		{RoomID: 10, UserID: 2},
+		{RoomID: 1, UserID: 1, Role: domain.RoomRoleHost},
+		{RoomID: 1, UserID: 3, Role: domain.RoomRoleMember},
+		{RoomID: 2, UserID: 1, Role: domain.RoomRoleMember},
+		{RoomID: 2, UserID: 2, Role: domain.RoomRoleHost},
+		{RoomID: 3, UserID: 1, Role: domain.RoomRoleHost},
+		{RoomID: 3, UserID: 2, Role: domain.RoomRoleMember},
+		{RoomID: 4, UserID: 2, Role: domain.RoomRoleHost},
+		{RoomID: 4, UserID: 3, Role: domain.RoomRoleMember},
+		{RoomID: 5, UserID: 1, Role: domain.RoomRoleHost},
+		{RoomID: 6, UserID: 3, Role: domain.RoomRoleHost},
+		{RoomID: 7, UserID: 1, Role: domain.RoomRoleHost},
+		{RoomID: 8, UserID: 2, Role: domain.RoomRoleHost},
+		{RoomID: 8, UserID: 3, Role: domain.RoomRoleMember},
+		{RoomID: 9, UserID: 1, Role: domain.RoomRoleMember},
+		{RoomID: 9, UserID: 3, Role: domain.RoomRoleHost},
+		{RoomID: 10, UserID: 2, Role: domain.RoomRoleHost},
 	}
 	for _, rp := range roomParticipants {
 		err := db.FirstOrCreate(&rp, rp).Error
@@ -21,3 +21,41 @@ func TestEncryption(t *testing.T) {
 		t.Errorf("expected decryptOutput to be equal to %d, but got %d", data, decryptOutput)
 	}
 }
+
+func TestDeriveKey(t *testing.T) {
+	secretKey := []byte("MYSECRETKEYFORTE")
+	invites, err := DeriveKey(secretKey, "room invites")
+	if err != nil {
+		t.Fatal("unexpected error happened:", err)
+	}
+	if len(invites) != len(secretKey) {
+		t.Errorf("expected a key of %d bytes, but got %d", len(secretKey), len(invites))
+	}
+	again, err := DeriveKey(secretKey, "room invites")
+	if err != nil {
+		t.Fatal("unexpected error happened:", err)
+	}
+	if string(again) != string(invites) {
+		t.Error("expected the same purpose to derive the same key")
+	}
+	emails, err := DeriveKey(secretKey, "email tokens")
+	if err != nil {
+		t.Fatal("unexpected error happened:", err)
+	}
+	inviteAES, err := NewAES[int](invites)
+	if err != nil {
+		t.Fatal("unexpected error happened:", err)
+	}
+	emailAES, err := NewAES[int](emails)
+	if err != nil {
+		t.Fatal("unexpected error happened:", err)
+	}
+	token, err := inviteAES.Encrypt(123456)
+	if err != nil {
+		t.Fatal("unexpected error happened:", err)
+	}
+	_, err = emailAES.Decrypt(token)
+	if err == nil {
+		t.Error("expected a token of one purpose not to decrypt as another")
+	}
+}
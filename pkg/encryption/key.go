@@ -0,0 +1,20 @@
+package encryption
+
+import (
+	"crypto/sha256"
+	"io"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// DeriveKey returns a key as long as secret that is only used for purpose, so
+// one secret can back several ciphers without a token made for one purpose
+// decrypting as another.
+func DeriveKey(secret []byte, purpose string) ([]byte, error) {
+	key := make([]byte, len(secret))
+	_, err := io.ReadFull(hkdf.New(sha256.New, secret, nil, []byte(purpose)), key)
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
@@ -0,0 +1,172 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCompose(t *testing.T) {
+	now := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	data, err := compose("StudyBud <noreply@studybud.dev>", Message{
+		To:      []string{"jane@example.com"},
+		Subject: "Reset your password",
+		Body:    "line one\nline two",
+	}, now)
+	if err != nil {
+		t.Fatal("unexpected error happened:", err)
+	}
+	output := string(data)
+	expected := []string{
+		"From: StudyBud <noreply@studybud.dev>\r\n",
+		"To: jane@example.com\r\n",
+		"Subject: Reset your password\r\n",
+		"Date: Wed, 01 May 2024 10:00:00 +0000\r\n",
+		"Content-Type: text/plain; charset=utf-8\r\n",
+		"\r\n\r\nline one\r\nline two\r\n",
+	}
+	for _, part := range expected {
+		if !strings.Contains(output, part) {
+			t.Errorf("expected message to contain %q, but got %q", part, output)
+		}
+	}
+}
+
+func TestComposeRejectsHeaderInjection(t *testing.T) {
+	testCases := []struct {
+		msg  Message
+		desc string
+	}{
+		{
+			msg:  Message{To: []string{"jane@example.com"}, Subject: "hi\r\nBcc: eve@example.com"},
+			desc: "subject",
+		},
+		{
+			msg:  Message{To: []string{"jane@example.com\nBcc: eve@example.com"}, Subject: "hi"},
+			desc: "recipient",
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			_, err := compose("noreply@studybud.dev", tC.msg, time.Now())
+			if !errors.Is(err, ErrInvalidHeader) {
+				t.Errorf("expected ErrInvalidHeader, but got %v", err)
+			}
+		})
+	}
+}
+
+func TestComposeRequiresRecipients(t *testing.T) {
+	_, err := compose("noreply@studybud.dev", Message{Subject: "hi"}, time.Now())
+	if err == nil {
+		t.Error("expected an error for a message without recipients")
+	}
+}
+
+func TestWriter(t *testing.T) {
+	var buf bytes.Buffer
+	m := NewWriter("noreply@studybud.dev", &buf)
+	err := m.Send(context.Background(), Message{To: []string{"jane@example.com"}, Subject: "hello", Body: "body"})
+	if err != nil {
+		t.Fatal("unexpected error happened:", err)
+	}
+	if !strings.Contains(buf.String(), "To: jane@example.com") {
+		t.Errorf("expected the message to be written, but got %q", buf.String())
+	}
+}
+
+func TestFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mail.log")
+	m, err := NewFile("noreply@studybud.dev", path)
+	if err != nil {
+		t.Fatal("unexpected error happened:", err)
+	}
+	for i := 0; i < 2; i++ {
+		err = m.Send(context.Background(), Message{To: []string{"jane@example.com"}, Subject: "hello", Body: "body"})
+		if err != nil {
+			t.Fatal("unexpected error happened:", err)
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal("unexpected error happened:", err)
+	}
+	if count := strings.Count(string(data), "Subject: hello"); count != 2 {
+		t.Errorf("expected 2 messages in the file, but got %d", count)
+	}
+}
+
+func TestSMTP(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("unexpected error happened:", err)
+	}
+	defer listener.Close()
+	received := make(chan string, 1)
+	go serveOneSMTPSession(t, listener, received)
+
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	m := NewSMTP(SMTPConfig{Host: host, Port: port, From: "noreply@studybud.dev"})
+	err = m.Send(context.Background(), Message{To: []string{"jane@example.com"}, Subject: "hello", Body: "reset link"})
+	if err != nil {
+		t.Fatal("unexpected error happened:", err)
+	}
+	select {
+	case data := <-received:
+		if !strings.Contains(data, "Subject: hello") || !strings.Contains(data, "reset link") {
+			t.Errorf("unexpected message received: %q", data)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the SMTP server did not receive a message")
+	}
+}
+
+// serveOneSMTPSession speaks just enough SMTP for net/smtp.SendMail without
+// offering STARTTLS or AUTH.
+func serveOneSMTPSession(t *testing.T, listener net.Listener, received chan<- string) {
+	conn, err := listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	text := textproto.NewConn(conn)
+	reply := func(line string) {
+		if err := text.PrintfLine("%s", line); err != nil {
+			t.Error(err)
+		}
+	}
+	reply("220 localhost ESMTP")
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		command := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		switch command {
+		case "EHLO", "HELO":
+			reply("250 localhost")
+		case "MAIL", "RCPT", "RSET", "NOOP":
+			reply("250 OK")
+		case "DATA":
+			reply("354 go ahead")
+			lines, err := text.ReadDotLines()
+			if err != nil {
+				return
+			}
+			received <- strings.Join(lines, "\n")
+			reply("250 OK")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 not implemented")
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package mailer
+
+import (
+	"context"
+	"net"
+	"net/smtp"
+	"time"
+)
+
+type SMTPConfig struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+type SMTP struct {
+	config SMTPConfig
+	auth   smtp.Auth
+}
+
+// NewSMTP returns a Mailer that delivers through an SMTP relay. It upgrades
+// to TLS with STARTTLS whenever the server offers it and only authenticates
+// when a username is set.
+func NewSMTP(config SMTPConfig) *SMTP {
+	var auth smtp.Auth
+	if len(config.Username) != 0 {
+		auth = smtp.PlainAuth("", config.Username, config.Password, config.Host)
+	}
+	return &SMTP{
+		config: config,
+		auth:   auth,
+	}
+}
+
+func (s *SMTP) Send(ctx context.Context, msg Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	data, err := compose(s.config.From, msg, time.Now())
+	if err != nil {
+		return err
+	}
+	addr := net.JoinHostPort(s.config.Host, s.config.Port)
+	return smtp.SendMail(addr, s.auth, s.config.From, msg.To, data)
+}
@@ -0,0 +1,47 @@
+package mailer
+
+import (
+	"context"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Writer writes every message to an io.Writer instead of delivering it, so
+// links in emails can be followed during local development.
+type Writer struct {
+	from string
+	mu   sync.Mutex
+	w    io.Writer
+}
+
+func NewWriter(from string, w io.Writer) *Writer {
+	return &Writer{
+		from: from,
+		w:    w,
+	}
+}
+
+// NewFile appends messages to the file at path, creating it if needed.
+func NewFile(from string, path string) (*Writer, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return NewWriter(from, file), nil
+}
+
+func (m *Writer) Send(ctx context.Context, msg Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	data, err := compose(m.from, msg, time.Now())
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, err = m.w.Write(append(data, "\r\n"...))
+	return err
+}
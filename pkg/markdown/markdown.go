@@ -0,0 +1,106 @@
+package markdown
+
+import (
+	"bytes"
+	"html/template"
+	"regexp"
+
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	highlighting "github.com/yuin/goldmark-highlighting/v2"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/util"
+)
+
+// converter turns Markdown into HTML. Raw HTML in the source is shown as text
+// and fenced code blocks are highlighted with CSS classes, the colors live in
+// web/static/styles/syntax.css.
+var converter = goldmark.New(
+	goldmark.WithExtensions(
+		extension.Table,
+		extension.Strikethrough,
+		extension.Linkify,
+		highlighting.NewHighlighting(
+			highlighting.WithFormatOptions(chromahtml.WithClasses(true)),
+		),
+	),
+	goldmark.WithRendererOptions(
+		html.WithHardWraps(),
+		renderer.WithNodeRenderers(util.Prioritized(escapedHTML{}, 100)),
+	),
+)
+
+// policy is what is left of the rendered HTML: the elements of user generated
+// content, links that cannot run scripts and the classes of highlighted code.
+var policy = newPolicy()
+
+func newPolicy() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^[a-zA-Z0-9 _-]+$`)).OnElements("pre", "code", "span")
+	p.RequireNoReferrerOnLinks(true)
+	p.AddTargetBlankToFullyQualifiedLinks(true)
+	return p
+}
+
+// Render converts source from Markdown to HTML that is safe to put in a page
+// as it is. The rendering is not cheap, callers keep the result instead of
+// rendering the same source on every request.
+func Render(source string) template.HTML {
+	var buf bytes.Buffer
+	err := converter.Convert([]byte(source), &buf)
+	if err != nil {
+		return template.HTML(template.HTMLEscapeString(source))
+	}
+	return template.HTML(policy.SanitizeBytes(buf.Bytes()))
+}
+
+// escapedHTML renders the HTML written in a message as text instead of
+// dropping it, people share snippets of it in rooms about the web.
+type escapedHTML struct{}
+
+func (escapedHTML) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(ast.KindHTMLBlock, renderHTMLBlock)
+	reg.Register(ast.KindRawHTML, renderRawHTML)
+}
+
+func renderHTMLBlock(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	n := node.(*ast.HTMLBlock)
+	if entering {
+		_, _ = w.WriteString("<p>")
+		for i := 0; i < n.Lines().Len(); i++ {
+			line := n.Lines().At(i)
+			writeEscapedLine(w, line.Value(source))
+		}
+		return ast.WalkContinue, nil
+	}
+	if n.HasClosure() {
+		writeEscapedLine(w, n.ClosureLine.Value(source))
+	}
+	_, _ = w.WriteString("</p>\n")
+	return ast.WalkContinue, nil
+}
+
+// writeEscapedLine writes a line of an HTML block, keeping its line break like
+// the hard wraps of paragraphs do.
+func writeEscapedLine(w util.BufWriter, line []byte) {
+	text := bytes.TrimRight(line, "\r\n")
+	_, _ = w.Write(util.EscapeHTML(text))
+	if len(text) != len(line) {
+		_, _ = w.WriteString("<br>\n")
+	}
+}
+
+func renderRawHTML(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if entering {
+		n := node.(*ast.RawHTML)
+		for i := 0; i < n.Segments.Len(); i++ {
+			segment := n.Segments.At(i)
+			_, _ = w.Write(util.EscapeHTML(segment.Value(source)))
+		}
+	}
+	return ast.WalkSkipChildren, nil
+}
@@ -0,0 +1,70 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	testCases := []struct {
+		source   string
+		contains []string
+		excludes []string
+		desc     string
+	}{
+		{
+			source:   "**bold** and _italic_\nnext line",
+			contains: []string{"<strong>bold</strong>", "<em>italic</em>", "<br>"},
+			desc:     "Emphasis And Line Breaks",
+		},
+		{
+			source:   "```go\nfunc main() {}\n```",
+			contains: []string{`<pre class="chroma">`, `<span class="kd">func</span>`},
+			desc:     "Highlighted Code Block",
+		},
+		{
+			source:   "`<b>` is bold",
+			contains: []string{"<code>&lt;b&gt;</code>"},
+			desc:     "Inline Code",
+		},
+		{
+			source:   "<script>alert(1)</script>",
+			contains: []string{"&lt;script&gt;alert(1)&lt;/script&gt;"},
+			excludes: []string{"<script"},
+			desc:     "HTML Block Shown As Text",
+		},
+		{
+			source:   "look <img src=x onerror=alert(1)> here",
+			contains: []string{"&lt;img src=x onerror=alert(1)&gt;"},
+			excludes: []string{"<img"},
+			desc:     "Inline HTML Shown As Text",
+		},
+		{
+			source:   "[click](javascript:alert(1)) [docs](https://go.dev)",
+			contains: []string{`<a href="https://go.dev" rel="nofollow noreferrer noopener" target="_blank">docs</a>`},
+			excludes: []string{"javascript:"},
+			desc:     "Unsafe Links Removed",
+		},
+		{
+			source:   "[x](https://go.dev \"a\\\" onclick=\\\"alert(1)\")",
+			contains: []string{`<a href="https://go.dev"`},
+			excludes: []string{"onclick"},
+			desc:     "Titles Cannot Add Attributes",
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			output := string(Render(tC.source))
+			for _, s := range tC.contains {
+				if !strings.Contains(output, s) {
+					t.Errorf("expected %q in %q", s, output)
+				}
+			}
+			for _, s := range tC.excludes {
+				if strings.Contains(output, s) {
+					t.Errorf("did not expect %q in %q", s, output)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	gooidc "github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+var (
+	ErrMissingIDToken = errors.New("oidc: token response has no id_token")
+	ErrNonceMismatch  = errors.New("oidc: id_token nonce does not match")
+)
+
+// Config describes one OpenID Connect provider. Name identifies it in URLs,
+// RedirectURL must be registered with the provider.
+type Config struct {
+	Name         string
+	DisplayName  string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	// Scopes are requested on top of "openid". Email and profile are used
+	// when empty.
+	Scopes []string
+}
+
+// Claims are the parts of a verified ID token the application uses.
+type Claims struct {
+	Subject           string `json:"sub"`
+	Email             string `json:"email"`
+	EmailVerified     bool   `json:"email_verified"`
+	Name              string `json:"name"`
+	PreferredUsername string `json:"preferred_username"`
+}
+
+// Provider runs the authorization code flow with PKCE against one issuer.
+// The discovery document is fetched on first use and retried on the next
+// login if the issuer could not be reached.
+type Provider struct {
+	config Config
+
+	mu       sync.Mutex
+	oauth2   *oauth2.Config
+	verifier *gooidc.IDTokenVerifier
+}
+
+func New(config Config) *Provider {
+	if len(config.DisplayName) == 0 {
+		config.DisplayName = config.Name
+	}
+	if len(config.Scopes) == 0 {
+		config.Scopes = []string{"email", "profile"}
+	}
+	return &Provider{config: config}
+}
+
+func (p *Provider) Name() string {
+	return p.config.Name
+}
+
+func (p *Provider) DisplayName() string {
+	return p.config.DisplayName
+}
+
+// NewVerifier returns a random PKCE code verifier. It is kept on the server
+// while the user is at the provider and passed to Exchange.
+func NewVerifier() string {
+	return oauth2.GenerateVerifier()
+}
+
+// AuthCodeURL returns the provider URL to send the user to. state comes back
+// on the callback, nonce is bound into the ID token.
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error) {
+	config, _, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	return config.AuthCodeURL(state, gooidc.Nonce(nonce), oauth2.S256ChallengeOption(verifier)), nil
+}
+
+// Exchange trades the code from the callback for tokens and returns the
+// claims of the verified ID token.
+func (p *Provider) Exchange(ctx context.Context, code, verifier, nonce string) (Claims, error) {
+	config, idTokenVerifier, err := p.discover(ctx)
+	if err != nil {
+		return Claims{}, err
+	}
+	token, err := config.Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return Claims{}, fmt.Errorf("oidc: exchange code: %w", err)
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok || len(rawIDToken) == 0 {
+		return Claims{}, ErrMissingIDToken
+	}
+	idToken, err := idTokenVerifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return Claims{}, fmt.Errorf("oidc: verify id_token: %w", err)
+	}
+	if idToken.Nonce != nonce {
+		return Claims{}, ErrNonceMismatch
+	}
+	var claims Claims
+	err = idToken.Claims(&claims)
+	if err != nil {
+		return Claims{}, fmt.Errorf("oidc: decode claims: %w", err)
+	}
+	return claims, nil
+}
+
+func (p *Provider) discover(ctx context.Context) (*oauth2.Config, *gooidc.IDTokenVerifier, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.oauth2 != nil {
+		return p.oauth2, p.verifier, nil
+	}
+	provider, err := gooidc.NewProvider(ctx, p.config.Issuer)
+	if err != nil {
+		return nil, nil, fmt.Errorf("oidc: discover %s: %w", p.config.Issuer, err)
+	}
+	p.oauth2 = &oauth2.Config{
+		ClientID:     p.config.ClientID,
+		ClientSecret: p.config.ClientSecret,
+		Endpoint:     provider.Endpoint(),
+		RedirectURL:  p.config.RedirectURL,
+		Scopes:       append([]string{gooidc.ScopeOpenID}, p.config.Scopes...),
+	}
+	p.verifier = provider.Verifier(&gooidc.Config{ClientID: p.config.ClientID})
+	return p.oauth2, p.verifier, nil
+}
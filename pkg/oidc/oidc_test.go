@@ -0,0 +1,304 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// mockProvider is a minimal OpenID Connect provider: it serves discovery and
+// the JWKS, and issues RS256 signed ID tokens for codes handed out by
+// authorize.
+type mockProvider struct {
+	t      *testing.T
+	server *httptest.Server
+	key    *rsa.PrivateKey
+	// signer signs the ID tokens, it is key unless a test swaps it.
+	signer *rsa.PrivateKey
+
+	mu     sync.Mutex
+	codes  map[string]authRequest
+	claims map[string]any
+}
+
+type authRequest struct {
+	challenge string
+	nonce     string
+}
+
+func newMockProvider(t *testing.T) *mockProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &mockProvider{
+		t:      t,
+		key:    key,
+		signer: key,
+		codes:  make(map[string]authRequest),
+		claims: map[string]any{
+			"sub":                "student-42",
+			"email":              "jane@school.edu",
+			"email_verified":     true,
+			"name":               "Jane Doe",
+			"preferred_username": "jane",
+		},
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", m.discovery)
+	mux.HandleFunc("/jwks", m.jwks)
+	mux.HandleFunc("/token", m.token)
+	m.server = httptest.NewServer(mux)
+	t.Cleanup(m.server.Close)
+	return m
+}
+
+func (m *mockProvider) config() Config {
+	return Config{
+		Name:         "school",
+		Issuer:       m.server.URL,
+		ClientID:     "studybud",
+		ClientSecret: "secret",
+		RedirectURL:  "http://localhost:8080/login/oidc/school/callback",
+	}
+}
+
+// authorize plays the user logging in at the provider: it checks the
+// request built by AuthCodeURL and returns the code for the callback.
+func (m *mockProvider) authorize(authURL string) string {
+	u, err := url.Parse(authURL)
+	if err != nil {
+		m.t.Fatal(err)
+	}
+	query := u.Query()
+	if query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "" {
+		m.t.Fatalf("expected a S256 code challenge, but got %q", u.RawQuery)
+	}
+	if !strings.Contains(query.Get("scope"), "openid") {
+		m.t.Fatalf("expected the openid scope, but got %q", query.Get("scope"))
+	}
+	code := "code-" + query.Get("state")
+	m.mu.Lock()
+	m.codes[code] = authRequest{challenge: query.Get("code_challenge"), nonce: query.Get("nonce")}
+	m.mu.Unlock()
+	return code
+}
+
+func (m *mockProvider) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, map[string]any{
+		"issuer":                                m.server.URL,
+		"authorization_endpoint":                m.server.URL + "/authorize",
+		"token_endpoint":                        m.server.URL + "/token",
+		"jwks_uri":                              m.server.URL + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+	})
+}
+
+func (m *mockProvider) jwks(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, map[string]any{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"alg": "RS256",
+			"use": "sig",
+			"kid": "test",
+			"n":   base64.RawURLEncoding.EncodeToString(m.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(m.key.E)).Bytes()),
+		}},
+	})
+}
+
+func (m *mockProvider) token(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	m.mu.Lock()
+	request, ok := m.codes[r.PostForm.Get("code")]
+	delete(m.codes, r.PostForm.Get("code"))
+	m.mu.Unlock()
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if !ok || base64.RawURLEncoding.EncodeToString(sum[:]) != request.challenge {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":"invalid_grant"}`))
+		return
+	}
+	claims := map[string]any{
+		"iss":   m.server.URL,
+		"aud":   "studybud",
+		"iat":   time.Now().Unix(),
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"nonce": request.nonce,
+	}
+	for k, v := range m.claims {
+		claims[k] = v
+	}
+	writeJSON(w, map[string]any{
+		"access_token": "access",
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     m.sign(claims),
+	})
+}
+
+func (m *mockProvider) sign(claims map[string]any) string {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","kid":"test","typ":"JWT"}`))
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		m.t.Fatal(err)
+	}
+	signingInput := header + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, m.signer, crypto.SHA256, digest[:])
+	if err != nil {
+		m.t.Fatal(err)
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func TestLoginFlow(t *testing.T) {
+	mock := newMockProvider(t)
+	provider := New(mock.config())
+	ctx := context.Background()
+	verifier := NewVerifier()
+
+	authURL, err := provider.AuthCodeURL(ctx, "state1", "nonce1", verifier)
+	if err != nil {
+		t.Fatal("unexpected error happened:", err)
+	}
+	if !strings.HasPrefix(authURL, mock.server.URL+"/authorize?") {
+		t.Fatalf("expected the authorization endpoint, but got %q", authURL)
+	}
+	code := mock.authorize(authURL)
+
+	claims, err := provider.Exchange(ctx, code, verifier, "nonce1")
+	if err != nil {
+		t.Fatal("unexpected error happened:", err)
+	}
+	expected := Claims{
+		Subject:           "student-42",
+		Email:             "jane@school.edu",
+		EmailVerified:     true,
+		Name:              "Jane Doe",
+		PreferredUsername: "jane",
+	}
+	if claims != expected {
+		t.Errorf("expected claims %+v, but got %+v", expected, claims)
+	}
+}
+
+func TestExchangeRejectsWrongVerifier(t *testing.T) {
+	mock := newMockProvider(t)
+	provider := New(mock.config())
+	ctx := context.Background()
+
+	authURL, err := provider.AuthCodeURL(ctx, "state1", "nonce1", NewVerifier())
+	if err != nil {
+		t.Fatal("unexpected error happened:", err)
+	}
+	code := mock.authorize(authURL)
+
+	_, err = provider.Exchange(ctx, code, NewVerifier(), "nonce1")
+	if err == nil {
+		t.Fatal("expected the exchange to fail with another verifier")
+	}
+}
+
+func TestExchangeRejectsWrongNonce(t *testing.T) {
+	mock := newMockProvider(t)
+	provider := New(mock.config())
+	ctx := context.Background()
+	verifier := NewVerifier()
+
+	authURL, err := provider.AuthCodeURL(ctx, "state1", "nonce1", verifier)
+	if err != nil {
+		t.Fatal("unexpected error happened:", err)
+	}
+	code := mock.authorize(authURL)
+
+	_, err = provider.Exchange(ctx, code, verifier, "nonce2")
+	if !errors.Is(err, ErrNonceMismatch) {
+		t.Errorf("expected %v, but got %v", ErrNonceMismatch, err)
+	}
+}
+
+func TestExchangeRejectsForeignSignature(t *testing.T) {
+	mock := newMockProvider(t)
+	provider := New(mock.config())
+	ctx := context.Background()
+	verifier := NewVerifier()
+
+	authURL, err := provider.AuthCodeURL(ctx, "state1", "nonce1", verifier)
+	if err != nil {
+		t.Fatal("unexpected error happened:", err)
+	}
+	code := mock.authorize(authURL)
+	// Sign with a key the JWKS does not publish.
+	mock.signer, err = rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = provider.Exchange(ctx, code, verifier, "nonce1")
+	if err == nil {
+		t.Fatal("expected an ID token signed with an unknown key to be rejected")
+	}
+}
+
+func TestUnverifiedEmail(t *testing.T) {
+	mock := newMockProvider(t)
+	mock.claims["email_verified"] = false
+	provider := New(mock.config())
+	ctx := context.Background()
+	verifier := NewVerifier()
+
+	authURL, err := provider.AuthCodeURL(ctx, "state1", "nonce1", verifier)
+	if err != nil {
+		t.Fatal("unexpected error happened:", err)
+	}
+	claims, err := provider.Exchange(ctx, mock.authorize(authURL), verifier, "nonce1")
+	if err != nil {
+		t.Fatal("unexpected error happened:", err)
+	}
+	if claims.EmailVerified {
+		t.Error("expected email_verified to be false")
+	}
+}
+
+func TestDiscoveryIsRetried(t *testing.T) {
+	mock := newMockProvider(t)
+	config := mock.config()
+	config.Issuer = mock.server.URL + "/missing"
+	provider := New(config)
+
+	_, err := provider.AuthCodeURL(context.Background(), "state", "nonce", NewVerifier())
+	if err == nil {
+		t.Fatal("expected discovery of an unknown issuer to fail")
+	}
+	provider.config.Issuer = mock.server.URL
+	_, err = provider.AuthCodeURL(context.Background(), "state", "nonce", NewVerifier())
+	if err != nil {
+		t.Fatal("expected discovery to be retried, but got:", err)
+	}
+}
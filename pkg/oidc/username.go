@@ -0,0 +1,61 @@
+package oidc
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	// maxUsernameLength matches the username column of the users table.
+	maxUsernameLength = 150
+	// maxUsernameSuffix bounds how many numbered variants of a username are
+	// tried before giving up.
+	maxUsernameSuffix = 100
+)
+
+var ErrNoFreeUsername = errors.New("oidc: no free username left for the identity")
+
+var usernameInvalidChars = regexp.MustCompile(`[^a-zA-Z0-9_.]`)
+
+// Username derives a username for a new account from the preferred username
+// or the local part of the email, made to pass utils.ValidateUsername. When
+// taken reports it is in use a number is appended, counting up from 2 until
+// a free one is found.
+func (c Claims) Username(taken func(username string) (bool, error)) (string, error) {
+	base := c.PreferredUsername
+	if len(base) == 0 {
+		base, _, _ = strings.Cut(c.Email, "@")
+	}
+	base = usernameInvalidChars.ReplaceAllString(base, "")
+	if len(base) == 0 || !isASCIILetter(base[0]) {
+		base = "user" + base
+	}
+	if len(base) < 3 {
+		base += "_sso"
+	}
+	for n := 1; n <= maxUsernameSuffix; n++ {
+		suffix := ""
+		if n > 1 {
+			suffix = strconv.Itoa(n)
+		}
+		username := base
+		if len(username)+len(suffix) > maxUsernameLength {
+			username = username[:maxUsernameLength-len(suffix)]
+		}
+		username += suffix
+		inUse, err := taken(username)
+		if err != nil {
+			return "", err
+		}
+		if !inUse {
+			return username, nil
+		}
+	}
+	return "", ErrNoFreeUsername
+}
+
+func isASCIILetter(c byte) bool {
+	return ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
@@ -0,0 +1,109 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestUsername(t *testing.T) {
+	testCases := []struct {
+		claims   Claims
+		taken    []string
+		expected string
+		desc     string
+	}{
+		{
+			claims:   Claims{PreferredUsername: "jane", Email: "jane@school.edu"},
+			expected: "jane",
+			desc:     "uses the preferred username",
+		},
+		{
+			claims:   Claims{Email: "j.doe@school.edu"},
+			expected: "j.doe",
+			desc:     "falls back to the local part of the email",
+		},
+		{
+			claims:   Claims{PreferredUsername: "42-jane!"},
+			expected: "user42jane",
+			desc:     "drops invalid characters and starts with a letter",
+		},
+		{
+			claims:   Claims{PreferredUsername: "jo"},
+			expected: "jo_sso",
+			desc:     "pads short usernames",
+		},
+		{
+			claims:   Claims{PreferredUsername: "jane"},
+			taken:    []string{"jane", "jane2"},
+			expected: "jane3",
+			desc:     "counts up until a username is free",
+		},
+		{
+			claims:   Claims{PreferredUsername: strings.Repeat("a", 200)},
+			taken:    []string{strings.Repeat("a", 150)},
+			expected: strings.Repeat("a", 149) + "2",
+			desc:     "keeps numbered usernames within the column",
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			username, err := tC.claims.Username(takenIn(tC.taken))
+			if err != nil {
+				t.Fatal("unexpected error happened:", err)
+			}
+			if username != tC.expected {
+				t.Errorf("expected %q, but got %q", tC.expected, username)
+			}
+		})
+	}
+}
+
+// TestUsernameCollision logs in at the provider as somebody whose preferred
+// username already belongs to a local account.
+func TestUsernameCollision(t *testing.T) {
+	mock := newMockProvider(t)
+	provider := New(mock.config())
+	ctx := context.Background()
+	verifier := NewVerifier()
+
+	authURL, err := provider.AuthCodeURL(ctx, "state1", "nonce1", verifier)
+	if err != nil {
+		t.Fatal("unexpected error happened:", err)
+	}
+	claims, err := provider.Exchange(ctx, mock.authorize(authURL), verifier, "nonce1")
+	if err != nil {
+		t.Fatal("unexpected error happened:", err)
+	}
+	username, err := claims.Username(takenIn([]string{"jane"}))
+	if err != nil {
+		t.Fatal("unexpected error happened:", err)
+	}
+	if username != "jane2" {
+		t.Errorf("expected %q, but got %q", "jane2", username)
+	}
+}
+
+func TestUsernameErrors(t *testing.T) {
+	lookupErr := errors.New("database is down")
+	_, err := Claims{PreferredUsername: "jane"}.Username(func(string) (bool, error) { return false, lookupErr })
+	if !errors.Is(err, lookupErr) {
+		t.Errorf("expected %v, but got %v", lookupErr, err)
+	}
+	_, err = Claims{PreferredUsername: "jane"}.Username(func(string) (bool, error) { return true, nil })
+	if !errors.Is(err, ErrNoFreeUsername) {
+		t.Errorf("expected %v, but got %v", ErrNoFreeUsername, err)
+	}
+}
+
+func takenIn(usernames []string) func(string) (bool, error) {
+	return func(username string) (bool, error) {
+		for _, taken := range usernames {
+			if strings.EqualFold(taken, username) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+}
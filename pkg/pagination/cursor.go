@@ -0,0 +1,65 @@
+package pagination
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor points at the last row of a page for keyset pagination. Value holds
+// the sort column of that row and ID breaks ties between equal values.
+type Cursor struct {
+	Value string
+	ID    uint
+}
+
+func NewTimeCursor(t time.Time, id uint) Cursor {
+	return Cursor{Value: t.UTC().Format(time.RFC3339Nano), ID: id}
+}
+
+func (c Cursor) Time() (time.Time, error) {
+	t, err := time.Parse(time.RFC3339Nano, c.Value)
+	if err != nil {
+		return time.Time{}, ErrInvalidCursor
+	}
+	return t, nil
+}
+
+// NewRankCursor points at a row of results sorted by relevance. The rank is
+// kept exactly so the next page starts right after it.
+func NewRankCursor(rank float32, id uint) Cursor {
+	return Cursor{Value: strconv.FormatFloat(float64(rank), 'g', -1, 32), ID: id}
+}
+
+func (c Cursor) Rank() (float32, error) {
+	rank, err := strconv.ParseFloat(c.Value, 32)
+	if err != nil {
+		return 0, ErrInvalidCursor
+	}
+	return float32(rank), nil
+}
+
+func Encode(c Cursor) string {
+	raw := strconv.FormatUint(uint64(c.ID), 10) + ":" + c.Value
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func Decode(token string) (Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+	id, value, found := strings.Cut(string(raw), ":")
+	if !found {
+		return Cursor{}, ErrInvalidCursor
+	}
+	parsedID, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+	return Cursor{Value: value, ID: uint(parsedID)}, nil
+}
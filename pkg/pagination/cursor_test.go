@@ -0,0 +1,77 @@
+package pagination
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	testCases := []struct {
+		cursor Cursor
+		desc   string
+	}{
+		{
+			cursor: NewTimeCursor(time.Date(2024, 8, 26, 10, 30, 0, 123456000, time.UTC), 42),
+			desc:   "Time Cursor",
+		},
+		{
+			cursor: Cursor{Value: "Go: Best Practices", ID: 7},
+			desc:   "Value With Separator",
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			decoded, err := Decode(Encode(tC.cursor))
+			if err != nil {
+				t.Fatal("unexpected error happened:", err)
+			}
+			if decoded != tC.cursor {
+				t.Errorf("expected cursor to be %+v, but got %+v", tC.cursor, decoded)
+			}
+		})
+	}
+}
+
+func TestTimeCursor(t *testing.T) {
+	created := time.Date(2024, 8, 26, 10, 30, 0, 123456000, time.FixedZone("Tehran", 12600))
+	cursor, err := Decode(Encode(NewTimeCursor(created, 1)))
+	if err != nil {
+		t.Fatal("unexpected error happened:", err)
+	}
+	decoded, err := cursor.Time()
+	if err != nil {
+		t.Fatal("unexpected error happened:", err)
+	}
+	if !decoded.Equal(created) {
+		t.Errorf("expected time to be %v, but got %v", created, decoded)
+	}
+}
+
+func TestRankCursor(t *testing.T) {
+	for _, rank := range []float32{0, 0.1, 0.030396355, 1.5e-7} {
+		cursor, err := Decode(Encode(NewRankCursor(rank, 3)))
+		if err != nil {
+			t.Fatal("unexpected error happened:", err)
+		}
+		decoded, err := cursor.Rank()
+		if err != nil {
+			t.Fatal("unexpected error happened:", err)
+		}
+		if decoded != rank {
+			t.Errorf("expected rank to be %v, but got %v", rank, decoded)
+		}
+	}
+	_, err := Cursor{Value: "2024-08-26T10:30:00Z", ID: 1}.Rank()
+	if err != ErrInvalidCursor {
+		t.Errorf("expected a time cursor to be rejected, but got %v", err)
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	for _, token := range []string{"%%%", "bm8tc2VwYXJhdG9y", "YWJjOnZhbHVl"} {
+		_, err := Decode(token)
+		if err != ErrInvalidCursor {
+			t.Errorf("expected %q to be rejected, but got %v", token, err)
+		}
+	}
+}
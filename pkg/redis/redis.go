@@ -36,6 +36,74 @@ func (r *Redis) Set(ctx context.Context, prefix string, expiration time.Duration
 	return nil
 }
 
+// SetNX stores value under key only when the key does not exist yet and
+// reports whether it was stored.
+func (r *Redis) SetNX(ctx context.Context, prefix string, expiration time.Duration, key string, value any) (bool, error) {
+	primeKey := createKey(prefix, key)
+	return r.client.SetNX(ctx, primeKey, value, expiration).Result()
+}
+
+// Replace overwrites the value of an existing key and keeps its TTL. It fails
+// when the key does not exist.
+func (r *Redis) Replace(ctx context.Context, prefix string, key string, value any) error {
+	primeKey := createKey(prefix, key)
+	return r.client.SetArgs(ctx, primeKey, value, redis.SetArgs{Mode: "XX", KeepTTL: true}).Err()
+}
+
+// Incr increments the counter stored under key and returns the new value. The
+// expiration is only applied when the counter is created, so it measures a
+// fixed window from the first increment.
+func (r *Redis) Incr(ctx context.Context, prefix string, key string, expiration time.Duration) (int64, error) {
+	primeKey := createKey(prefix, key)
+	count, err := r.client.Incr(ctx, primeKey).Result()
+	if err != nil {
+		return 0, err
+	}
+	if count == 1 {
+		err = r.client.Expire(ctx, primeKey, expiration).Err()
+		if err != nil {
+			return 0, err
+		}
+	}
+	return count, nil
+}
+
+// TTL returns how long key has left to live, or zero when it does not exist
+// or never expires.
+func (r *Redis) TTL(ctx context.Context, prefix string, key string) time.Duration {
+	primeKey := createKey(prefix, key)
+	ttl, err := r.client.TTL(ctx, primeKey).Result()
+	if err != nil || ttl < 0 {
+		return 0
+	}
+	return ttl
+}
+
+// AddToSet adds member to the set stored under key and pushes the expiration
+// of the whole set to the given duration.
+func (r *Redis) AddToSet(ctx context.Context, prefix string, key string, member string, expiration time.Duration) error {
+	primeKey := createKey(prefix, key)
+	pipe := r.client.TxPipeline()
+	pipe.SAdd(ctx, primeKey, member)
+	pipe.Expire(ctx, primeKey, expiration)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (r *Redis) SetMembers(ctx context.Context, prefix string, key string) ([]string, error) {
+	primeKey := createKey(prefix, key)
+	return r.client.SMembers(ctx, primeKey).Result()
+}
+
+func (r *Redis) RemoveFromSet(ctx context.Context, prefix string, key string, members ...string) error {
+	primeKey := createKey(prefix, key)
+	values := make([]any, 0, len(members))
+	for _, member := range members {
+		values = append(values, member)
+	}
+	return r.client.SRem(ctx, primeKey, values...).Err()
+}
+
 func (r *Redis) Validate(ctx context.Context, prefix string, key string, value string) bool {
 	primeKey := createKey(prefix, key)
 	result, err := r.client.Get(ctx, primeKey).Result()
@@ -47,18 +115,31 @@ func (r *Redis) Validate(ctx context.Context, prefix string, key string, value s
 	return false
 }
 
+// Remove deletes the value stored under key. A key that does not exist, or
+// expired in the meantime, counts as removed.
 func (r *Redis) Remove(ctx context.Context, prefix string, key string) error {
 	primeKey := createKey(prefix, key)
 
-	_, err := r.client.Get(ctx, primeKey).Result()
-	if err != nil {
-		return err
-	}
-	err = r.client.Del(ctx, primeKey).Err()
+	return r.client.Del(ctx, primeKey).Err()
+}
+
+// Pop returns the value stored under key and deletes it in one step, so a
+// value can only be consumed once.
+func (r *Redis) Pop(ctx context.Context, prefix string, key string) (bool, []byte) {
+	primeKey := createKey(prefix, key)
+	result, err := r.client.GetDel(ctx, primeKey).Bytes()
 	if err != nil {
-		return err
+		return false, nil
 	}
-	return nil
+	return true, result
+}
+
+func (r *Redis) Publish(ctx context.Context, channel string, message any) error {
+	return r.client.Publish(ctx, channel, message).Err()
+}
+
+func (r *Redis) Subscribe(ctx context.Context, channels ...string) *redis.PubSub {
+	return r.client.Subscribe(ctx, channels...)
 }
 
 func createKey(prefix string, key string) string {
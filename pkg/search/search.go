@@ -0,0 +1,106 @@
+// Package search turns what people type in a search box into Postgres full
+// text queries and the highlighted snippets Postgres returns into HTML.
+package search
+
+import (
+	"html"
+	"html/template"
+	"strings"
+	"unicode"
+)
+
+// maxTerms bounds how many words of a search are looked up.
+const maxTerms = 16
+
+// Markers wrapped around the matches in snippets, see HeadlineOptions. They
+// are private use characters, which hardly ever show up in what is searched.
+const (
+	StartSel = "\ue000"
+	StopSel  = "\ue001"
+)
+
+// HeadlineOptions are the ts_headline options snippets are cut with, pass the
+// result to Highlight.
+const HeadlineOptions = "StartSel=" + StartSel + ", StopSel=" + StopSel + ", MinWords=10, MaxWords=30, MaxFragments=2, FragmentDelimiter=\" … \""
+
+// Query builds a to_tsquery expression from a search. Every word matches
+// itself and the words it starts, so results show up while it is typed. Words
+// are all required, "OR" between two words accepts either and a leading "-"
+// excludes a word. Anything but letters and digits separates words, which
+// keeps the tsquery syntax out of reach. Query returns "" when nothing is left
+// to look for.
+func Query(input string) string {
+	var b strings.Builder
+	terms, positive := 0, false
+	or := false
+	for _, token := range strings.Fields(input) {
+		if terms == maxTerms {
+			break
+		}
+		if strings.EqualFold(token, "or") {
+			or = terms > 0
+			continue
+		}
+		negate := len(token) > 1 && token[0] == '-'
+		if negate {
+			token = token[1:]
+		}
+		words := strings.FieldsFunc(token, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+		if len(words) == 0 {
+			continue
+		}
+		term := strings.Join(words, ":* & ") + ":*"
+		if len(words) > 1 {
+			term = "(" + term + ")"
+		}
+		if negate {
+			term = "!" + term
+		} else {
+			positive = true
+		}
+		if terms > 0 {
+			if or {
+				b.WriteString(" | ")
+			} else {
+				b.WriteString(" & ")
+			}
+		}
+		b.WriteString(term)
+		terms++
+		or = false
+	}
+	if !positive {
+		return ""
+	}
+	return b.String()
+}
+
+// Highlight escapes a snippet cut with HeadlineOptions and marks its matches
+// with <mark>. Markers that do not pair up are dropped.
+func Highlight(snippet string) template.HTML {
+	var b strings.Builder
+	open := false
+	for {
+		i := strings.IndexAny(snippet, StartSel+StopSel)
+		if i < 0 {
+			break
+		}
+		b.WriteString(html.EscapeString(snippet[:i]))
+		switch {
+		case strings.HasPrefix(snippet[i:], StartSel) && !open:
+			b.WriteString("<mark>")
+			open = true
+		case strings.HasPrefix(snippet[i:], StopSel) && open:
+			b.WriteString("</mark>")
+			open = false
+		}
+		snippet = snippet[i+len(StartSel):]
+	}
+	b.WriteString(html.EscapeString(snippet))
+	if open {
+		b.WriteString("</mark>")
+	}
+	return template.HTML(b.String())
+}
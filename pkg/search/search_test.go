@@ -0,0 +1,105 @@
+package search
+
+import (
+	"html/template"
+	"testing"
+)
+
+func TestQuery(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+		desc     string
+	}{
+		{
+			input:    "",
+			expected: "",
+			desc:     "Empty Search",
+		},
+		{
+			input:    "go channels",
+			expected: "go:* & channels:*",
+			desc:     "Every Word Is Required",
+		},
+		{
+			input:    "rust OR go",
+			expected: "rust:* | go:*",
+			desc:     "Either Word",
+		},
+		{
+			input:    "OR python or",
+			expected: "python:*",
+			desc:     "Dangling Or",
+		},
+		{
+			input:    "python -django",
+			expected: "python:* & !django:*",
+			desc:     "Excluded Word",
+		},
+		{
+			input:    "-django",
+			expected: "",
+			desc:     "Nothing To Look For",
+		},
+		{
+			input:    "node.js -c++",
+			expected: "(node:* & js:*) & !c:*",
+			desc:     "Punctuation Splits Words",
+		},
+		{
+			input:    "a:* | !b & (c) <-> 'd'",
+			expected: "a:* & b:* & c:* & d:*",
+			desc:     "Query Syntax Is Ignored",
+		},
+		{
+			input:    "برنامه نویسی",
+			expected: "برنامه:* & نویسی:*",
+			desc:     "Other Scripts",
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			output := Query(tC.input)
+			if output != tC.expected {
+				t.Errorf("expected %q, but got %q", tC.expected, output)
+			}
+		})
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	testCases := []struct {
+		snippet  string
+		expected template.HTML
+		desc     string
+	}{
+		{
+			snippet:  "learning " + StartSel + "go" + StopSel + " together",
+			expected: "learning <mark>go</mark> together",
+			desc:     "Marks Matches",
+		},
+		{
+			snippet:  "<script>" + StartSel + "alert" + StopSel + "(1)</script>",
+			expected: "&lt;script&gt;<mark>alert</mark>(1)&lt;/script&gt;",
+			desc:     "Escapes Text",
+		},
+		{
+			snippet:  StopSel + "a " + StartSel + StartSel + "b",
+			expected: "a <mark>b</mark>",
+			desc:     "Unpaired Markers",
+		},
+		{
+			snippet:  "no matches",
+			expected: "no matches",
+			desc:     "Plain Text",
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			output := Highlight(tC.snippet)
+			if output != tC.expected {
+				t.Errorf("expected %q, but got %q", tC.expected, output)
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path"
+	"strings"
+)
+
+var (
+	ErrNotFound   = errors.New("storage: file not found")
+	ErrInvalidKey = errors.New("storage: invalid key")
+)
+
+// Storage keeps uploaded files under slash separated keys such as
+// "attachments/<uuid>.png". Local writes them to a directory on disk, S3 to a
+// bucket of any S3 compatible service.
+type Storage interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Open returns ErrNotFound when nothing is stored under key.
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete does not fail when nothing is stored under key.
+	Delete(ctx context.Context, key string) error
+}
+
+// checkKey rejects keys that are not relative paths in canonical form, they
+// could reach out of the directory files are kept in.
+func checkKey(key string) error {
+	if len(key) == 0 || strings.ContainsAny(key, "\\\x00") || path.IsAbs(key) || path.Clean(key) != key ||
+		key == ".." || strings.HasPrefix(key, "../") {
+		return ErrInvalidKey
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckKey(t *testing.T) {
+	testCases := []struct {
+		key   string
+		valid bool
+	}{
+		{key: "attachments/a.png", valid: true},
+		{key: "a.png", valid: true},
+		{key: "", valid: false},
+		{key: "/etc/passwd", valid: false},
+		{key: "../secret", valid: false},
+		{key: "..", valid: false},
+		{key: "attachments/../../secret", valid: false},
+		{key: "attachments//a.png", valid: false},
+		{key: "attachments\\a.png", valid: false},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.key, func(t *testing.T) {
+			err := checkKey(tC.key)
+			if tC.valid && err != nil {
+				t.Errorf("expected %q to be valid, but got %v", tC.key, err)
+			}
+			if !tC.valid && !errors.Is(err, ErrInvalidKey) {
+				t.Errorf("expected %q to be invalid, but got %v", tC.key, err)
+			}
+		})
+	}
+}
+
+func TestLocal(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewLocal(filepath.Join(dir, "uploads"))
+	if err != nil {
+		t.Fatal("unexpected error happened:", err)
+	}
+	testStorage(t, s)
+	entries, err := os.ReadDir(filepath.Join(dir, "uploads", "attachments"))
+	if err != nil {
+		t.Fatal("unexpected error happened:", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("expected no files left behind, but got %d", len(entries))
+	}
+}
+
+// TestS3 runs against the S3 compatible service at STORAGE_TEST_S3_ENDPOINT,
+// a MinIO started with
+//
+//	docker run -p 9000:9000 minio/minio server /data
+//
+// and STORAGE_TEST_S3_ENDPOINT=localhost:9000 does.
+func TestS3(t *testing.T) {
+	endpoint := os.Getenv("STORAGE_TEST_S3_ENDPOINT")
+	if len(endpoint) == 0 {
+		t.Skip("STORAGE_TEST_S3_ENDPOINT is not set")
+	}
+	s, err := NewS3(context.Background(), S3Config{
+		Endpoint:        endpoint,
+		Bucket:          "studybud-test",
+		AccessKeyID:     envOr("STORAGE_TEST_S3_ACCESS_KEY_ID", "minioadmin"),
+		SecretAccessKey: envOr("STORAGE_TEST_S3_SECRET_ACCESS_KEY", "minioadmin"),
+	})
+	if err != nil {
+		t.Fatal("unexpected error happened:", err)
+	}
+	testStorage(t, s)
+}
+
+// testStorage puts a file, reads it back and deletes it.
+func testStorage(t *testing.T, s Storage) {
+	ctx := context.Background()
+	const key = "attachments/notes.txt"
+	content := "fenced code and some notes"
+	err := s.Put(ctx, key, strings.NewReader(content), int64(len(content)), "text/plain; charset=utf-8")
+	if err != nil {
+		t.Fatal("unexpected error happened:", err)
+	}
+	file, err := s.Open(ctx, key)
+	if err != nil {
+		t.Fatal("unexpected error happened:", err)
+	}
+	data, err := io.ReadAll(file)
+	file.Close()
+	if err != nil {
+		t.Fatal("unexpected error happened:", err)
+	}
+	if string(data) != content {
+		t.Errorf("expected %q, but got %q", content, data)
+	}
+	err = s.Delete(ctx, key)
+	if err != nil {
+		t.Fatal("unexpected error happened:", err)
+	}
+	_, err = s.Open(ctx, key)
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound after delete, but got %v", err)
+	}
+	err = s.Delete(ctx, key)
+	if err != nil {
+		t.Errorf("expected deleting a missing file to succeed, but got %v", err)
+	}
+	err = s.Put(ctx, "../escape.txt", strings.NewReader(content), int64(len(content)), "text/plain")
+	if !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("expected ErrInvalidKey, but got %v", err)
+	}
+}
+
+func envOr(name, fallback string) string {
+	if value := os.Getenv(name); len(value) != 0 {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,69 @@
+package thumbnail
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// maxPixels keeps images that claim huge dimensions from being decoded, a
+// few kilobytes of PNG can describe gigabytes of pixels.
+const maxPixels = 50_000_000
+
+var ErrTooLarge = errors.New("thumbnail: image is too large")
+
+// Generate scales the PNG, JPEG, GIF or WebP image in data down to fit a size
+// by size square, keeping its aspect ratio. Smaller images keep their size.
+// The thumbnail is a JPEG for JPEG images and a PNG otherwise, to keep
+// transparency, its content type is returned along.
+func Generate(data []byte, size int) ([]byte, string, error) {
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", err
+	}
+	if config.Width*config.Height > maxPixels {
+		return nil, "", ErrTooLarge
+	}
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", err
+	}
+	bounds := fit(src.Bounds().Dx(), src.Bounds().Dy(), size)
+	dst := image.NewRGBA(bounds)
+	draw.CatmullRom.Scale(dst, bounds, src, src.Bounds(), draw.Src, nil)
+
+	var buf bytes.Buffer
+	if format == "jpeg" {
+		err = jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 85})
+		return buf.Bytes(), "image/jpeg", err
+	}
+	err = png.Encode(&buf, dst)
+	return buf.Bytes(), "image/png", err
+}
+
+// fit returns the bounds of a width by height image scaled down to fit a
+// size by size square.
+func fit(width, height, size int) image.Rectangle {
+	if width <= size && height <= size {
+		return image.Rect(0, 0, width, height)
+	}
+	if width >= height {
+		return image.Rect(0, 0, size, max(1, height*size/width))
+	}
+	return image.Rect(0, 0, max(1, width*size/height), size)
+}
+
+// IsImage tells whether contentType is one of the formats Generate reads.
+func IsImage(contentType string) bool {
+	switch contentType {
+	case "image/png", "image/jpeg", "image/gif", "image/webp":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,98 @@
+package thumbnail
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	testCases := []struct {
+		width       int
+		height      int
+		encode      func(*bytes.Buffer, image.Image) error
+		contentType string
+		expected    image.Point
+		desc        string
+	}{
+		{
+			width:       800,
+			height:      400,
+			encode:      func(buf *bytes.Buffer, img image.Image) error { return png.Encode(buf, img) },
+			contentType: "image/png",
+			expected:    image.Pt(320, 160),
+			desc:        "Wide PNG",
+		},
+		{
+			width:       300,
+			height:      900,
+			encode:      func(buf *bytes.Buffer, img image.Image) error { return jpeg.Encode(buf, img, nil) },
+			contentType: "image/jpeg",
+			expected:    image.Pt(106, 320),
+			desc:        "Tall JPEG",
+		},
+		{
+			width:       100,
+			height:      50,
+			encode:      func(buf *bytes.Buffer, img image.Image) error { return png.Encode(buf, img) },
+			contentType: "image/png",
+			expected:    image.Pt(100, 50),
+			desc:        "Small Image Keeps Its Size",
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			src := image.NewRGBA(image.Rect(0, 0, tC.width, tC.height))
+			src.Set(0, 0, color.RGBA{R: 255, A: 255})
+			var buf bytes.Buffer
+			if err := tC.encode(&buf, src); err != nil {
+				t.Fatal("unexpected error happened:", err)
+			}
+			data, contentType, err := Generate(buf.Bytes(), 320)
+			if err != nil {
+				t.Fatal("unexpected error happened:", err)
+			}
+			if contentType != tC.contentType {
+				t.Errorf("expected %s, but got %s", tC.contentType, contentType)
+			}
+			thumb, _, err := image.DecodeConfig(bytes.NewReader(data))
+			if err != nil {
+				t.Fatal("unexpected error happened:", err)
+			}
+			if got := image.Pt(thumb.Width, thumb.Height); got != tC.expected {
+				t.Errorf("expected %v, but got %v", tC.expected, got)
+			}
+		})
+	}
+}
+
+func TestGenerateRejectsNonImages(t *testing.T) {
+	_, _, err := Generate([]byte("%PDF-1.7 not an image"), 320)
+	if err == nil {
+		t.Error("expected an error, but got nil")
+	}
+}
+
+func TestGenerateRejectsHugeImages(t *testing.T) {
+	// A 1x1 PNG whose header is rewritten to claim 100000x100000 pixels.
+	var buf bytes.Buffer
+	img := image.NewGray(image.Rect(0, 0, 1, 1))
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal("unexpected error happened:", err)
+	}
+	data := buf.Bytes()
+	// Width and height open the IHDR chunk, right after the 8 byte signature
+	// and the chunk's length and type. Its checksum follows the chunk.
+	copy(data[16:20], []byte{0x00, 0x01, 0x86, 0xa0})
+	copy(data[20:24], []byte{0x00, 0x01, 0x86, 0xa0})
+	binary.BigEndian.PutUint32(data[29:33], crc32.ChecksumIEEE(data[12:29]))
+	_, _, err := Generate(data, 320)
+	if err != ErrTooLarge {
+		t.Errorf("expected ErrTooLarge, but got %v", err)
+	}
+}
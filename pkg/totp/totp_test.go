@@ -0,0 +1,115 @@
+package totp
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA1 key of the RFC 6238 test vectors, "12345678901234567890".
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestCode(t *testing.T) {
+	testCases := []struct {
+		unix     int64
+		expected string
+		desc     string
+	}{
+		{unix: 59, expected: "287082", desc: "T=59"},
+		{unix: 1111111109, expected: "081804", desc: "T=1111111109"},
+		{unix: 1111111111, expected: "050471", desc: "T=1111111111"},
+		{unix: 1234567890, expected: "005924", desc: "T=1234567890"},
+		{unix: 2000000000, expected: "279037", desc: "T=2000000000"},
+		{unix: 20000000000, expected: "353130", desc: "T=20000000000"},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			output, err := Code(rfcSecret, time.Unix(tC.unix, 0))
+			if err != nil {
+				t.Fatal("unexpected error happened:", err)
+			}
+			if output != tC.expected {
+				t.Errorf("expected %s, but got %s", tC.expected, output)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	current, _ := Code(rfcSecret, now)
+	previous, _ := Code(rfcSecret, now.Add(-Period))
+	tooOld, _ := Code(rfcSecret, now.Add(-3*Period))
+	testCases := []struct {
+		code     string
+		expected bool
+		desc     string
+	}{
+		{code: current, expected: true, desc: "current code"},
+		{code: previous, expected: true, desc: "previous step within skew"},
+		{code: current[:3] + " " + current[3:], expected: true, desc: "code with a space"},
+		{code: tooOld, expected: false, desc: "outside skew"},
+		{code: "12345", expected: false, desc: "too short"},
+		{code: "abcdef", expected: false, desc: "not digits"},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			_, ok := Validate(rfcSecret, tC.code, now)
+			if ok != tC.expected {
+				t.Errorf("expected %v, but got %v", tC.expected, ok)
+			}
+		})
+	}
+}
+
+func TestValidateStep(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	previous, _ := Code(rfcSecret, now.Add(-Period))
+	step, ok := Validate(rfcSecret, previous, now)
+	if !ok {
+		t.Fatal("expected previous code to be valid")
+	}
+	if step != counter(now)-1 {
+		t.Errorf("expected step %d, but got %d", counter(now)-1, step)
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatal("unexpected error happened:", err)
+	}
+	key, err := decodeSecret(secret)
+	if err != nil {
+		t.Fatal("generated secret does not decode:", err)
+	}
+	if len(key) != secretSize {
+		t.Errorf("expected %d bytes, but got %d", secretSize, len(key))
+	}
+	if _, err := Code(secret, time.Now()); err != nil {
+		t.Error("unexpected error happened:", err)
+	}
+}
+
+func TestInvalidSecret(t *testing.T) {
+	_, err := Code("not base32!", time.Now())
+	if err != ErrInvalidSecret {
+		t.Errorf("expected %v, but got %v", ErrInvalidSecret, err)
+	}
+}
+
+func TestURL(t *testing.T) {
+	output := URL("StudyBud", "jane@example.com", rfcSecret)
+	expectedParts := []string{
+		"otpauth://totp/StudyBud:jane@example.com?",
+		"secret=" + rfcSecret,
+		"issuer=StudyBud",
+		"digits=6",
+		"period=30",
+	}
+	for _, part := range expectedParts {
+		if !strings.Contains(output, part) {
+			t.Errorf("expected %s to contain %s", output, part)
+		}
+	}
+}
@@ -3,7 +3,9 @@ package utils
 import (
 	"errors"
 	"fmt"
+	"path/filepath"
 	"regexp"
+	"slices"
 	"strings"
 	"time"
 	"unicode"
@@ -123,3 +125,75 @@ func FormatDuration(d time.Duration) string {
 	seconds := int(d.Seconds())
 	return fmt.Sprintf("%d seconds", seconds)
 }
+
+// FormatSize spells out a number of bytes in the largest unit that keeps it
+// at least one, with one decimal past kilobytes.
+func FormatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	value := float64(size) / unit
+	units := []string{"KB", "MB", "GB", "TB"}
+	i := 0
+	for value >= unit && i < len(units)-1 {
+		value /= unit
+		i++
+	}
+	if i == 0 {
+		return fmt.Sprintf("%.0f %s", value, units[i])
+	}
+	return fmt.Sprintf("%.1f %s", value, units[i])
+}
+
+// CleanFilename makes a client supplied file name safe to show and to send
+// back in headers: any directory part is dropped, control characters are
+// removed and it is cut to 255 bytes keeping its extension. An empty result
+// becomes "file".
+func CleanFilename(name string) string {
+	name = name[strings.LastIndexAny(name, `/\`)+1:]
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || r == unicode.ReplacementChar {
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.TrimSpace(name)
+	if name == "" || name == "." || name == ".." {
+		return "file"
+	}
+	for len(name) > 255 {
+		ext := filepath.Ext(name)
+		if len(ext) > 16 {
+			ext = ""
+		}
+		base := []rune(strings.TrimSuffix(name, ext))
+		name = string(base[:len(base)-1]) + ext
+	}
+	return name
+}
+
+// Truncate shortens s to at most n runes, marking the cut with an ellipsis.
+func Truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n]) + "…"
+}
+
+var mentionPattern = regexp.MustCompile(`(?:^|[^a-zA-Z0-9_.@])@([a-zA-Z][a-zA-Z0-9_.]*)`)
+
+// ParseMentions returns the usernames mentioned as "@username" in body, each
+// once and in order. Email addresses are not mentions, and a dot ending a
+// sentence is not part of the username.
+func ParseMentions(body string) []string {
+	var usernames []string
+	for _, match := range mentionPattern.FindAllStringSubmatch(body, -1) {
+		username := strings.TrimRight(match[1], ".")
+		if ValidateUsername(username) == nil && !slices.Contains(usernames, username) {
+			usernames = append(usernames, username)
+		}
+	}
+	return usernames
+}
@@ -1,6 +1,8 @@
 package utils
 
 import (
+	"slices"
+	"strings"
 	"testing"
 	"time"
 )
@@ -238,3 +240,181 @@ func TestFormatDuration(t *testing.T) {
 		})
 	}
 }
+
+func TestFormatSize(t *testing.T) {
+	testCases := []struct {
+		size     int64
+		expected string
+		desc     string
+	}{
+		{
+			size:     0,
+			expected: "0 B",
+			desc:     "empty",
+		},
+		{
+			size:     1023,
+			expected: "1023 B",
+			desc:     "bytes",
+		},
+		{
+			size:     1536,
+			expected: "2 KB",
+			desc:     "kilobytes",
+		},
+		{
+			size:     5 * 1024 * 1024,
+			expected: "5.0 MB",
+			desc:     "megabytes",
+		},
+		{
+			size:     3 * 1024 * 1024 * 1024 / 2,
+			expected: "1.5 GB",
+			desc:     "gigabytes",
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			output := FormatSize(tC.size)
+			if output != tC.expected {
+				t.Errorf("expected %s, but got %s", tC.expected, output)
+			}
+		})
+	}
+}
+
+func TestCleanFilename(t *testing.T) {
+	testCases := []struct {
+		name     string
+		expected string
+		desc     string
+	}{
+		{
+			name:     "notes.pdf",
+			expected: "notes.pdf",
+			desc:     "plain name",
+		},
+		{
+			name:     "../../etc/passwd",
+			expected: "passwd",
+			desc:     "relative path",
+		},
+		{
+			name:     `C:\Users\elyar\main.go`,
+			expected: "main.go",
+			desc:     "windows path",
+		},
+		{
+			name:     "bad\r\nname.txt",
+			expected: "badname.txt",
+			desc:     "control characters",
+		},
+		{
+			name:     "..",
+			expected: "file",
+			desc:     "dot dot",
+		},
+		{
+			name:     "  ",
+			expected: "file",
+			desc:     "blank",
+		},
+		{
+			name:     strings.Repeat("a", 300) + ".png",
+			expected: strings.Repeat("a", 251) + ".png",
+			desc:     "too long",
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			output := CleanFilename(tC.name)
+			if output != tC.expected {
+				t.Errorf("expected %s, but got %s", tC.expected, output)
+			}
+		})
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	testCases := []struct {
+		input    string
+		n        int
+		expected string
+		desc     string
+	}{
+		{
+			input:    "hello",
+			n:        10,
+			expected: "hello",
+			desc:     "shorter than limit",
+		},
+		{
+			input:    "hello",
+			n:        5,
+			expected: "hello",
+			desc:     "exactly the limit",
+		},
+		{
+			input:    "hello world",
+			n:        5,
+			expected: "hello…",
+			desc:     "longer than limit",
+		},
+		{
+			input:    "سلام دنیا",
+			n:        4,
+			expected: "سلام…",
+			desc:     "multi-byte runes",
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			output := Truncate(tC.input, tC.n)
+			if output != tC.expected {
+				t.Errorf("expected %s, but got %s", tC.expected, output)
+			}
+		})
+	}
+}
+
+func TestParseMentions(t *testing.T) {
+	testCases := []struct {
+		body     string
+		expected []string
+		desc     string
+	}{
+		{
+			body:     "no mentions here",
+			expected: nil,
+			desc:     "no mentions",
+		},
+		{
+			body:     "@elyar can you check this, @sara.k?",
+			expected: []string{"elyar", "sara.k"},
+			desc:     "mentions at the start and in the middle",
+		},
+		{
+			body:     "thanks @elyar. and again @elyar",
+			expected: []string{"elyar"},
+			desc:     "trailing dot and repeated mention",
+		},
+		{
+			body:     "mail elyar@email.com or @me",
+			expected: nil,
+			desc:     "email address and too short username",
+		},
+		{
+			body:     "@@elyar @1abc",
+			expected: nil,
+			desc:     "invalid usernames",
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			output := ParseMentions(tC.body)
+			if !slices.Equal(output, tC.expected) {
+				t.Errorf("expected %v, but got %v", tC.expected, output)
+			}
+		})
+	}
+}
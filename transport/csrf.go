@@ -0,0 +1,112 @@
+package transport
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+	"strings"
+)
+
+// CSRF protection uses the double submit cookie pattern: every browser gets a
+// random token in an HttpOnly cookie, pages echo it in a hidden form field (or
+// the X-CSRF-Token header for scripts) and unsafe requests are only let
+// through when both copies match. A cross site page can make the browser send
+// the cookie, but it cannot read it to fill in the field.
+const (
+	CSRFCookieName = "csrf_token"
+	CSRFFieldName  = "csrf_token"
+	CSRFHeaderName = "X-CSRF-Token"
+)
+
+type csrfCtxKey struct{}
+
+// CSRF returns a middleware that issues the token cookie and rejects unsafe
+// requests whose token is missing or wrong by calling onFailure. Requests
+// authenticated with an "Authorization: Bearer" header are exempt, browsers
+// never attach that header on their own. So are requests under apiPrefix
+// that come without the sessionCookie: nothing but a token they hold
+// themselves can authenticate them, which lets clients without cookies log
+// in through the API.
+func CSRF(apiPrefix, sessionCookie string, onFailure http.HandlerFunc) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token := ""
+			if cookie, err := r.Cookie(CSRFCookieName); err == nil && len(cookie.Value) != 0 {
+				token = cookie.Value
+			}
+			if !IsSafeMethod(r.Method) && !hasBearerToken(r) && !cookielessAPIRequest(r, apiPrefix, sessionCookie) {
+				if token == "" || !validCSRFToken(r, token) {
+					onFailure(w, r)
+					return
+				}
+			}
+			if token == "" {
+				var err error
+				token, err = newCSRFToken()
+				if err != nil {
+					w.WriteHeader(http.StatusInternalServerError)
+					return
+				}
+				http.SetCookie(w, &http.Cookie{
+					Name:     CSRFCookieName,
+					Value:    token,
+					Path:     "/",
+					Secure:   true,
+					HttpOnly: true,
+					SameSite: http.SameSiteLaxMode,
+				})
+			}
+			ctx := context.WithValue(r.Context(), csrfCtxKey{}, token)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// CSRFToken returns the token to embed in the forms of the page answering r.
+func CSRFToken(r *http.Request) string {
+	token, _ := r.Context().Value(csrfCtxKey{}).(string)
+	return token
+}
+
+func validCSRFToken(r *http.Request, token string) bool {
+	submitted := r.Header.Get(CSRFHeaderName)
+	if submitted == "" {
+		submitted = r.PostFormValue(CSRFFieldName)
+	}
+	return subtle.ConstantTimeCompare([]byte(submitted), []byte(token)) == 1
+}
+
+func newCSRFToken() (string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// IsSafeMethod reports whether method only reads data.
+func IsSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
+
+// cookielessAPIRequest reports whether r goes to the API without the session
+// cookie a cross site page could make the browser send along.
+func cookielessAPIRequest(r *http.Request, apiPrefix, sessionCookie string) bool {
+	if r.URL.Path != apiPrefix && !strings.HasPrefix(r.URL.Path, apiPrefix+"/") {
+		return false
+	}
+	_, err := r.Cookie(sessionCookie)
+	return err != nil
+}
+
+func hasBearerToken(r *http.Request) bool {
+	scheme, _, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	return ok && strings.EqualFold(scheme, "Bearer")
+}
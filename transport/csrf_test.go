@@ -0,0 +1,124 @@
+package transport
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestCSRF(t *testing.T) {
+	const (
+		apiPrefix     = "/apis/v1"
+		sessionCookie = "session_token"
+		token         = "csrf-token-of-the-browser"
+	)
+	testCases := []struct {
+		method       string
+		path         string
+		contentType  string
+		body         string
+		cookies      []*http.Cookie
+		header       http.Header
+		expectedCode int
+		desc         string
+	}{
+		{
+			method:       http.MethodPost,
+			path:         "/apis/v1/auth/login",
+			contentType:  "application/json",
+			body:         `{"email":"jane@example.com","password":"secret"}`,
+			expectedCode: http.StatusOK,
+			desc:         "cookie-less JSON login goes through",
+		},
+		{
+			method:       http.MethodPost,
+			path:         "/apis/v1/rooms",
+			contentType:  "application/json",
+			body:         `{"name":"room"}`,
+			cookies:      []*http.Cookie{{Name: sessionCookie, Value: "session"}},
+			expectedCode: http.StatusForbidden,
+			desc:         "cookie-authenticated cross-site API post is rejected",
+		},
+		{
+			method:       http.MethodPost,
+			path:         "/apis/v1/rooms",
+			contentType:  "application/json",
+			body:         `{"name":"room"}`,
+			cookies:      []*http.Cookie{{Name: sessionCookie, Value: "session"}, {Name: CSRFCookieName, Value: token}},
+			header:       http.Header{CSRFHeaderName: []string{token}},
+			expectedCode: http.StatusOK,
+			desc:         "cookie-authenticated API post with the token goes through",
+		},
+		{
+			method:       http.MethodPost,
+			path:         "/apis/v1/rooms",
+			contentType:  "application/json",
+			body:         `{"name":"room"}`,
+			cookies:      []*http.Cookie{{Name: sessionCookie, Value: "session"}},
+			header:       http.Header{"Authorization": []string{"Bearer token"}},
+			expectedCode: http.StatusOK,
+			desc:         "bearer authenticated API post goes through",
+		},
+		{
+			method:       http.MethodPost,
+			path:         "/login",
+			contentType:  "application/x-www-form-urlencoded",
+			body:         url.Values{"email": {"jane@example.com"}}.Encode(),
+			expectedCode: http.StatusForbidden,
+			desc:         "cookie-less form post is rejected",
+		},
+		{
+			method:       http.MethodPost,
+			path:         "/room/1",
+			contentType:  "application/x-www-form-urlencoded",
+			body:         url.Values{"body": {"hello"}, CSRFFieldName: {"forged"}}.Encode(),
+			cookies:      []*http.Cookie{{Name: sessionCookie, Value: "session"}, {Name: CSRFCookieName, Value: token}},
+			expectedCode: http.StatusForbidden,
+			desc:         "cross-site form post with a wrong token is rejected",
+		},
+		{
+			method:       http.MethodPost,
+			path:         "/room/1",
+			contentType:  "application/x-www-form-urlencoded",
+			body:         url.Values{"body": {"hello"}, CSRFFieldName: {token}}.Encode(),
+			cookies:      []*http.Cookie{{Name: sessionCookie, Value: "session"}, {Name: CSRFCookieName, Value: token}},
+			expectedCode: http.StatusOK,
+			desc:         "form post with the token goes through",
+		},
+		{
+			method:       http.MethodGet,
+			path:         "/home",
+			cookies:      []*http.Cookie{{Name: sessionCookie, Value: "session"}},
+			expectedCode: http.StatusOK,
+			desc:         "safe method needs no token",
+		},
+	}
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	onFailure := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}
+	handler := CSRF(apiPrefix, sessionCookie, onFailure)(next)
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			r := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+			if tc.contentType != "" {
+				r.Header.Set("Content-Type", tc.contentType)
+			}
+			for name := range tc.header {
+				r.Header.Set(name, tc.header[name][0])
+			}
+			for _, cookie := range tc.cookies {
+				r.AddCookie(cookie)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			if w.Code != tc.expectedCode {
+				t.Errorf("expected status %d, but got %d", tc.expectedCode, w.Code)
+			}
+		})
+	}
+}
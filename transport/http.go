@@ -15,6 +15,9 @@ type HTTPTransporter interface {
 	Shutdown(ctx context.Context) error
 	AddHandler(httpMethod HttpMethod, path string, f func(w http.ResponseWriter, r *http.Request))
 	ServeStaticFiles(filePath, prefix, webDir string)
+	// Use appends middlewares to the router, it must be called before any
+	// handler is added.
+	Use(middlewares ...func(http.Handler) http.Handler)
 }
 
 type HttpServer struct {
@@ -72,6 +75,10 @@ func (s *HttpServer) AddHandler(httpMethod HttpMethod, path string, f func(w htt
 	}
 }
 
+func (s *HttpServer) Use(middlewares ...func(http.Handler) http.Handler) {
+	s.router.Use(middlewares...)
+}
+
 func (s *HttpServer) ServeStaticFiles(filePath, prefix, webDir string) {
 	s.router.Handle(filePath, http.StripPrefix(prefix, http.FileServer(http.Dir(webDir))))
 }
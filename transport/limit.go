@@ -0,0 +1,19 @@
+package transport
+
+import "net/http"
+
+// LimitBody returns a middleware that caps request bodies at n bytes. Requests
+// announcing a larger body are answered with 413 right away, the others fail
+// to read past the limit.
+func LimitBody(n int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > n {
+				http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, n)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -67,3 +67,216 @@ if (photoInput)
 // Scroll to Bottom
 const conversationThread = document.querySelector(".room__box");
 if (conversationThread) conversationThread.scrollTop = conversationThread.scrollHeight;
+
+// Live Room
+const closeIcon = `<svg version="1.1" xmlns="http://www.w3.org/2000/svg" width="32" height="32" viewBox="0 0 32 32">
+<title>remove</title>
+<path d="M27.314 6.019l-1.333-1.333-9.98 9.981-9.981-9.981-1.333 1.333 9.981 9.981-9.981 9.98 1.333 1.333 9.981-9.98 9.98 9.98 1.333-1.333-9.98-9.98 9.98-9.981z"></path>
+</svg>`;
+const roomThreads = document.querySelector(".threads[data-room-id]");
+if (roomThreads && roomThreads.dataset.live === "true") {
+  const roomID = roomThreads.dataset.roomId;
+  const currentUsername = roomThreads.dataset.username;
+  const isModerator = roomThreads.dataset.moderator === "true";
+  const messageForm = document.querySelector(".room__messageForm");
+  const scheme = window.location.protocol === "https:" ? "wss" : "ws";
+  const socket = new WebSocket(`${scheme}://${window.location.host}/room/${roomID}/ws`);
+
+  const buildThread = (message) => {
+    const thread = document.createElement("div");
+    thread.classList.add("thread");
+    thread.dataset.messageId = message.id;
+
+    const top = document.createElement("div");
+    top.classList.add("thread__top");
+    const author = document.createElement("div");
+    author.classList.add("thread__author");
+    const authorLink = document.createElement("a");
+    authorLink.href = `/profile/${message.user_id}`;
+    authorLink.classList.add("thread__authorInfo");
+    const avatar = document.createElement("div");
+    avatar.classList.add("avatar", "avatar--small");
+    const avatarImage = document.createElement("img");
+    avatarImage.src = message.avatar;
+    avatar.appendChild(avatarImage);
+    const username = document.createElement("span");
+    username.textContent = `@${message.username}`;
+    authorLink.append(avatar, username);
+    const date = document.createElement("span");
+    date.classList.add("thread__date");
+    date.textContent = message.since ? `${message.since} ago` : "just now";
+    author.append(authorLink, date);
+    top.appendChild(author);
+
+    if (isModerator || message.username === currentUsername) {
+      const deleteLink = document.createElement("a");
+      deleteLink.href = `/delete-message/${message.id}`;
+      deleteLink.innerHTML = `<div class="thread__delete">${closeIcon}</div>`;
+      top.appendChild(deleteLink);
+    }
+
+    const details = document.createElement("div");
+    details.classList.add("thread__details", "markdown");
+    // body_html was rendered from Markdown and sanitized by the server.
+    details.innerHTML = message.body_html;
+    thread.append(top, details);
+    if (message.attachments) thread.appendChild(buildAttachments(message.attachments));
+    if (message.parent_id) {
+      thread.classList.add("thread--reply");
+    } else {
+      thread.id = `message-${message.id}`;
+      thread.appendChild(buildReplies(message));
+    }
+    return thread;
+  };
+
+  // buildAttachments lists the files attached to a message, images by their
+  // thumbnail.
+  const buildAttachments = (attachments) => {
+    const list = document.createElement("ul");
+    list.classList.add("thread__attachments");
+    attachments.forEach((attachment) => {
+      const item = document.createElement("li");
+      item.classList.add("attachment");
+      const link = document.createElement("a");
+      link.href = attachment.url;
+      link.target = "_blank";
+      link.rel = "noopener";
+      if (attachment.thumbnail_url) {
+        const image = document.createElement("img");
+        image.classList.add("attachment__thumbnail");
+        image.src = attachment.thumbnail_url;
+        image.alt = attachment.name;
+        link.appendChild(image);
+      } else {
+        link.classList.add("attachment__file");
+        const name = document.createElement("span");
+        name.classList.add("attachment__name");
+        name.textContent = attachment.name;
+        const size = document.createElement("span");
+        size.classList.add("attachment__size");
+        size.textContent = attachment.size;
+        link.append(name, size);
+      }
+      item.appendChild(link);
+      list.appendChild(item);
+    });
+    return list;
+  };
+
+  // buildReplies folds the thread started by message, with a form to reply
+  // when the current user may post.
+  const buildReplies = (message) => {
+    const replies = document.createElement("details");
+    replies.classList.add("thread__replies");
+    replies.dataset.threadId = message.id;
+    const summary = document.createElement("summary");
+    summary.dataset.replyCount = 0;
+    summary.textContent = "Reply";
+    const list = document.createElement("div");
+    list.classList.add("thread__replyList");
+    replies.append(summary, list);
+    if (messageForm) {
+      const form = document.createElement("form");
+      form.classList.add("thread__replyForm");
+      form.method = "post";
+      form.appendChild(messageForm.querySelector("input[name=csrf_token]").cloneNode());
+      const parent = document.createElement("input");
+      parent.type = "hidden";
+      parent.name = "parent_id";
+      parent.value = message.id;
+      const input = document.createElement("input");
+      input.name = "body";
+      input.placeholder = `Reply to @${message.username}...`;
+      input.required = true;
+      form.append(parent, input);
+      replies.appendChild(form);
+    }
+    return replies;
+  };
+
+  // countReplies updates the summary of a thread after a reply came or went.
+  const countReplies = (replies, delta) => {
+    const summary = replies.querySelector("summary");
+    const count = Number(summary.dataset.replyCount) + delta;
+    summary.dataset.replyCount = count;
+    summary.textContent = count === 0 ? "Reply" : count === 1 ? "1 reply" : `${count} replies`;
+  };
+
+  socket.addEventListener("message", (event) => {
+    const roomEvent = JSON.parse(event.data);
+    switch (roomEvent.type) {
+      case "message.created": {
+        const message = roomEvent.message;
+        if (!message.parent_id) {
+          roomThreads.appendChild(buildThread(message));
+          if (conversationThread) conversationThread.scrollTop = conversationThread.scrollHeight;
+          break;
+        }
+        const replies = roomThreads.querySelector(`[data-thread-id="${message.parent_id}"]`);
+        if (!replies) break;
+        replies.querySelector(".thread__replyList").appendChild(buildThread(message));
+        countReplies(replies, 1);
+        break;
+      }
+      case "message.edited": {
+        const thread = roomThreads.querySelector(`[data-message-id="${roomEvent.message.id}"]`);
+        if (!thread) break;
+        thread.querySelector(":scope > .thread__details").innerHTML = roomEvent.message.body_html;
+        const author = thread.querySelector(":scope > .thread__top > .thread__author");
+        if (!author.querySelector(".thread__edited")) {
+          const edited = document.createElement("span");
+          edited.classList.add("thread__edited");
+          edited.textContent = "(edited)";
+          author.appendChild(edited);
+        }
+        break;
+      }
+      case "message.deleted": {
+        const thread = roomThreads.querySelector(`[data-message-id="${roomEvent.message.id}"]`);
+        if (!thread) break;
+        const replies = thread.closest(".thread__replies");
+        thread.remove();
+        if (replies) countReplies(replies, -1);
+        break;
+      }
+    }
+  });
+
+  // Messages and replies go over the socket while it is open, the forms
+  // post them the regular way otherwise and whenever files are attached.
+  document.querySelector(".room").addEventListener("submit", (event) => {
+    const form = event.target;
+    if (form !== messageForm && !form.classList.contains("thread__replyForm")) return;
+    if (socket.readyState !== WebSocket.OPEN) return;
+    const files = form.querySelector("input[type=file]");
+    if (files && files.files.length > 0) return;
+    event.preventDefault();
+    const input = form.querySelector("[name=body]");
+    const body = input.value.trim();
+    if (!body) return;
+    const parent = form.querySelector("input[name=parent_id]");
+    socket.send(JSON.stringify(parent ? { body, parent_id: Number(parent.value) } : { body }));
+    input.value = "";
+  });
+}
+
+// Enter sends a message and Shift+Enter starts a new line, so code blocks can
+// be written in the room.
+const messageBody = document.querySelector(".room__messageForm textarea[name=body]");
+if (messageBody)
+  messageBody.addEventListener("keydown", (event) => {
+    if (event.key !== "Enter" || event.shiftKey || event.isComposing) return;
+    event.preventDefault();
+    messageBody.form.requestSubmit();
+  });
+
+// Attach Files
+const attachInput = document.querySelector(".room__attach input[type=file]");
+if (attachInput)
+  attachInput.addEventListener("change", () => {
+    const label = attachInput.closest(".room__attach");
+    const count = attachInput.files.length;
+    label.classList.toggle("room__attach--selected", count > 0);
+    label.title = count > 0 ? `${count} file${count === 1 ? "" : "s"} attached` : "Attach files";
+  });